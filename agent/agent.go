@@ -0,0 +1,531 @@
+// Package agent periodically checks for new bundles and installs them.
+package agent
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/holoplot/go-rauc/internal/backoff"
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/holoplot/go-rauc/source"
+	"github.com/holoplot/go-rauc/staging"
+)
+
+const (
+	defaultInterval      = time.Hour
+	defaultRetryInterval = time.Minute
+	defaultJitter        = 0.1
+	defaultClass         = "rootfs"
+)
+
+// Options contains options for the AgentNew function
+type Options struct {
+	// Source is checked for updates. A bundle is considered an update if its
+	// version differs from the one in the booted slot.
+	Source source.BundleSource
+	// BundleURL is used to open a source with source.Open if Source is nil.
+	BundleURL string
+	// StagingDirectory receives bundles that have to be fetched from their
+	// source before installing. Defaults to the system's temp directory.
+	StagingDirectory string
+	// Staging, if not nil, is used instead of StagingDirectory. Fetched
+	// bundles are kept there until they are installed, so that failed
+	// installations are retried without downloading the bundle again.
+	Staging *staging.Area
+	// Class is the slot class whose bundle version is compared.
+	// Defaults to "rootfs".
+	Class string
+	// Interval between two checks. Defaults to one hour.
+	Interval time.Duration
+	// RetryInterval is the delay after a failed cycle. It doubles with each
+	// further failure, up to Interval. Defaults to one minute.
+	RetryInterval time.Duration
+	// Jitter randomizes all delays by up to this fraction, so devices do
+	// not check in lockstep. Defaults to 0.1, negative values disable it.
+	Jitter         float64
+	InstallOptions rauc.InstallBundleOptions
+	// FacadeMinInterval is the minimum time between two CheckForUpdate or
+	// InstallLatest calls from the same user on the D-Bus facade, or the
+	// same client of the HTTP API.
+	FacadeMinInterval time.Duration
+	// Authorizer, if not nil, decides which callers may use the D-Bus
+	// facade. It also applies to the HTTP API, after the caller has
+	// authenticated, see HTTPOptions.
+	Authorizer Authorizer
+	// StatisticsSize is the number of installation attempts kept for
+	// Statistics. Defaults to 16.
+	StatisticsSize int
+	// Reboot restarts the system with RebootOptions after a successful
+	// installation.
+	Reboot        bool
+	RebootOptions rauc.RebootOptions
+	// DeviceID places the device in staged rollouts announced by the
+	// source. Defaults to the content of MachineIDFile.
+	DeviceID string
+	// IgnoreRollout installs updates regardless of their rollout.
+	IgnoreRollout bool
+	// TrackActivation records each installation with
+	// rauc.RecordPendingActivation, for health checks after rebooting.
+	TrackActivation bool
+	// OnSuccess and OnFailure are run in order after an installation
+	// succeeded or failed, see Command.
+	OnSuccess []Command
+	OnFailure []Command
+	// ReportDirectory, if not empty, receives a Report of each
+	// installation attempt as JSON, and as HTML if ReportHTML is set.
+	ReportDirectory string
+	ReportHTML      bool
+}
+
+// UpdateAvailableEvent is published when a check found a bundle to install.
+type UpdateAvailableEvent struct {
+	Bundle  string
+	Version string
+}
+
+// EventType implements rauc.Event.
+func (e UpdateAvailableEvent) EventType() string {
+	return "agent.update-available"
+}
+
+// CheckFailedEvent is published when checking for an update failed.
+type CheckFailedEvent struct {
+	Err error
+}
+
+// EventType implements rauc.Event.
+func (e CheckFailedEvent) EventType() string {
+	return "agent.check-failed"
+}
+
+// ErrInProgress is returned when an installation is requested while the
+// agent is already checking for or installing an update.
+var ErrInProgress = errors.New("agent: installation already in progress")
+
+// Status describes what the agent is doing and the outcome of its last
+// check.
+type Status struct {
+	LastCheck        time.Time
+	LastError        error
+	AvailableVersion string
+	// InstalledVersion is the last version installed by this agent. It is
+	// not installed again, even though it is not booted yet.
+	InstalledVersion string
+	// Deferred is set if AvailableVersion is not installed yet because
+	// the device is not part of its rollout.
+	Deferred   bool
+	Installing bool
+}
+
+// Agent checks for updates periodically, or whenever triggered, and
+// installs them.
+type Agent struct {
+	installer rauc.Backend
+	options   Options
+	trigger   chan struct{}
+
+	mutex  sync.Mutex
+	status Status
+	stats  []InstallStats
+	// running is set while an install cycle runs, see begin.
+	running bool
+	// lastCall holds the time of the last request per client, see admit.
+	lastCall map[string]time.Time
+}
+
+// AgentNew returns a newly allocated Agent object
+func AgentNew(installer rauc.Backend, options Options) (*Agent, error) {
+	if options.Class == "" {
+		options.Class = defaultClass
+	}
+
+	if options.Interval == 0 {
+		options.Interval = defaultInterval
+	}
+
+	if options.RetryInterval == 0 {
+		options.RetryInterval = defaultRetryInterval
+	}
+
+	if options.StatisticsSize == 0 {
+		options.StatisticsSize = defaultStatisticsSize
+	}
+
+	if options.Jitter == 0 {
+		options.Jitter = defaultJitter
+	}
+
+	if options.Source == nil {
+		var err error
+		if options.Source, err = source.Open(options.BundleURL); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Agent{
+		installer: installer,
+		options:   options,
+		trigger:   make(chan struct{}, 1),
+		lastCall:  make(map[string]time.Time),
+	}, nil
+}
+
+// locate returns a location the daemon can read the bundle from, fetching
+// it into the staging directory if needed. The returned function releases
+// fetched files again; keep asks to leave them in the staging area for a
+// later attempt. If stats is not nil, the time spent fetching is recorded
+// in it.
+func (a *Agent) locate(ctx context.Context, b *source.Bundle, stats *InstallStats) (string, func(keep bool), error) {
+	location, err := a.options.Source.Resolve(ctx, b)
+	if err == nil {
+		return location, func(bool) {}, nil
+	}
+
+	if err != source.ErrNotResolvable {
+		return "", nil, err
+	}
+
+	fetch := func(dest string) error {
+		start := time.Now()
+		err := a.options.Source.Fetch(ctx, b, dest)
+		if stats != nil {
+			stats.DownloadTime = time.Since(start)
+		}
+
+		return err
+	}
+
+	if area := a.options.Staging; area != nil {
+		e, ok := area.Lookup(b.Location, b.Version)
+		if !ok {
+			if e, err = area.Store(b.Location, b.Version, fetch); err != nil {
+				return "", nil, err
+			}
+		}
+
+		release := func(keep bool) {
+			if !keep {
+				area.Remove(e.Path)
+			}
+		}
+
+		return e.Path, release, nil
+	}
+
+	f, err := ioutil.TempFile(a.options.StagingDirectory, "bundle-*.raucb")
+	if err != nil {
+		return "", nil, err
+	}
+	f.Close()
+
+	cleanup := func(bool) {
+		os.Remove(f.Name())
+	}
+
+	if err := fetch(f.Name()); err != nil {
+		cleanup(false)
+		return "", nil, err
+	}
+
+	return f.Name(), cleanup, nil
+}
+
+// bootedVersion returns the bundle version of the booted slot of the
+// configured class.
+func (a *Agent) bootedVersion() (string, error) {
+	s, err := rauc.GetBootedSlot(a.installer, a.options.Class)
+	if err != nil {
+		return "", err
+	}
+
+	return s.BundleVersion, nil
+}
+
+// check returns the latest bundle of the source and whether it is an
+// update. Bundles of unknown version are inspected by the daemon. With
+// force, the bundle is always considered an update.
+func (a *Agent) check(ctx context.Context, force bool) (*source.Bundle, bool, error) {
+	b, err := a.options.Source.Latest(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if force {
+		a.mutex.Lock()
+		a.status.AvailableVersion = b.Version
+		a.status.Deferred = false
+		a.mutex.Unlock()
+
+		return b, true, nil
+	}
+
+	if b.Version == "" {
+		location, cleanup, err := a.locate(ctx, b, nil)
+		if err != nil {
+			return nil, false, err
+		}
+
+		_, b.Version, err = a.installer.Info(location)
+		if area := a.options.Staging; err == nil && area != nil && filepath.Dir(location) == area.Directory() {
+			err = area.SetVersion(location, b.Version)
+		}
+		cleanup(err == nil)
+
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	booted, err := a.bootedVersion()
+	if err != nil {
+		return nil, false, err
+	}
+
+	a.mutex.Lock()
+	available := b.Version != booted && b.Version != a.status.InstalledVersion
+	a.mutex.Unlock()
+
+	admitted := true
+	if available {
+		if admitted, err = a.admitted(b); err != nil {
+			return nil, false, err
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.status.AvailableVersion = ""
+	a.status.Deferred = available && !admitted
+	if available {
+		a.status.AvailableVersion = b.Version
+	}
+
+	return b, available && admitted, nil
+}
+
+// CheckForUpdate reports whether the source offers an update, and the
+// version of its latest bundle.
+func (a *Agent) CheckForUpdate(ctx context.Context) (bool, string, error) {
+	b, available, err := a.check(ctx, false)
+	if err != nil {
+		return false, "", err
+	}
+
+	return available, b.Version, nil
+}
+
+// Status returns the current status of the agent.
+func (a *Agent) Status() Status {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.status
+}
+
+func (a *Agent) setResult(err error) {
+	a.mutex.Lock()
+	a.status.LastCheck = time.Now()
+	a.status.LastError = err
+	a.mutex.Unlock()
+}
+
+// InstallLatest installs the latest bundle of the source if it is an update.
+// It fails with ErrInProgress while another cycle runs.
+func (a *Agent) InstallLatest(ctx context.Context) error {
+	return a.installLatest(ctx, false)
+}
+
+// Reinstall installs the latest bundle of the source even if its version
+// is booted or was installed before, and regardless of its rollout. It is
+// meant to rewrite a slot that is suspected to be corrupted.
+func (a *Agent) Reinstall(ctx context.Context) error {
+	return a.installLatest(ctx, true)
+}
+
+// begin marks an install cycle as running, failing with ErrInProgress if
+// one is running already.
+func (a *Agent) begin() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.running {
+		return ErrInProgress
+	}
+	a.running = true
+
+	return nil
+}
+
+func (a *Agent) end() {
+	a.mutex.Lock()
+	a.running = false
+	a.mutex.Unlock()
+}
+
+func (a *Agent) installLatest(ctx context.Context, force bool) error {
+	if err := a.begin(); err != nil {
+		return err
+	}
+	defer a.end()
+
+	return a.cycle(ctx, force)
+}
+
+// startInstall is installLatest, returning once the cycle is started. The
+// cycle outlives ctx.
+func (a *Agent) startInstall(force bool) error {
+	if err := a.begin(); err != nil {
+		return err
+	}
+
+	go func() {
+		defer a.end()
+		a.cycle(context.Background(), force)
+	}()
+
+	return nil
+}
+
+// cycle checks for an update and installs it.
+func (a *Agent) cycle(ctx context.Context, force bool) error {
+	b, available, err := a.check(ctx, force)
+	if err != nil {
+		a.setResult(err)
+		a.installer.Events().Publish(CheckFailedEvent{Err: err})
+		return err
+	}
+
+	if !available {
+		a.setResult(nil)
+		return nil
+	}
+
+	a.installer.Events().Publish(UpdateAvailableEvent{
+		Bundle:  b.Location,
+		Version: b.Version,
+	})
+
+	a.mutex.Lock()
+	a.status.Installing = true
+	a.mutex.Unlock()
+
+	stats := InstallStats{
+		Bundle:  b.Location,
+		Version: b.Version,
+		Started: time.Now(),
+	}
+
+	var slotsBefore []rauc.SlotStatus
+	if a.options.ReportDirectory != "" {
+		slotsBefore, _ = a.installer.GetSlotStatus()
+	}
+
+	location, cleanup, err := a.locate(ctx, b, &stats)
+	if err == nil {
+		err = a.install(ctx, location, &stats)
+		cleanup(err != nil)
+	}
+
+	stats.Err = err
+	a.addStats(stats)
+
+	if a.options.ReportDirectory != "" {
+		r := a.newReport(b, stats)
+		r.SlotsBefore = slotsBefore
+		a.writeReport(r)
+	}
+
+	a.mutex.Lock()
+	a.status.Installing = false
+	if err == nil {
+		a.status.InstalledVersion = b.Version
+		a.status.AvailableVersion = ""
+	}
+	a.mutex.Unlock()
+
+	if err == nil && a.options.TrackActivation {
+		err = rauc.RecordPendingActivation(a.installedSlot(b.Version), b.Version)
+	}
+
+	a.runCommands(ctx, b.Location, b.Version, err)
+
+	if err == nil && a.options.Reboot {
+		err = rauc.RunHooks(ctx, rauc.HookBeforeActivate, a.options.InstallOptions.Hooks.BeforeActivate, rauc.HookInfo{Bundle: b.Location})
+		if err == nil {
+			err = rauc.Reboot(ctx, a.options.RebootOptions)
+		}
+	}
+
+	a.setResult(err)
+
+	return err
+}
+
+// Trigger makes a running agent check for updates immediately instead of
+// waiting for the next interval.
+func (a *Agent) Trigger() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// TriggerOnSignal calls Trigger whenever one of the given signals (e.g.
+// syscall.SIGUSR1) is received, until the context is cancelled.
+func (a *Agent) TriggerOnSignal(ctx context.Context, signals ...os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+
+	go func() {
+		defer signal.Stop(c)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c:
+				a.Trigger()
+			}
+		}
+	}()
+}
+
+// Run checks for and installs updates once per interval, sooner after a
+// failed cycle, and whenever triggered, until the context is cancelled. Errors of single cycles are
+// published as events, not returned.
+func (a *Agent) Run(ctx context.Context) error {
+	retry := backoff.Backoff{
+		Initial: a.options.RetryInterval,
+		Max:     a.options.Interval,
+		Jitter:  a.options.Jitter,
+	}
+
+	for {
+		delay := backoff.Jitter(a.options.Interval, a.options.Jitter)
+		if err := a.InstallLatest(ctx); errors.Is(err, ErrInProgress) {
+			// A cycle started through the facade is running.
+		} else if err != nil {
+			delay = retry.Next()
+		} else {
+			retry.Reset()
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		case <-a.trigger:
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/holoplot/go-rauc/raucmock"
+	"github.com/holoplot/go-rauc/source"
+)
+
+// testSource offers a single bundle the daemon can read directly.
+type testSource struct {
+	bundle source.Bundle
+	err    error
+}
+
+func (s *testSource) Latest(ctx context.Context) (*source.Bundle, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	b := s.bundle
+	return &b, nil
+}
+
+func (s *testSource) Resolve(ctx context.Context, b *source.Bundle) (string, error) {
+	return b.Location, nil
+}
+
+func (s *testSource) Fetch(ctx context.Context, b *source.Bundle, dest string) error {
+	return errors.New("testSource: cannot fetch")
+}
+
+func TestInstallCycle(t *testing.T) {
+	const location = "/bundles/update.raucb"
+	unreachable := errors.New("source unreachable")
+
+	tests := []struct {
+		name     string
+		version  string
+		bundles  map[string]rauc.BundleInfo
+		err      error
+		installs []raucmock.Install
+		force    bool
+		running  bool
+		// wantErr is the cause of the expected error, if any.
+		wantErr          error
+		installed        int
+		installedVersion string
+	}{
+		{name: "update", version: "2.0.0", installed: 1, installedVersion: "2.0.0"},
+		{name: "booted version", version: "1.0.0"},
+		{name: "reinstall booted version", version: "1.0.0", force: true, installed: 1, installedVersion: "1.0.0"},
+		{name: "version from daemon", bundles: map[string]rauc.BundleInfo{location: {Compatible: "board", Version: "2.0.0"}}, installed: 1, installedVersion: "2.0.0"},
+		{name: "source failure", version: "2.0.0", err: unreachable, wantErr: unreachable},
+		{name: "refused", version: "2.0.0", installs: []raucmock.Install{{Err: rauc.ErrBusy}}, wantErr: rauc.ErrBusy, installed: 1},
+		{name: "failed", version: "2.0.0", installs: []raucmock.Install{{LastError: "Installation error: No space left on device"}}, wantErr: rauc.ErrNoSpace, installed: 1},
+		{name: "in progress", version: "2.0.0", running: true, wantErr: ErrInProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := raucmock.MockNew(raucmock.Options{
+				Bundles:  tt.bundles,
+				Installs: tt.installs,
+			})
+
+			a, err := AgentNew(mock, Options{
+				Source: &testSource{bundle: source.Bundle{Location: location, Version: tt.version}, err: tt.err},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.running = tt.running
+
+			if tt.force {
+				err = a.Reinstall(context.Background())
+			} else {
+				err = a.InstallLatest(context.Background())
+			}
+
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			calls := mock.CallsTo("InstallBundle")
+			if len(calls) != tt.installed {
+				t.Fatalf("got %d InstallBundle calls, want %d", len(calls), tt.installed)
+			}
+			if len(calls) > 0 && calls[0].Args[0] != location {
+				t.Errorf("installed %v, want %s", calls[0].Args[0], location)
+			}
+
+			status := a.Status()
+			if status.InstalledVersion != tt.installedVersion {
+				t.Errorf("got installed version %q, want %q", status.InstalledVersion, tt.installedVersion)
+			}
+			if status.Installing {
+				t.Error("still installing")
+			}
+			if tt.wantErr != nil && tt.wantErr != ErrInProgress && status.LastError != err {
+				t.Errorf("got last error %v, want %v", status.LastError, err)
+			}
+		})
+	}
+}
+
+func TestInstallLatestExclusive(t *testing.T) {
+	a, err := AgentNew(raucmock.MockNew(raucmock.Options{}), Options{
+		Source: &testSource{bundle: source.Bundle{Location: "/bundles/update.raucb", Version: "2.0.0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.startInstall(false); err != ErrInProgress {
+		t.Fatalf("startInstall() = %v, want ErrInProgress", err)
+	}
+
+	if err := a.startInstall(true); err != ErrInProgress {
+		t.Fatalf("startInstall(force) = %v, want ErrInProgress", err)
+	}
+
+	a.end()
+
+	if err := a.InstallLatest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrUnauthorized is returned by Authorizers that refuse a caller.
+var ErrUnauthorized = errors.New("agent: unauthorized")
+
+// errRateLimited is the cause of requests refused by FacadeMinInterval.
+var errRateLimited = errors.New("rate limit exceeded")
+
+// Caller describes a request to the D-Bus facade or the HTTP API.
+type Caller struct {
+	// Method is the requested operation: CheckForUpdate, InstallLatest,
+	// Reinstall or Status.
+	Method string
+	// Sender, UID and PID identify callers on the D-Bus facade.
+	Sender string
+	UID    uint32
+	PID    uint32
+	// RemoteAddr, Token and Certificates identify callers of the HTTP API.
+	// Token is the bearer token of the request, Certificates the verified
+	// client certificate chain, leaf first.
+	RemoteAddr   string
+	Token        string
+	Certificates []*x509.Certificate
+}
+
+// subject returns the common name of the caller's client certificate.
+func (c Caller) subject() string {
+	if len(c.Certificates) == 0 {
+		return ""
+	}
+
+	return c.Certificates[0].Subject.CommonName
+}
+
+// key identifies the caller for the rate limit. HTTP clients without a
+// certificate or token are identified by their host, not the port of the
+// connection.
+func (c Caller) key() string {
+	switch {
+	case c.Sender != "":
+		return fmt.Sprintf("uid:%d", c.UID)
+	case c.subject() != "":
+		return "cn:" + c.subject()
+	case c.Token != "":
+		return fmt.Sprintf("token:%x", sha256.Sum256([]byte(c.Token)))
+	}
+
+	host, _, err := net.SplitHostPort(c.RemoteAddr)
+	if err != nil {
+		host = c.RemoteAddr
+	}
+
+	return "host:" + host
+}
+
+// Authorizer decides whether a caller may use a method of the D-Bus facade
+// or the HTTP API. It returns nil to allow the request.
+type Authorizer interface {
+	Authorize(ctx context.Context, c Caller) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface.
+type AuthorizerFunc func(ctx context.Context, c Caller) error
+
+// Authorize implements Authorizer.
+func (f AuthorizerFunc) Authorize(ctx context.Context, c Caller) error {
+	return f(ctx, c)
+}
+
+// TokenAuthorizer allows callers presenting one of the bearer tokens.
+func TokenAuthorizer(tokens ...string) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, c Caller) error {
+		if c.Token == "" {
+			return ErrUnauthorized
+		}
+
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(c.Token), []byte(t)) == 1 {
+				return nil
+			}
+		}
+
+		return ErrUnauthorized
+	})
+}
+
+// CertificateAuthorizer allows callers whose verified client certificate
+// has one of the common names.
+func CertificateAuthorizer(commonNames ...string) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, c Caller) error {
+		if subject := c.subject(); subject != "" {
+			for _, name := range commonNames {
+				if subject == name {
+					return nil
+				}
+			}
+		}
+
+		return ErrUnauthorized
+	})
+}
+
+// UIDAuthorizer allows callers on the D-Bus facade running as one of the
+// users.
+func UIDAuthorizer(uids ...uint32) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, c Caller) error {
+		if c.Sender != "" {
+			for _, uid := range uids {
+				if c.UID == uid {
+					return nil
+				}
+			}
+		}
+
+		return ErrUnauthorized
+	})
+}
+
+// AnyAuthorizer allows callers allowed by one of the authorizers.
+func AnyAuthorizer(authorizers ...Authorizer) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, c Caller) error {
+		err := ErrUnauthorized
+		for _, a := range authorizers {
+			if err = a.Authorize(ctx, c); err == nil {
+				return nil
+			}
+		}
+
+		return err
+	})
+}
+
+// admit records a request in the audit trail, checks it with auth, if not
+// nil, and enforces the per-client rate limit.
+func (a *Agent) admit(ctx context.Context, c Caller, auth Authorizer) error {
+	e := FacadeRequestEvent{
+		Time:       time.Now(),
+		Method:     c.Method,
+		Sender:     c.Sender,
+		UID:        c.UID,
+		PID:        c.PID,
+		RemoteAddr: c.RemoteAddr,
+		Subject:    c.subject(),
+	}
+
+	if auth != nil {
+		e.Err = auth.Authorize(ctx, c)
+	}
+
+	// Status is read-only and polled, it is not rate limited.
+	if interval := a.options.FacadeMinInterval; interval > 0 && e.Err == nil && c.Method != "Status" {
+		key := c.key()
+
+		a.mutex.Lock()
+		if last, ok := a.lastCall[key]; ok && e.Time.Sub(last) < interval {
+			e.Err = fmt.Errorf("%w, retry in %v", errRateLimited, (interval - e.Time.Sub(last)).Round(time.Second))
+		} else {
+			for k, last := range a.lastCall {
+				if e.Time.Sub(last) >= interval {
+					delete(a.lastCall, k)
+				}
+			}
+			a.lastCall[key] = e.Time
+		}
+		a.mutex.Unlock()
+	}
+
+	a.installer.Events().Publish(e)
+
+	return e.Err
+}
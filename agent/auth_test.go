@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/holoplot/go-rauc/raucmock"
+)
+
+func testCertificate(commonName string) []*x509.Certificate {
+	return []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}}
+}
+
+func TestAuthorizers(t *testing.T) {
+	refuse := AuthorizerFunc(func(ctx context.Context, c Caller) error {
+		return errors.New("refused")
+	})
+
+	tests := []struct {
+		name   string
+		auth   Authorizer
+		caller Caller
+		ok     bool
+	}{
+		{name: "token", auth: TokenAuthorizer("a", "b"), caller: Caller{Token: "b"}, ok: true},
+		{name: "wrong token", auth: TokenAuthorizer("a"), caller: Caller{Token: "b"}},
+		{name: "token prefix", auth: TokenAuthorizer("secret"), caller: Caller{Token: "sec"}},
+		{name: "empty token", auth: TokenAuthorizer(""), caller: Caller{}},
+		{name: "common name", auth: CertificateAuthorizer("ops"), caller: Caller{Certificates: testCertificate("ops")}, ok: true},
+		{name: "wrong common name", auth: CertificateAuthorizer("ops"), caller: Caller{Certificates: testCertificate("dev")}},
+		{name: "no certificate", auth: CertificateAuthorizer(""), caller: Caller{}},
+		{name: "uid", auth: UIDAuthorizer(0, 1000), caller: Caller{Sender: ":1.1", UID: 1000}, ok: true},
+		{name: "wrong uid", auth: UIDAuthorizer(0), caller: Caller{Sender: ":1.1", UID: 1000}},
+		{name: "uid over HTTP", auth: UIDAuthorizer(0), caller: Caller{RemoteAddr: "10.0.0.1:1234"}},
+		{name: "any", auth: AnyAuthorizer(refuse, TokenAuthorizer("a")), caller: Caller{Token: "a"}, ok: true},
+		{name: "any refused", auth: AnyAuthorizer(refuse, TokenAuthorizer("a")), caller: Caller{Token: "b"}},
+		{name: "any empty", auth: AnyAuthorizer(), caller: Caller{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.Authorize(context.Background(), tt.caller)
+			if ok := err == nil; ok != tt.ok {
+				t.Fatalf("got error %v, want success %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCallerKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Caller
+		same bool
+	}{
+		{name: "same user", a: Caller{Sender: ":1.1", UID: 1000}, b: Caller{Sender: ":1.2", UID: 1000}, same: true},
+		{name: "other user", a: Caller{Sender: ":1.1", UID: 1000}, b: Caller{Sender: ":1.1", UID: 1001}},
+		{name: "same host", a: Caller{RemoteAddr: "192.0.2.1:40000"}, b: Caller{RemoteAddr: "192.0.2.1:40001"}, same: true},
+		{name: "other host", a: Caller{RemoteAddr: "192.0.2.1:40000"}, b: Caller{RemoteAddr: "192.0.2.2:40000"}},
+		{name: "same token", a: Caller{RemoteAddr: "192.0.2.1:40000", Token: "a"}, b: Caller{RemoteAddr: "192.0.2.2:40001", Token: "a"}, same: true},
+		{name: "other token", a: Caller{RemoteAddr: "192.0.2.1:40000", Token: "a"}, b: Caller{RemoteAddr: "192.0.2.1:40000", Token: "b"}},
+		{name: "same certificate", a: Caller{RemoteAddr: "192.0.2.1:40000", Certificates: testCertificate("ops")}, b: Caller{RemoteAddr: "192.0.2.2:40001", Certificates: testCertificate("ops")}, same: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := tt.a.key() == tt.b.key(); same != tt.same {
+				t.Fatalf("keys %q and %q, want same %v", tt.a.key(), tt.b.key(), tt.same)
+			}
+		})
+	}
+}
+
+func TestAdmitPrunes(t *testing.T) {
+	a, err := AgentNew(raucmock.MockNew(raucmock.Options{}), Options{
+		Source:            &testSource{},
+		FacadeMinInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.lastCall["host:192.0.2.1"] = time.Now().Add(-time.Hour)
+
+	if err := a.admit(context.Background(), Caller{Method: "CheckForUpdate", RemoteAddr: "192.0.2.2:40000"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := a.lastCall["host:192.0.2.1"]; ok || len(a.lastCall) != 1 {
+		t.Fatalf("got %v, want only host:192.0.2.2", a.lastCall)
+	}
+}
+
+func TestAdmitRateLimit(t *testing.T) {
+	a, err := AgentNew(raucmock.MockNew(raucmock.Options{}), Options{
+		Source:            &testSource{},
+		FacadeMinInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, cancel := a.installer.Events().Subscribe(16)
+	defer cancel()
+
+	alice := Caller{Method: "InstallLatest", Sender: ":1.1", UID: 1000}
+	bob := Caller{Method: "InstallLatest", Sender: ":1.2", UID: 1001}
+	status := Caller{Method: "Status", Sender: ":1.1", UID: 1000}
+
+	tests := []struct {
+		caller  Caller
+		limited bool
+	}{
+		{caller: alice},
+		{caller: bob},
+		{caller: alice, limited: true},
+		{caller: status},
+		{caller: status},
+	}
+
+	for i, tt := range tests {
+		err := a.admit(context.Background(), tt.caller, nil)
+		if limited := errors.Is(err, errRateLimited); limited != tt.limited {
+			t.Fatalf("call %d: got error %v, want rate limited %v", i, err, tt.limited)
+		}
+
+		e, ok := (<-events).(FacadeRequestEvent)
+		if !ok || e.Method != tt.caller.Method || e.UID != tt.caller.UID || (e.Err != nil) != tt.limited {
+			t.Fatalf("call %d: unexpected event %+v", i, e)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const defaultCommandTimeout = time.Minute
+
+// Command is an external program run when an installation ended. It gets
+// the outcome in its environment:
+//
+//	RAUC_RESULT   "success" or "failure"
+//	RAUC_BUNDLE   location of the bundle
+//	RAUC_VERSION  version of the bundle
+//	RAUC_SLOT     slot the bundle was installed to, if known
+//	RAUC_ERROR    error message, for failures
+type Command struct {
+	Command []string
+	// Timeout defaults to one minute. The command is killed when it
+	// expires.
+	Timeout time.Duration
+}
+
+// CommandFailedEvent is published when a completion command fails. The
+// result of the installation is not affected.
+type CommandFailedEvent struct {
+	Command []string
+	Err     error
+}
+
+// EventType implements rauc.Event.
+func (e CommandFailedEvent) EventType() string {
+	return "agent.command-failed"
+}
+
+// installedSlot returns the name of the slot of the configured class
+// that version was installed to last, or an empty string.
+func (a *Agent) installedSlot(version string) string {
+	slots, err := rauc.GetSlots(a.installer)
+	if err != nil {
+		return ""
+	}
+
+	var slot string
+	var latest time.Time
+
+	for _, s := range slots {
+		if s.Class != a.options.Class || s.BundleVersion != version {
+			continue
+		}
+
+		if slot == "" || s.InstalledTimestamp.After(latest) {
+			slot, latest = s.Name, s.InstalledTimestamp
+		}
+	}
+
+	return slot
+}
+
+// runCommands runs the completion commands for the result of an
+// installation of bundle.
+func (a *Agent) runCommands(ctx context.Context, bundle, version string, result error) {
+	commands := a.options.OnSuccess
+	env := []string{"RAUC_RESULT=success"}
+	if result != nil {
+		commands = a.options.OnFailure
+		env = []string{"RAUC_RESULT=failure", "RAUC_ERROR=" + result.Error()}
+	}
+
+	if len(commands) == 0 {
+		return
+	}
+
+	env = append(env,
+		"RAUC_BUNDLE="+bundle,
+		"RAUC_VERSION="+version,
+		"RAUC_SLOT="+a.installedSlot(version))
+
+	for _, c := range commands {
+		if err := c.run(ctx, env); err != nil {
+			a.installer.Events().Publish(CommandFailedEvent{Command: c.Command, Err: err})
+		}
+	}
+}
+
+func (c Command) run(ctx context.Context, env []string) error {
+	if len(c.Command) == 0 {
+		return errors.New("agent: empty command")
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultCommandTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+
+	out, err := cmd.CombinedOutput()
+	if msg := strings.TrimSpace(string(out)); err != nil && msg != "" {
+		return fmt.Errorf("agent: %s: %v: %s", c.Command[0], err, msg)
+	} else if err != nil {
+		return fmt.Errorf("agent: %s: %v", c.Command[0], err)
+	}
+
+	return nil
+}
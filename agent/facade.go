@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+const (
+	// FacadeBusName is the bus name requested by ExportFacade.
+	FacadeBusName = "com.holoplot.rauc.Agent"
+	// FacadeObjectPath is the path the facade object is exported at.
+	FacadeObjectPath = dbus.ObjectPath("/com/holoplot/rauc/Agent")
+	// FacadeInterface is the interface implemented by the facade object.
+	FacadeInterface = "com.holoplot.rauc.Agent"
+)
+
+const facadeIntrospection = `
+<interface name="` + FacadeInterface + `">
+	<method name="CheckForUpdate">
+		<arg direction="out" type="b" name="available"/>
+		<arg direction="out" type="s" name="version"/>
+	</method>
+	<method name="InstallLatest"/>
+	<method name="Reinstall"/>
+	<method name="Status">
+		<arg direction="out" type="a{sv}" name="status"/>
+	</method>
+</interface>`
+
+// FacadeRequestEvent is published for every call to a method of the
+// facade and every request to the HTTP API, as an audit trail.
+type FacadeRequestEvent struct {
+	Time   time.Time
+	Method string
+	// Sender is the unique bus name of the caller, UID and PID identify
+	// the calling process.
+	Sender string
+	UID    uint32
+	PID    uint32
+	// RemoteAddr is the address of HTTP callers, Subject the common name
+	// of their client certificate.
+	RemoteAddr string
+	Subject    string
+	// Err is set if the request was refused.
+	Err error
+}
+
+// EventType implements rauc.Event.
+func (e FacadeRequestEvent) EventType() string {
+	return "agent.facade-request"
+}
+
+// facade implements the D-Bus methods of the agent's facade interface.
+type facade struct {
+	agent *Agent
+	conn  *dbus.Conn
+}
+
+// admit records a request in the audit trail, checks it with the agent's
+// Authorizer and enforces the per-client rate limit, keyed by the caller's
+// UID. Callers whose credentials cannot be looked up are refused.
+func (f *facade) admit(method string, sender dbus.Sender) *dbus.Error {
+	c := Caller{
+		Method: method,
+		Sender: string(sender),
+	}
+
+	bus := f.conn.BusObject()
+	if err := bus.Call("org.freedesktop.DBus.GetConnectionUnixUser", 0, string(sender)).Store(&c.UID); err != nil {
+		return dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", []interface{}{fmt.Sprintf("cannot look up caller: %v", err)})
+	}
+
+	if err := bus.Call("org.freedesktop.DBus.GetConnectionUnixProcessID", 0, string(sender)).Store(&c.PID); err != nil {
+		return dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", []interface{}{fmt.Sprintf("cannot look up caller: %v", err)})
+	}
+
+	err := f.agent.admit(context.Background(), c, f.agent.options.Authorizer)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errRateLimited):
+		return dbus.NewError("org.freedesktop.DBus.Error.LimitsExceeded", []interface{}{err.Error()})
+	}
+
+	return dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", []interface{}{err.Error()})
+}
+
+func (f *facade) CheckForUpdate(sender dbus.Sender) (bool, string, *dbus.Error) {
+	if err := f.admit("CheckForUpdate", sender); err != nil {
+		return false, "", err
+	}
+
+	available, version, err := f.agent.CheckForUpdate(context.Background())
+	if err != nil {
+		return false, "", dbus.MakeFailedError(err)
+	}
+
+	return available, version, nil
+}
+
+// InstallLatest starts the installation in the background and returns
+// immediately, as installations outlast the D-Bus method call timeout.
+// Callers follow the progress via Status.
+func (f *facade) InstallLatest(sender dbus.Sender) *dbus.Error {
+	if err := f.admit("InstallLatest", sender); err != nil {
+		return err
+	}
+
+	if err := f.agent.startInstall(false); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	return nil
+}
+
+// Reinstall is like InstallLatest, but installs the latest bundle even if
+// it is not an update.
+func (f *facade) Reinstall(sender dbus.Sender) *dbus.Error {
+	if err := f.admit("Reinstall", sender); err != nil {
+		return err
+	}
+
+	if err := f.agent.startInstall(true); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	return nil
+}
+
+func (f *facade) Status(sender dbus.Sender) (map[string]dbus.Variant, *dbus.Error) {
+	if err := f.admit("Status", sender); err != nil {
+		return nil, err
+	}
+
+	status := f.agent.Status()
+
+	lastError := ""
+	if status.LastError != nil {
+		lastError = status.LastError.Error()
+	}
+
+	lastCheck := int64(0)
+	if !status.LastCheck.IsZero() {
+		lastCheck = status.LastCheck.Unix()
+	}
+
+	return map[string]dbus.Variant{
+		"last-check":        dbus.MakeVariant(lastCheck),
+		"last-error":        dbus.MakeVariant(lastError),
+		"available-version": dbus.MakeVariant(status.AvailableVersion),
+		"installed-version": dbus.MakeVariant(status.InstalledVersion),
+		"deferred":          dbus.MakeVariant(status.Deferred),
+		"installing":        dbus.MakeVariant(status.Installing),
+	}, nil
+}
+
+// ExportFacade exports a small D-Bus API (CheckForUpdate, InstallLatest,
+// Reinstall, Status) for the agent on conn and requests FacadeBusName, so
+// that other applications on the device can drive updates without knowing
+// RAUC's interface.
+func (a *Agent) ExportFacade(conn *dbus.Conn) error {
+	f := &facade{
+		agent: a,
+		conn:  conn,
+	}
+
+	if err := conn.Export(f, FacadeObjectPath, FacadeInterface); err != nil {
+		return fmt.Errorf("agent: Export(): %v", err)
+	}
+
+	data := "<node>" + facadeIntrospection + introspect.IntrospectDataString + "</node>"
+
+	if err := conn.Export(introspect.Introspectable(data), FacadeObjectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("agent: Export(): %v", err)
+	}
+
+	reply, err := conn.RequestName(FacadeBusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("agent: RequestName(): %v", err)
+	}
+
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("agent: bus name %s already taken", FacadeBusName)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/raucmock"
+	"github.com/holoplot/go-rauc/rauctest"
+)
+
+func TestFacadeStatus(t *testing.T) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	d, err := rauctest.DaemonNew(rauctest.DaemonOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	uid := uint32(os.Getuid())
+
+	tests := []struct {
+		name       string
+		authorizer Authorizer
+		denied     bool
+	}{
+		{name: "allowed", authorizer: UIDAuthorizer(uid)},
+		{name: "refused", authorizer: UIDAuthorizer(uid + 1), denied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := d.Connect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			client, err := d.Connect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			a, err := AgentNew(raucmock.MockNew(raucmock.Options{}), Options{
+				Source:     &testSource{},
+				Authorizer: tt.authorizer,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := a.ExportFacade(server); err != nil {
+				t.Fatal(err)
+			}
+
+			events, cancel := a.installer.Events().Subscribe(16)
+			defer cancel()
+
+			var status map[string]dbus.Variant
+			err = client.Object(FacadeBusName, FacadeObjectPath).Call(FacadeInterface+".Status", 0).Store(&status)
+
+			var dbusErr dbus.Error
+			if tt.denied {
+				if !errors.As(err, &dbusErr) || dbusErr.Name != "org.freedesktop.DBus.Error.AccessDenied" {
+					t.Fatalf("got error %v, want AccessDenied", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if _, ok := status["installing"]; !ok {
+				t.Fatalf("got status %v without installing", status)
+			}
+
+			e, ok := (<-events).(FacadeRequestEvent)
+			if !ok || e.Method != "Status" || e.UID != uid || (e.Err != nil) != tt.denied {
+				t.Fatalf("unexpected event %+v", e)
+			}
+		})
+	}
+}
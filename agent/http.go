@@ -0,0 +1,253 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errUnauthenticated is the cause of HTTP requests with neither a known
+// token nor a verified client certificate.
+var errUnauthenticated = fmt.Errorf("%w: no valid token or client certificate", ErrUnauthorized)
+
+// HTTPOptions configures the HTTP API of the agent, see ListenAndServe.
+type HTTPOptions struct {
+	// Addr is the address to listen on, as in ":8443".
+	Addr string
+	// CertFile and KeyFile hold the server's certificate and key.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, if set, holds the CAs client certificates are verified
+	// against. Clients with a verified certificate are authenticated.
+	ClientCAFile string
+	// Tokens are the bearer tokens that authenticate clients. Without
+	// tokens, clients must present a certificate.
+	Tokens []string
+}
+
+// httpStatus is the JSON representation of Status.
+type httpStatus struct {
+	LastCheck        *time.Time `json:"last_check,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+	AvailableVersion string     `json:"available_version,omitempty"`
+	InstalledVersion string     `json:"installed_version,omitempty"`
+	Deferred         bool       `json:"deferred"`
+	Installing       bool       `json:"installing"`
+}
+
+// httpCheck is the JSON response of /check.
+type httpCheck struct {
+	Available bool   `json:"available"`
+	Version   string `json:"version"`
+}
+
+// httpError is the JSON response of failed requests.
+type httpError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, httpError{Error: err.Error()})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+
+	h := r.Header.Get("Authorization")
+	if len(h) < len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(h[len(prefix):])
+}
+
+// authenticator accepts callers with one of tokens or a verified client
+// certificate.
+func authenticator(tokens []string) Authorizer {
+	byToken := TokenAuthorizer(tokens...)
+
+	return AuthorizerFunc(func(ctx context.Context, c Caller) error {
+		if len(c.Certificates) > 0 {
+			return nil
+		}
+
+		if len(tokens) > 0 && byToken.Authorize(ctx, c) == nil {
+			return nil
+		}
+
+		return errUnauthenticated
+	})
+}
+
+// httpHandler returns the handler of the HTTP API. Callers must pass auth,
+// then the agent's Authorizer, if set.
+func (a *Agent) httpHandler(auth Authorizer) http.Handler {
+	if authorizer := a.options.Authorizer; authorizer != nil {
+		authenticate := auth
+		auth = AuthorizerFunc(func(ctx context.Context, c Caller) error {
+			if err := authenticate.Authorize(ctx, c); err != nil {
+				return err
+			}
+
+			return authorizer.Authorize(ctx, c)
+		})
+	}
+
+	mux := http.NewServeMux()
+
+	handle := func(path, method, name string, f func(w http.ResponseWriter, r *http.Request)) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != method {
+				w.Header().Set("Allow", method)
+				writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+				return
+			}
+
+			c := Caller{
+				Method:     name,
+				RemoteAddr: r.RemoteAddr,
+				Token:      bearerToken(r),
+			}
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+				c.Certificates = r.TLS.VerifiedChains[0]
+			}
+
+			err := a.admit(r.Context(), c, auth)
+			switch {
+			case err == nil:
+				f(w, r)
+			case errors.Is(err, errUnauthenticated):
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, err)
+			case errors.Is(err, errRateLimited):
+				writeError(w, http.StatusTooManyRequests, err)
+			default:
+				writeError(w, http.StatusForbidden, err)
+			}
+		})
+	}
+
+	handle("/status", http.MethodGet, "Status", func(w http.ResponseWriter, r *http.Request) {
+		status := a.Status()
+
+		s := httpStatus{
+			AvailableVersion: status.AvailableVersion,
+			InstalledVersion: status.InstalledVersion,
+			Deferred:         status.Deferred,
+			Installing:       status.Installing,
+		}
+		if !status.LastCheck.IsZero() {
+			s.LastCheck = &status.LastCheck
+		}
+		if status.LastError != nil {
+			s.LastError = status.LastError.Error()
+		}
+
+		writeJSON(w, http.StatusOK, s)
+	})
+
+	handle("/check", http.MethodPost, "CheckForUpdate", func(w http.ResponseWriter, r *http.Request) {
+		available, version, err := a.CheckForUpdate(r.Context())
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, httpCheck{Available: available, Version: version})
+	})
+
+	install := func(force bool) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			err := a.startInstall(force)
+			switch {
+			case errors.Is(err, ErrInProgress):
+				writeError(w, http.StatusConflict, err)
+			case err != nil:
+				writeError(w, http.StatusInternalServerError, err)
+			default:
+				w.WriteHeader(http.StatusAccepted)
+			}
+		}
+	}
+
+	handle("/install", http.MethodPost, "InstallLatest", install(false))
+	handle("/reinstall", http.MethodPost, "Reinstall", install(true))
+
+	return mux
+}
+
+// ListenAndServe serves an HTTP API over TLS, the network counterpart of
+// the D-Bus facade: GET /status, POST /check, and POST /install and
+// /reinstall, which start the installation and return 202 Accepted.
+// Clients authenticate with a bearer token or a client certificate, at
+// least one of them must be configured. The Authorizer and
+// FacadeMinInterval of the agent's Options apply as on the facade.
+// ListenAndServe returns when ctx is done.
+func (a *Agent) ListenAndServe(ctx context.Context, options HTTPOptions) error {
+	if options.CertFile == "" || options.KeyFile == "" {
+		return errors.New("agent: ListenAndServe(): certificate and key are required")
+	}
+
+	if len(options.Tokens) == 0 && options.ClientCAFile == "" {
+		return errors.New("agent: ListenAndServe(): tokens or a client CA are required")
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if options.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(options.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("agent: ListenAndServe(): %v", err)
+		}
+
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("agent: ListenAndServe(): no certificates in %s", options.ClientCAFile)
+		}
+
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if len(options.Tokens) == 0 {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	server := &http.Server{
+		Addr:      options.Addr,
+		Handler:   a.httpHandler(authenticator(options.Tokens)),
+		TLSConfig: config,
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.Close()
+		case <-done:
+		}
+	}()
+
+	err := server.ListenAndServeTLS(options.CertFile, options.KeyFile)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return fmt.Errorf("agent: ListenAndServe(): %v", err)
+}
@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/holoplot/go-rauc/raucmock"
+	"github.com/holoplot/go-rauc/source"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	const token = "secret"
+
+	denyDev := AuthorizerFunc(func(ctx context.Context, c Caller) error {
+		if c.subject() == "dev" {
+			return ErrUnauthorized
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		cn       string
+		running  bool
+		interval time.Duration
+		repeat   bool
+		code     int
+	}{
+		{name: "no credentials", method: http.MethodGet, path: "/status", code: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, path: "/status", token: "guess", code: http.StatusUnauthorized},
+		{name: "status", method: http.MethodGet, path: "/status", token: token, code: http.StatusOK},
+		{name: "status by certificate", method: http.MethodGet, path: "/status", cn: "ops", code: http.StatusOK},
+		{name: "refused certificate", method: http.MethodGet, path: "/status", cn: "dev", code: http.StatusForbidden},
+		{name: "wrong method", method: http.MethodPost, path: "/status", token: token, code: http.StatusMethodNotAllowed},
+		{name: "check", method: http.MethodPost, path: "/check", token: token, code: http.StatusOK},
+		{name: "check rate limited", method: http.MethodPost, path: "/check", token: token, interval: time.Hour, repeat: true, code: http.StatusTooManyRequests},
+		{name: "status not rate limited", method: http.MethodGet, path: "/status", token: token, interval: time.Hour, repeat: true, code: http.StatusOK},
+		{name: "install", method: http.MethodPost, path: "/install", token: token, code: http.StatusAccepted},
+		{name: "install in progress", method: http.MethodPost, path: "/install", token: token, running: true, code: http.StatusConflict},
+		{name: "reinstall in progress", method: http.MethodPost, path: "/reinstall", token: token, running: true, code: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := AgentNew(raucmock.MockNew(raucmock.Options{}), Options{
+				Source:            &testSource{bundle: source.Bundle{Location: "/bundles/update.raucb", Version: "2.0.0"}},
+				FacadeMinInterval: tt.interval,
+				Authorizer:        denyDev,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.running = tt.running
+
+			handler := a.httpHandler(authenticator([]string{token}))
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < 1 || (tt.repeat && i < 2); i++ {
+				r := httptest.NewRequest(tt.method, tt.path, nil)
+				if tt.token != "" {
+					r.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				if tt.cn != "" {
+					r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{testCertificate(tt.cn)}}
+				}
+
+				w = httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+			}
+
+			if w.Code != tt.code {
+				t.Fatalf("got status %d (%s), want %d", w.Code, w.Body, tt.code)
+			}
+
+			if tt.running {
+				return
+			}
+
+			// Let a started installation finish.
+			for deadline := time.Now().Add(time.Second); a.begin() != nil; {
+				if time.Now().After(deadline) {
+					t.Fatal("installation did not finish")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	a, err := AgentNew(raucmock.MockNew(raucmock.Options{}), Options{
+		Source: &testSource{bundle: source.Bundle{Location: "/bundles/update.raucb", Version: "2.0.0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/check", nil)
+	r.Header.Set("Authorization", "bearer secret")
+
+	w := httptest.NewRecorder()
+	a.httpHandler(authenticator([]string{"secret"})).ServeHTTP(w, r)
+
+	var check httpCheck
+	if err := json.NewDecoder(w.Body).Decode(&check); err != nil {
+		t.Fatal(err)
+	}
+
+	if !check.Available || check.Version != "2.0.0" {
+		t.Fatalf("got %+v, want version 2.0.0 available", check)
+	}
+}
+
+func TestListenAndServeOptions(t *testing.T) {
+	a, err := AgentNew(raucmock.MockNew(raucmock.Options{}), Options{Source: &testSource{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []HTTPOptions{
+		{Addr: "127.0.0.1:0", Tokens: []string{"secret"}},
+		{Addr: "127.0.0.1:0", CertFile: "cert.pem", KeyFile: "key.pem"},
+	}
+
+	for _, options := range tests {
+		if err := a.ListenAndServe(context.Background(), options); err == nil || errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("%+v: got error %v, want invalid options", options, err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/holoplot/go-rauc/source"
+)
+
+// ReportDevice identifies the device in a Report.
+type ReportDevice struct {
+	ID         string `json:"id,omitempty"`
+	Hostname   string `json:"hostname,omitempty"`
+	Compatible string `json:"compatible,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	BootSlot   string `json:"boot_slot,omitempty"`
+	// AgentVersion is the version of go-rauc.
+	AgentVersion string `json:"agent_version"`
+}
+
+// ReportBundle describes the installed bundle in a Report.
+type ReportBundle struct {
+	Location   string `json:"location"`
+	Version    string `json:"version,omitempty"`
+	Compatible string `json:"compatible,omitempty"`
+	Bytes      int64  `json:"bytes,omitempty"`
+}
+
+// ReportTimings holds the durations of InstallStats in a Report.
+type ReportTimings struct {
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Download time.Duration `json:"download_ns"`
+	Queue    time.Duration `json:"queue_ns"`
+	Install  time.Duration `json:"install_ns"`
+}
+
+// Report is a self-contained record of an installation attempt, written
+// to Options.ReportDirectory.
+type Report struct {
+	Device   ReportDevice        `json:"device"`
+	Bundle   ReportBundle        `json:"bundle"`
+	Timings  ReportTimings       `json:"timings"`
+	Progress []rauc.ProgressStep `json:"progress"`
+	// Success is set if the installation succeeded. Error holds the error
+	// of a failed one.
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// SlotsBefore and SlotsAfter are the slot states around the
+	// installation. They are missing if they could not be read.
+	SlotsBefore []rauc.SlotStatus `json:"slots_before,omitempty"`
+	SlotsAfter  []rauc.SlotStatus `json:"slots_after,omitempty"`
+}
+
+// ReportFailedEvent is published when a report cannot be written.
+type ReportFailedEvent struct {
+	Err error
+}
+
+// EventType implements rauc.Event.
+func (e ReportFailedEvent) EventType() string {
+	return "agent.report-failed"
+}
+
+// newReport collects what the report of an installation needs, apart from
+// the slots before it.
+func (a *Agent) newReport(b *source.Bundle, stats InstallStats) *Report {
+	r := &Report{
+		Device: ReportDevice{
+			AgentVersion: rauc.Version(),
+		},
+		Bundle: ReportBundle{
+			Location:   b.Location,
+			Version:    b.Version,
+			Compatible: b.Compatible,
+			Bytes:      stats.Bytes,
+		},
+		Timings: ReportTimings{
+			Started:  stats.Started,
+			Finished: time.Now(),
+			Download: stats.DownloadTime,
+			Queue:    stats.QueueTime,
+			Install:  stats.InstallTime,
+		},
+		Progress: stats.Progress,
+		Success:  stats.Err == nil,
+	}
+
+	if stats.Err != nil {
+		r.Error = stats.Err.Error()
+	}
+
+	// The device fields are best effort, a report is written regardless.
+	r.Device.ID, _ = a.deviceID()
+	r.Device.Hostname, _ = os.Hostname()
+	r.Device.Compatible, _ = a.installer.GetCompatible()
+	r.Device.Variant, _ = a.installer.GetVariant()
+	r.Device.BootSlot, _ = a.installer.GetBootSlot()
+	r.SlotsAfter, _ = a.installer.GetSlotStatus()
+
+	return r
+}
+
+// writeReport writes r to the report directory, as JSON and, if
+// configured, HTML.
+func (a *Agent) writeReport(r *Report) {
+	name := r.Timings.Started.UTC().Format("20060102T150405Z")
+	if r.Bundle.Version != "" {
+		name += "-" + r.Bundle.Version
+	}
+
+	err := WriteReport(filepath.Join(a.options.ReportDirectory, name+".json"), r)
+	if err == nil && a.options.ReportHTML {
+		err = WriteReportHTML(filepath.Join(a.options.ReportDirectory, name+".html"), r)
+	}
+
+	if err != nil {
+		a.installer.Events().Publish(ReportFailedEvent{Err: err})
+	}
+}
+
+// writeFile writes data to a temporary file and renames it to path, so
+// that archivers never pick up partial reports.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("agent: report: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("agent: report: %v", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("agent: report: %v", err)
+	}
+
+	return nil
+}
+
+// WriteReport writes r to path as indented JSON.
+func WriteReport(path string, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("agent: report: %v", err)
+	}
+
+	return writeFile(path, append(data, '\n'))
+}
+
+type reportSlot struct {
+	Name string
+	Keys [][2]string
+}
+
+func reportSlots(slots []rauc.SlotStatus) []reportSlot {
+	var out []reportSlot
+
+	for _, s := range slots {
+		keys := make([]string, 0, len(s.Status))
+		for k := range s.Status {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		rs := reportSlot{Name: s.SlotName}
+		for _, k := range keys {
+			rs.Keys = append(rs.Keys, [2]string{k, fmt.Sprint(s.Status[k].Value())})
+		}
+
+		out = append(out, rs)
+	}
+
+	return out
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"slots": reportSlots,
+	"time":  func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Installation of {{.Bundle.Version}} on {{.Device.Hostname}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+.ok { color: #080; } .failed { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Installation {{if .Success}}<span class="ok">succeeded</span>{{else}}<span class="failed">failed</span>{{end}}</h1>
+{{with .Error}}<p class="failed">{{.}}</p>{{end}}
+<h2>Device</h2>
+<table>
+<tr><th>ID</th><td>{{.Device.ID}}</td></tr>
+<tr><th>Hostname</th><td>{{.Device.Hostname}}</td></tr>
+<tr><th>Compatible</th><td>{{.Device.Compatible}}</td></tr>
+<tr><th>Variant</th><td>{{.Device.Variant}}</td></tr>
+<tr><th>Booted</th><td>{{.Device.BootSlot}}</td></tr>
+<tr><th>go-rauc</th><td>{{.Device.AgentVersion}}</td></tr>
+</table>
+<h2>Bundle</h2>
+<table>
+<tr><th>Location</th><td>{{.Bundle.Location}}</td></tr>
+<tr><th>Version</th><td>{{.Bundle.Version}}</td></tr>
+<tr><th>Compatible</th><td>{{.Bundle.Compatible}}</td></tr>
+<tr><th>Bytes</th><td>{{.Bundle.Bytes}}</td></tr>
+</table>
+<h2>Timings</h2>
+<table>
+<tr><th>Started</th><td>{{time .Timings.Started}}</td></tr>
+<tr><th>Finished</th><td>{{time .Timings.Finished}}</td></tr>
+<tr><th>Download</th><td>{{.Timings.Download}}</td></tr>
+<tr><th>Queue</th><td>{{.Timings.Queue}}</td></tr>
+<tr><th>Install</th><td>{{.Timings.Install}}</td></tr>
+</table>
+<h2>Progress</h2>
+<table>
+<tr><th>Time</th><th>%</th><th>Step</th></tr>
+{{range .Progress}}<tr><td>{{time .Time}}</td><td>{{.Percentage}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{define "slots"}}{{range slots .}}<h3>{{.Name}}</h3>
+<table>
+{{range .Keys}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+<h2>Slots before</h2>
+{{template "slots" .SlotsBefore}}
+<h2>Slots after</h2>
+{{template "slots" .SlotsAfter}}
+</body>
+</html>
+`))
+
+// WriteReportHTML writes r to path as a standalone HTML page.
+func WriteReportHTML(path string, r *Report) error {
+	var b strings.Builder
+	if err := reportTemplate.Execute(&b, r); err != nil {
+		return fmt.Errorf("agent: report: %v", err)
+	}
+
+	return writeFile(path, []byte(b.String()))
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/holoplot/go-rauc/source"
+)
+
+// MachineIDFile is read for the device ID used in rollouts.
+var MachineIDFile = "/etc/machine-id"
+
+// RolloutDeferredEvent is published when an update is available, but this
+// device is not part of its rollout yet.
+type RolloutDeferredEvent struct {
+	Bundle  string
+	Version string
+	// Bucket is the position of the device in the rollout of this
+	// version, from 0 to 100.
+	Bucket     float64
+	Percentage float64
+	NotBefore  time.Time
+}
+
+// EventType implements rauc.Event.
+func (e RolloutDeferredEvent) EventType() string {
+	return "agent.rollout-deferred"
+}
+
+// RolloutBucket returns the position of a device in the rollout of a
+// version, from 0 (inclusive) to 100 (exclusive). A rollout to N percent
+// of devices includes all devices whose bucket is below N. The position
+// is stable for a device and version, but differs between versions, so
+// the same devices are not always the first to update.
+func RolloutBucket(deviceID, version string) float64 {
+	sum := sha256.Sum256([]byte(deviceID + "\x00" + version))
+	n := binary.BigEndian.Uint64(sum[:8])
+
+	return float64(n>>11) / float64(1<<53) * 100
+}
+
+// deviceID returns the ID of the device for rollouts.
+func (a *Agent) deviceID() (string, error) {
+	if a.options.DeviceID != "" {
+		return a.options.DeviceID, nil
+	}
+
+	return MachineID()
+}
+
+// MachineID returns the content of MachineIDFile.
+func MachineID() (string, error) {
+	b, err := ioutil.ReadFile(MachineIDFile)
+	if err != nil {
+		return "", fmt.Errorf("agent: device ID: %v", err)
+	}
+
+	id := strings.TrimSpace(string(b))
+	if id == "" {
+		return "", fmt.Errorf("agent: device ID: %s is empty", MachineIDFile)
+	}
+
+	return id, nil
+}
+
+// admitted reports whether this device is part of the rollout of b, and
+// publishes a RolloutDeferredEvent if it is not.
+func (a *Agent) admitted(b *source.Bundle) (bool, error) {
+	if b.Rollout == nil || a.options.IgnoreRollout {
+		return true, nil
+	}
+
+	id, err := a.deviceID()
+	if err != nil {
+		return false, err
+	}
+
+	bucket := RolloutBucket(id, b.Version)
+	if bucket < b.Rollout.Percentage && !time.Now().Before(b.Rollout.NotBefore) {
+		return true, nil
+	}
+
+	a.installer.Events().Publish(RolloutDeferredEvent{
+		Bundle:     b.Location,
+		Version:    b.Version,
+		Bucket:     bucket,
+		Percentage: b.Rollout.Percentage,
+		NotBefore:  b.Rollout.NotBefore,
+	})
+
+	return false, nil
+}
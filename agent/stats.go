@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const defaultStatisticsSize = 16
+
+// InstallStats describes one installation attempt of the agent.
+type InstallStats struct {
+	Bundle  string
+	Version string
+	// Started is the time the update was found.
+	Started time.Time
+	// DownloadTime is the time spent fetching the bundle into the staging
+	// directory. It is zero for bundles the daemon reads directly.
+	DownloadTime time.Duration
+	// QueueTime is the time between the install request and the daemon
+	// accepting it, e.g. waiting for gates and the install lock.
+	QueueTime time.Duration
+	// InstallTime is the time the daemon spent on the installation.
+	InstallTime time.Duration
+	// Bytes is the size of the bundle, if it is a local file.
+	Bytes int64
+	// Progress holds the progress steps reported by the daemon. For a
+	// failed installation, the last one is the step it failed in.
+	Progress []rauc.ProgressStep
+	// Err is nil if the installation succeeded.
+	Err error
+}
+
+// InstallStatsEvent is published with the statistics of each installation
+// attempt, for metrics collectors and history journals.
+type InstallStatsEvent struct {
+	Stats InstallStats
+}
+
+// EventType implements rauc.Event.
+func (e InstallStatsEvent) EventType() string {
+	return "agent.install-stats"
+}
+
+// Statistics returns the statistics of the most recent installation
+// attempts, oldest first.
+func (a *Agent) Statistics() []InstallStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]InstallStats(nil), a.stats...)
+}
+
+func (a *Agent) addStats(s InstallStats) {
+	a.mutex.Lock()
+	a.stats = append(a.stats, s)
+	if len(a.stats) > a.options.StatisticsSize {
+		a.stats = a.stats[len(a.stats)-a.options.StatisticsSize:]
+	}
+	a.mutex.Unlock()
+
+	a.installer.Events().Publish(InstallStatsEvent{Stats: s})
+}
+
+// contextInstaller is implemented by backends that can stop waiting for
+// an installation.
+type contextInstaller interface {
+	InstallBundleContext(ctx context.Context, filename string, options rauc.InstallBundleOptions) error
+}
+
+// install installs the bundle at location and fills in the timing of s.
+// It stops waiting when ctx is done, for backends that support it.
+func (a *Agent) install(ctx context.Context, location string, s *InstallStats) error {
+	if fi, err := os.Stat(location); err == nil && fi.Mode().IsRegular() {
+		s.Bytes = fi.Size()
+	}
+
+	events, cancel := a.installer.Events().Subscribe(16)
+	accepted := make(chan time.Time, 1)
+
+	go func() {
+		defer close(accepted)
+
+		for e := range events {
+			if e, ok := e.(rauc.InstallStartedEvent); ok && e.Bundle == location {
+				accepted <- time.Now()
+				return
+			}
+		}
+	}()
+
+	options := a.options.InstallOptions
+	options.ProgressHistory = new(rauc.ProgressHistory)
+
+	requested := time.Now()
+	var err error
+	if c, ok := a.installer.(contextInstaller); ok {
+		err = c.InstallBundleContext(ctx, location, options)
+	} else {
+		err = a.installer.InstallBundle(location, options)
+	}
+	done := time.Now()
+	cancel()
+
+	s.Progress = options.ProgressHistory.Steps()
+
+	if t, ok := <-accepted; ok {
+		s.QueueTime = t.Sub(requested)
+		s.InstallTime = done.Sub(t)
+	} else {
+		s.QueueTime = done.Sub(requested)
+	}
+
+	return err
+}
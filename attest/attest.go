@@ -0,0 +1,135 @@
+// Package attest produces measurement reports of the installed slots, which
+// can optionally be anchored in a TPM so backends can verify which software
+// a device claims to run.
+package attest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+// SlotMeasurement describes the content of a single slot.
+type SlotMeasurement struct {
+	SlotName         string `json:"slot"`
+	Class            string `json:"class"`
+	BundleCompatible string `json:"bundle_compatible,omitempty"`
+	BundleVersion    string `json:"bundle_version,omitempty"`
+	BundleHash       string `json:"bundle_hash,omitempty"`
+	// SHA256 is the hash RAUC recorded when installing the slot.
+	SHA256 string `json:"sha256,omitempty"`
+	// DeviceSHA256 is the hash of the slot's device content, if requested.
+	DeviceSHA256 string `json:"device_sha256,omitempty"`
+}
+
+// Report is the measurement of all slots of a system.
+type Report struct {
+	Time       time.Time         `json:"time"`
+	Compatible string            `json:"compatible"`
+	BootSlot   string            `json:"boot_slot"`
+	Slots      []SlotMeasurement `json:"slots"`
+	// Digest is the SHA-256 over the canonical JSON encoding of Compatible,
+	// BootSlot and Slots. This is what gets extended into a PCR.
+	Digest []byte `json:"digest"`
+}
+
+// Options contains options for the Measure function
+type Options struct {
+	// HashDevices additionally hashes the content of each slot's device, up
+	// to the image size RAUC recorded. This reads the whole slot and can
+	// take a long time.
+	HashDevices bool
+}
+
+func variantString(status map[string]dbus.Variant, key string) string {
+	s, _ := status[key].Value().(string)
+	return s
+}
+
+// hashDevice computes the SHA-256 of the first size bytes of device.
+func hashDevice(device string, size int64) (string, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, size); err != nil {
+		return "", fmt.Errorf("attest: reading %s: %v", device, err)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Measure collects the measurement report from the RAUC daemon.
+func Measure(installer rauc.Backend, options Options) (*Report, error) {
+	compatible, err := installer.GetCompatible()
+	if err != nil {
+		return nil, err
+	}
+
+	bootSlot, err := installer.GetBootSlot()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := installer.GetSlotStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		Time:       time.Now(),
+		Compatible: compatible,
+		BootSlot:   bootSlot,
+	}
+
+	for _, s := range status {
+		m := SlotMeasurement{
+			SlotName:         s.SlotName,
+			Class:            variantString(s.Status, rauc.SlotKeyClass),
+			BundleCompatible: variantString(s.Status, rauc.SlotKeyBundleCompatible),
+			BundleVersion:    variantString(s.Status, rauc.SlotKeyBundleVersion),
+			BundleHash:       variantString(s.Status, rauc.SlotKeyBundleHash),
+			SHA256:           variantString(s.Status, rauc.SlotKeySHA256),
+		}
+
+		if options.HashDevices {
+			size, err := strconv.ParseInt(fmt.Sprint(s.Status[rauc.SlotKeySize].Value()), 10, 64)
+			if err == nil && size > 0 {
+				if m.DeviceSHA256, err = hashDevice(variantString(s.Status, rauc.SlotKeyDevice), size); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		r.Slots = append(r.Slots, m)
+	}
+
+	sort.Slice(r.Slots, func(i, j int) bool {
+		return r.Slots[i].SlotName < r.Slots[j].SlotName
+	})
+
+	canonical, err := json.Marshal(struct {
+		Compatible string            `json:"compatible"`
+		BootSlot   string            `json:"boot_slot"`
+		Slots      []SlotMeasurement `json:"slots"`
+	}{r.Compatible, r.BootSlot, r.Slots})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(canonical)
+	r.Digest = digest[:]
+
+	return r, nil
+}
@@ -0,0 +1,87 @@
+package attest
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The TPM is accessed with the tpm2-tools command line utilities, which
+// have to be installed.
+
+func tpm2(ctx context.Context, name string, args ...string) error {
+	if out, err := exec.CommandContext(ctx, name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("attest: %s: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// ExtendPCR extends the report's digest into the SHA-256 bank of the given
+// PCR. PCR 16 is the debug PCR; production systems typically use one of the
+// application PCRs that are not touched by firmware.
+func (r *Report) ExtendPCR(ctx context.Context, pcr int) error {
+	return tpm2(ctx, "tpm2_pcrextend", fmt.Sprintf("%d:sha256=%s", pcr, hex.EncodeToString(r.Digest)))
+}
+
+// Quote is a TPM quote over a set of PCRs.
+type Quote struct {
+	// Message is the TPMS_ATTEST structure that was signed.
+	Message []byte
+	// Signature is the TPMT_SIGNATURE over Message.
+	Signature []byte
+	// PCRs holds the values of the quoted PCRs.
+	PCRs []byte
+}
+
+// Quote produces a quote over the given SHA-256 PCRs, signed by the
+// attestation key loaded at akContext (a file or persistent handle). The
+// nonce is provided by the verifier to guarantee freshness.
+func (r *Report) Quote(ctx context.Context, akContext string, pcrs []int, nonce []byte) (*Quote, error) {
+	dir, err := ioutil.TempDir("", "rauc-quote")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	selection := make([]string, len(pcrs))
+	for i, p := range pcrs {
+		selection[i] = fmt.Sprint(p)
+	}
+
+	msg := filepath.Join(dir, "quote.msg")
+	sig := filepath.Join(dir, "quote.sig")
+	values := filepath.Join(dir, "quote.pcrs")
+
+	err = tpm2(ctx, "tpm2_quote",
+		"-c", akContext,
+		"-l", "sha256:"+strings.Join(selection, ","),
+		"-q", hex.EncodeToString(nonce),
+		"-m", msg,
+		"-s", sig,
+		"-o", values)
+	if err != nil {
+		return nil, err
+	}
+
+	q := new(Quote)
+	for _, f := range []struct {
+		path string
+		dest *[]byte
+	}{
+		{msg, &q.Message},
+		{sig, &q.Signature},
+		{values, &q.PCRs},
+	} {
+		if *f.dest, err = ioutil.ReadFile(f.path); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,76 @@
+package bootloader
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Barebox reads the bootstate.<bootname>.remaining_attempts variables using
+// barebox-state.
+type Barebox struct {
+	// Command is the barebox-state binary to use. Defaults to "barebox-state".
+	Command string
+}
+
+func (b *Barebox) command() string {
+	if b.Command == "" {
+		return "barebox-state"
+	}
+
+	return b.Command
+}
+
+// list returns the "name=value" lines of all state variables.
+func (b *Barebox) list() (string, error) {
+	command := b.command()
+
+	out, err := exec.Command(command, "-d").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s -d: %v", command, err)
+	}
+
+	return string(out), nil
+}
+
+func (b *Barebox) get(variable string) (int, error) {
+	command := b.command()
+
+	out, err := exec.Command(command, "-g", variable).Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s -g %s: %v", command, variable, err)
+	}
+
+	v, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0, fmt.Errorf("bootloader: cannot parse %s: %v", variable, err)
+	}
+
+	return v, nil
+}
+
+// ReadAttempts implements Reader.
+func (b *Barebox) ReadAttempts(bootnames []string) ([]Attempts, error) {
+	// The default attempt count is optional in the state description.
+	max, err := b.get("bootstate.default_attempts")
+	if err != nil {
+		max = 0
+	}
+
+	attempts := make([]Attempts, 0, len(bootnames))
+	for _, bootname := range bootnames {
+		remaining, err := b.get(fmt.Sprintf("bootstate.%s.remaining_attempts", bootname))
+		if err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, Attempts{
+			Bootname:  bootname,
+			Remaining: remaining,
+			Max:       max,
+		})
+	}
+
+	return attempts, nil
+}
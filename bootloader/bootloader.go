@@ -0,0 +1,40 @@
+// Package bootloader provides read access to the boot attempt counters that
+// the bootloader backends supported by RAUC maintain for each slot.
+package bootloader
+
+import (
+	"fmt"
+)
+
+// Attempts describes the boot attempt state of a single boot slot.
+type Attempts struct {
+	// Bootname is the name the bootloader uses for the slot (e.g. "A").
+	Bootname string `json:"bootname" yaml:"bootname"`
+	// Remaining is the number of boot attempts left before the bootloader
+	// falls back to another slot.
+	Remaining int `json:"remaining" yaml:"remaining"`
+	// Max is the number of attempts the slot is reset to when marked good,
+	// or 0 if the backend does not expose it.
+	Max int `json:"max" yaml:"max"`
+}
+
+// Reader is implemented by all bootloader backends.
+type Reader interface {
+	// ReadAttempts returns the attempt counters of the given boot slots.
+	ReadAttempts(bootnames []string) ([]Attempts, error)
+}
+
+// ReaderForBackend returns a Reader for the bootloader backend as named by
+// the 'bootloader' key in RAUC's system.conf.
+func ReaderForBackend(backend string) (Reader, error) {
+	switch backend {
+	case "uboot":
+		return &UBoot{}, nil
+	case "barebox":
+		return &Barebox{}, nil
+	case "grub":
+		return &Grub{}, nil
+	}
+
+	return nil, fmt.Errorf("bootloader: unsupported backend %q", backend)
+}
@@ -0,0 +1,79 @@
+package bootloader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	grubDefaultEnvFile = "/boot/grub/grubenv"
+)
+
+// Grub reads the <bootname>_OK and <bootname>_TRY variables from a GRUB
+// environment block. RAUC's reference GRUB script boots a slot once after
+// it has been marked active, so a slot has at most one attempt.
+type Grub struct {
+	// EnvFile is the path to the GRUB environment block.
+	// Defaults to /boot/grub/grubenv.
+	EnvFile string
+}
+
+func (g *Grub) env() (map[string]string, error) {
+	path := g.EnvFile
+	if path == "" {
+		path = grubDefaultEnvFile
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 {
+			env[kv[0]] = kv[1]
+		}
+	}
+
+	return env, scanner.Err()
+}
+
+// ReadAttempts implements Reader.
+func (g *Grub) ReadAttempts(bootnames []string) ([]Attempts, error) {
+	env, err := g.env()
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := make([]Attempts, 0, len(bootnames))
+	for _, bootname := range bootnames {
+		ok, found := env[bootname+"_OK"]
+		if !found {
+			return nil, fmt.Errorf("bootloader: %s_OK not set in GRUB environment", bootname)
+		}
+
+		remaining := 0
+		if ok == "1" && env[bootname+"_TRY"] != "1" {
+			remaining = 1
+		}
+
+		attempts = append(attempts, Attempts{
+			Bootname:  bootname,
+			Remaining: remaining,
+			Max:       1,
+		})
+	}
+
+	return attempts, nil
+}
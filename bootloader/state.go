@@ -0,0 +1,123 @@
+package bootloader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// State is what the bootloader will do on the next boot.
+type State struct {
+	Backend string `json:"backend" yaml:"backend"`
+	// Order lists the bootnames in the order the bootloader tries them.
+	Order    []string   `json:"order,omitempty" yaml:"order,omitempty"`
+	Attempts []Attempts `json:"attempts" yaml:"attempts"`
+	// Flags holds further variables that affect the next boot, such as
+	// U-Boot's upgrade_available.
+	Flags map[string]string `json:"flags,omitempty" yaml:"flags,omitempty"`
+}
+
+// StateReader is implemented by backends that can report more than the
+// attempt counters.
+type StateReader interface {
+	// ReadOrder returns the boot order and backend specific flags.
+	ReadOrder() (order []string, flags map[string]string, err error)
+}
+
+// ReadState reads the boot state of the named backend for the given boot
+// slots.
+func ReadState(backend string, bootnames []string) (*State, error) {
+	r, err := ReaderForBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := r.ReadAttempts(bootnames)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &State{
+		Backend:  backend,
+		Attempts: attempts,
+	}
+
+	if sr, ok := r.(StateReader); ok {
+		if s.Order, s.Flags, err = sr.ReadOrder(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// ubootFlags are the U-Boot variables of the bootcount mechanism.
+var ubootFlags = []string{"upgrade_available", "bootcount", "bootlimit"}
+
+// ReadOrder implements StateReader with the BOOT_ORDER variable.
+func (u *UBoot) ReadOrder() ([]string, map[string]string, error) {
+	env, err := u.env()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flags := make(map[string]string)
+	for _, k := range ubootFlags {
+		if v, ok := env[k]; ok {
+			flags[k] = v
+		}
+	}
+
+	return strings.Fields(env["BOOT_ORDER"]), flags, nil
+}
+
+// ReadOrder implements StateReader with the ORDER variable.
+func (g *Grub) ReadOrder() ([]string, map[string]string, error) {
+	env, err := g.env()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return strings.Fields(env["ORDER"]), nil, nil
+}
+
+// ReadOrder implements StateReader. Barebox has no boot order variable,
+// it tries the slots by priority, skipping those with priority 0.
+func (b *Barebox) ReadOrder() ([]string, map[string]string, error) {
+	out, err := b.list()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	priorities := make(map[string]int)
+	flags := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "bootstate.") {
+			continue
+		}
+
+		name := strings.TrimPrefix(kv[0], "bootstate.")
+		if strings.HasSuffix(name, ".priority") {
+			var priority int
+			if _, err := fmt.Sscan(kv[1], &priority); err == nil && priority > 0 {
+				priorities[strings.TrimSuffix(name, ".priority")] = priority
+			}
+		} else if !strings.Contains(name, ".") {
+			flags[name] = kv[1]
+		}
+	}
+
+	order := make([]string, 0, len(priorities))
+	for bootname := range priorities {
+		order = append(order, bootname)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if priorities[order[i]] != priorities[order[j]] {
+			return priorities[order[i]] > priorities[order[j]]
+		}
+		return order[i] < order[j]
+	})
+
+	return order, flags, nil
+}
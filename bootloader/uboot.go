@@ -0,0 +1,82 @@
+package bootloader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	ubootDefaultMaxAttempts = 3
+)
+
+// UBoot reads the BOOT_<bootname>_LEFT variables from the U-Boot environment
+// using fw_printenv.
+type UBoot struct {
+	// Command is the fw_printenv binary to use. Defaults to "fw_printenv".
+	Command string
+	// MaxAttempts is the number of attempts the boot script resets a slot to.
+	// Defaults to 3, which is what RAUC's reference script uses.
+	MaxAttempts int
+}
+
+func (u *UBoot) env() (map[string]string, error) {
+	command := u.Command
+	if command == "" {
+		command = "fw_printenv"
+	}
+
+	out, err := exec.Command(command).Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", command, err)
+	}
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) == 2 {
+			env[kv[0]] = kv[1]
+		}
+	}
+
+	return env, scanner.Err()
+}
+
+// ReadAttempts implements Reader.
+func (u *UBoot) ReadAttempts(bootnames []string) ([]Attempts, error) {
+	env, err := u.env()
+	if err != nil {
+		return nil, err
+	}
+
+	max := u.MaxAttempts
+	if max == 0 {
+		max = ubootDefaultMaxAttempts
+	}
+
+	attempts := make([]Attempts, 0, len(bootnames))
+	for _, bootname := range bootnames {
+		key := fmt.Sprintf("BOOT_%s_LEFT", bootname)
+		v, ok := env[key]
+		if !ok {
+			return nil, fmt.Errorf("bootloader: %s not set in U-Boot environment", key)
+		}
+
+		remaining, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("bootloader: cannot parse %s: %v", key, err)
+		}
+
+		attempts = append(attempts, Attempts{
+			Bootname:  bootname,
+			Remaining: remaining,
+			Max:       max,
+		})
+	}
+
+	return attempts, nil
+}
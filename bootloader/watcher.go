@@ -0,0 +1,128 @@
+package bootloader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const (
+	watcherDefaultInterval = time.Minute
+)
+
+// LowAttemptsEvent is published when a slot's remaining boot attempts drop
+// to or below the watcher's threshold.
+type LowAttemptsEvent struct {
+	Attempts Attempts
+}
+
+// EventType implements rauc.Event.
+func (e LowAttemptsEvent) EventType() string {
+	return "bootloader.low-attempts"
+}
+
+// AttemptsReadErrorEvent is published when the watcher fails to read the
+// attempt counters.
+type AttemptsReadErrorEvent struct {
+	Err error
+}
+
+// EventType implements rauc.Event.
+func (e AttemptsReadErrorEvent) EventType() string {
+	return "bootloader.read-error"
+}
+
+// Watcher periodically reads the boot attempt counters and publishes a
+// LowAttemptsEvent once per slot when it is about to run out of attempts,
+// giving operators a chance to intervene before the bootloader falls back.
+type Watcher struct {
+	Reader    Reader
+	Bootnames []string
+	Bus       *rauc.EventBus
+	// Interval between two reads. Defaults to one minute.
+	Interval time.Duration
+	// Threshold is the number of remaining attempts at which an event is
+	// raised. Defaults to 1 (the last attempt).
+	Threshold int
+
+	mutex    sync.Mutex
+	last     []Attempts
+	lastRead time.Time
+	reported map[string]bool
+}
+
+// Last returns the counters of the most recent successful read and the time
+// it happened. It can be used to export the values as metrics.
+func (w *Watcher) Last() ([]Attempts, time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return append([]Attempts(nil), w.last...), w.lastRead
+}
+
+func (w *Watcher) poll() {
+	attempts, err := w.Reader.ReadAttempts(w.Bootnames)
+	if err != nil {
+		if w.Bus != nil {
+			w.Bus.Publish(AttemptsReadErrorEvent{Err: err})
+		}
+		return
+	}
+
+	threshold := w.Threshold
+	if threshold == 0 {
+		threshold = 1
+	}
+
+	w.mutex.Lock()
+	w.last = attempts
+	w.lastRead = time.Now()
+	if w.reported == nil {
+		w.reported = make(map[string]bool)
+	}
+
+	var low []Attempts
+	for _, a := range attempts {
+		if a.Remaining > threshold {
+			// Counter has been reset, report again next time it drops.
+			w.reported[a.Bootname] = false
+			continue
+		}
+
+		if !w.reported[a.Bootname] {
+			w.reported[a.Bootname] = true
+			low = append(low, a)
+		}
+	}
+	w.mutex.Unlock()
+
+	if w.Bus != nil {
+		for _, a := range low {
+			w.Bus.Publish(LowAttemptsEvent{Attempts: a})
+		}
+	}
+}
+
+// Run reads the counters immediately and then once per interval until the
+// context is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = watcherDefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,15 +3,25 @@ package main
 // This utility copies a single file from RAUC's respective 'other' slot to the
 // host file system. This can for instance be used to determine which software
 // version is stored on the 'other' slot.
+//
+// The destination is written to a temporary file next to it, which is
+// renamed into place once it is complete, so an interrupted copy leaves
+// the previous file intact.
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/holoplot/go-rauc/internal/sandbox"
 	"github.com/holoplot/go-rauc/rauc"
 	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-isatty"
@@ -19,10 +29,45 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func stripQuotes(s string) string {
-	s = strings.TrimSuffix(s, "\"")
-	s = strings.TrimPrefix(s, "\"")
-	return s
+// writeAtomic copies from into tmp, checks its SHA-256 digest against sum
+// if that is not empty and renames tmp to to. tmp is removed on errors.
+func writeAtomic(tmp, dir *os.File, from io.Reader, to, sum string) (err error) {
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), from); err != nil {
+		return err
+	}
+
+	if sum != "" {
+		if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, sum) {
+			return fmt.Errorf("checksum mismatch: expected %s, got %s", sum, actual)
+		}
+	}
+
+	if err := tmp.Chmod(0644); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), to); err != nil {
+		return err
+	}
+
+	// Persist the rename itself.
+	return dir.Sync()
 }
 
 func main() {
@@ -40,6 +85,8 @@ func main() {
 	fromFlag := flag.String("from", "", "File to copy from (in the other slot's filesystem)")
 	mountPointFlag := flag.String("mount-point", "/tmp/rauc-other-slot", "Mount point to use temporarily")
 	classFlag := flag.String("class", "rootfs", "Slot class to mount")
+	sandboxFlag := flag.Bool("sandbox", true, "Drop capabilities and filesystem access before copying")
+	sha256Flag := flag.String("sha256", "", "Expected SHA-256 digest of the file, checked before it replaces the destination")
 	flag.Parse()
 
 	if *toFlag == "" || *fromFlag == "" {
@@ -54,92 +101,107 @@ func main() {
 			Msg("Cannot initialize")
 	}
 
-	statuses, err := raucInstaller.GetSlotStatus()
+	slot, err := raucInstaller.GetOtherSlot(*classFlag)
 	if err != nil {
 		log.Fatal().
 			Err(err).
-			Msg("Cannot get slot statuses")
+			Msg("Cannot find other slot")
 	}
 
-	for _, status := range statuses {
-		if s, ok := status.Status["class"]; !ok || stripQuotes(s.String()) != *classFlag {
-			continue
-		}
-
-		s, ok := status.Status["state"]
-		if !ok {
-			continue
-		}
-		state := stripQuotes(s.String())
+	device := slot.Device
+	log.Info().
+		Str("device", device).
+		Msg("Device path for mount")
 
-		if state == "booted" {
-			continue
-		}
+	if err := os.MkdirAll(*mountPointFlag, 0755); err != nil && err != os.ErrExist {
+		log.Error().
+			Err(err).
+			Msg("MkdirTemp() failed")
+		return
+	}
 
-		device := stripQuotes(status.Status["device"].String())
-		log.Info().
+	if err = syscall.Mount(device, *mountPointFlag, "squashfs", 0, ""); err != nil {
+		log.Error().
+			Err(err).
 			Str("device", device).
-			Msg("Device path for mount")
+			Str("mountPoint", *mountPointFlag).
+			Msg("Unable to mount")
+		return
+	}
 
-		if err := os.MkdirAll(*mountPointFlag, 0755); err != nil && err != os.ErrExist {
-			log.Error().
-				Err(err).
-				Msg("MkdirTemp() failed")
-			return
-		}
+	log.Info().
+		Str("device", device).
+		Str("mountPoint", *mountPointFlag).
+		Msg("Successfully mounted")
 
-		if err = syscall.Mount(device, *mountPointFlag, "squashfs", 0, ""); err != nil {
-			log.Error().
-				Err(err).
-				Str("device", device).
-				Str("mountPoint", *mountPointFlag).
-				Msg("Unable to mount")
-			return
-		}
+	from, err := os.Open(*mountPointFlag + *fromFlag)
 
-		log.Info().
-			Str("device", device).
-			Str("mountPoint", *mountPointFlag).
-			Msg("Successfully mounted")
+	// Detach the mount right away. The open file keeps the filesystem
+	// alive until it is closed, so no privileges are needed later on.
+	syscall.Unmount(*mountPointFlag, syscall.MNT_DETACH)
 
-		defer syscall.Unmount(*mountPointFlag, 0)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("from", *fromFlag).
+			Msg("Cannot open")
+		return
+	}
 
-		from, err := os.Open(*mountPointFlag + *fromFlag)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("from", *fromFlag).
-				Msg("Cannot open")
-			return
-		}
+	defer from.Close()
 
-		defer from.Close()
+	toDir := filepath.Dir(*toFlag)
 
-		to, err := os.OpenFile(*toFlag, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("to", *toFlag).
-				Msg("Cannot open")
-			return
-		}
+	dir, err := os.Open(toDir)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("to", *toFlag).
+			Msg("Cannot open destination directory")
+		return
+	}
 
-		defer to.Close()
+	defer dir.Close()
 
-		_, err = io.Copy(to, from)
+	to, err := ioutil.TempFile(toDir, "."+filepath.Base(*toFlag)+".")
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("to", *toFlag).
+			Msg("Cannot create temporary file")
+		return
+	}
+
+	if *sandboxFlag {
+		// The destination directory stays writable for the rename.
+		landlocked, err := sandbox.Restrict(toDir)
 		if err != nil {
+			to.Close()
+			os.Remove(to.Name())
 			log.Error().
-				Str("to", *toFlag).
-				Str("from", *fromFlag).
 				Err(err).
-				Msg("Cannot copy file content")
+				Msg("Cannot restrict process")
 			return
 		}
 
-		log.Info().
+		if !landlocked {
+			log.Warn().
+				Msg("Landlock not supported by kernel, only dropped capabilities")
+		}
+	}
+
+	if err := writeAtomic(to, dir, from, *toFlag, *sha256Flag); err != nil {
+		log.Error().
 			Str("to", *toFlag).
 			Str("from", *fromFlag).
-			Str("class", *classFlag).
-			Msg("Successfully copied")
+			Err(err).
+			Msg("Cannot copy file content")
+		return
 	}
+
+	log.Info().
+		Str("to", *toFlag).
+		Str("from", *fromFlag).
+		Str("class", *classFlag).
+		Msg("Successfully copied")
 }
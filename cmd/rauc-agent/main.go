@@ -0,0 +1,273 @@
+package main
+
+// This utility polls a bundle location and installs the bundle whenever its
+// version differs from the booted one. Sending SIGUSR1 triggers a check
+// immediately.
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/agent"
+	"github.com/holoplot/go-rauc/eventlog"
+	"github.com/holoplot/go-rauc/heartbeat"
+	"github.com/holoplot/go-rauc/policy"
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/holoplot/go-rauc/staging"
+	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// attach waits for an installation the previous instance detached from.
+func attach(installer *rauc.Installer) {
+	bundle, err := installer.AttachToCurrentOperation(rauc.InstallBundleOptions{})
+	switch {
+	case errors.Is(err, rauc.ErrNothingToAttach):
+	case err != nil:
+		log.Error().
+			Err(err).
+			Str("bundle", bundle).
+			Msg("Detached installation failed")
+	default:
+		log.Info().
+			Str("bundle", bundle).
+			Msg("Detached installation completed")
+	}
+}
+
+// detachOnSignal leaves an installation in flight to the daemon when
+// the agent is stopped.
+func detachOnSignal(installer *rauc.Installer, signals ...os.Signal) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	<-c
+
+	if err := installer.Detach(); err != nil {
+		log.Error().
+			Err(err).
+			Msg("Cannot detach from installation")
+	}
+
+	os.Exit(0)
+}
+
+func main() {
+	consoleWriter := zerolog.ConsoleWriter{
+		Out: colorable.NewColorableStdout(),
+	}
+
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		consoleWriter.TimeFormat = time.RFC3339
+	}
+
+	log.Logger = log.Output(consoleWriter)
+
+	urlFlag := flag.String("url", "", "Bundle source to poll (path, directory or URL)")
+	intervalFlag := flag.Duration("interval", time.Hour, "Time between two checks")
+	classFlag := flag.String("class", "rootfs", "Slot class to compare versions of")
+	waitFlag := flag.Duration("wait-for-daemon", time.Minute, "Time to wait for the RAUC daemon at startup")
+	facadeFlag := flag.Bool("facade", false, "Export a D-Bus API to control the agent")
+	stallFlag := flag.Duration("stall-timeout", 0, "Give up waiting for an installation whose progress does not change for this long")
+	backendFlag := flag.String("backend", "", "RAUC backend to use: dbus, cli, sim or empty to detect")
+	facadeIntervalFlag := flag.Duration("facade-min-interval", 10*time.Second, "Minimum time between two D-Bus requests of the same user")
+	stagingFlag := flag.String("staging", "", "Directory to keep downloaded bundles in until they are installed")
+	stagingMaxFlag := flag.Int64("staging-max-bytes", 0, "Size limit of the staging directory")
+	heartbeatFlag := flag.String("heartbeat-url", "", "Endpoint to report the device status to")
+	heartbeatTokenFlag := flag.String("heartbeat-token", "", "Bearer token for the heartbeat endpoint")
+	trackFlag := flag.Bool("track-activation", false, "Record installations for the boot confirmation in "+rauc.PendingActivationFile)
+	reinstallFlag := flag.Bool("reinstall", false, "Install the latest bundle once, even if it is already installed, and exit")
+	onSuccessFlag := flag.String("on-success", "", "Shell command to run after a successful installation")
+	onFailureFlag := flag.String("on-failure", "", "Shell command to run after a failed installation")
+	commandTimeoutFlag := flag.Duration("command-timeout", time.Minute, "Time after which -on-success and -on-failure commands are killed")
+	reportFlag := flag.String("report-dir", "", "Directory to write a JSON report of each installation to")
+	reportHTMLFlag := flag.Bool("report-html", false, "Write an HTML report next to each JSON report")
+	policyFlag := flag.String("policy", "", "Policy file (YAML or JSON) overriding -url, -interval and -class")
+	httpAddrFlag := flag.String("http-addr", "", "Address to serve the HTTP API on, over TLS")
+	httpCertFlag := flag.String("http-cert", "", "Certificate of the HTTP API")
+	httpKeyFlag := flag.String("http-key", "", "Key of the HTTP API")
+	httpClientCAFlag := flag.String("http-client-ca", "", "CAs to verify client certificates of the HTTP API against")
+	httpTokenFlag := flag.String("http-token", "", "Bearer token for the HTTP API")
+	httpCommonNamesFlag := flag.String("http-allow-cn", "", "Comma-separated common names of client certificates allowed to use the HTTP API")
+	flag.Parse()
+
+	if *urlFlag == "" && *policyFlag == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	backend, err := rauc.BackendNew(rauc.BackendOptions{
+		Type: rauc.BackendType(*backendFlag),
+		Installer: rauc.InstallerOptions{
+			WaitForDaemon: *waitFlag,
+		},
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Cannot initialize")
+	}
+
+	defer eventlog.Zerolog(backend.Events(), log.Logger)()
+
+	options := agent.Options{
+		BundleURL: *urlFlag,
+		Class:     *classFlag,
+		Interval:  *intervalFlag,
+		InstallOptions: rauc.InstallBundleOptions{
+			StallTimeout:    *stallFlag,
+			Lock:            true,
+			VerifyInstalled: true,
+		},
+
+		FacadeMinInterval: *facadeIntervalFlag,
+		TrackActivation:   *trackFlag,
+		ReportDirectory:   *reportFlag,
+		ReportHTML:        *reportHTMLFlag,
+	}
+
+	if *onSuccessFlag != "" {
+		options.OnSuccess = []agent.Command{{
+			Command: []string{"/bin/sh", "-c", *onSuccessFlag},
+			Timeout: *commandTimeoutFlag,
+		}}
+	}
+
+	if *onFailureFlag != "" {
+		options.OnFailure = []agent.Command{{
+			Command: []string{"/bin/sh", "-c", *onFailureFlag},
+			Timeout: *commandTimeoutFlag,
+		}}
+	}
+
+	if *stagingFlag != "" {
+		options.Staging, err = staging.AreaNew(staging.Options{
+			Directory: *stagingFlag,
+			MaxBytes:  *stagingMaxFlag,
+			Backend:   backend,
+		})
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Cannot set up staging directory")
+		}
+	}
+
+	if *policyFlag != "" {
+		p, err := policy.Load(*policyFlag)
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Cannot load policy")
+		}
+
+		options = p.AgentOptions(options)
+	}
+
+	if *httpCommonNamesFlag != "" {
+		// Restrict certificates of HTTP clients, leave the D-Bus facade open.
+		httpAuthorizer := agent.AnyAuthorizer(
+			agent.TokenAuthorizer(*httpTokenFlag),
+			agent.CertificateAuthorizer(strings.Split(*httpCommonNamesFlag, ",")...),
+		)
+
+		options.Authorizer = agent.AuthorizerFunc(func(ctx context.Context, c agent.Caller) error {
+			if c.Sender != "" {
+				return nil
+			}
+
+			return httpAuthorizer.Authorize(ctx, c)
+		})
+	}
+
+	a, err := agent.AgentNew(backend, options)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Cannot create agent")
+	}
+
+	if *facadeFlag {
+		conn, err := dbus.SystemBus()
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Cannot connect to system bus")
+		}
+
+		if err := a.ExportFacade(conn); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Cannot export D-Bus API")
+		}
+	}
+
+	if installer, ok := backend.(*rauc.Installer); ok {
+		attach(installer)
+		go detachOnSignal(installer, syscall.SIGTERM, syscall.SIGINT)
+	}
+
+	ctx := context.Background()
+
+	if *reinstallFlag {
+		if err := a.Reinstall(ctx); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Reinstallation failed")
+		}
+		return
+	}
+
+	a.TriggerOnSignal(ctx, syscall.SIGUSR1)
+
+	if *heartbeatFlag != "" {
+		reporter, err := heartbeat.ReporterNew(backend, heartbeat.Options{
+			URL:   *heartbeatFlag,
+			Token: *heartbeatTokenFlag,
+			Agent: a,
+		})
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Cannot create heartbeat reporter")
+		}
+
+		go reporter.Run(ctx)
+	}
+
+	if *httpAddrFlag != "" {
+		httpOptions := agent.HTTPOptions{
+			Addr:         *httpAddrFlag,
+			CertFile:     *httpCertFlag,
+			KeyFile:      *httpKeyFlag,
+			ClientCAFile: *httpClientCAFlag,
+		}
+		if *httpTokenFlag != "" {
+			httpOptions.Tokens = []string{*httpTokenFlag}
+		}
+
+		go func() {
+			if err := a.ListenAndServe(ctx, httpOptions); err != nil {
+				log.Fatal().
+					Err(err).
+					Msg("Cannot serve HTTP API")
+			}
+		}()
+	}
+
+	log.Info().
+		Str("url", options.BundleURL).
+		Dur("interval", options.Interval).
+		Str("version", rauc.Version()).
+		Msg("Agent started")
+
+	a.Run(ctx)
+}
@@ -0,0 +1,71 @@
+package main
+
+// This utility confirms an update after booting into it: it runs the health
+// checks of a configuration file and marks the booted slot good once they
+// pass. It does nothing unless an update is waiting for confirmation, see
+// rauc-agent -track-activation. With -check-only, it runs the checks once
+// and reports the result in its exit status.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/holoplot/go-rauc/health"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+func printResults(results []health.Result) {
+	for _, r := range results {
+		fmt.Println(r)
+	}
+}
+
+func main() {
+	configFlag := flag.String("config", "/etc/go-rauc/health.yaml", "Health check configuration (YAML or JSON)")
+	backendFlag := flag.String("backend", "", "RAUC backend to use: dbus, cli, sim or empty to detect")
+	checkOnlyFlag := flag.Bool("check-only", false, "Run the checks once without marking slots")
+	flag.Parse()
+
+	config, err := health.Load(*configFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	options, err := config.ConfirmOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	if *checkOnlyFlag {
+		results, ok := health.Run(ctx, options.Checks)
+		printResults(results)
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	backend, err := rauc.BackendNew(rauc.BackendOptions{
+		Type: rauc.BackendType(*backendFlag),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot initialize: %v\n", err)
+		os.Exit(1)
+	}
+
+	state, results, err := health.Confirm(ctx, backend, options)
+	printResults(results)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Update not confirmed (%s): %v\n", state, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Activation state: %s\n", state)
+}
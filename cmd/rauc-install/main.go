@@ -0,0 +1,86 @@
+package main
+
+// This utility installs a bundle, taking the cross-process install lock
+// first. A bundle name of "-" reads the bundle from stdin, so that it can
+// be piped from ssh or curl:
+//
+//	curl -s https://updates.example.com/latest.raucb | rauc-install -
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+// stringList collects the values of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func main() {
+	backendFlag := flag.String("backend", "", "RAUC backend to use: dbus, cli, sim or empty to detect")
+	spoolFlag := flag.String("spool-dir", rauc.DefaultSpoolDirectory, "Directory to store a bundle read from stdin in")
+	maxBytesFlag := flag.Int64("max-bytes", 0, "Size limit of a bundle read from stdin, 0 for none")
+	sha256Flag := flag.String("sha256", "", "Expected SHA-256 digest of a bundle read from stdin")
+	ignoreCompatibleFlag := flag.Bool("ignore-compatible", false, "Install the bundle even if its compatible does not match the system")
+	lockFlag := flag.Bool("lock", true, "Wait for other processes using the install lock")
+	tlsCertFlag := flag.String("tls-cert", "", "Client certificate for streaming from HTTPS servers")
+	tlsKeyFlag := flag.String("tls-key", "", "Client key for streaming from HTTPS servers")
+	tlsCAFlag := flag.String("tls-ca", "", "CA certificate to verify HTTPS servers with")
+	tlsNoVerifyFlag := flag.Bool("tls-no-verify", false, "Do not verify the certificate of HTTPS servers")
+	var headers stringList
+	flag.Var(&headers, "http-header", "HTTP header for streaming, may be repeated")
+	timeoutFlag := flag.Duration("timeout", 0, "Give up waiting for the installation after this long, 0 for never")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <bundle|->\n", os.Args[0])
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	backend, err := rauc.BackendNew(rauc.BackendOptions{
+		Type: rauc.BackendType(*backendFlag),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot initialize: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := rauc.InstallBundleOptions{
+		IgnoreIncompatible: *ignoreCompatibleFlag,
+		Lock:               *lockFlag,
+		Timeout:            *timeoutFlag,
+		TLSCert:            *tlsCertFlag,
+		TLSKey:             *tlsKeyFlag,
+		TLSCA:              *tlsCAFlag,
+		TLSNoVerify:        *tlsNoVerifyFlag,
+		HTTPHeaders:        headers,
+	}
+
+	bundle := flag.Arg(0)
+	if bundle == "-" {
+		err = rauc.InstallBundleFromReader(backend, os.Stdin, rauc.SpoolOptions{
+			Directory: *spoolFlag,
+			MaxBytes:  *maxBytesFlag,
+			SHA256:    *sha256Flag,
+		}, options)
+	} else {
+		err = backend.InstallBundle(bundle, options)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Installation failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Installation completed")
+}
@@ -0,0 +1,282 @@
+package main
+
+// This utility watches the RAUC daemon and sends notifications about
+// installations and rollbacks to the sinks configured in a YAML file,
+// independently of the process that installs updates:
+//
+//	sinks:
+//	  - type: webhook
+//	    url: https://hooks.example.com/rauc
+//	  - type: mqtt
+//	    url: tcp://broker.example.com:1883
+//	    topic: devices/board-17/rauc
+//	    events: [install-failed, rollback]
+//	  - type: email
+//	    server: smtp.example.com:587
+//	    from: device@example.com
+//	    to: [ops@example.com]
+//	  - type: desktop
+//
+// Sinks without events receive all of them.
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Notification events.
+const (
+	eventInstallStarted   = "install-started"
+	eventInstallCompleted = "install-completed"
+	eventInstallFailed    = "install-failed"
+	eventRollback         = "rollback"
+)
+
+type notification struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Device  string    `json:"device,omitempty"`
+	Slot    string    `json:"slot,omitempty"`
+	Version string    `json:"version,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+func (n *notification) summary() string {
+	switch n.Event {
+	case eventInstallStarted:
+		return fmt.Sprintf("%s: installation started", n.Device)
+	case eventInstallCompleted:
+		return fmt.Sprintf("%s: installed %s to %s", n.Device, n.Version, n.Slot)
+	case eventInstallFailed:
+		return fmt.Sprintf("%s: installation failed", n.Device)
+	case eventRollback:
+		return fmt.Sprintf("%s: rolled back to %s", n.Device, n.Slot)
+	}
+
+	return n.Device + ": " + n.Event
+}
+
+type config struct {
+	Sinks []sinkConfig `yaml:"sinks"`
+}
+
+func loadConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := yaml.NewDecoder(f)
+	d.KnownFields(true)
+
+	c := new(config)
+	if err := d.Decode(c); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// newestSlot returns the slot that was installed to last.
+func newestSlot(slots []rauc.Slot) (rauc.Slot, bool) {
+	var newest rauc.Slot
+
+	for _, s := range slots {
+		if s.InstalledTimestamp.After(newest.InstalledTimestamp) {
+			newest = s
+		}
+	}
+
+	return newest, !newest.InstalledTimestamp.IsZero()
+}
+
+// bootTime returns the time the system was booted.
+func bootTime() (time.Time, error) {
+	data, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			sec, err := strconv.ParseInt(strings.TrimSpace(line[6:]), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+
+			return time.Unix(sec, 0), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("no btime in /proc/stat")
+}
+
+// rollback returns a notification if the system was booted after the
+// last installation, but not into the slot installed to.
+func rollback(installer *rauc.Installer) (*notification, error) {
+	slots, err := installer.GetSlots()
+	if err != nil {
+		return nil, err
+	}
+
+	last, ok := newestSlot(slots)
+	if !ok {
+		return nil, nil
+	}
+
+	booted, err := bootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	if last.InstalledTimestamp.After(booted) || last.Booted() {
+		return nil, nil
+	}
+
+	n := &notification{Event: eventRollback}
+	if s, ok := rauc.FindBootedSlot(slots, last.Class); ok {
+		n.Slot = s.Name
+		n.Version = s.BundleVersion
+	}
+
+	n.Message = fmt.Sprintf("Slot %s with version %s was installed at %s, but %s was booted",
+		last.Name, last.BundleVersion, last.InstalledTimestamp.Format(time.RFC3339), n.Slot)
+
+	return n, nil
+}
+
+// completed returns the notification for a Completed signal.
+func completed(installer *rauc.Installer, code int32) *notification {
+	n := &notification{Event: eventInstallCompleted}
+
+	if code != 0 {
+		n.Event = eventInstallFailed
+		n.Message, _ = installer.GetLastError()
+		return n
+	}
+
+	if slots, err := installer.GetSlots(); err == nil {
+		if s, ok := newestSlot(slots); ok {
+			n.Slot = s.Name
+			n.Version = s.BundleVersion
+		}
+	}
+
+	return n
+}
+
+func dispatch(sinks []sink, n *notification) {
+	n.Time = time.Now()
+	n.Device, _ = os.Hostname()
+
+	for _, s := range sinks {
+		if !s.wants(n.Event) {
+			continue
+		}
+
+		if err := s.send(n); err != nil {
+			log.Error().
+				Err(err).
+				Str("sink", s.name()).
+				Str("event", n.Event).
+				Msg("Cannot send notification")
+		}
+	}
+}
+
+func main() {
+	consoleWriter := zerolog.ConsoleWriter{
+		Out: colorable.NewColorableStdout(),
+	}
+
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		consoleWriter.TimeFormat = time.RFC3339
+	}
+
+	log.Logger = log.Output(consoleWriter)
+
+	configFlag := flag.String("config", "/etc/rauc-notify.yaml", "Configuration file")
+	waitFlag := flag.Duration("wait-for-daemon", time.Minute, "Time to wait for the RAUC daemon at startup")
+	flag.Parse()
+
+	c, err := loadConfig(*configFlag)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Cannot load configuration")
+	}
+
+	sinks, err := sinksNew(c.Sinks)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid configuration")
+	}
+
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Cannot connect to system bus")
+	}
+
+	installer, err := rauc.InstallerNewWithOptions(rauc.InstallerOptions{
+		Conn:          conn,
+		WaitForDaemon: *waitFlag,
+	})
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Cannot initialize")
+	}
+
+	signals, _ := installer.SubscribeSignals(10)
+
+	if n, err := rollback(installer); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Cannot check for rollback")
+	} else if n != nil {
+		dispatch(sinks, n)
+	}
+
+	log.Info().
+		Int("sinks", len(sinks)).
+		Msg("Waiting for events")
+
+	for signal := range signals {
+		switch signal.Name {
+		case "de.pengutronix.rauc.Installer.Completed":
+			var code int32
+			if err := dbus.Store(signal.Body, &code); err == nil {
+				dispatch(sinks, completed(installer, code))
+			}
+		case "org.freedesktop.DBus.Properties.PropertiesChanged":
+			var iface string
+			var changed map[string]dbus.Variant
+			var invalidated []string
+			if err := dbus.Store(signal.Body, &iface, &changed, &invalidated); err != nil {
+				continue
+			}
+
+			if op, ok := changed["Operation"].Value().(string); ok && op == "installing" {
+				dispatch(sinks, &notification{Event: eventInstallStarted})
+			}
+		}
+	}
+}
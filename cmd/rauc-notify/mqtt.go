@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// mqttPublish connects to the broker, publishes payload with QoS 0 and
+// disconnects again, speaking just enough MQTT 3.1.1 for that.
+func mqttPublish(config sinkConfig, payload []byte) error {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: sinkTimeout}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.Dial("tcp", hostPort(u, "1883"))
+	case "tls", "ssl", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "8883"), nil)
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(sinkTimeout))
+
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("rauc-notify-%d", time.Now().UnixNano())
+	}
+
+	// Protocol name, level 4 and a clean session.
+	flags := byte(0x02)
+	connect := append(mqttString("MQTT"), 4, 0, 0, 60)
+	body := mqttString(clientID)
+	if config.Username != "" {
+		flags |= 0x80
+		body = append(body, mqttString(config.Username)...)
+	}
+	if config.Password != "" {
+		flags |= 0x40
+		body = append(body, mqttString(config.Password)...)
+	}
+	connect[7] = flags
+
+	if _, err := conn.Write(mqttPacket(0x10, append(connect, body...))); err != nil {
+		return err
+	}
+
+	var connack [4]byte
+	if _, err := io.ReadFull(conn, connack[:]); err != nil {
+		return err
+	}
+	if connack[0] != 0x20 {
+		return errors.New("unexpected reply to CONNECT")
+	}
+	if connack[3] != 0 {
+		return fmt.Errorf("connection refused, code %d", connack[3])
+	}
+
+	publish := append(mqttString(config.Topic), payload...)
+	if _, err := conn.Write(mqttPacket(0x30, publish)); err != nil {
+		return err
+	}
+
+	_, err = conn.Write([]byte{0xe0, 0})
+	return err
+}
+
+func hostPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// mqttString encodes s with its two byte length prefix.
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+// mqttPacket prepends the fixed header to body.
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+
+	return append(packet, body...)
+}
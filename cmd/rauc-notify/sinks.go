@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const sinkTimeout = 30 * time.Second
+
+type sinkConfig struct {
+	// Type is one of webhook, mqtt, email or desktop.
+	Type string `yaml:"type"`
+	// Events limits the sink to these events.
+	Events []string `yaml:"events,omitempty"`
+	// URL is the webhook endpoint, or the MQTT broker as in
+	// "tcp://host:1883" or "tls://host:8883".
+	URL    string            `yaml:"url,omitempty"`
+	Header map[string]string `yaml:"header,omitempty"`
+	// Topic and ClientID are used by MQTT sinks.
+	Topic    string `yaml:"topic,omitempty"`
+	ClientID string `yaml:"client_id,omitempty"`
+	// Server, From and To are used by email sinks.
+	Server string   `yaml:"server,omitempty"`
+	From   string   `yaml:"from,omitempty"`
+	To     []string `yaml:"to,omitempty"`
+	// Username and Password authenticate MQTT and email sinks.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+type sink interface {
+	name() string
+	wants(event string) bool
+	send(n *notification) error
+}
+
+// filter implements sink.wants.
+type filter []string
+
+func (f filter) wants(event string) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	for _, e := range f {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sinksNew(configs []sinkConfig) ([]sink, error) {
+	var sinks []sink
+
+	for i, c := range configs {
+		var s sink
+
+		switch c.Type {
+		case "webhook":
+			if c.URL == "" {
+				return nil, fmt.Errorf("sink %d: url is required", i)
+			}
+			s = &webhookSink{filter(c.Events), c, &http.Client{Timeout: sinkTimeout}}
+		case "mqtt":
+			if c.URL == "" || c.Topic == "" {
+				return nil, fmt.Errorf("sink %d: url and topic are required", i)
+			}
+			s = &mqttSink{filter(c.Events), c}
+		case "email":
+			if c.Server == "" || c.From == "" || len(c.To) == 0 {
+				return nil, fmt.Errorf("sink %d: server, from and to are required", i)
+			}
+			s = &emailSink{filter(c.Events), c}
+		case "desktop":
+			s = &desktopSink{filter(c.Events)}
+		default:
+			return nil, fmt.Errorf("sink %d: unknown type %q", i, c.Type)
+		}
+
+		sinks = append(sinks, s)
+	}
+
+	return sinks, nil
+}
+
+// webhookSink posts notifications as JSON.
+type webhookSink struct {
+	filter
+	config sinkConfig
+	client *http.Client
+}
+
+func (s *webhookSink) name() string {
+	return "webhook " + s.config.URL
+}
+
+func (s *webhookSink) send(n *notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", rauc.UserAgent())
+	for k, v := range s.config.Header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", s.config.URL, resp.Status)
+	}
+
+	return nil
+}
+
+// mqttSink publishes notifications as JSON.
+type mqttSink struct {
+	filter
+	config sinkConfig
+}
+
+func (s *mqttSink) name() string {
+	return "mqtt " + s.config.URL
+}
+
+func (s *mqttSink) send(n *notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	return mqttPublish(s.config, payload)
+}
+
+// emailSink mails notifications in plain text.
+type emailSink struct {
+	filter
+	config sinkConfig
+}
+
+func (s *emailSink) name() string {
+	return "email " + s.config.Server
+}
+
+func (s *emailSink) send(n *notification) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		host := strings.Split(s.config.Server, ":")[0]
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, host)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.config.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", n.summary())
+	fmt.Fprintf(&b, "Date: %s\r\n\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Event:   %s\r\nTime:    %s\r\n", n.Event, n.Time.Format(time.RFC3339))
+	if n.Slot != "" {
+		fmt.Fprintf(&b, "Slot:    %s\r\n", n.Slot)
+	}
+	if n.Version != "" {
+		fmt.Fprintf(&b, "Version: %s\r\n", n.Version)
+	}
+	if n.Message != "" {
+		fmt.Fprintf(&b, "\r\n%s\r\n", n.Message)
+	}
+
+	return smtp.SendMail(s.config.Server, auth, s.config.From, s.config.To, []byte(b.String()))
+}
+
+// desktopSink shows notifications through the notification daemon of
+// the session bus.
+type desktopSink struct {
+	filter
+}
+
+func (s *desktopSink) name() string {
+	return "desktop"
+}
+
+func (s *desktopSink) send(n *notification) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+
+	object := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	call := object.Call("org.freedesktop.Notifications.Notify", 0,
+		"rauc-notify", uint32(0), "", n.summary(), n.Message,
+		[]string{}, map[string]dbus.Variant{}, int32(-1))
+
+	return call.Err
+}
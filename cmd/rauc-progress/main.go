@@ -0,0 +1,160 @@
+package main
+
+// This utility follows the installation run by the RAUC daemon and prints
+// its progress to stdout until it completes, as "percent<TAB>message"
+// lines or as NDJSON. It is meant to be piped into frontends such as
+// dialog --gauge. The exit status is 1 if the installation failed.
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+type progressLine struct {
+	Percentage   int32  `json:"percentage"`
+	Message      string `json:"message"`
+	NestingDepth int32  `json:"nesting_depth"`
+}
+
+type completedLine struct {
+	Completed bool   `json:"completed"`
+	Error     string `json:"error,omitempty"`
+}
+
+type printer struct {
+	json bool
+	e    *json.Encoder
+}
+
+func (p *printer) progress(percentage int32, message string, depth int32) {
+	if p.json {
+		p.e.Encode(progressLine{percentage, message, depth})
+		return
+	}
+
+	fmt.Printf("%d\t%s\n", percentage, message)
+}
+
+func (p *printer) completed(lastError string) {
+	if p.json {
+		p.e.Encode(completedLine{true, lastError})
+		return
+	}
+
+	if lastError != "" {
+		fmt.Fprintf(os.Stderr, "Installation failed: %s\n", lastError)
+	}
+}
+
+// waitForInstallation returns once an installation runs, or false after
+// timeout.
+func waitForInstallation(installer *rauc.Installer, timeout, interval time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		operation, err := installer.GetOperation()
+		if err != nil {
+			return false, err
+		}
+
+		if rauc.Operation(operation) == rauc.OperationInstalling {
+			return true, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func main() {
+	formatFlag := flag.String("format", "text", "Output format: text or json")
+	waitFlag := flag.Duration("wait", 0, "Time to wait for an installation to start")
+	intervalFlag := flag.Duration("interval", 250*time.Millisecond, "Time between two progress queries")
+	flag.Parse()
+
+	if *formatFlag != "text" && *formatFlag != "json" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	p := &printer{
+		json: *formatFlag == "json",
+		e:    json.NewEncoder(os.Stdout),
+	}
+
+	installer, err := rauc.InstallerNew()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot initialize: %v\n", err)
+		os.Exit(1)
+	}
+
+	// LastError tells a failure apart from earlier ones once the
+	// installation completes.
+	initialError, err := installer.GetLastError()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot get last error: %v\n", err)
+		os.Exit(1)
+	}
+
+	running, err := waitForInstallation(installer, *waitFlag, *intervalFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot get operation: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !running {
+		fmt.Fprintln(os.Stderr, "No installation in progress")
+		os.Exit(1)
+	}
+
+	lastPercentage, lastMessage := int32(-1), ""
+
+	for {
+		operation, err := installer.GetOperation()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot get operation: %v\n", err)
+			os.Exit(1)
+		}
+
+		progress, err := rauc.ProgressOf(installer)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot get progress: %v\n", err)
+			os.Exit(1)
+		}
+
+		if progress.Percentage != lastPercentage || progress.Message != lastMessage {
+			p.progress(progress.Percentage, progress.Message, progress.NestingDepth)
+			lastPercentage, lastMessage = progress.Percentage, progress.Message
+		}
+
+		if rauc.Operation(operation) != rauc.OperationInstalling {
+			break
+		}
+
+		time.Sleep(*intervalFlag)
+	}
+
+	lastError, err := installer.GetLastError()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot get last error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if lastError == initialError {
+		lastError = ""
+	}
+
+	p.completed(lastError)
+
+	if lastError != "" {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+// This utility prints the state of the RAUC daemon and all slots, including
+// where each slot is currently mounted, and the state of the bootloader.
+// With -diagnose, it checks the RAUC setup instead.
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/holoplot/go-rauc/bootloader"
+	"github.com/holoplot/go-rauc/rauc"
+	yaml "gopkg.in/yaml.v3"
+)
+
+type status struct {
+	rauc.Snapshot `yaml:",inline"`
+	// Bootloader is nil if the bootloader state could not be read.
+	Bootloader *bootloader.State `json:"bootloader,omitempty" yaml:"bootloader,omitempty"`
+}
+
+// readBootloader reads the state of the bootloader configured in
+// system.conf for all slots with a bootname.
+func readBootloader() (*bootloader.State, error) {
+	config, err := rauc.ReadSystemConfig("")
+	if err != nil {
+		return nil, err
+	}
+
+	var bootnames []string
+	for _, slot := range config.Slots {
+		if slot.Bootname != "" {
+			bootnames = append(bootnames, slot.Bootname)
+		}
+	}
+
+	return bootloader.ReadState(config.Bootloader, bootnames)
+}
+
+func printBootloader(b *bootloader.State) {
+	fmt.Printf("\nBootloader: %s\n", b.Backend)
+	if len(b.Order) > 0 {
+		fmt.Printf("    order: %s\n", strings.Join(b.Order, " "))
+	}
+
+	for _, a := range b.Attempts {
+		if a.Max > 0 {
+			fmt.Printf("    %s: %d of %d attempts left\n", a.Bootname, a.Remaining, a.Max)
+		} else {
+			fmt.Printf("    %s: %d attempts left\n", a.Bootname, a.Remaining)
+		}
+	}
+
+	keys := make([]string, 0, len(b.Flags))
+	for k := range b.Flags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Printf("    %s: %s\n", k, b.Flags[k])
+	}
+}
+
+func printStatus(s *status) {
+	printSnapshot(&s.Snapshot)
+
+	if s.Bootloader != nil {
+		printBootloader(s.Bootloader)
+	}
+}
+
+func printSnapshot(s *rauc.Snapshot) {
+	fmt.Printf("Compatible: %s\n", s.Compatible)
+	fmt.Printf("Variant:    %s\n", s.Variant)
+	fmt.Printf("Booted:     %s\n", s.BootSlot)
+	fmt.Printf("Operation:  %s\n", s.Operation)
+
+	if s.Service != nil {
+		fmt.Printf("Service:    %s/%s (result %s, %d restarts)\n",
+			s.Service.ActiveState, s.Service.SubState, s.Service.Result, s.Service.NRestarts)
+	}
+
+	for _, slot := range s.Slots {
+		fmt.Printf("\n%s:\n", slot.SlotName)
+
+		keys := make([]string, 0, len(slot.Status))
+		for k := range slot.Status {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			v := slot.Status[k]
+			if s, ok := v.Value().(string); ok {
+				fmt.Printf("    %s: %s\n", k, s)
+			} else {
+				fmt.Printf("    %s: %s\n", k, v.String())
+			}
+		}
+
+		for _, m := range slot.Mounts {
+			mode := "rw"
+			if m.ReadOnly {
+				mode = "ro"
+			}
+			fmt.Printf("    mounted: %s (%s, %s)\n", m.MountPoint, m.FSType, mode)
+		}
+	}
+}
+
+func printChecks(checks []rauc.Check) {
+	for _, c := range checks {
+		fmt.Printf("%-16s %-8s %s\n", c.Name, c.Status, c.Detail)
+		if c.Remedy != "" && c.Status != rauc.CheckOK {
+			fmt.Printf("%-16s %-8s %s\n", "", "", c.Remedy)
+		}
+	}
+}
+
+func encode(format string, v interface{}, text func()) error {
+	switch format {
+	case "json":
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(v)
+	case "yaml":
+		e := yaml.NewEncoder(os.Stdout)
+		e.SetIndent(2)
+		if err := e.Encode(v); err != nil {
+			return err
+		}
+		return e.Close()
+	}
+
+	text()
+	return nil
+}
+
+func main() {
+	formatFlag := flag.String("format", "text", "Output format: text, json or yaml")
+	classFlag := flag.String("class", "", "Comma-separated slot classes to show, all if empty")
+	diagnoseFlag := flag.Bool("diagnose", false, "Check the RAUC setup and exit with 1 if a check failed")
+	bootloaderFlag := flag.Bool("bootloader", true, "Include the boot order and attempt counters of the bootloader")
+	flag.Parse()
+
+	if *formatFlag != "text" && *formatFlag != "json" && *formatFlag != "yaml" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	backend, err := rauc.BackendNew(rauc.BackendOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot initialize: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *diagnoseFlag {
+		checks := rauc.Diagnose(context.Background(), backend)
+		if err := encode(*formatFlag, checks, func() { printChecks(checks) }); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot encode checks: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, c := range checks {
+			if c.Status == rauc.CheckFailed {
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
+	options := rauc.SnapshotOptions{}
+	if *classFlag != "" {
+		options.Filter.Classes = strings.Split(*classFlag, ",")
+	}
+
+	snapshot, err := rauc.SnapshotWithOptions(backend, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot get status: %v\n", err)
+		os.Exit(1)
+	}
+
+	s := &status{Snapshot: *snapshot}
+
+	if *bootloaderFlag {
+		if s.Bootloader, err = readBootloader(); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read bootloader state: %v\n", err)
+		}
+	}
+
+	if err := encode(*formatFlag, s, func() { printStatus(s) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot encode status: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,271 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChunkSize = 8 << 20
+	chunkRetries     = 3
+)
+
+// chunkManifest lists the SHA-256 of each chunk of a bundle.
+type chunkManifest struct {
+	ChunkSize int64    `json:"chunk_size"`
+	SHA256    []string `json:"sha256"`
+}
+
+// offsetWriter writes to f sequentially from offset on.
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// probe returns the size of the resource at url if the server supports
+// range requests for it, and -1 otherwise.
+func (m *Manager) probe(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("download: %v", err)
+	}
+	req.Header.Set("User-Agent", m.options.UserAgent)
+
+	resp, err := m.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, transient(ctx, fmt.Errorf("download: %v", err))
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= 0 {
+		return -1, nil
+	}
+
+	return resp.ContentLength, nil
+}
+
+func (m *Manager) fetchChunkManifest(ctx context.Context, url string) (*chunkManifest, error) {
+	req, err := http.NewRequest(http.MethodGet, url+m.options.ChunkManifestSuffix, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download: %v", err)
+	}
+	req.Header.Set("User-Agent", m.options.UserAgent)
+
+	resp, err := m.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, transient(ctx, fmt.Errorf("download: chunk manifest: %v", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download: %s: %s", url+m.options.ChunkManifestSuffix, resp.Status)
+	}
+
+	manifest := new(chunkManifest)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("download: chunk manifest: %v", err)
+	}
+
+	if manifest.ChunkSize <= 0 {
+		return nil, errors.New("download: chunk manifest: invalid chunk size")
+	}
+
+	return manifest, nil
+}
+
+// downloadChunked downloads url to destination over parallel range
+// requests. It returns false if the server does not support them, so the
+// caller falls back to a single stream.
+func (m *Manager) downloadChunked(ctx context.Context, url, destination string) (bool, error) {
+	size, err := m.probe(ctx, url)
+	if err != nil || size < 0 {
+		return err != nil, err
+	}
+
+	chunkSize := m.options.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	var manifest *chunkManifest
+	if m.options.ChunkManifestSuffix != "" {
+		if manifest, err = m.fetchChunkManifest(ctx, url); err != nil {
+			return true, err
+		}
+		chunkSize = manifest.ChunkSize
+	}
+
+	chunks := int((size + chunkSize - 1) / chunkSize)
+	if manifest != nil && len(manifest.SHA256) != chunks {
+		return true, fmt.Errorf("download: chunk manifest lists %d chunks, expected %d", len(manifest.SHA256), chunks)
+	}
+
+	tmp, err := os.Create(partFile(destination))
+	if err != nil {
+		return true, fmt.Errorf("download: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Truncate(size); err != nil {
+		tmp.Close()
+		return true, fmt.Errorf("download: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	connections := m.options.Connections
+	limit := func(t time.Time) int64 {
+		return connectionLimit(m.options.rateLimit(t), connections)
+	}
+
+	jobs := make(chan int)
+	errs := make(chan error, connections)
+	var wg sync.WaitGroup
+
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for chunk := range jobs {
+				start := int64(chunk) * chunkSize
+				end := start + chunkSize
+				if end > size {
+					end = size
+				}
+
+				expected := ""
+				if manifest != nil {
+					expected = manifest.SHA256[chunk]
+				}
+
+				if err := m.fetchChunkWithRetries(ctx, url, tmp, start, end, expected, limit); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for chunk := 0; chunk < chunks; chunk++ {
+		select {
+		case jobs <- chunk:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := tmp.Close(); err != nil {
+		return true, fmt.Errorf("download: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		return true, err
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		return true, fmt.Errorf("download: %v", err)
+	}
+
+	return true, m.finish(ctx, url, tmp.Name(), destination)
+}
+
+// fetchChunkWithRetries fetches a chunk, trying again a few times after
+// transient errors and checksum mismatches.
+func (m *Manager) fetchChunkWithRetries(ctx context.Context, url string, f *os.File, start, end int64, expected string, limit func(time.Time) int64) error {
+	var err error
+
+	for attempt := 0; attempt < chunkRetries; attempt++ {
+		if err = m.fetchChunk(ctx, url, f, start, end, expected, limit); err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		var t *transientError
+		if !errors.As(err, &t) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (m *Manager) fetchChunk(ctx context.Context, url string, f *os.File, start, end int64, expected string, limit func(time.Time) int64) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("download: %v", err)
+	}
+	req.Header.Set("User-Agent", m.options.UserAgent)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	resp, err := m.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return transient(ctx, fmt.Errorf("download: %v", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		err := fmt.Errorf("download: %s: range %d-%d: %s", url, start, end-1, resp.Status)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return transient(ctx, err)
+		}
+		return err
+	}
+
+	body := &throttledReader{
+		ctx:    ctx,
+		reader: io.LimitReader(resp.Body, end-start),
+		limit:  limit,
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(&offsetWriter{f, start}, hash), body)
+	if err != nil {
+		return transient(ctx, fmt.Errorf("download: %s: %v", url, err))
+	}
+
+	if n != end-start {
+		return transient(ctx, fmt.Errorf("download: %s: range %d-%d: short read", url, start, end-1))
+	}
+
+	if expected != "" && hex.EncodeToString(hash.Sum(nil)) != expected {
+		return transient(ctx, fmt.Errorf("download: %s: range %d-%d: checksum mismatch", url, start, end-1))
+	}
+
+	return nil
+}
+
+// connectionLimit splits the rate limit total between connections. Each
+// connection gets at least one byte per second, as zero means unlimited.
+func connectionLimit(total int64, connections int) int64 {
+	if total <= 0 || connections <= 1 {
+		return total
+	}
+
+	if limit := total / int64(connections); limit > 0 {
+		return limit
+	}
+
+	return 1
+}
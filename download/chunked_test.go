@@ -0,0 +1,24 @@
+package download
+
+import "testing"
+
+func TestConnectionLimit(t *testing.T) {
+	tests := []struct {
+		total       int64
+		connections int
+		want        int64
+	}{
+		{0, 4, 0},
+		{-1, 4, -1},
+		{1000, 1, 1000},
+		{1000, 4, 250},
+		{3, 4, 1},
+		{1, 8, 1},
+	}
+
+	for _, tt := range tests {
+		if got := connectionLimit(tt.total, tt.connections); got != tt.want {
+			t.Errorf("connectionLimit(%d, %d) = %d, want %d", tt.total, tt.connections, got, tt.want)
+		}
+	}
+}
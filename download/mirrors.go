@@ -0,0 +1,134 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+)
+
+// Mirror is one of several URLs serving the same bundle.
+type Mirror struct {
+	URL string
+	// Weight makes MirrorOrder pick mirrors at random, in proportion to
+	// their weights. Mirrors are tried in the given order if all weights
+	// are zero.
+	Weight int
+}
+
+// MirrorFailedEvent is published when a mirror failed and the next one
+// is tried.
+type MirrorFailedEvent struct {
+	URL string
+	Err error
+}
+
+// EventType implements rauc.Event.
+func (e MirrorFailedEvent) EventType() string {
+	return "download.mirror-failed"
+}
+
+// MirrorOrder returns the URLs of mirrors in the order they should be
+// tried.
+func MirrorOrder(mirrors []Mirror) []string {
+	weighted := false
+	for _, m := range mirrors {
+		weighted = weighted || m.Weight > 0
+	}
+
+	urls := make([]string, len(mirrors))
+	if !weighted {
+		for i, m := range mirrors {
+			urls[i] = m.URL
+		}
+		return urls
+	}
+
+	// Weighted random sampling without replacement: sorting by
+	// u^(1/weight) puts each mirror first with a probability proportional
+	// to its weight. Mirrors without weight go last.
+	keys := make([]float64, len(mirrors))
+	order := make([]int, len(mirrors))
+	for i, m := range mirrors {
+		order[i] = i
+		if m.Weight > 0 {
+			keys[i] = math.Pow(rand.Float64(), 1/float64(m.Weight))
+		} else {
+			keys[i] = -1
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return keys[order[a]] > keys[order[b]]
+	})
+
+	for i, j := range order {
+		urls[i] = mirrors[j].URL
+	}
+
+	return urls
+}
+
+// DownloadMirrors downloads the bundle from the first mirror that works,
+// see MirrorOrder, and returns the URL of that mirror.
+func (m *Manager) DownloadMirrors(ctx context.Context, mirrors []Mirror, destination string) (string, error) {
+	if len(mirrors) == 0 {
+		return "", errors.New("download: no mirrors")
+	}
+
+	var err error
+	for _, url := range MirrorOrder(mirrors) {
+		if err = m.Download(ctx, url, destination); err == nil {
+			return url, nil
+		}
+
+		if ctx.Err() != nil {
+			return "", err
+		}
+
+		if m.options.Events != nil {
+			m.options.Events.Publish(MirrorFailedEvent{URL: url, Err: err})
+		}
+	}
+
+	return "", fmt.Errorf("download: all mirrors failed, last error: %v", err)
+}
+
+// Reachable returns the URL of the first mirror that answers a HEAD
+// request successfully, for installs the daemon streams.
+func (m *Manager) Reachable(ctx context.Context, mirrors []Mirror) (string, error) {
+	if len(mirrors) == 0 {
+		return "", errors.New("download: no mirrors")
+	}
+
+	var err error
+	for _, url := range MirrorOrder(mirrors) {
+		var req *http.Request
+		if req, err = http.NewRequest(http.MethodHead, url, nil); err != nil {
+			continue
+		}
+		req.Header.Set("User-Agent", m.options.UserAgent)
+
+		var resp *http.Response
+		if resp, err = m.client.Do(req.WithContext(ctx)); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return url, nil
+			}
+			err = fmt.Errorf("%s: %s", url, resp.Status)
+		}
+
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("download: %v", err)
+		}
+
+		if m.options.Events != nil {
+			m.options.Events.Publish(MirrorFailedEvent{URL: url, Err: err})
+		}
+	}
+
+	return "", fmt.Errorf("download: no mirror reachable, last error: %v", err)
+}
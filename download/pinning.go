@@ -0,0 +1,85 @@
+package download
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// errPinMismatch is returned from TLS handshakes when no certificate of the
+// server's chain matches a configured pin.
+var errPinMismatch = errors.New("download: no certificate matches the configured pins")
+
+// pinVerifier returns a tls.Config.VerifyPeerCertificate callback that
+// accepts a connection if a certificate of a verified chain matches one of
+// the pins. It runs after the regular CA validation, it does not replace
+// it. Certificates the server sent but that are not part of a verified
+// chain are ignored, so that a server cannot pass by appending a pinned
+// certificate. Without verified chains, as with InsecureSkipVerify, only
+// the leaf certificate is checked.
+//
+// spkiPins are base64 encoded SHA-256 hashes of a certificate's
+// SubjectPublicKeyInfo, as in HPKP's "pin-sha256". certPins are
+// hex encoded SHA-256 fingerprints of the DER encoded certificate.
+func pinVerifier(spkiPins, certPins []string) func([][]byte, [][]*x509.Certificate) error {
+	spki := make(map[string]bool)
+	for _, p := range spkiPins {
+		spki[strings.TrimPrefix(p, "sha256/")] = true
+	}
+
+	fingerprints := make(map[string]bool)
+	for _, p := range certPins {
+		fingerprints[strings.ToLower(strings.Replace(p, ":", "", -1))] = true
+	}
+
+	matches := func(cert *x509.Certificate) bool {
+		fingerprint := sha256.Sum256(cert.Raw)
+		if fingerprints[hex.EncodeToString(fingerprint[:])] {
+			return true
+		}
+
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		return spki[base64.StdEncoding.EncodeToString(hash[:])]
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 {
+			if len(rawCerts) == 0 {
+				return errPinMismatch
+			}
+
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil || !matches(leaf) {
+				return errPinMismatch
+			}
+
+			return nil
+		}
+
+		for _, chain := range verifiedChains {
+			for _, cert := range chain {
+				if matches(cert) {
+					return nil
+				}
+			}
+		}
+
+		return errPinMismatch
+	}
+}
+
+// tlsConfig returns the TLS configuration for the given options, or nil if
+// the defaults apply.
+func tlsConfig(options *Options) *tls.Config {
+	if len(options.PinnedSPKI) == 0 && len(options.PinnedCertificates) == 0 {
+		return nil
+	}
+
+	return &tls.Config{
+		VerifyPeerCertificate: pinVerifier(options.PinnedSPKI, options.PinnedCertificates),
+	}
+}
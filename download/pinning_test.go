@@ -0,0 +1,135 @@
+package download
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert returns a certificate signed by parent, or a self-signed CA
+// if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+func certPin(c *testCert) string {
+	sum := sha256.Sum256(c.cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func spkiPin(c *testCert) string {
+	sum := sha256.Sum256(c.cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestPinVerifier(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	leaf := newTestCert(t, "leaf", ca)
+	pinnedCA := newTestCert(t, "pinned", nil)
+
+	tests := []struct {
+		name     string
+		chain    []*testCert
+		spki     []string
+		certs    []string
+		insecure bool
+		ok       bool
+	}{
+		{name: "leaf certificate", chain: []*testCert{leaf}, certs: []string{certPin(leaf)}, ok: true},
+		{name: "CA certificate", chain: []*testCert{leaf}, certs: []string{certPin(ca)}, ok: true},
+		{name: "leaf SPKI", chain: []*testCert{leaf}, spki: []string{spkiPin(leaf)}, ok: true},
+		{name: "CA SPKI", chain: []*testCert{leaf, ca}, spki: []string{spkiPin(ca)}, ok: true},
+		{name: "no match", chain: []*testCert{leaf}, certs: []string{certPin(pinnedCA)}},
+		{name: "appended certificate", chain: []*testCert{leaf, ca, pinnedCA}, certs: []string{certPin(pinnedCA)}},
+		{name: "appended SPKI", chain: []*testCert{leaf, pinnedCA}, spki: []string{spkiPin(pinnedCA)}},
+		{name: "insecure leaf", chain: []*testCert{leaf}, certs: []string{certPin(leaf)}, insecure: true, ok: true},
+		{name: "insecure appended", chain: []*testCert{leaf, pinnedCA}, certs: []string{certPin(pinnedCA)}, insecure: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverCert := tls.Certificate{PrivateKey: leaf.key, Leaf: leaf.cert}
+			for _, c := range tt.chain {
+				serverCert.Certificate = append(serverCert.Certificate, c.cert.Raw)
+			}
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}}
+			server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+			server.StartTLS()
+			defer server.Close()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.cert)
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:               roots,
+				InsecureSkipVerify:    tt.insecure,
+				VerifyPeerCertificate: pinVerifier(tt.spki, tt.certs),
+			}}}
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if ok := err == nil; ok != tt.ok {
+				t.Fatalf("got error %v, want success %v", err, tt.ok)
+			}
+		})
+	}
+}
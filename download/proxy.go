@@ -0,0 +1,36 @@
+package download
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyFunc returns the proxy selection function for an http.Transport.
+//
+// The RAUC daemon has no D-Bus option to configure a proxy for streaming
+// installs; it uses the proxy environment variables of rauc.service.
+func proxyFunc(proxy string, noProxy []string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("download: invalid proxy URL %q", proxy)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+
+		for _, n := range noProxy {
+			n = strings.ToLower(strings.TrimPrefix(n, "."))
+			if n == "*" || host == n || strings.HasSuffix(host, "."+n) {
+				return nil, nil
+			}
+		}
+
+		return proxyURL, nil
+	}, nil
+}
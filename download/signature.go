@@ -0,0 +1,113 @@
+package download
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrSignatureInvalid is returned when a detached signature does not
+// verify against the downloaded bundle.
+var ErrSignatureInvalid = errors.New("download: detached signature invalid")
+
+// SignatureVerifier checks a detached signature of a downloaded file. This
+// is independent of the CMS signature inside the bundle, which the daemon
+// verifies; it allows deployments to sign their distribution channel
+// separately.
+type SignatureVerifier interface {
+	// Verify checks signature against the content of the file at path.
+	Verify(path string, signature []byte) error
+}
+
+// Ed25519Verifier verifies raw 64 byte Ed25519 signatures made over the
+// SHA-256 digest of the file, so that large bundles need not be held in
+// memory.
+type Ed25519Verifier struct {
+	// PublicKeys lists the accepted signers.
+	PublicKeys []ed25519.PublicKey
+}
+
+// Verify implements SignatureVerifier.
+func (v *Ed25519Verifier) Verify(path string, signature []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	digest := h.Sum(nil)
+
+	for _, key := range v.PublicKeys {
+		if ed25519.Verify(key, digest, signature) {
+			return nil
+		}
+	}
+
+	return ErrSignatureInvalid
+}
+
+// verifyCommand runs an external verifier with the signature stored in a
+// temporary file whose path replaces "{sig}" in args.
+func verifyCommand(name string, args []string, signature []byte) error {
+	f, err := ioutil.TempFile("", "rauc-signature")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(signature); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	for i := range args {
+		args[i] = strings.Replace(args[i], "{sig}", f.Name(), -1)
+	}
+
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrSignatureInvalid, name, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// CMSVerifier verifies detached CMS signatures (DER encoded) with openssl.
+type CMSVerifier struct {
+	// CAFile holds the trusted certificates in PEM format.
+	CAFile string
+}
+
+// Verify implements SignatureVerifier.
+func (v *CMSVerifier) Verify(path string, signature []byte) error {
+	return verifyCommand("openssl", []string{
+		"cms", "-verify", "-binary",
+		"-inform", "DER", "-in", "{sig}",
+		"-content", path,
+		"-CAfile", v.CAFile,
+		"-purpose", "any",
+		"-out", os.DevNull,
+	}, signature)
+}
+
+// OpenPGPVerifier verifies detached OpenPGP signatures (.sig or .asc)
+// with gpgv.
+type OpenPGPVerifier struct {
+	// Keyring is the keyring file with the accepted public keys.
+	Keyring string
+}
+
+// Verify implements SignatureVerifier.
+func (v *OpenPGPVerifier) Verify(path string, signature []byte) error {
+	return verifyCommand("gpgv", []string{"--keyring", v.Keyring, "{sig}", path}, signature)
+}
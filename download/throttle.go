@@ -0,0 +1,93 @@
+package download
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// RateProfile limits the download rate during a window of the day, e.g. to
+// allow full speed at night only.
+type RateProfile struct {
+	// From and To are offsets since local midnight. A window with To
+	// before From wraps around midnight.
+	From time.Duration
+	To   time.Duration
+	// BytesPerSecond is the limit within the window. Zero means unlimited.
+	BytesPerSecond int64
+}
+
+func (r RateProfile) contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	if r.From <= r.To {
+		return offset >= r.From && offset < r.To
+	}
+
+	return offset >= r.From || offset < r.To
+}
+
+// rateLimit returns the limit in bytes per second that applies at time t.
+// The first matching profile wins, the global limit applies otherwise.
+func (o *Options) rateLimit(t time.Time) int64 {
+	for _, p := range o.RateProfiles {
+		if p.contains(t) {
+			return p.BytesPerSecond
+		}
+	}
+
+	return o.RateLimit
+}
+
+// throttledReader is a token bucket rate limiter around an io.Reader. The
+// limit is re-evaluated on every read, so profile changes take effect
+// during long downloads.
+type throttledReader struct {
+	ctx    context.Context
+	reader io.Reader
+	limit  func(time.Time) int64
+	tokens float64
+	last   time.Time
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	now := time.Now()
+	limit := t.limit(now)
+	if limit <= 0 {
+		t.last = time.Time{}
+		return t.reader.Read(p)
+	}
+
+	if int64(len(p)) > limit {
+		p = p[:limit]
+	}
+
+	if t.last.IsZero() {
+		t.tokens = 0
+	} else {
+		t.tokens += now.Sub(t.last).Seconds() * float64(limit)
+		if t.tokens > float64(limit) {
+			t.tokens = float64(limit)
+		}
+	}
+	t.last = now
+
+	if missing := float64(len(p)) - t.tokens; missing > 0 {
+		timer := time.NewTimer(time.Duration(missing / float64(limit) * float64(time.Second)))
+		select {
+		case <-t.ctx.Done():
+			timer.Stop()
+			return 0, t.ctx.Err()
+		case <-timer.C:
+		}
+
+		t.tokens += missing
+		t.last = time.Now()
+	}
+
+	n, err := t.reader.Read(p)
+	t.tokens -= float64(n)
+
+	return n, err
+}
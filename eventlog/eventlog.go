@@ -0,0 +1,187 @@
+// Package eventlog writes the events published on a rauc.EventBus to
+// zerolog or log/slog loggers, with the same messages and fields for
+// both.
+package eventlog
+
+import (
+	"strings"
+
+	"github.com/holoplot/go-rauc/agent"
+	"github.com/holoplot/go-rauc/bootloader"
+	"github.com/holoplot/go-rauc/download"
+	"github.com/holoplot/go-rauc/heartbeat"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const subscriptionSize = 64
+
+type level int
+
+const (
+	levelDebug level = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// record is the logger independent form of an event.
+type record struct {
+	level   level
+	message string
+	fields  []field
+}
+
+func (r *record) add(key string, value interface{}) *record {
+	r.fields = append(r.fields, field{key, value})
+	return r
+}
+
+// result returns levelError if err is not nil and ok otherwise.
+func result(err error, ok level) level {
+	if err != nil {
+		return levelError
+	}
+
+	return ok
+}
+
+// recordOf translates e into a record. Every record has an "event" field
+// holding e.EventType().
+func recordOf(e rauc.Event) record {
+	r := record{level: levelDebug, message: "Event"}
+	r.add("event", e.EventType())
+
+	switch e := e.(type) {
+	case rauc.InstallStartedEvent:
+		r.level, r.message = levelInfo, "Installation started"
+		r.add("bundle", e.Bundle)
+	case rauc.InstallCompletedEvent:
+		r.level, r.message = result(e.Err, levelInfo), "Installation completed"
+		if e.Err != nil {
+			r.message = "Installation failed"
+		}
+		r.add("bundle", e.Bundle).add("error", e.Err)
+	case rauc.InstallStalledEvent:
+		r.level, r.message = levelWarn, "Installation stalled"
+		r.add("bundle", e.Err.Bundle).
+			add("percentage", e.Err.Percentage).
+			add("step", e.Err.Message).
+			add("since", e.Err.Since)
+	case rauc.InstallDetachedEvent:
+		r.level, r.message = levelInfo, "Detached from installation"
+		r.add("bundle", e.Bundle)
+	case rauc.DecodeWarningEvent:
+		r.level, r.message = levelWarn, "Unexpected value from RAUC daemon"
+		r.add("name", e.Warning.Name).
+			add("expected", e.Warning.Expected).
+			add("got", e.Warning.Got)
+	case rauc.RebootRequiredEvent:
+		r.level, r.message = levelInfo, "Reboot required"
+		r.add("reason", e.Reason)
+	case rauc.ReconnectedEvent:
+		r.level, r.message = levelInfo, "Reconnected to D-Bus"
+		r.add("attempts", e.Attempts).add("downtime", e.Downtime)
+	case rauc.ThermalEvent:
+		r.level, r.message = levelInfo, "Temperature back to normal"
+		if e.Overheat {
+			r.level, r.message = levelWarn, "Temperature too high"
+		}
+		r.add("zone", e.Zone).add("celsius", e.Celsius)
+	case agent.UpdateAvailableEvent:
+		r.level, r.message = levelInfo, "Update available"
+		r.add("bundle", e.Bundle).add("version", e.Version)
+	case agent.CheckFailedEvent:
+		r.level, r.message = levelError, "Update check failed"
+		r.add("error", e.Err)
+	case agent.FacadeRequestEvent:
+		r.level, r.message = levelInfo, "D-Bus request"
+		if e.Err != nil {
+			r.level = levelWarn
+		}
+		r.add("method", e.Method).
+			add("sender", e.Sender).
+			add("uid", e.UID).
+			add("pid", e.PID).
+			add("error", e.Err)
+	case agent.RolloutDeferredEvent:
+		r.level, r.message = levelInfo, "Update deferred by rollout"
+		r.add("bundle", e.Bundle).
+			add("version", e.Version).
+			add("bucket", e.Bucket).
+			add("percentage", e.Percentage)
+		if !e.NotBefore.IsZero() {
+			r.add("not_before", e.NotBefore)
+		}
+	case agent.InstallStatsEvent:
+		r.message = "Installation statistics"
+		r.add("bundle", e.Stats.Bundle).
+			add("version", e.Stats.Version).
+			add("download", e.Stats.DownloadTime).
+			add("queue", e.Stats.QueueTime).
+			add("install", e.Stats.InstallTime).
+			add("bytes", e.Stats.Bytes).
+			add("error", e.Stats.Err)
+		if n := len(e.Stats.Progress); n > 0 && e.Stats.Err != nil {
+			r.add("step", e.Stats.Progress[n-1].Message)
+		}
+	case agent.CommandFailedEvent:
+		r.level, r.message = levelWarn, "Completion command failed"
+		r.add("command", strings.Join(e.Command, " ")).add("error", e.Err)
+	case agent.ReportFailedEvent:
+		r.level, r.message = levelWarn, "Cannot write installation report"
+		r.add("error", e.Err)
+	case download.MirrorFailedEvent:
+		r.level, r.message = levelWarn, "Mirror failed"
+		r.add("url", e.URL).add("error", e.Err)
+	case download.StartedEvent:
+		r.level, r.message = levelInfo, "Download started"
+		r.add("url", e.URL)
+	case download.CompletedEvent:
+		r.level, r.message = result(e.Err, levelInfo), "Download completed"
+		if e.Err != nil {
+			r.message = "Download failed"
+		}
+		r.add("url", e.URL).add("error", e.Err)
+	case heartbeat.ReportFailedEvent:
+		r.level, r.message = levelWarn, "Heartbeat report failed"
+		r.add("error", e.Err)
+	case bootloader.LowAttemptsEvent:
+		r.level, r.message = levelWarn, "Few boot attempts left"
+		r.add("bootname", e.Attempts.Bootname).
+			add("remaining", e.Attempts.Remaining).
+			add("max", e.Attempts.Max)
+	case bootloader.AttemptsReadErrorEvent:
+		r.level, r.message = levelError, "Cannot read boot attempts"
+		r.add("error", e.Err)
+	}
+
+	// Drop nil errors, so that successful events carry no error field.
+	fields := r.fields[:0]
+	for _, f := range r.fields {
+		if f.value != nil {
+			fields = append(fields, f)
+		}
+	}
+	r.fields = fields
+
+	return r
+}
+
+// forward calls log for each event published on bus until the returned
+// function is called.
+func forward(bus *rauc.EventBus, log func(record)) func() {
+	events, cancel := bus.Subscribe(subscriptionSize)
+
+	go func() {
+		for e := range events {
+			log(recordOf(e))
+		}
+	}()
+
+	return cancel
+}
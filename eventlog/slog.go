@@ -0,0 +1,31 @@
+//go:build go1.21
+// +build go1.21
+
+package eventlog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+var slogLevels = map[level]slog.Level{
+	levelDebug: slog.LevelDebug,
+	levelInfo:  slog.LevelInfo,
+	levelWarn:  slog.LevelWarn,
+	levelError: slog.LevelError,
+}
+
+// Slog writes all events published on bus to logger until the returned
+// function is called.
+func Slog(bus *rauc.EventBus, logger *slog.Logger) func() {
+	return forward(bus, func(r record) {
+		attrs := make([]slog.Attr, 0, len(r.fields))
+		for _, f := range r.fields {
+			attrs = append(attrs, slog.Any(f.key, f.value))
+		}
+
+		logger.LogAttrs(context.Background(), slogLevels[r.level], r.message, attrs...)
+	})
+}
@@ -0,0 +1,40 @@
+package eventlog
+
+import (
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/rs/zerolog"
+)
+
+var zerologLevels = map[level]zerolog.Level{
+	levelDebug: zerolog.DebugLevel,
+	levelInfo:  zerolog.InfoLevel,
+	levelWarn:  zerolog.WarnLevel,
+	levelError: zerolog.ErrorLevel,
+}
+
+// Zerolog writes all events published on bus to logger until the returned
+// function is called.
+func Zerolog(bus *rauc.EventBus, logger zerolog.Logger) func() {
+	return forward(bus, func(r record) {
+		e := logger.WithLevel(zerologLevels[r.level])
+
+		for _, f := range r.fields {
+			switch v := f.value.(type) {
+			case string:
+				e = e.Str(f.key, v)
+			case error:
+				e = e.AnErr(f.key, v)
+			case time.Duration:
+				e = e.Dur(f.key, v)
+			case time.Time:
+				e = e.Time(f.key, v)
+			default:
+				e = e.Interface(f.key, v)
+			}
+		}
+
+		e.Msg(r.message)
+	})
+}
@@ -1,10 +1,11 @@
 module github.com/holoplot/go-rauc
 
-go 1.12
+go 1.13
 
 require (
 	github.com/godbus/dbus/v5 v5.1.0
 	github.com/mattn/go-colorable v0.1.13
 	github.com/mattn/go-isatty v0.0.19
 	github.com/rs/zerolog v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
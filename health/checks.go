@@ -0,0 +1,176 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// SystemdUnit checks that a systemd unit is active.
+type SystemdUnit struct {
+	Unit string
+}
+
+// Name implements Check.
+func (c *SystemdUnit) Name() string { return "systemd " + c.Unit }
+
+// Run implements Check.
+func (c *SystemdUnit) Run(ctx context.Context) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+
+	manager := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
+
+	var path dbus.ObjectPath
+	if err := manager.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.LoadUnit", 0, c.Unit).Store(&path); err != nil {
+		return err
+	}
+
+	v, err := conn.Object("org.freedesktop.systemd1", path).GetProperty("org.freedesktop.systemd1.Unit.ActiveState")
+	if err != nil {
+		return err
+	}
+
+	if state, _ := v.Value().(string); state != "active" {
+		return fmt.Errorf("unit is %s", state)
+	}
+
+	return nil
+}
+
+// HTTP checks that a GET request to URL returns Status, or any 2xx status
+// if Status is 0.
+type HTTP struct {
+	URL    string
+	Status int
+	// Insecure skips the verification of the server certificate.
+	Insecure bool
+}
+
+// Name implements Check.
+func (c *HTTP) Name() string { return "http " + c.URL }
+
+// Run implements Check.
+func (c *HTTP) Run(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	client := http.DefaultClient
+	if c.Insecure {
+		client = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if c.Status != 0 && resp.StatusCode != c.Status {
+		return fmt.Errorf("status %d, expected %d", resp.StatusCode, c.Status)
+	}
+
+	if c.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// TCP checks that a TCP connection to Address can be established.
+type TCP struct {
+	Address string
+}
+
+// Name implements Check.
+func (c *TCP) Name() string { return "tcp " + c.Address }
+
+// Run implements Check.
+func (c *TCP) Run(ctx context.Context) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// Process checks that a process with the command name Command runs, as
+// shown in /proc/<pid>/comm.
+type Process struct {
+	Command string
+}
+
+// ProcDir is where Process looks for processes.
+var ProcDir = "/proc"
+
+// Name implements Check.
+func (c *Process) Name() string { return "process " + c.Command }
+
+// Run implements Check.
+func (c *Process) Run(ctx context.Context) error {
+	comms, err := filepath.Glob(filepath.Join(ProcDir, "[0-9]*", "comm"))
+	if err != nil {
+		return err
+	}
+
+	for _, comm := range comms {
+		b, err := ioutil.ReadFile(comm)
+		if err == nil && strings.TrimSpace(string(b)) == c.Command {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no process %q", c.Command)
+}
+
+// DiskSpace checks that the filesystem holding Path has at least
+// MinFreeBytes and MinFreePercent available to unprivileged users.
+type DiskSpace struct {
+	Path           string
+	MinFreeBytes   uint64
+	MinFreePercent float64
+}
+
+// Name implements Check.
+func (c *DiskSpace) Name() string { return "disk " + c.Path }
+
+// Run implements Check.
+func (c *DiskSpace) Run(ctx context.Context) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(c.Path, &st); err != nil {
+		return err
+	}
+
+	free := st.Bavail * uint64(st.Bsize)
+	if free < c.MinFreeBytes {
+		return fmt.Errorf("%d bytes free, need %d", free, c.MinFreeBytes)
+	}
+
+	if st.Blocks > 0 {
+		percent := float64(st.Bavail) * 100 / float64(st.Blocks)
+		if percent < c.MinFreePercent {
+			return fmt.Errorf("%.1f%% free, need %.1f%%", percent, c.MinFreePercent)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package health
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Check types of CheckConfig.
+const (
+	TypeSystemd = "systemd"
+	TypeHTTP    = "http"
+	TypeTCP     = "tcp"
+	TypeProcess = "process"
+	TypeDisk    = "disk"
+)
+
+// duration is a time.Duration written as a string such as "90s".
+type duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", value.Line, err)
+	}
+
+	*d = duration(v)
+	return nil
+}
+
+// CheckConfig configures one of the checks of this package. Type selects
+// the check and the keys it uses.
+type CheckConfig struct {
+	Type string `yaml:"type"`
+	// Unit is the unit of a TypeSystemd check.
+	Unit string `yaml:"unit,omitempty"`
+	// URL, Status and Insecure configure a TypeHTTP check.
+	URL      string `yaml:"url,omitempty"`
+	Status   int    `yaml:"status,omitempty"`
+	Insecure bool   `yaml:"insecure,omitempty"`
+	// Address is the host:port of a TypeTCP check.
+	Address string `yaml:"address,omitempty"`
+	// Command is the process name of a TypeProcess check.
+	Command string `yaml:"command,omitempty"`
+	// Path, MinFreeMB and MinFreePercent configure a TypeDisk check.
+	Path           string  `yaml:"path,omitempty"`
+	MinFreeMB      uint64  `yaml:"min_free_mb,omitempty"`
+	MinFreePercent float64 `yaml:"min_free_percent,omitempty"`
+}
+
+// Config is a set of checks and how to run them, as in
+//
+//	timeout: 5m
+//	interval: 10s
+//	mark_bad: true
+//	checks:
+//	  - type: systemd
+//	    unit: app.service
+//	  - type: http
+//	    url: http://localhost:8080/health
+//	  - type: tcp
+//	    address: localhost:1883
+//	  - type: process
+//	    command: mosquitto
+//	  - type: disk
+//	    path: /data
+//	    min_free_mb: 100
+type Config struct {
+	Timeout  duration      `yaml:"timeout,omitempty"`
+	Interval duration      `yaml:"interval,omitempty"`
+	MarkBad  bool          `yaml:"mark_bad,omitempty"`
+	Checks   []CheckConfig `yaml:"checks"`
+}
+
+// Check returns the check described by c.
+func (c CheckConfig) Check() (Check, error) {
+	var missing string
+
+	switch c.Type {
+	case TypeSystemd:
+		if c.Unit != "" {
+			return &SystemdUnit{Unit: c.Unit}, nil
+		}
+		missing = "unit"
+	case TypeHTTP:
+		if c.URL != "" {
+			return &HTTP{URL: c.URL, Status: c.Status, Insecure: c.Insecure}, nil
+		}
+		missing = "url"
+	case TypeTCP:
+		if c.Address != "" {
+			return &TCP{Address: c.Address}, nil
+		}
+		missing = "address"
+	case TypeProcess:
+		if c.Command != "" {
+			return &Process{Command: c.Command}, nil
+		}
+		missing = "command"
+	case TypeDisk:
+		if c.Path != "" {
+			return &DiskSpace{
+				Path:           c.Path,
+				MinFreeBytes:   c.MinFreeMB << 20,
+				MinFreePercent: c.MinFreePercent,
+			}, nil
+		}
+		missing = "path"
+	default:
+		return nil, fmt.Errorf("health: unknown check type %q", c.Type)
+	}
+
+	return nil, fmt.Errorf("health: %s check needs %s", c.Type, missing)
+}
+
+// ConfirmOptions returns the options for Confirm described by c.
+func (c *Config) ConfirmOptions() (ConfirmOptions, error) {
+	options := ConfirmOptions{
+		Timeout:  time.Duration(c.Timeout),
+		Interval: time.Duration(c.Interval),
+		MarkBad:  c.MarkBad,
+	}
+
+	for _, cc := range c.Checks {
+		check, err := cc.Check()
+		if err != nil {
+			return options, err
+		}
+
+		options.Checks = append(options.Checks, check)
+	}
+
+	return options, nil
+}
+
+// Parse reads a configuration in YAML or JSON format. Unknown keys are
+// rejected, so that typos do not go unnoticed.
+func Parse(r io.Reader) (*Config, error) {
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+
+	c := new(Config)
+	if err := d.Decode(c); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("health: %v", err)
+	}
+
+	// Catch configuration errors at load time.
+	if _, err := c.ConfirmOptions(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Load reads a configuration from a file.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("health: %v", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
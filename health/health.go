@@ -0,0 +1,133 @@
+// Package health implements the checks that decide whether an update is
+// confirmed after booting into it, and ready-made checks for common
+// conditions. Checks can be configured in a YAML or JSON document, see
+// Parse.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+// ErrUnhealthy is returned by Confirm when the checks kept failing.
+var ErrUnhealthy = errors.New("health: checks failed")
+
+// Check is a single health check.
+type Check interface {
+	// Name describes the check in results, e.g. "tcp localhost:1883".
+	Name() string
+	// Run returns nil if the condition is met.
+	Run(ctx context.Context) error
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name string `json:"name" yaml:"name"`
+	// Err is nil if the check passed.
+	Err      error         `json:"-" yaml:"-"`
+	Error    string        `json:"error,omitempty" yaml:"error,omitempty"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+}
+
+// Run runs all checks once, in order.
+func Run(ctx context.Context, checks []Check) (results []Result, ok bool) {
+	ok = true
+
+	for _, c := range checks {
+		start := time.Now()
+		err := c.Run(ctx)
+
+		r := Result{
+			Name:     c.Name(),
+			Err:      err,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			r.Error = err.Error()
+			ok = false
+		}
+
+		results = append(results, r)
+	}
+
+	return results, ok
+}
+
+// ConfirmOptions contains options for the Confirm function
+type ConfirmOptions struct {
+	Checks []Check
+	// Timeout is how long failing checks are retried. Defaults to
+	// 5 minutes.
+	Timeout time.Duration
+	// Interval is the time between two rounds of checks. Defaults to
+	// 10 seconds.
+	Interval time.Duration
+	// MarkBad marks the booted slot bad if the checks fail, so that the
+	// bootloader falls back on the next boot.
+	MarkBad bool
+}
+
+// Confirm runs the checks if the system booted into an update that is
+// waiting for confirmation, see rauc.CheckActivation. Once all checks
+// pass, it marks the booted slot good and clears the pending activation.
+// If they still fail after options.Timeout, it returns ErrUnhealthy.
+// Nothing is checked in other activation states.
+func Confirm(ctx context.Context, b rauc.Backend, options ConfirmOptions) (rauc.ActivationState, []Result, error) {
+	state, _, err := rauc.CheckActivation(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if state != rauc.ActivationFirstBoot && state != rauc.ActivationRebooted {
+		return state, nil, nil
+	}
+
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+
+	interval := options.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		results, ok := Run(ctx, options.Checks)
+		if ok {
+			if _, err := rauc.MarkGood(b, "booted"); err != nil {
+				return state, results, err
+			}
+
+			return state, results, rauc.ClearPendingActivation()
+		}
+
+		select {
+		case <-ctx.Done():
+			if options.MarkBad {
+				if _, err := rauc.MarkBad(b, "booted"); err != nil {
+					return state, results, err
+				}
+			}
+
+			return state, results, ErrUnhealthy
+		case <-time.After(interval):
+		}
+	}
+}
+
+// String implements fmt.Stringer.
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s: %v", r.Name, r.Err)
+	}
+
+	return r.Name + ": ok"
+}
@@ -0,0 +1,213 @@
+// Package heartbeat periodically reports the state of a device to a fleet
+// backend.
+package heartbeat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/holoplot/go-rauc/agent"
+	"github.com/holoplot/go-rauc/internal/backoff"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const (
+	defaultInterval     = 15 * time.Minute
+	defaultRetryInitial = 30 * time.Second
+	defaultTimeout      = 30 * time.Second
+	defaultJitter       = 0.1
+)
+
+// Options contains options for the ReporterNew function
+type Options struct {
+	// URL is the endpoint the reports are POSTed to.
+	URL string
+	// Interval between two reports. Defaults to 15 minutes. Failed reports
+	// are retried sooner, with exponential backoff up to Interval.
+	Interval time.Duration
+	// Jitter randomizes the interval by up to this fraction. Defaults to
+	// 0.1, negative values disable it.
+	Jitter float64
+	// Timeout of a single request. Defaults to 30 seconds.
+	Timeout time.Duration
+	// Token is sent as bearer token, Username and Password as basic
+	// authentication, Header as is.
+	Token              string
+	Username, Password string
+	Header             http.Header
+	// DeviceID identifies the device. Defaults to agent.MachineID().
+	DeviceID string
+	// Agent, if not nil, adds the result of its last installation.
+	Agent *agent.Agent
+}
+
+// InstallResult is the outcome of the last installation of the agent.
+type InstallResult struct {
+	Bundle  string    `json:"bundle"`
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Report is the JSON document sent to the endpoint.
+type Report struct {
+	Device        string         `json:"device"`
+	ClientVersion string         `json:"client_version"`
+	Time          time.Time      `json:"time"`
+	Snapshot      *rauc.Snapshot `json:"snapshot,omitempty"`
+	// SnapshotError is set if the snapshot could not be taken.
+	SnapshotError string         `json:"snapshot_error,omitempty"`
+	LastInstall   *InstallResult `json:"last_install,omitempty"`
+}
+
+// ReportFailedEvent is published when a report could not be delivered.
+type ReportFailedEvent struct {
+	Err error
+}
+
+// EventType implements rauc.Event.
+func (e ReportFailedEvent) EventType() string {
+	return "heartbeat.failed"
+}
+
+// Reporter sends reports of a device.
+type Reporter struct {
+	backend rauc.Backend
+	options Options
+	client  *http.Client
+}
+
+// ReporterNew returns a newly allocated Reporter object
+func ReporterNew(backend rauc.Backend, options Options) (*Reporter, error) {
+	if options.URL == "" {
+		return nil, fmt.Errorf("heartbeat: no URL")
+	}
+
+	if options.Interval == 0 {
+		options.Interval = defaultInterval
+	}
+
+	if options.Jitter == 0 {
+		options.Jitter = defaultJitter
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = defaultTimeout
+	}
+
+	if options.DeviceID == "" {
+		id, err := agent.MachineID()
+		if err != nil {
+			return nil, err
+		}
+		options.DeviceID = id
+	}
+
+	return &Reporter{
+		backend: backend,
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+	}, nil
+}
+
+// Collect returns the current report.
+func (r *Reporter) Collect() *Report {
+	report := &Report{
+		Device:        r.options.DeviceID,
+		ClientVersion: rauc.Version(),
+		Time:          time.Now(),
+	}
+
+	snapshot, err := rauc.SnapshotOf(r.backend)
+	if err != nil {
+		report.SnapshotError = err.Error()
+	} else {
+		report.Snapshot = snapshot
+	}
+
+	if r.options.Agent != nil {
+		if stats := r.options.Agent.Statistics(); len(stats) > 0 {
+			last := stats[len(stats)-1]
+			report.LastInstall = &InstallResult{
+				Bundle:  last.Bundle,
+				Version: last.Version,
+				Time:    last.Started,
+			}
+			if last.Err != nil {
+				report.LastInstall.Error = last.Err.Error()
+			}
+		}
+	}
+
+	return report
+}
+
+// Send delivers one report.
+func (r *Reporter) Send(ctx context.Context) error {
+	body, err := json.Marshal(r.Collect())
+	if err != nil {
+		return fmt.Errorf("heartbeat: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("heartbeat: %v", err)
+	}
+
+	for k, v := range r.options.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", rauc.UserAgent())
+
+	switch {
+	case r.options.Token != "":
+		req.Header.Set("Authorization", "Bearer "+r.options.Token)
+	case r.options.Username != "":
+		req.SetBasicAuth(r.options.Username, r.options.Password)
+	}
+
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("heartbeat: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("heartbeat: %s: %s", r.options.URL, resp.Status)
+	}
+
+	return nil
+}
+
+// Run sends a report once per interval until the context is cancelled.
+// Failed reports are published as ReportFailedEvent and retried with
+// backoff.
+func (r *Reporter) Run(ctx context.Context) error {
+	retry := backoff.Backoff{
+		Initial: defaultRetryInitial,
+		Max:     r.options.Interval,
+		Jitter:  r.options.Jitter,
+	}
+
+	for {
+		delay := backoff.Jitter(r.options.Interval, r.options.Jitter)
+		if err := r.Send(ctx); err != nil {
+			r.backend.Events().Publish(ReportFailedEvent{Err: err})
+			delay = retry.Next()
+		} else {
+			retry.Reset()
+		}
+
+		if err := backoff.Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package indicator
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	gpioGetLineHandleIoctl       = 0xc16cb403
+	gpioHandleSetLineValuesIoctl = 0xc040b409
+
+	gpioHandleRequestOutput    = 1 << 1
+	gpioHandleRequestActiveLow = 1 << 2
+	gpioHandlesMax             = 64
+)
+
+// struct gpiohandle_request from linux/gpio.h
+type gpioHandleRequest struct {
+	lineOffsets   [gpioHandlesMax]uint32
+	flags         uint32
+	defaultValues [gpioHandlesMax]uint8
+	consumerLabel [32]byte
+	lines         uint32
+	fd            int32
+}
+
+// struct gpiohandle_data from linux/gpio.h
+type gpioHandleData struct {
+	values [gpioHandlesMax]uint8
+}
+
+// GPIOLine is an Output for a line of a GPIO character device such as
+// /dev/gpiochip0.
+type GPIOLine struct {
+	fd int
+}
+
+// GPIOLineOpen requests a line of a GPIO chip as output. The line is held
+// until Close is called.
+func GPIOLineOpen(chip string, offset uint32, activeLow bool) (*GPIOLine, error) {
+	f, err := os.Open(chip)
+	if err != nil {
+		return nil, fmt.Errorf("indicator: %v", err)
+	}
+	defer f.Close()
+
+	req := gpioHandleRequest{
+		flags: gpioHandleRequestOutput,
+		lines: 1,
+	}
+	req.lineOffsets[0] = offset
+	copy(req.consumerLabel[:], "go-rauc")
+
+	if activeLow {
+		req.flags |= gpioHandleRequestActiveLow
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), gpioGetLineHandleIoctl, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return nil, fmt.Errorf("indicator: cannot request line %d of %s: %v", offset, chip, errno)
+	}
+
+	return &GPIOLine{fd: int(req.fd)}, nil
+}
+
+// SetValue implements Output.
+func (l *GPIOLine) SetValue(on bool) error {
+	var data gpioHandleData
+	if on {
+		data.values[0] = 1
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(l.fd), gpioHandleSetLineValuesIoctl, uintptr(unsafe.Pointer(&data)))
+	if errno != 0 {
+		return fmt.Errorf("indicator: cannot set line value: %v", errno)
+	}
+
+	return nil
+}
+
+// Close releases the line.
+func (l *GPIOLine) Close() error {
+	return syscall.Close(l.fd)
+}
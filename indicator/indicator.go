@@ -0,0 +1,130 @@
+// Package indicator drives LEDs or GPIO lines from the update events
+// published on a rauc.EventBus, so headless devices can signal their update
+// state without custom glue code.
+package indicator
+
+import (
+	"context"
+	"time"
+
+	"github.com/holoplot/go-rauc/download"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+// State is the update state shown by an Indicator.
+type State int
+
+const (
+	StateIdle State = iota
+	StateDownloading
+	StateInstalling
+	StateSucceeded
+	StateFailed
+)
+
+// Output is a single on/off output such as an LED or a GPIO line.
+type Output interface {
+	SetValue(on bool) error
+}
+
+// Pattern describes how an output is driven in a given state. An output with
+// a zero Off duration is steadily on, one with a zero On duration is off.
+type Pattern struct {
+	On  time.Duration
+	Off time.Duration
+}
+
+// DefaultPatterns are used by an Indicator without Patterns.
+var DefaultPatterns = map[State]Pattern{
+	StateIdle:        {},
+	StateDownloading: {On: 500 * time.Millisecond, Off: 500 * time.Millisecond},
+	StateInstalling:  {On: 100 * time.Millisecond, Off: 100 * time.Millisecond},
+	StateSucceeded:   {On: time.Second},
+	StateFailed:      {On: 100 * time.Millisecond, Off: 900 * time.Millisecond},
+}
+
+// Indicator shows the update state on an Output.
+type Indicator struct {
+	Output   Output
+	Patterns map[State]Pattern
+}
+
+// stateForEvent maps an event to the state it causes.
+func stateForEvent(e rauc.Event) (State, bool) {
+	switch e := e.(type) {
+	case download.StartedEvent:
+		return StateDownloading, true
+	case download.CompletedEvent:
+		if e.Err != nil {
+			return StateFailed, true
+		}
+	case rauc.InstallStartedEvent:
+		return StateInstalling, true
+	case rauc.InstallCompletedEvent:
+		if e.Err != nil {
+			return StateFailed, true
+		}
+		return StateSucceeded, true
+	}
+
+	return StateIdle, false
+}
+
+func (i *Indicator) pattern(s State) Pattern {
+	patterns := i.Patterns
+	if patterns == nil {
+		patterns = DefaultPatterns
+	}
+
+	return patterns[s]
+}
+
+// Run subscribes to bus and drives the output until the context is
+// cancelled. The output is switched off on return.
+func (i *Indicator) Run(ctx context.Context, bus *rauc.EventBus) error {
+	events, cancel := bus.Subscribe(16)
+	defer cancel()
+	defer i.Output.SetValue(false)
+
+	pattern := i.pattern(StateIdle)
+	on := false
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case e := <-events:
+			state, ok := stateForEvent(e)
+			if !ok {
+				continue
+			}
+
+			pattern = i.pattern(state)
+			on = false
+			timer.Stop()
+			timer.Reset(0)
+
+		case <-timer.C:
+			switch {
+			case pattern.On == 0:
+				on = false
+			case pattern.Off == 0:
+				on = true
+			default:
+				on = !on
+				if on {
+					timer.Reset(pattern.On)
+				} else {
+					timer.Reset(pattern.Off)
+				}
+			}
+
+			if err := i.Output.SetValue(on); err != nil {
+				return err
+			}
+		}
+	}
+}
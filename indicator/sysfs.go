@@ -0,0 +1,56 @@
+package indicator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// LED is an Output for an LED class device in /sys/class/leds.
+type LED struct {
+	// Name of the LED, e.g. "status:green".
+	Name string
+	// MaxBrightness is written to switch the LED on. Defaults to 1.
+	MaxBrightness int
+}
+
+// SetValue implements Output.
+func (l *LED) SetValue(on bool) error {
+	brightness := 0
+	if on {
+		brightness = l.MaxBrightness
+		if brightness == 0 {
+			brightness = 1
+		}
+	}
+
+	path := filepath.Join("/sys/class/leds", l.Name, "brightness")
+	if err := ioutil.WriteFile(path, []byte(fmt.Sprintf("%d", brightness)), 0644); err != nil {
+		return fmt.Errorf("indicator: %v", err)
+	}
+
+	return nil
+}
+
+// SysfsGPIO is an Output for a GPIO line exported through the legacy
+// /sys/class/gpio interface. The line has to be exported and configured as
+// output beforehand.
+type SysfsGPIO struct {
+	Number    int
+	ActiveLow bool
+}
+
+// SetValue implements Output.
+func (g *SysfsGPIO) SetValue(on bool) error {
+	value := "0"
+	if on != g.ActiveLow {
+		value = "1"
+	}
+
+	path := fmt.Sprintf("/sys/class/gpio/gpio%d/value", g.Number)
+	if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
+		return fmt.Errorf("indicator: %v", err)
+	}
+
+	return nil
+}
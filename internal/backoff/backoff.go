@@ -0,0 +1,102 @@
+// Package backoff computes delays for polling and retry loops. Delays grow
+// exponentially and are randomized, so that many devices recovering from
+// the same outage do not retry in lockstep.
+package backoff
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	randMutex sync.Mutex
+	random    = rand.New(rand.NewSource(seed()))
+)
+
+// seed returns a random seed, so devices booting at the same time do not
+// share their jitter.
+func seed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// Jitter returns d randomized by up to fraction in either direction, e.g.
+// a fraction of 0.1 returns a value between 0.9*d and 1.1*d.
+func Jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	randMutex.Lock()
+	r := random.Float64()
+	randMutex.Unlock()
+
+	return time.Duration(float64(d) * (1 + fraction*(2*r-1)))
+}
+
+// Backoff yields exponentially growing delays. The zero value starts at
+// one second, doubles on each call to Next and is not capped.
+type Backoff struct {
+	Initial time.Duration
+	// Max caps the delay before jitter is applied. Zero means no cap.
+	Max time.Duration
+	// Multiplier defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction by which delays are randomized, see Jitter.
+	Jitter float64
+
+	current time.Duration
+}
+
+// Next returns the delay before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.Initial
+		if b.current == 0 {
+			b.current = time.Second
+		}
+	} else {
+		multiplier := b.Multiplier
+		if multiplier == 0 {
+			multiplier = 2
+		}
+
+		b.current = time.Duration(float64(b.current) * multiplier)
+	}
+
+	if b.Max > 0 && b.current > b.Max {
+		b.current = b.Max
+	}
+
+	return Jitter(b.current, b.Jitter)
+}
+
+// Reset starts over at the initial delay.
+func (b *Backoff) Reset() {
+	b.current = 0
+}
+
+// Sleep waits for d or until the context is done, whichever comes first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,4 @@
+// Package sandbox restricts the current process after it has acquired the
+// resources it needs, so that a compromised process cannot be leveraged
+// into arbitrary writes on the host's filesystem.
+package sandbox
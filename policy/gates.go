@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOutsideWindow is returned by WindowGate outside of all windows.
+	ErrOutsideWindow = errors.New("policy: outside of maintenance window")
+	// ErrNotApproved is returned by ApprovalGate if the installation has
+	// not been approved.
+	ErrNotApproved = errors.New("policy: installation not approved")
+	// ErrCheckFailed is matched by errors of CommandGate.
+	ErrCheckFailed = errors.New("policy: check failed")
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a maintenance window in local time. Windows whose End is
+// before their Start span midnight.
+type Window struct {
+	// Days are three letter weekday names ("mon", "tue", ...). The window
+	// is open every day if empty. For windows spanning midnight, the day
+	// is the one the window opens on.
+	Days []string `json:"days,omitempty" yaml:"days,omitempty"`
+	// Start and End are given as "15:04".
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+
+	parsed     bool
+	days       map[time.Weekday]bool
+	start, end time.Duration
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("policy: invalid time %q, expected HH:MM", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w *Window) parse() error {
+	var err error
+
+	if w.start, err = parseClock(w.Start); err != nil {
+		return err
+	}
+
+	if w.end, err = parseClock(w.End); err != nil {
+		return err
+	}
+
+	w.days = nil
+	for _, d := range w.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return fmt.Errorf("policy: unknown weekday %q", d)
+		}
+
+		if w.days == nil {
+			w.days = make(map[time.Weekday]bool)
+		}
+		w.days[day] = true
+	}
+
+	w.parsed = true
+	return nil
+}
+
+// Contains reports whether t lies within the window.
+func (w *Window) Contains(t time.Time) bool {
+	if !w.parsed && w.parse() != nil {
+		return false
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	clock := t.Sub(midnight)
+
+	open := func(day time.Weekday) bool {
+		return w.days == nil || w.days[day]
+	}
+
+	if w.start <= w.end {
+		return open(t.Weekday()) && clock >= w.start && clock < w.end
+	}
+
+	// The window spans midnight.
+	if clock >= w.start {
+		return open(t.Weekday())
+	}
+
+	return clock < w.end && open(midnight.AddDate(0, 0, -1).Weekday())
+}
+
+// WindowGate is a rauc.Gate that only lets installations proceed within
+// one of its maintenance windows.
+type WindowGate struct {
+	Windows []Window
+}
+
+// Check implements rauc.Gate.
+func (g *WindowGate) Check(ctx context.Context) error {
+	now := time.Now()
+
+	for i := range g.Windows {
+		if g.Windows[i].Contains(now) {
+			return nil
+		}
+	}
+
+	return ErrOutsideWindow
+}
+
+// ApprovalGate is a rauc.Gate that lets installations proceed only while
+// File exists, e.g. after an operator has created it through a management
+// interface.
+type ApprovalGate struct {
+	File string
+}
+
+// Check implements rauc.Gate.
+func (g *ApprovalGate) Check(ctx context.Context) error {
+	if _, err := os.Stat(g.File); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotApproved
+		}
+		return fmt.Errorf("policy: %v", err)
+	}
+
+	return nil
+}
+
+// CommandGate is a rauc.Gate that runs a command and lets installations
+// proceed if it exits successfully.
+type CommandGate struct {
+	Command []string
+}
+
+// Check implements rauc.Gate.
+func (g *CommandGate) Check(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, g.Command[0], g.Command[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v: %s", ErrCheckFailed, g.Command[0], err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+// Package policy describes the update behavior of a device in a YAML or
+// JSON document, and turns it into the options of an agent. Products
+// change when and how they update by changing the document.
+//
+// A policy looks like this:
+//
+//	source:
+//	  url: https://updates.example.com/board/latest.raucb
+//	schedule:
+//	  interval: 6h
+//	  windows:
+//	    - days: [sat, sun]
+//	      start: "02:00"
+//	      end: "05:00"
+//	approval:
+//	  required: true
+//	checks:
+//	  network: true
+//	  min_battery_percent: 30
+//	reboot:
+//	  mode: immediate
+//	  delay: 1m
+package policy
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/holoplot/go-rauc/agent"
+	"github.com/holoplot/go-rauc/download"
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/holoplot/go-rauc/source"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DefaultApprovalFile is the approval flag file used if none is configured.
+const DefaultApprovalFile = "/run/go-rauc/approved"
+
+// Duration is a time.Duration written as a string such as "90s" or "6h".
+type Duration time.Duration
+
+// MarshalYAML implements yaml.Marshaler.
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", value.Line, err)
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", time.Duration(d).String())), nil
+}
+
+// Source selects where bundles come from.
+type Source struct {
+	URL string `json:"url" yaml:"url"`
+	// Mirrors serve the same bundle as the HTTP(S) URL. They are tried in
+	// order after it fails.
+	Mirrors []string `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+	// Class is the slot class whose bundle version is compared.
+	Class            string `json:"class,omitempty" yaml:"class,omitempty"`
+	StagingDirectory string `json:"staging_directory,omitempty" yaml:"staging_directory,omitempty"`
+}
+
+// Schedule controls when the agent checks for and installs updates.
+type Schedule struct {
+	Interval      Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	RetryInterval Duration `json:"retry_interval,omitempty" yaml:"retry_interval,omitempty"`
+	Jitter        float64  `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	// Windows restricts installations to maintenance windows. Updates are
+	// installed at any time if empty.
+	Windows []Window `json:"windows,omitempty" yaml:"windows,omitempty"`
+}
+
+// Approval requires an operator to approve installations.
+type Approval struct {
+	Required bool `json:"required" yaml:"required"`
+	// File has to exist for installations to proceed. Defaults to
+	// DefaultApprovalFile.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// Checks are conditions the system has to meet before an installation.
+type Checks struct {
+	// Network requires connectivity to the bundle host.
+	Network bool `json:"network,omitempty" yaml:"network,omitempty"`
+	// MinBatteryPercent and RequireAC configure a rauc.PowerGate.
+	MinBatteryPercent float64 `json:"min_battery_percent,omitempty" yaml:"min_battery_percent,omitempty"`
+	RequireAC         bool    `json:"require_ac,omitempty" yaml:"require_ac,omitempty"`
+	// MaxCelsius configures a rauc.ThermalGate.
+	MaxCelsius float64 `json:"max_celsius,omitempty" yaml:"max_celsius,omitempty"`
+	// Commands are run in order. An installation is deferred unless all
+	// of them exit successfully.
+	Commands [][]string `json:"commands,omitempty" yaml:"commands,omitempty"`
+}
+
+// Reboot modes.
+const (
+	// RebootNever leaves rebooting to someone else.
+	RebootNever = "never"
+	// RebootImmediate reboots right after a successful installation.
+	RebootImmediate = "immediate"
+)
+
+// Reboot controls what happens after a successful installation.
+type Reboot struct {
+	// Mode is RebootNever (the default) or RebootImmediate.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Strategy is "systemctl" (the default), "logind", "syscall" or
+	// "command".
+	Strategy string   `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	Command  []string `json:"command,omitempty" yaml:"command,omitempty"`
+	Delay    Duration `json:"delay,omitempty" yaml:"delay,omitempty"`
+}
+
+// Policy is the update behavior of a device.
+type Policy struct {
+	Source   Source   `json:"source" yaml:"source"`
+	Schedule Schedule `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Approval Approval `json:"approval,omitempty" yaml:"approval,omitempty"`
+	Checks   Checks   `json:"checks,omitempty" yaml:"checks,omitempty"`
+	Reboot   Reboot   `json:"reboot,omitempty" yaml:"reboot,omitempty"`
+}
+
+// Parse reads a policy in YAML or JSON format. Unknown keys are rejected,
+// so that typos do not go unnoticed.
+func Parse(r io.Reader) (*Policy, error) {
+	d := yaml.NewDecoder(r)
+	d.KnownFields(true)
+
+	p := new(Policy)
+	if err := d.Decode(p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("policy: %v", err)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Load reads a policy from a file.
+func Load(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %v", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+var rebootStrategies = map[string]rauc.RebootStrategy{
+	"":          rauc.RebootSystemctl,
+	"systemctl": rauc.RebootSystemctl,
+	"logind":    rauc.RebootLogind,
+	"syscall":   rauc.RebootSyscall,
+	"command":   rauc.RebootCommand,
+}
+
+func (p *Policy) validate() error {
+	if p.Source.URL == "" {
+		return fmt.Errorf("policy: source.url is required")
+	}
+
+	if len(p.Source.Mirrors) > 0 {
+		for _, m := range append([]string{p.Source.URL}, p.Source.Mirrors...) {
+			u, err := url.Parse(m)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("policy: source: mirrors need HTTP(S) URLs, got %q", m)
+			}
+		}
+	}
+
+	for i := range p.Schedule.Windows {
+		if err := p.Schedule.Windows[i].parse(); err != nil {
+			return err
+		}
+	}
+
+	switch p.Reboot.Mode {
+	case "", RebootNever, RebootImmediate:
+	default:
+		return fmt.Errorf("policy: unknown reboot mode %q", p.Reboot.Mode)
+	}
+
+	strategy, ok := rebootStrategies[p.Reboot.Strategy]
+	if !ok {
+		return fmt.Errorf("policy: unknown reboot strategy %q", p.Reboot.Strategy)
+	}
+
+	if strategy == rauc.RebootCommand && len(p.Reboot.Command) == 0 {
+		return fmt.Errorf("policy: reboot strategy command needs a command")
+	}
+
+	for _, c := range p.Checks.Commands {
+		if len(c) == 0 {
+			return fmt.Errorf("policy: empty check command")
+		}
+	}
+
+	return nil
+}
+
+// Gates returns the gates implementing the approval requirement, the
+// checks and the maintenance windows of the policy.
+func (p *Policy) Gates() []rauc.Gate {
+	var gates []rauc.Gate
+
+	if len(p.Schedule.Windows) > 0 {
+		gates = append(gates, &WindowGate{Windows: p.Schedule.Windows})
+	}
+
+	if p.Approval.Required {
+		file := p.Approval.File
+		if file == "" {
+			file = DefaultApprovalFile
+		}
+		gates = append(gates, &ApprovalGate{File: file})
+	}
+
+	if p.Checks.MinBatteryPercent > 0 || p.Checks.RequireAC {
+		gates = append(gates, &rauc.PowerGate{
+			MinBatteryPercent: p.Checks.MinBatteryPercent,
+			RequireAC:         p.Checks.RequireAC,
+		})
+	}
+
+	if p.Checks.MaxCelsius > 0 {
+		gates = append(gates, &rauc.ThermalGate{MaxCelsius: p.Checks.MaxCelsius})
+	}
+
+	if p.Checks.Network {
+		gates = append(gates, &rauc.NetworkGate{URL: p.Source.URL})
+	}
+
+	for _, c := range p.Checks.Commands {
+		gates = append(gates, &CommandGate{Command: c})
+	}
+
+	return gates
+}
+
+// AgentOptions returns the agent options implementing the policy. Options
+// the policy does not cover are taken from base.
+func (p *Policy) AgentOptions(base agent.Options) agent.Options {
+	o := base
+
+	o.BundleURL = p.Source.URL
+	o.Source = nil
+	if len(p.Source.Mirrors) > 0 {
+		mirrors := []download.Mirror{{URL: p.Source.URL}}
+		for _, m := range p.Source.Mirrors {
+			mirrors = append(mirrors, download.Mirror{URL: m})
+		}
+
+		// The default download options cannot fail.
+		o.Source, _ = source.MirrorSourceNew(mirrors, download.Options{})
+	}
+	if p.Source.Class != "" {
+		o.Class = p.Source.Class
+	}
+	if p.Source.StagingDirectory != "" {
+		o.StagingDirectory = p.Source.StagingDirectory
+	}
+
+	if p.Schedule.Interval != 0 {
+		o.Interval = time.Duration(p.Schedule.Interval)
+	}
+	if p.Schedule.RetryInterval != 0 {
+		o.RetryInterval = time.Duration(p.Schedule.RetryInterval)
+	}
+	if p.Schedule.Jitter != 0 {
+		o.Jitter = p.Schedule.Jitter
+	}
+
+	o.InstallOptions.Gates = append(append([]rauc.Gate(nil), base.InstallOptions.Gates...), p.Gates()...)
+
+	o.Reboot = p.Reboot.Mode == RebootImmediate
+	o.RebootOptions = rauc.RebootOptions{
+		Strategy: rebootStrategies[p.Reboot.Strategy],
+		Command:  p.Reboot.Command,
+		Delay:    time.Duration(p.Reboot.Delay),
+	}
+
+	return o
+}
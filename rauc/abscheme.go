@@ -0,0 +1,129 @@
+package rauc
+
+import (
+	"fmt"
+)
+
+// SlotGroup is a set of slots that are installed and booted together: one
+// bootable slot plus all slots that name it (directly or indirectly) as
+// their parent.
+type SlotGroup struct {
+	Bootname string
+	Slots    []*SlotConfig
+}
+
+// RootSlot follows the parent chain of the named slot and returns the slot
+// at its top, which is the one handled by the bootloader.
+func (c *SystemConfig) RootSlot(name string) (*SlotConfig, error) {
+	seen := make(map[string]bool)
+
+	for {
+		slot, ok := c.Slot(name)
+		if !ok {
+			return nil, fmt.Errorf("RAUC: unknown slot %q", name)
+		}
+
+		if slot.Parent == "" {
+			return slot, nil
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("RAUC: parent loop at slot %q", name)
+		}
+		seen[name] = true
+
+		name = slot.Parent
+	}
+}
+
+// Groups returns the slot groups of the configuration, in the order
+// their bootable slots appear.
+func (c *SystemConfig) Groups() ([]SlotGroup, error) {
+	var groups []SlotGroup
+	index := make(map[string]int)
+
+	for i := range c.Slots {
+		root, err := c.RootSlot(c.Slots[i].Name)
+		if err != nil {
+			return nil, err
+		}
+
+		n, ok := index[root.Name]
+		if !ok {
+			n = len(groups)
+			index[root.Name] = n
+			groups = append(groups, SlotGroup{Bootname: root.Bootname})
+		}
+
+		groups[n].Slots = append(groups[n].Slots, &c.Slots[i])
+	}
+
+	return groups, nil
+}
+
+// OtherBootname returns the bootname of the slot that the given bootname
+// is paired with, e.g. "B" for "A". It fails unless exactly two slots of the
+// same class carry bootnames.
+func (c *SystemConfig) OtherBootname(bootname string) (string, error) {
+	var class string
+	for _, s := range c.Slots {
+		if s.Bootname == bootname {
+			class = s.Class
+			break
+		}
+	}
+
+	if class == "" {
+		return "", errorf("", ErrSlotNotFound, "RAUC: no slot with bootname %q", bootname)
+	}
+
+	var others []string
+	for _, s := range c.Slots {
+		if s.Class == class && s.Bootname != "" && s.Bootname != bootname {
+			others = append(others, s.Bootname)
+		}
+	}
+
+	if len(others) != 1 {
+		return "", fmt.Errorf("RAUC: slot class %q does not form a pair (%d other bootnames)", class, len(others))
+	}
+
+	return others[0], nil
+}
+
+// OtherSlot returns the slot of the same class as the named one that
+// belongs to the paired slot group, e.g. "appfs.1" for "appfs.0".
+func (c *SystemConfig) OtherSlot(name string) (*SlotConfig, error) {
+	slot, ok := c.Slot(name)
+	if !ok {
+		return nil, fmt.Errorf("RAUC: unknown slot %q", name)
+	}
+
+	root, err := c.RootSlot(name)
+	if err != nil {
+		return nil, err
+	}
+
+	otherBootname, err := c.OtherBootname(root.Bootname)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range c.Slots {
+		s := &c.Slots[i]
+		if s.Class != slot.Class {
+			continue
+		}
+
+		r, err := c.RootSlot(s.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.Bootname == otherBootname {
+			return s, nil
+		}
+	}
+
+	return nil, errorf("", ErrSlotNotFound, "RAUC: no %s slot in group %q", slot.Class, otherBootname)
+}
@@ -0,0 +1,166 @@
+package rauc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// BootIDFile holds the kernel's random boot ID, which changes with
+	// every boot.
+	BootIDFile = "/proc/sys/kernel/random/boot_id"
+	// PendingActivationFile records an installed but unconfirmed update.
+	// It has to survive reboots.
+	PendingActivationFile = "/var/lib/go-rauc/pending-activation.json"
+)
+
+// BootID returns the ID of the current boot.
+func BootID() (string, error) {
+	b, err := ioutil.ReadFile(BootIDFile)
+	if err != nil {
+		return "", fmt.Errorf("RAUC: BootID(): %v", err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// ActivationState tells where the system is in activating an installed
+// update, see CheckActivation.
+type ActivationState string
+
+// Activation states.
+const (
+	// ActivationNone means no update is waiting for confirmation.
+	ActivationNone ActivationState = "none"
+	// ActivationPending means the system has not rebooted since the
+	// installation.
+	ActivationPending ActivationState = "pending"
+	// ActivationFirstBoot means this is the first boot into the updated
+	// slot.
+	ActivationFirstBoot ActivationState = "first-boot"
+	// ActivationRebooted means the updated slot was booted before, but
+	// the update was not confirmed then.
+	ActivationRebooted ActivationState = "rebooted"
+	// ActivationRolledBack means the system rebooted into a different
+	// slot than the updated one.
+	ActivationRolledBack ActivationState = "rolled-back"
+)
+
+// PendingActivation describes an installed update until it is confirmed.
+type PendingActivation struct {
+	Slot      string    `json:"slot"`
+	Version   string    `json:"version"`
+	Installed time.Time `json:"installed"`
+	// BootID is the boot the update was installed in, FirstBootID the
+	// first boot into the updated slot.
+	BootID      string `json:"boot_id"`
+	FirstBootID string `json:"first_boot_id,omitempty"`
+}
+
+func (a *PendingActivation) write() error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(PendingActivationFile), 0755); err != nil {
+		return err
+	}
+
+	tmp := PendingActivationFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, PendingActivationFile)
+}
+
+// RecordPendingActivation records that version was installed to slot in
+// the current boot, to be checked with CheckActivation after rebooting.
+func RecordPendingActivation(slot, version string) error {
+	id, err := BootID()
+	if err != nil {
+		return err
+	}
+
+	a := &PendingActivation{
+		Slot:      slot,
+		Version:   version,
+		Installed: time.Now(),
+		BootID:    id,
+	}
+
+	if err := a.write(); err != nil {
+		return fmt.Errorf("RAUC: RecordPendingActivation(): %v", err)
+	}
+
+	return nil
+}
+
+// CheckActivation compares the pending activation, if any, with the
+// current boot and the booted slot. The first call in the first boot of
+// the updated slot returns ActivationFirstBoot, later calls in the same
+// boot as well, and calls in later boots ActivationRebooted.
+func CheckActivation(b Backend) (ActivationState, *PendingActivation, error) {
+	data, err := ioutil.ReadFile(PendingActivationFile)
+	if os.IsNotExist(err) {
+		return ActivationNone, nil, nil
+	} else if err != nil {
+		return "", nil, fmt.Errorf("RAUC: CheckActivation(): %v", err)
+	}
+
+	a := new(PendingActivation)
+	if err := json.Unmarshal(data, a); err != nil {
+		return "", nil, fmt.Errorf("RAUC: CheckActivation(): %v", err)
+	}
+
+	id, err := BootID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if id == a.BootID {
+		return ActivationPending, a, nil
+	}
+
+	status, err := b.GetSlotStatus()
+	if err != nil {
+		return "", nil, err
+	}
+
+	booted := false
+	for _, s := range status {
+		state, _ := s.GetString(SlotKeyState)
+		booted = booted || (s.SlotName == a.Slot && state == SlotStateBooted)
+	}
+
+	switch {
+	case !booted:
+		return ActivationRolledBack, a, nil
+	case a.FirstBootID == "":
+		a.FirstBootID = id
+		if err := a.write(); err != nil {
+			return "", nil, fmt.Errorf("RAUC: CheckActivation(): %v", err)
+		}
+		fallthrough
+	case a.FirstBootID == id:
+		return ActivationFirstBoot, a, nil
+	}
+
+	return ActivationRebooted, a, nil
+}
+
+// ClearPendingActivation removes the pending activation, once the update
+// was confirmed or given up on.
+func ClearPendingActivation() error {
+	if err := os.Remove(PendingActivationFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("RAUC: ClearPendingActivation(): %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// AdaptiveBlockHashIndex is the adaptive update method that only writes
+// the blocks of an image that differ from the target slot, or from other
+// slots of the same class. Bundles using it need to be installed by
+// streaming or from the verity format to save bandwidth.
+const AdaptiveBlockHashIndex = "block-hash-index"
+
+// ErrInspectNotSupported is returned by InspectBundle and AdaptiveMethods
+// for backends that cannot inspect bundles.
+var ErrInspectNotSupported = errors.New("RAUC: backend cannot inspect bundles")
+
+// AdaptiveMethods returns the adaptive update methods supported by the
+// images of a bundle, by slot class. Images without adaptive methods are
+// not included. It needs RAUC 1.8 or later.
+func AdaptiveMethods(b Backend, filename string) (map[string][]string, error) {
+	info, err := InspectBundle(b, filename, InspectBundleOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	methods := make(map[string][]string)
+	for _, image := range info.Images {
+		if image.SlotClass != "" && len(image.Adaptive) > 0 {
+			methods[image.SlotClass] = image.Adaptive
+		}
+	}
+
+	return methods, nil
+}
+
+// SupportsAdaptive reports whether any image of a bundle supports the
+// block-hash-index adaptive update method.
+func SupportsAdaptive(b Backend, filename string) (bool, error) {
+	methods, err := AdaptiveMethods(b, filename)
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range methods {
+		for _, method := range m {
+			if method == AdaptiveBlockHashIndex {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// cliInstallArgs converts the args dictionary of the InstallBundle method
+// to "rauc install" command line options.
+func cliInstallArgs(args map[string]interface{}) []string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var options []string
+
+	for _, k := range keys {
+		// The daemon takes a list of headers, the tool one option per header.
+		flag := "--" + k
+		if k == "http-headers" {
+			flag = "--http-header"
+		}
+
+		switch v := args[k].(type) {
+		case bool:
+			if v {
+				options = append(options, flag)
+			}
+		case []string:
+			for _, s := range v {
+				options = append(options, flag+"="+s)
+			}
+		default:
+			options = append(options, fmt.Sprintf("%s=%v", flag, v))
+		}
+	}
+
+	return options
+}
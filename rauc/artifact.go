@@ -0,0 +1,68 @@
+package rauc
+
+import (
+	"context"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// Artifact is an artifact installed to an artifact repository, such as
+// an application container image.
+type Artifact struct {
+	// Repo is the name of the repository holding the artifact.
+	Repo     string `json:"repo" yaml:"repo"`
+	Name     string `json:"name" yaml:"name"`
+	Checksum string `json:"checksum" yaml:"checksum"`
+	// References are the slots referring to this instance of the
+	// artifact. Instances without references are removed by the daemon.
+	References []string `json:"references,omitempty" yaml:"references,omitempty"`
+}
+
+// artifactsOf decodes the reply of the GetArtifactStatus method. An
+// artifact installed in several versions yields one Artifact per instance.
+func artifactsOf(repos []map[string]dbus.Variant) []Artifact {
+	var artifacts []Artifact
+
+	for _, repo := range repos {
+		entries, _ := repo["artifacts"].Value().([]map[string]dbus.Variant)
+
+		for _, entry := range entries {
+			a := Artifact{
+				Repo: variantString(repo, "name"),
+				Name: variantString(entry, "name"),
+			}
+
+			instances, _ := entry["instances"].Value().([]map[string]dbus.Variant)
+			if len(instances) == 0 {
+				instances = []map[string]dbus.Variant{entry}
+			}
+
+			for _, instance := range instances {
+				a.Checksum = variantString(instance, "checksum")
+				a.References = variantStrings(instance, "references")
+				artifacts = append(artifacts, a)
+			}
+		}
+	}
+
+	return artifacts
+}
+
+// GetArtifactStatus returns the artifacts of all artifact repositories.
+// It needs RAUC 1.14 or later.
+func (p *Installer) GetArtifactStatus() ([]Artifact, error) {
+	return p.GetArtifactStatusContext(context.Background())
+}
+
+// GetArtifactStatusContext is GetArtifactStatus, giving up when ctx is
+// done.
+func (p *Installer) GetArtifactStatusContext(ctx context.Context) ([]Artifact, error) {
+	var repos []map[string]dbus.Variant
+
+	err := p.call(ctx, p.interfaceForMember("GetArtifactStatus")).Store(&repos)
+	if err != nil {
+		return nil, callError("GetArtifactStatus", err)
+	}
+
+	return artifactsOf(repos), nil
+}
@@ -0,0 +1,159 @@
+package rauc
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// Backend is the API shared by the D-Bus client (Installer), the
+// command line client (CLI) and the Simulator.
+type Backend interface {
+	Events() *EventBus
+	InstallBundle(filename string, options InstallBundleOptions) error
+	Info(filename string) (compatible string, version string, err error)
+	Mark(state string, slotIdentifier string) (slotName string, message string, err error)
+	GetSlotStatus() ([]SlotStatus, error)
+	GetOperation() (string, error)
+	GetLastError() (string, error)
+	// GetProgress is what ProgressOf is built on. Callers use ProgressOf.
+	GetProgress() (percentage int32, message string, nestingDepth int32, err error)
+	GetCompatible() (string, error)
+	GetVariant() (string, error)
+	GetBootSlot() (string, error)
+}
+
+// InstallerAPI is the API of the Installer, for code that needs to
+// replace it in unit tests. Package raucmock implements it.
+type InstallerAPI interface {
+	Backend
+	InstallBundleContext(ctx context.Context, filename string, options InstallBundleOptions) error
+	InfoContext(ctx context.Context, filename string) (compatible string, version string, err error)
+	InspectBundle(filename string, options InspectBundleOptions) (*BundleInfo, error)
+	InspectBundleContext(ctx context.Context, filename string, options InspectBundleOptions) (*BundleInfo, error)
+	MarkContext(ctx context.Context, state string, slotIdentifier string) (slotName string, message string, err error)
+	MarkSlot(state MarkState, slotIdentifier string) (MarkResult, error)
+	MarkSlotContext(ctx context.Context, state MarkState, slotIdentifier string) (MarkResult, error)
+	MarkGood(slotIdentifier string) (MarkResult, error)
+	MarkBad(slotIdentifier string) (MarkResult, error)
+	MarkActive(slotIdentifier string) (MarkResult, error)
+	GetSlotStatusContext(ctx context.Context) ([]SlotStatus, error)
+	GetSlots() ([]Slot, error)
+	GetSlotsContext(ctx context.Context) ([]Slot, error)
+	GetBootedSlot(class string) (Slot, error)
+	GetBootedSlotContext(ctx context.Context, class string) (Slot, error)
+	GetOtherSlot(class string) (Slot, error)
+	GetOtherSlotContext(ctx context.Context, class string) (Slot, error)
+	GetOperationContext(ctx context.Context) (string, error)
+	GetLastErrorContext(ctx context.Context) (string, error)
+	GetProgressContext(ctx context.Context) (percentage int32, message string, nestingDepth int32, err error)
+	GetCompatibleContext(ctx context.Context) (string, error)
+	GetVariantContext(ctx context.Context) (string, error)
+	GetBootSlotContext(ctx context.Context) (string, error)
+	GetPrimary() (string, error)
+	GetPrimaryContext(ctx context.Context) (string, error)
+	GetArtifactStatus() ([]Artifact, error)
+	GetArtifactStatusContext(ctx context.Context) ([]Artifact, error)
+	WaitForOperation(ctx context.Context, target Operation) (lastError string, err error)
+	AttachToCurrentOperation(options InstallBundleOptions) (bundle string, err error)
+	AttachToCurrentOperationContext(ctx context.Context, options InstallBundleOptions) (bundle string, err error)
+	Detach() error
+	SubscribeCompleted() (<-chan CompletedEvent, func())
+	WatchProperties(ctx context.Context) (<-chan PropertyChange, error)
+	StartInstall(filename string, options InstallBundleOptions) (*InstallJob, error)
+	StartInstallContext(ctx context.Context, filename string, options InstallBundleOptions) (*InstallJob, error)
+	SubscribeSignals(size int) (<-chan *dbus.Signal, func())
+	DroppedSignals() uint64
+	Snapshot() (*Snapshot, error)
+	GetServiceState() (*ServiceState, error)
+	GetSlotMap() (*SlotMap, error)
+	KexecActivate(ctx context.Context, options KexecOptions) error
+	ForwardProgress(ctx context.Context, splash Splash, interval time.Duration) error
+}
+
+var _ InstallerAPI = (*Installer)(nil)
+
+// BackendType selects the implementation returned by BackendNew.
+type BackendType string
+
+const (
+	// BackendAuto uses D-Bus if the RAUC service is available on the system
+	// bus, or on InstallerOptions.Conn if set, and the rauc command line
+	// tool otherwise.
+	BackendAuto BackendType = ""
+	BackendDBus BackendType = "dbus"
+	BackendCLI  BackendType = "cli"
+	// BackendSimulator is an in-memory simulation, see Simulator.
+	BackendSimulator BackendType = "sim"
+)
+
+// BackendOptions contains options for the BackendNew function
+type BackendOptions struct {
+	Type      BackendType
+	Installer InstallerOptions
+	CLI       CLIOptions
+	Simulator SimulatorOptions
+}
+
+// BackendNew returns a newly allocated Backend of the configured type
+func BackendNew(options BackendOptions) (Backend, error) {
+	switch options.Type {
+	case BackendDBus:
+		return dbusBackend(options.Installer)
+	case BackendCLI:
+		return cliBackend(options.CLI)
+	case BackendSimulator:
+		return SimulatorNew(options.Simulator), nil
+	case BackendAuto:
+	default:
+		return nil, fmt.Errorf("RAUC: unknown backend %q", options.Type)
+	}
+
+	var err error
+
+	conn := options.Installer.Conn
+	if conn == nil {
+		conn, err = dbus.SystemBus()
+	}
+
+	if err == nil {
+		if available, _ := nameAvailable(conn, options.Installer.busName()); available {
+			return dbusBackend(options.Installer)
+		}
+	}
+
+	command := options.CLI.Command
+	if command == "" {
+		command = "rauc"
+	}
+
+	if _, err := exec.LookPath(command); err == nil {
+		return cliBackend(options.CLI)
+	}
+
+	// Neither is available right now, the daemon may still show up.
+	return dbusBackend(options.Installer)
+}
+
+// The helpers below avoid returning typed nil pointers as a Backend.
+
+func dbusBackend(options InstallerOptions) (Backend, error) {
+	p, err := InstallerNewWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func cliBackend(options CLIOptions) (Backend, error) {
+	c, err := CLINew(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,174 @@
+package rauc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const batchDefaultProgressInterval = time.Second
+
+// BatchPolicy selects what InstallBatch does when a bundle fails.
+type BatchPolicy int
+
+const (
+	// BatchStopOnFailure skips all bundles after a failed one.
+	BatchStopOnFailure BatchPolicy = iota
+	// BatchContinue installs the remaining bundles anyway.
+	BatchContinue
+)
+
+// BatchBundle is a bundle of a batch, with its install options.
+type BatchBundle struct {
+	Filename string
+	Options  InstallBundleOptions
+}
+
+// BatchOptions contains options for the InstallBatch function
+type BatchOptions struct {
+	Policy BatchPolicy
+	// ProgressInterval is the time between two BatchProgressEvents.
+	// Defaults to one second.
+	ProgressInterval time.Duration
+}
+
+// BatchProgressEvent is published while a bundle of a batch is installed.
+type BatchProgressEvent struct {
+	// Index of the bundle in the batch, and the number of bundles.
+	Index, Total int
+	Bundle       string
+	Progress     Progress
+}
+
+// EventType implements Event.
+func (e BatchProgressEvent) EventType() string {
+	return "batch.progress"
+}
+
+// BatchResult is the outcome of one bundle of a batch.
+type BatchResult struct {
+	Bundle   string
+	Started  time.Time
+	Duration time.Duration
+	// Skipped is set for bundles not installed because an earlier one
+	// failed.
+	Skipped bool
+	Err     error
+}
+
+// BatchReport is the combined result of InstallBatch, with one result per
+// bundle in the order given.
+type BatchReport struct {
+	Results []BatchResult
+}
+
+// Failed returns the results of the bundles that failed.
+func (r *BatchReport) Failed() []BatchResult {
+	var failed []BatchResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// BatchError is returned by InstallBatch if any bundle failed.
+type BatchError struct {
+	Report *BatchReport
+}
+
+func (e *BatchError) Error() string {
+	failed := e.Report.Failed()
+
+	messages := make([]string, 0, len(failed))
+	for _, result := range failed {
+		messages = append(messages, fmt.Sprintf("%s: %v", result.Bundle, result.Err))
+	}
+
+	return fmt.Sprintf("RAUC: %d of %d bundles failed: %s",
+		len(failed), len(e.Report.Results), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the error of the first failed bundle.
+func (e *BatchError) Unwrap() error {
+	if failed := e.Report.Failed(); len(failed) > 0 {
+		return failed[0].Err
+	}
+
+	return nil
+}
+
+// InstallBatch installs bundles one after another as a single job, e.g. a
+// bootloader bundle before a rootfs bundle. It always returns a report; the
+// error is a *BatchError if any bundle failed.
+func InstallBatch(b Backend, bundles []BatchBundle, options BatchOptions) (*BatchReport, error) {
+	if options.ProgressInterval == 0 {
+		options.ProgressInterval = batchDefaultProgressInterval
+	}
+
+	report := &BatchReport{
+		Results: make([]BatchResult, len(bundles)),
+	}
+
+	failed := false
+
+	for i, bundle := range bundles {
+		result := &report.Results[i]
+		result.Bundle = bundle.Filename
+
+		if failed && options.Policy == BatchStopOnFailure {
+			result.Skipped = true
+			continue
+		}
+
+		done := make(chan struct{})
+		go reportBatchProgress(b, i, len(bundles), bundle.Filename, options.ProgressInterval, done)
+
+		result.Started = time.Now()
+		result.Err = b.InstallBundle(bundle.Filename, bundle.Options)
+		result.Duration = time.Since(result.Started)
+		close(done)
+
+		if result.Err != nil {
+			failed = true
+		}
+	}
+
+	if failed {
+		return report, &BatchError{Report: report}
+	}
+
+	return report, nil
+}
+
+// reportBatchProgress publishes a BatchProgressEvent for every change of
+// the progress until done is closed.
+func reportBatchProgress(b Backend, index, total int, bundle string, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := Progress{Percentage: -1}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		progress, err := ProgressOf(b)
+		if err != nil || progress == last {
+			continue
+		}
+		last = progress
+
+		b.Events().Publish(BatchProgressEvent{
+			Index:    index,
+			Total:    total,
+			Bundle:   bundle,
+			Progress: progress,
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotABundle is returned by CheckBundlePath for files that are not
+// RAUC bundles.
+var ErrNotABundle = errors.New("RAUC: not a bundle")
+
+// CheckBundlePath returns the absolute path of a bundle with all symlinks
+// resolved, after checking that it is a file that can be read. The format
+// is left to the daemon, as plain, verity and crypt bundles differ. URLs
+// are returned unchanged, for the daemon to stream them.
+func CheckBundlePath(filename string) (string, error) {
+	if strings.Contains(filename, "://") {
+		return filename, nil
+	}
+
+	path, err := filepath.Abs(filename)
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("RAUC: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("RAUC: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("RAUC: %v", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %s", ErrNotABundle, path)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,69 @@
+package rauc
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckBundlePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundlepath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Crypt bundles do not start with a squashfs superblock.
+	crypt := filepath.Join(dir, "crypt.raucb")
+	if err := ioutil.WriteFile(crypt, []byte("\x00encrypted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "link.raucb")
+	if err := os.Symlink(crypt, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+		err      error
+	}{
+		{name: "crypt bundle", filename: crypt, want: crypt},
+		{name: "symlink", filename: link, want: crypt},
+		{name: "url", filename: "https://example.com/update.raucb", want: "https://example.com/update.raucb"},
+		{name: "directory", filename: dir, err: ErrNotABundle},
+		{name: "missing", filename: filepath.Join(dir, "missing.raucb"), err: os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := CheckBundlePath(tt.filename)
+
+			if tt.err != nil {
+				if err == nil {
+					t.Fatalf("got %s, want error", path)
+				}
+				if tt.err == ErrNotABundle && !errors.Is(err, ErrNotABundle) {
+					t.Fatalf("got error %v, want ErrNotABundle", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if path != tt.want {
+				t.Fatalf("got %s, want %s", path, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package rauc
+
+import (
+	"context"
+	"sync"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// cachedProperties are the properties kept by the cache. Operation,
+// Progress and LastError change during installations and are always read
+// from the daemon.
+var cachedProperties = map[string]bool{
+	"Compatible": true,
+	"Variant":    true,
+	"BootSlot":   true,
+}
+
+// propertyCache holds property values and the slot status until the
+// daemon signals a change.
+type propertyCache struct {
+	mutex      sync.Mutex
+	properties map[string]dbus.Variant
+	slotStatus []SlotStatus
+	// generation counts invalidations, so values fetched before one are
+	// not stored afterwards.
+	generation uint64
+}
+
+func (p *Installer) startCache() {
+	p.cache = &propertyCache{
+		properties: make(map[string]dbus.Variant),
+	}
+
+	// Missing a signal would keep stale values, so the cache gets a
+	// large buffer.
+	signals, _ := p.SubscribeSignals(64)
+
+	go func() {
+		for signal := range signals {
+			p.cache.handleSignal(p, signal)
+		}
+	}()
+}
+
+func (c *propertyCache) handleSignal(p *Installer, signal *dbus.Signal) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch signal.Name {
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		if signal.Path != p.path || len(signal.Body) < 3 {
+			return
+		}
+
+		if iface, _ := signal.Body[0].(string); iface != p.iface {
+			return
+		}
+
+		changed, _ := signal.Body[1].(map[string]dbus.Variant)
+		for name, v := range changed {
+			if cachedProperties[name] {
+				c.properties[name] = v
+			}
+		}
+
+		invalidated, _ := signal.Body[2].([]string)
+		for _, name := range invalidated {
+			delete(c.properties, name)
+		}
+		c.generation++
+
+	case p.interfaceForMember("Completed"):
+		c.slotStatus = nil
+		c.generation++
+
+	case "org.freedesktop.DBus.NameOwnerChanged", SignalReconnected:
+		// The daemon restarted, possibly with a different configuration,
+		// or changes were missed while disconnected.
+		c.properties = make(map[string]dbus.Variant)
+		c.slotStatus = nil
+		c.generation++
+	}
+}
+
+func (c *propertyCache) invalidateSlotStatus() {
+	c.mutex.Lock()
+	c.slotStatus = nil
+	c.generation++
+	c.mutex.Unlock()
+}
+
+// lookupSlotStatus returns the cached slot status, or nil, and the
+// current generation.
+func (c *propertyCache) lookupSlotStatus() ([]SlotStatus, uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.slotStatus == nil {
+		return nil, c.generation
+	}
+
+	return copySlotStatus(c.slotStatus), c.generation
+}
+
+func (c *propertyCache) storeSlotStatus(status []SlotStatus, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation == c.generation {
+		c.slotStatus = copySlotStatus(status)
+	}
+}
+
+// getProperty returns a property, from the cache if possible.
+func (p *Installer) getProperty(ctx context.Context, name string) (dbus.Variant, error) {
+	var generation uint64
+	if p.cache != nil && cachedProperties[name] {
+		p.cache.mutex.Lock()
+		v, ok := p.cache.properties[name]
+		generation = p.cache.generation
+		p.cache.mutex.Unlock()
+
+		if ok {
+			return v, nil
+		}
+	}
+
+	v, err := p.property(ctx, name)
+	if err != nil {
+		return dbus.Variant{}, callError("Get"+name, err)
+	}
+
+	if p.cache != nil && cachedProperties[name] {
+		p.cache.mutex.Lock()
+		if generation == p.cache.generation {
+			p.cache.properties[name] = v
+		}
+		p.cache.mutex.Unlock()
+	}
+
+	return v, nil
+}
+
+// copySlotStatus returns a copy of status that callers may modify.
+func copySlotStatus(status []SlotStatus) []SlotStatus {
+	c := make([]SlotStatus, len(status))
+	for i, s := range status {
+		c[i] = SlotStatus{
+			SlotName: s.SlotName,
+			Status:   make(map[string]dbus.Variant, len(s.Status)),
+		}
+
+		for k, v := range s.Status {
+			c[i].Status[k] = v
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,48 @@
+package rauc
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// CasyncConfig is the [casync] section of system.conf. The daemon takes
+// these settings from its configuration only, there is no way to pass them
+// per installation over D-Bus.
+type CasyncConfig struct {
+	// StorePath overrides the chunk store location for all bundles.
+	StorePath string
+	// TmpPath is the directory used for the chunk cache while extracting.
+	TmpPath string
+	// InstallArgs are passed to casync/desync on the command line.
+	InstallArgs string
+	UseDesync   bool
+}
+
+// Casync returns the casync settings of the configuration.
+func (c *SystemConfig) Casync() CasyncConfig {
+	keys := c.Sections["casync"]
+
+	return CasyncConfig{
+		StorePath:   keys["storepath"],
+		TmpPath:     keys["tmppath"],
+		InstallArgs: keys["install-args"],
+		UseDesync:   keys["use-desync"] == "true",
+	}
+}
+
+// CasyncStore returns the chunk store the daemon uses for a casync bundle
+// at the given location: StorePath if configured, otherwise the bundle's
+// path or URL with the ".raucb" suffix replaced by ".castr".
+func (c CasyncConfig) CasyncStore(bundle string) string {
+	if c.StorePath != "" {
+		return c.StorePath
+	}
+
+	if u, err := url.Parse(bundle); err == nil && u.Scheme != "" && u.Host != "" {
+		u.Path = strings.TrimSuffix(u.Path, path.Ext(u.Path)) + ".castr"
+		return u.String()
+	}
+
+	return strings.TrimSuffix(bundle, path.Ext(bundle)) + ".castr"
+}
@@ -0,0 +1,448 @@
+package rauc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// CLIOptions contains options for the CLINew function
+type CLIOptions struct {
+	// Command is the rauc binary to run. Defaults to "rauc" in $PATH.
+	Command string
+	// Events is the bus the CLI publishes its events on. A private
+	// bus is created if nil.
+	Events *EventBus
+}
+
+// CLI talks to RAUC by running the rauc command line tool, for systems
+// that use RAUC without its D-Bus service. It mirrors the Installer API.
+type CLI struct {
+	command string
+	events  *EventBus
+
+	mutex     sync.Mutex
+	operation string
+	lastError string
+	progress  cliProgress
+}
+
+type cliProgress struct {
+	percentage int32
+	message    string
+}
+
+var cliProgressLine = regexp.MustCompile(`^\s*(\d+)%\s+(.*)$`)
+
+// CLINew returns a newly allocated CLI object
+func CLINew(options CLIOptions) (*CLI, error) {
+	c := &CLI{
+		command:   options.Command,
+		events:    options.Events,
+		operation: string(OperationIdle),
+	}
+
+	if c.command == "" {
+		c.command = "rauc"
+	}
+
+	if _, err := exec.LookPath(c.command); err != nil {
+		return nil, fmt.Errorf("RAUC: CLINew(): %v", err)
+	}
+
+	if c.events == nil {
+		c.events = EventBusNew()
+	}
+
+	return c, nil
+}
+
+// Events returns the bus the CLI publishes its events on.
+func (c *CLI) Events() *EventBus {
+	return c.events
+}
+
+func (c *CLI) run(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command(c.command, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// InstallBundle installs a bundle with "rauc install" and waits for it to
+// finish. Progress lines of the tool are available through GetProgress.
+// As with the daemon, giving up waiting, e.g. after options.Timeout, does
+// not abort the installation: the tool keeps running in the background.
+func (c *CLI) InstallBundle(filename string, options InstallBundleOptions) error {
+	return c.InstallBundleContext(context.Background(), filename, options)
+}
+
+// InstallBundleContext is InstallBundle, but stops waiting for the tool
+// when ctx is done.
+func (c *CLI) InstallBundleContext(ctx context.Context, filename string, options InstallBundleOptions) (err error) {
+	if err := CheckGates(ctx, options.Gates); err != nil {
+		return err
+	}
+
+	unlock, err := lockForInstall(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := RunHooks(ctx, HookBeforeInstall, options.Hooks.BeforeInstall, HookInfo{Bundle: filename}); err != nil {
+		return err
+	}
+	defer func() {
+		err = afterInstall(options.Hooks, filename, err)
+	}()
+
+	verify, err := prepareVerify(c, filename, options)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = verify(err)
+	}()
+
+	args := []string{"install"}
+	args = append(args, cliInstallArgs(options.daemonArgs())...)
+	args = append(args, filename)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.command, args...)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("RAUC: Install(): %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("RAUC: Install(): %v", err)
+	}
+
+	c.mutex.Lock()
+	c.operation = string(OperationInstalling)
+	c.progress = cliProgress{}
+	c.mutex.Unlock()
+
+	c.events.Publish(InstallStartedEvent{Bundle: filename})
+	defer func() {
+		c.mutex.Lock()
+		c.operation = string(OperationIdle)
+		c.mutex.Unlock()
+
+		c.events.Publish(InstallCompletedEvent{Bundle: filename, Err: err})
+	}()
+
+	// Buffered, so the goroutine reaps the tool even if InstallBundle
+	// stopped waiting for it.
+	done := make(chan error, 1)
+	go func() {
+		lastError := c.scanInstallOutput(stdout, options.ProgressHistory)
+		err := cmd.Wait()
+		if err != nil {
+			if lastError == "" {
+				lastError = lastLine(stderr.String())
+			}
+			if lastError == "" {
+				lastError = err.Error()
+			}
+
+			c.mutex.Lock()
+			c.lastError = lastError
+			c.mutex.Unlock()
+
+			err = installError(lastError)
+		}
+		done <- err
+	}()
+
+	var watchdogTick <-chan time.Time
+	if options.Watchdog != nil {
+		interval := options.WatchdogInterval
+		if interval == 0 {
+			interval = 10 * time.Second
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdogTick = ticker.C
+	}
+
+	var stall *stallDetector
+	var stallTick <-chan time.Time
+	if options.StallTimeout > 0 {
+		stall = newStallDetector(filename, options.StallTimeout, c)
+
+		ticker := time.NewTicker(stall.interval())
+		defer ticker.Stop()
+		stallTick = ticker.C
+	}
+
+	var timeout <-chan time.Time
+	if options.Timeout > 0 {
+		timer := time.NewTimer(options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-watchdogTick:
+			if err := options.Watchdog.Keepalive(); err != nil {
+				return err
+			}
+		case <-stallTick:
+			if e := stall.check(); e != nil {
+				c.events.Publish(InstallStalledEvent{Err: e})
+				return e
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return &TimeoutError{
+				Bundle:  filename,
+				Timeout: options.Timeout,
+				State:   collectDaemonState(c),
+			}
+		}
+	}
+}
+
+// scanInstallOutput records progress lines and returns the reported
+// LastError, if any.
+func (c *CLI) scanInstallOutput(r io.Reader, history *ProgressHistory) string {
+	var lastError string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "LastError: ") {
+			lastError = strings.TrimPrefix(line, "LastError: ")
+			continue
+		}
+
+		m := cliProgressLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		percentage, _ := strconv.Atoi(m[1])
+
+		c.mutex.Lock()
+		c.progress = cliProgress{int32(percentage), m[2]}
+		c.mutex.Unlock()
+
+		history.record(Progress{Percentage: int32(percentage), Message: m[2], NestingDepth: 1})
+	}
+
+	return lastError
+}
+
+// Info provides information on a given bundle.
+func (c *CLI) Info(filename string) (compatible string, version string, err error) {
+	out, err := c.run("info", "--output-format=json", filename)
+	if err != nil {
+		return "", "", callError("Info", err)
+	}
+
+	var info struct {
+		Compatible string `json:"compatible"`
+		Version    string `json:"version"`
+	}
+
+	if err := json.Unmarshal(out, &info); err != nil {
+		return "", "", callError("Info", err)
+	}
+
+	return info.Compatible, info.Version, nil
+}
+
+var cliMarkedSlot = regexp.MustCompile(`marked slot (\S+) as`)
+
+// Mark keeps a slot bootable (state == “good”), makes it unbootable (state == “bad”)
+// or explicitly activates it for the next boot (state == “active”).
+func (c *CLI) Mark(state string, slotIdentifier string) (slotName string, message string, err error) {
+	if _, err := ParseMarkState(state); err != nil {
+		return "", "", err
+	}
+
+	out, err := c.run("status", "mark-"+state, slotIdentifier)
+	if err != nil {
+		return "", "", callError("Mark", err)
+	}
+
+	message = lastLine(string(out))
+	if m := cliMarkedSlot.FindStringSubmatch(message); m != nil {
+		slotName = m[1]
+	}
+
+	return slotName, message, nil
+}
+
+type cliStatus struct {
+	Compatible string                              `json:"compatible"`
+	Variant    string                              `json:"variant"`
+	Booted     string                              `json:"booted"`
+	Primary    string                              `json:"boot_primary"`
+	Slots      []map[string]map[string]interface{} `json:"slots"`
+}
+
+func (c *CLI) status() (*cliStatus, error) {
+	out, err := c.run("status", "--detailed", "--output-format=json")
+	if err != nil {
+		return nil, err
+	}
+
+	var status cliStatus
+	d := json.NewDecoder(bytes.NewReader(out))
+	d.UseNumber()
+	if err := d.Decode(&status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// cliStatusKey maps a JSON key of "rauc status" to the key used in the
+// D-Bus GetSlotStatus reply.
+func cliStatusKey(key string) string {
+	switch key {
+	case "checksum.sha256":
+		return SlotKeySHA256
+	case "checksum.size":
+		return SlotKeySize
+	}
+
+	return strings.Replace(key, "_", "-", -1)
+}
+
+func flattenCLIStatus(prefix string, in map[string]interface{}, out map[string]dbus.Variant) {
+	for k, v := range in {
+		key := prefix + k
+
+		if nested, ok := v.(map[string]interface{}); ok {
+			// slot_status holds the status keys at the top level.
+			if k == "slot_status" {
+				flattenCLIStatus("", nested, out)
+			} else {
+				flattenCLIStatus(key+".", nested, out)
+			}
+			continue
+		}
+
+		key = cliStatusKey(key)
+		if variant, ok := statusVariant(key, v); ok {
+			out[key] = variant
+		}
+	}
+}
+
+// GetSlotStatus is an access method to get all slots’ status.
+func (c *CLI) GetSlotStatus() (status []SlotStatus, err error) {
+	s, err := c.status()
+	if err != nil {
+		return nil, callError("GetSlotStatus", err)
+	}
+
+	for _, slots := range s.Slots {
+		for name, fields := range slots {
+			entry := SlotStatus{
+				SlotName: name,
+				Status:   make(map[string]dbus.Variant),
+			}
+
+			flattenCLIStatus("", fields, entry.Status)
+			status = append(status, entry)
+		}
+	}
+
+	return status, nil
+}
+
+// GetOperation returns "installing" while InstallBundle runs, and "idle"
+// otherwise. Operations started by other processes are not visible.
+func (c *CLI) GetOperation() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.operation, nil
+}
+
+// GetLastError returns the error message of the last failed InstallBundle call.
+func (c *CLI) GetLastError() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.lastError, nil
+}
+
+// GetProgress returns the progress of the running InstallBundle call in the
+// form (percentage, message, nesting depth)
+func (c *CLI) GetProgress() (percentage int32, message string, nestingDepth int32, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.progress.percentage, c.progress.message, 1, nil
+}
+
+// GetCompatible returns the system’s compatible string.
+func (c *CLI) GetCompatible() (string, error) {
+	s, err := c.status()
+	if err != nil {
+		return "", callError("GetCompatible", err)
+	}
+
+	return s.Compatible, nil
+}
+
+// GetVariant returns the system’s variant.
+func (c *CLI) GetVariant() (string, error) {
+	s, err := c.status()
+	if err != nil {
+		return "", callError("GetVariant", err)
+	}
+
+	return s.Variant, nil
+}
+
+// GetBootSlot returns the currently used boot slot.
+func (c *CLI) GetBootSlot() (string, error) {
+	s, err := c.status()
+	if err != nil {
+		return "", callError("GetBootSlot", err)
+	}
+
+	return s.Booted, nil
+}
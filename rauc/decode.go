@@ -0,0 +1,119 @@
+package rauc
+
+import (
+	"fmt"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// DecodeWarning describes a value of an unexpected type sent by the
+// daemon, e.g. by a RAUC version with a different API. The value is
+// replaced by its zero value.
+type DecodeWarning struct {
+	// Name is the property or slot status key.
+	Name string
+	// Expected and Got are D-Bus signatures.
+	Expected string
+	Got      string
+}
+
+func (w DecodeWarning) String() string {
+	return fmt.Sprintf("%s: expected type %s, got %s", w.Name, w.Expected, w.Got)
+}
+
+// DecodeWarningEvent is published for each DecodeWarning.
+type DecodeWarningEvent struct {
+	Warning DecodeWarning
+}
+
+// EventType implements Event.
+func (DecodeWarningEvent) EventType() string { return "decode.warning" }
+
+func (p *Installer) warn(name, expected string, got interface{}) {
+	p.events.Publish(DecodeWarningEvent{
+		Warning: DecodeWarning{
+			Name:     name,
+			Expected: expected,
+			Got:      signatureOf(got),
+		},
+	})
+}
+
+// signatureOf returns the signature of a decoded value, without panicking
+// on values that have none.
+func signatureOf(v interface{}) (signature string) {
+	if v == nil {
+		return ""
+	}
+
+	defer func() {
+		if recover() != nil {
+			signature = fmt.Sprintf("%T", v)
+		}
+	}()
+
+	return dbus.SignatureOf(v).String()
+}
+
+// stringValue returns the string held by v, or an empty string and a
+// warning if it holds a different type.
+func (p *Installer) stringValue(name string, v dbus.Variant) string {
+	s, ok := v.Value().(string)
+	if !ok {
+		p.warn(name, "s", v.Value())
+	}
+
+	return s
+}
+
+// toInt32 converts any D-Bus integer type to int32.
+func toInt32(v interface{}) (int32, bool) {
+	switch n := v.(type) {
+	case int32:
+		return n, true
+	case byte:
+		return int32(n), true
+	case int16:
+		return int32(n), true
+	case uint16:
+		return int32(n), true
+	case uint32:
+		return int32(n), true
+	case int64:
+		return int32(n), true
+	case uint64:
+		return int32(n), true
+	}
+
+	return 0, false
+}
+
+// decodeProgress decodes a Progress value, tolerating integers of other
+// widths. ok is false if v does not resemble a progress tuple at all.
+func decodeProgress(v interface{}) (percentage int32, message string, nestingDepth int32, ok bool) {
+	fields, isSlice := v.([]interface{})
+	if variants, isVariants := v.([]dbus.Variant); isVariants {
+		fields, isSlice = make([]interface{}, len(variants)), true
+		for i := range variants {
+			fields[i] = variants[i]
+		}
+	}
+
+	if !isSlice || len(fields) < 3 {
+		return 0, "", 0, false
+	}
+
+	values := make([]interface{}, 3)
+	for i := range values {
+		values[i] = fields[i]
+		if v, isVariant := fields[i].(dbus.Variant); isVariant {
+			values[i] = v.Value()
+		}
+	}
+
+	percentage, ok1 := toInt32(values[0])
+	message, ok2 := values[1].(string)
+	nestingDepth, ok3 := toInt32(values[2])
+
+	return percentage, message, nestingDepth, ok1 && ok2 && ok3
+}
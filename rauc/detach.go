@@ -0,0 +1,195 @@
+package rauc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// AttachStateFile is where Detach stores the state of the installation in
+// flight. It lives on a tmpfs, as an installation does not survive a reboot.
+var AttachStateFile = "/run/go-rauc/install.json"
+
+var (
+	// ErrDetached is returned by InstallBundle and AttachToCurrentOperation
+	// when Detach is called while they wait.
+	ErrDetached = errors.New("RAUC: detached from installation")
+	// ErrNothingToAttach is returned by AttachToCurrentOperation if no
+	// state was stored by Detach.
+	ErrNothingToAttach = errors.New("RAUC: no detached installation")
+)
+
+// AttachState is the state stored by Detach.
+type AttachState struct {
+	Bundle  string    `json:"bundle"`
+	Started time.Time `json:"started"`
+	// Installed holds the installation stamps of the slots when the
+	// installation started, see installStamps. They tell the result if
+	// the installation completes while detached.
+	Installed map[string]string `json:"installed"`
+}
+
+// installStamps returns the installation timestamp and count of every slot,
+// by slot name. RAUC updates them for the slots a successful installation
+// wrote to.
+func (p *Installer) installStamps(ctx context.Context) (map[string]string, error) {
+	slots, err := p.GetSlotStatusContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stamps := make(map[string]string, len(slots))
+	for _, s := range slots {
+		stamps[s.SlotName] = fmt.Sprintf("%v/%v", s.Status[SlotKeyInstalledTimestamp].Value(), s.Status[SlotKeyInstalledCount].Value())
+	}
+
+	return stamps, nil
+}
+
+// installResult tells the result of an installation that completed while
+// no one listened for its Completed signal: it succeeded if it updated the
+// installation stamps of a slot, and failed with the daemon's LastError
+// otherwise.
+func (p *Installer) installResult(ctx context.Context, s *AttachState) error {
+	if s.Installed == nil {
+		return errorf("", nil, "RAUC: installation of %s completed with unknown result", s.Bundle)
+	}
+
+	stamps, err := p.installStamps(ctx)
+	if err != nil {
+		return err
+	}
+
+	for name, stamp := range stamps {
+		if before, ok := s.Installed[name]; ok && before != stamp {
+			return nil
+		}
+	}
+
+	lastError, err := p.GetLastErrorContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if lastError == "" {
+		lastError = "installation failed"
+	}
+
+	return installError(lastError)
+}
+
+// InstallDetachedEvent is published when an installation is detached from.
+type InstallDetachedEvent struct {
+	Bundle string
+}
+
+// EventType implements Event.
+func (InstallDetachedEvent) EventType() string { return "install.detached" }
+
+// track records s as the installation in flight and returns the channel
+// closed by Detach.
+func (p *Installer) track(s *AttachState) <-chan struct{} {
+	p.detachMutex.Lock()
+	defer p.detachMutex.Unlock()
+
+	p.current = s
+	if p.detach == nil {
+		p.detach = make(chan struct{})
+	}
+
+	return p.detach
+}
+
+func (p *Installer) untrack() {
+	p.detachMutex.Lock()
+	p.current = nil
+	p.detachMutex.Unlock()
+}
+
+// Detach makes InstallBundle or AttachToCurrentOperation calls that are
+// waiting return ErrDetached, without touching the installation itself.
+// The state needed by AttachToCurrentOperation is written to
+// AttachStateFile. Detach does nothing if no installation is in flight.
+func (p *Installer) Detach() error {
+	p.detachMutex.Lock()
+	defer p.detachMutex.Unlock()
+
+	if p.current == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(p.current)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(AttachStateFile), 0755); err != nil {
+		return fmt.Errorf("RAUC: Detach(): %v", err)
+	}
+
+	if err := ioutil.WriteFile(AttachStateFile, data, 0644); err != nil {
+		return fmt.Errorf("RAUC: Detach(): %v", err)
+	}
+
+	p.current = nil
+	if p.detach != nil {
+		close(p.detach)
+		p.detach = nil
+	}
+
+	return nil
+}
+
+// AttachToCurrentOperation waits for an installation that was detached
+// from with Detach, possibly by a different process, to complete. It
+// returns the bundle and the result of the installation, which may have
+// completed in the meantime.
+func (p *Installer) AttachToCurrentOperation(options InstallBundleOptions) (bundle string, err error) {
+	return p.AttachToCurrentOperationContext(context.Background(), options)
+}
+
+// AttachToCurrentOperationContext is AttachToCurrentOperation, but stops
+// waiting when ctx is done. The state is kept for a later attempt then.
+func (p *Installer) AttachToCurrentOperationContext(ctx context.Context, options InstallBundleOptions) (bundle string, err error) {
+	data, err := ioutil.ReadFile(AttachStateFile)
+	if os.IsNotExist(err) {
+		return "", ErrNothingToAttach
+	} else if err != nil {
+		return "", fmt.Errorf("RAUC: AttachToCurrentOperation(): %v", err)
+	}
+
+	var state AttachState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", fmt.Errorf("RAUC: AttachToCurrentOperation(): %v", err)
+	}
+
+	// Subscribe before looking at the operation, to not miss the signal.
+	doneChannel, unsubscribe := p.SubscribeSignals(64)
+	defer unsubscribe()
+
+	call := p.call(ctx, "org.freedesktop.DBus.Properties.Get", p.iface, "Operation")
+
+	var operation string
+	if err := call.Store(&operation); err != nil {
+		return state.Bundle, callError("GetOperation", err)
+	}
+
+	defer func() {
+		if !errors.Is(err, ErrDetached) && !errors.Is(err, ErrInstallTimeout) && !errors.Is(err, ErrInstallStalled) && ctx.Err() == nil {
+			os.Remove(AttachStateFile)
+		}
+	}()
+
+	if Operation(operation) != OperationInstalling {
+		return state.Bundle, p.installResult(ctx, &state)
+	}
+
+	detach := p.track(&state)
+
+	return state.Bundle, p.waitForCompletion(ctx, state.Bundle, doneChannel, p.completionFilter(call.ResponseSequence), detach, options)
+}
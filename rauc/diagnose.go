@@ -0,0 +1,196 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CheckStatus is the outcome of a diagnostic check.
+type CheckStatus string
+
+// Check outcomes.
+const (
+	CheckOK      CheckStatus = "ok"
+	CheckWarning CheckStatus = "warning"
+	CheckFailed  CheckStatus = "failed"
+	// CheckSkipped is used for checks that depend on a failed one.
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// Check is the result of a single diagnostic check.
+type Check struct {
+	Name   string      `json:"name" yaml:"name"`
+	Status CheckStatus `json:"status" yaml:"status"`
+	Detail string      `json:"detail,omitempty" yaml:"detail,omitempty"`
+	// Remedy hints at how to fix a problem.
+	Remedy string `json:"remedy,omitempty" yaml:"remedy,omitempty"`
+}
+
+// diagnosis collects checks.
+type diagnosis []Check
+
+func (d *diagnosis) add(name string, status CheckStatus, detail, remedy string) {
+	*d = append(*d, Check{Name: name, Status: status, Detail: detail, Remedy: remedy})
+}
+
+// Diagnose checks the RAUC setup of the system: the daemon and its
+// service, system.conf, the slots and the state left by previous
+// installations. It stops early if the context is done.
+func Diagnose(ctx context.Context, b Backend) []Check {
+	var d diagnosis
+
+	operation, err := b.GetOperation()
+	if err != nil {
+		d.add("daemon", CheckFailed, err.Error(), "Check that the RAUC daemon runs, e.g. with \"systemctl status rauc\".")
+	} else {
+		d.add("daemon", CheckOK, "operation "+operation, "")
+	}
+	daemon := err == nil
+
+	if p, ok := b.(*Installer); ok && ctx.Err() == nil {
+		d.service(p)
+	}
+
+	if ctx.Err() == nil {
+		d.system(b, daemon)
+	}
+
+	if ctx.Err() == nil {
+		d.lastError(b, daemon)
+		d.lock()
+		d.reboot()
+	}
+
+	return d
+}
+
+func (d *diagnosis) service(p *Installer) {
+	s, err := p.GetServiceState()
+	switch {
+	case err != nil:
+		d.add("service", CheckSkipped, err.Error(), "")
+	case s.ActiveState != "active":
+		d.add("service", CheckFailed, s.String(), "See \"journalctl -u "+ServiceUnit+"\" for why the service is not active.")
+	case s.NRestarts > 0:
+		d.add("service", CheckWarning, s.String(), "See \"journalctl -u "+ServiceUnit+"\" for why the service restarted.")
+	default:
+		d.add("service", CheckOK, s.String(), "")
+	}
+}
+
+func (d *diagnosis) system(b Backend, daemon bool) {
+	config, err := ReadSystemConfig("")
+	if err != nil {
+		d.add("system-config", CheckFailed, err.Error(), "Install a system.conf at one of "+strings.Join(SystemConfigPaths, ", ")+".")
+	} else {
+		d.add("system-config", CheckOK, fmt.Sprintf("%d slots", len(config.Slots)), "")
+	}
+
+	if !daemon {
+		d.add("compatible", CheckSkipped, "", "")
+		d.add("slots", CheckSkipped, "", "")
+		d.add("booted-slot", CheckSkipped, "", "")
+		return
+	}
+
+	compatible, err := b.GetCompatible()
+	switch {
+	case err != nil:
+		d.add("compatible", CheckFailed, err.Error(), "")
+	case config != nil && config.Compatible != compatible:
+		d.add("compatible", CheckWarning,
+			fmt.Sprintf("daemon reports %q, system.conf has %q", compatible, config.Compatible),
+			"Restart the daemon to pick up the current system.conf.")
+	default:
+		d.add("compatible", CheckOK, compatible, "")
+	}
+
+	slots, err := b.GetSlotStatus()
+	if err != nil {
+		d.add("slots", CheckFailed, err.Error(), "")
+		d.add("booted-slot", CheckSkipped, "", "")
+		return
+	}
+
+	var missing []string
+	if config != nil {
+		for _, c := range config.Slots {
+			found := false
+			for _, s := range slots {
+				found = found || s.SlotName == c.Name
+			}
+			if !found {
+				missing = append(missing, c.Name)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		d.add("slots", CheckWarning, "not reported by the daemon: "+strings.Join(missing, ", "),
+			"Restart the daemon to pick up the current system.conf.")
+	} else {
+		d.add("slots", CheckOK, fmt.Sprintf("%d slots", len(slots)), "")
+	}
+
+	for _, s := range slots {
+		if state, _ := s.GetString(SlotKeyState); state != SlotStateBooted {
+			continue
+		}
+
+		bootStatus, _ := s.GetString(SlotKeyBootStatus)
+		if bootStatus == BootStatusBad {
+			d.add("booted-slot", CheckWarning, s.SlotName+" is booted but marked bad",
+				"Mark the slot good with \"rauc status mark-good\" once the system works.")
+		} else {
+			d.add("booted-slot", CheckOK, s.SlotName, "")
+		}
+		return
+	}
+
+	d.add("booted-slot", CheckFailed, "no slot is marked as booted",
+		"Check that the kernel command line has rauc.slot= or that the bootloader integration works.")
+}
+
+func (d *diagnosis) lastError(b Backend, daemon bool) {
+	if !daemon {
+		d.add("last-error", CheckSkipped, "", "")
+		return
+	}
+
+	lastError, err := b.GetLastError()
+	switch {
+	case err != nil:
+		d.add("last-error", CheckFailed, err.Error(), "")
+	case lastError != "":
+		d.add("last-error", CheckWarning, lastError, "The last installation failed, see the daemon's log for details.")
+	default:
+		d.add("last-error", CheckOK, "", "")
+	}
+}
+
+func (d *diagnosis) lock() {
+	l, err := TryLockInstall()
+	switch {
+	case errors.Is(err, ErrLocked):
+		d.add("install-lock", CheckWarning, "held by another process", "Wait for the other installation to finish.")
+	case err != nil:
+		d.add("install-lock", CheckWarning, err.Error(), "Check that "+InstallLockFile+" can be created.")
+	default:
+		l.Unlock()
+		d.add("install-lock", CheckOK, "", "")
+	}
+}
+
+func (d *diagnosis) reboot() {
+	required, reason, err := RebootRequired()
+	switch {
+	case err != nil:
+		d.add("reboot-required", CheckWarning, err.Error(), "")
+	case required:
+		d.add("reboot-required", CheckWarning, reason, "Reboot to activate the installed update.")
+	default:
+		d.add("reboot-required", CheckOK, "", "")
+	}
+}
@@ -0,0 +1,154 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// Causes of failures reported by RAUC or the bus. Errors returned by the
+// backends match them with errors.Is if the failure could be classified.
+var (
+	// ErrIncompatibleBundle is the cause of installations of bundles for
+	// a different compatible.
+	ErrIncompatibleBundle = errors.New("RAUC: bundle is not compatible with the system")
+	// ErrSignatureInvalid is the cause of installations of bundles whose
+	// signature cannot be verified.
+	ErrSignatureInvalid = errors.New("RAUC: bundle signature is invalid")
+	// ErrNoSpace is the cause of installations that ran out of space.
+	ErrNoSpace = errors.New("RAUC: no space left on device")
+	// ErrBusy is the cause of calls rejected because the daemon is
+	// processing a different method.
+	ErrBusy = errors.New("RAUC: daemon is busy")
+	// ErrSlotNotFound is the cause of calls for a slot that does not
+	// exist, and is returned by GetBootedSlot and GetOtherSlot if no slot
+	// matches.
+	ErrSlotNotFound = errors.New("RAUC: slot not found")
+	// ErrDBusUnavailable is the cause of calls that did not reach the
+	// daemon, because the bus or the daemon is not running.
+	ErrDBusUnavailable = errors.New("RAUC: D-Bus service unavailable")
+	// ErrNotSupported is the cause of calls of methods or properties the
+	// daemon does not know, usually because it is too old.
+	ErrNotSupported = errors.New("RAUC: not supported by the daemon")
+)
+
+// Error is a failure reported by RAUC or the bus. Use errors.As to get it,
+// and errors.Is to compare its Cause.
+type Error struct {
+	// Method is the method that failed, empty for failed installations.
+	Method string
+	// Name is the D-Bus error name, if any.
+	Name string
+	// Message is the error message of RAUC, the bus or the rauc tool.
+	Message string
+	// Cause is one of the Err variables above, or nil if the failure
+	// could not be classified.
+	Cause error
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Method == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("RAUC: %s(): %s", e.Method, e.Message)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the cause of e.
+func (e *Error) Is(target error) bool {
+	return e.Cause != nil && target == e.Cause
+}
+
+// errorf returns an Error of method with cause.
+func errorf(method string, cause error, format string, args ...interface{}) *Error {
+	return &Error{
+		Method:  method,
+		Message: fmt.Sprintf(format, args...),
+		Cause:   cause,
+	}
+}
+
+// callError wraps err, the failure of a call of method.
+func callError(method string, err error) error {
+	e := &Error{
+		Method:  method,
+		Message: err.Error(),
+		Err:     err,
+	}
+
+	var value dbus.Error
+	var pointer *dbus.Error
+	if errors.As(err, &value) {
+		e.Name = value.Name
+	} else if errors.As(err, &pointer) {
+		e.Name = pointer.Name
+	}
+
+	if errors.Is(err, dbus.ErrClosed) {
+		e.Cause = ErrDBusUnavailable
+	} else {
+		e.Cause = causeOf(e.Name, e.Message)
+	}
+
+	return e
+}
+
+// installError returns the failure of an installation, given the LastError
+// of the daemon.
+func installError(lastError string) error {
+	return &Error{
+		Message: lastError,
+		Cause:   causeOf("", lastError),
+	}
+}
+
+var errorNameCauses = map[string]error{
+	"org.freedesktop.DBus.Error.ServiceUnknown":  ErrDBusUnavailable,
+	"org.freedesktop.DBus.Error.NameHasNoOwner":  ErrDBusUnavailable,
+	"org.freedesktop.DBus.Error.NoReply":         ErrDBusUnavailable,
+	"org.freedesktop.DBus.Error.NoServer":        ErrDBusUnavailable,
+	"org.freedesktop.DBus.Error.Disconnected":    ErrDBusUnavailable,
+	"org.freedesktop.DBus.Error.UnknownMethod":   ErrNotSupported,
+	"org.freedesktop.DBus.Error.UnknownProperty": ErrNotSupported,
+}
+
+// errorMessageCauses maps parts of RAUC's error messages to causes. RAUC
+// reports most failures as org.freedesktop.DBus.Error.Failed, so only the
+// message tells them apart.
+var errorMessageCauses = []struct {
+	text  string
+	cause error
+}{
+	{"compatible mismatch", ErrIncompatibleBundle},
+	{"signature verification failed", ErrSignatureInvalid},
+	{"failed to verify signature", ErrSignatureInvalid},
+	{"no space left on device", ErrNoSpace},
+	{"already processing a different method", ErrBusy},
+	{"no slot with", ErrSlotNotFound},
+	{"too small to contain a valid signature", ErrNotABundle},
+}
+
+// causeOf classifies a failure by its D-Bus error name and message.
+func causeOf(name, message string) error {
+	if cause, ok := errorNameCauses[name]; ok {
+		return cause
+	}
+
+	message = strings.ToLower(message)
+	for _, c := range errorMessageCauses {
+		if strings.Contains(message, c.text) {
+			return c.cause
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+func TestCauseOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		cause   error
+	}{
+		{name: "org.freedesktop.DBus.Error.ServiceUnknown", cause: ErrDBusUnavailable},
+		{name: "org.freedesktop.DBus.Error.NameHasNoOwner", cause: ErrDBusUnavailable},
+		{name: "org.freedesktop.DBus.Error.NoReply", cause: ErrDBusUnavailable},
+		{name: "org.freedesktop.DBus.Error.UnknownMethod", cause: ErrNotSupported},
+		{name: "org.freedesktop.DBus.Error.UnknownProperty", cause: ErrNotSupported},
+		{name: "org.freedesktop.DBus.Error.UnknownMethod", message: "compatible mismatch", cause: ErrNotSupported},
+		{name: "org.freedesktop.DBus.Error.Failed", message: "Compatible mismatch: Expected 'a' but bundle manifest has 'b'", cause: ErrIncompatibleBundle},
+		{message: "signature verification failed: unable to get local issuer certificate", cause: ErrSignatureInvalid},
+		{message: "Failed to verify signature", cause: ErrSignatureInvalid},
+		{message: "Failed to copy image: No space left on device", cause: ErrNoSpace},
+		{message: "Already processing a different method", cause: ErrBusy},
+		{message: "No slot with class rootfs and name foo found", cause: ErrSlotNotFound},
+		{message: "File size too small to contain a valid signature", cause: ErrNotABundle},
+		{name: "org.freedesktop.DBus.Error.Failed", message: "Installation error"},
+		{},
+	}
+
+	for _, tt := range tests {
+		if cause := causeOf(tt.name, tt.message); cause != tt.cause {
+			t.Errorf("causeOf(%q, %q) = %v, want %v", tt.name, tt.message, cause, tt.cause)
+		}
+	}
+}
+
+func TestCallError(t *testing.T) {
+	tests := []struct {
+		desc  string
+		err   error
+		name  string
+		cause error
+	}{
+		{
+			desc:  "value",
+			err:   dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown", Body: []interface{}{"not activatable"}},
+			name:  "org.freedesktop.DBus.Error.ServiceUnknown",
+			cause: ErrDBusUnavailable,
+		},
+		{
+			desc:  "pointer",
+			err:   dbus.NewError("org.freedesktop.DBus.Error.Failed", []interface{}{"Already processing a different method"}),
+			name:  "org.freedesktop.DBus.Error.Failed",
+			cause: ErrBusy,
+		},
+		{
+			desc:  "wrapped",
+			err:   fmt.Errorf("call: %w", dbus.NewError("org.freedesktop.DBus.Error.UnknownMethod", nil)),
+			name:  "org.freedesktop.DBus.Error.UnknownMethod",
+			cause: ErrNotSupported,
+		},
+		{
+			desc:  "closed",
+			err:   dbus.ErrClosed,
+			cause: ErrDBusUnavailable,
+		},
+		{
+			desc: "unclassified",
+			err:  errors.New("something else"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := callError("Install", tt.err)
+
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("got %T, want *Error", err)
+			}
+
+			if e.Method != "Install" || e.Name != tt.name || e.Cause != tt.cause {
+				t.Fatalf("got method %q, name %q, cause %v, want Install, %q, %v", e.Method, e.Name, e.Cause, tt.name, tt.cause)
+			}
+
+			if want := "RAUC: Install(): " + tt.err.Error(); err.Error() != want {
+				t.Errorf("got message %q, want %q", err.Error(), want)
+			}
+
+			if !reflect.DeepEqual(errors.Unwrap(err), tt.err) {
+				t.Errorf("%v does not wrap %v", err, tt.err)
+			}
+
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("%v is not %v", err, tt.cause)
+			}
+		})
+	}
+}
+
+func TestInstallError(t *testing.T) {
+	err := installError("Installation error: Compatible mismatch")
+
+	if err.Error() != "Installation error: Compatible mismatch" {
+		t.Errorf("got message %q, want LastError as is", err.Error())
+	}
+
+	if !errors.Is(err, ErrIncompatibleBundle) {
+		t.Errorf("%v is not ErrIncompatibleBundle", err)
+	}
+
+	if errors.Is(installError("failed"), ErrIncompatibleBundle) {
+		t.Error("unclassified error matches ErrIncompatibleBundle")
+	}
+}
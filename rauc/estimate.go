@@ -0,0 +1,138 @@
+package rauc
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateSmoothing is the weight of the latest observation in the smoothed
+// progress rate.
+const rateSmoothing = 0.3
+
+// ProgressEstimate is the output of a ProgressAggregator.
+type ProgressEstimate struct {
+	// Progress is the last value passed to Update.
+	Progress Progress
+	// Overall is the overall percentage. It never decreases.
+	Overall float64
+	Elapsed time.Duration
+	// ETA is the estimated time until completion, or zero if unknown.
+	ETA time.Duration
+}
+
+// ProgressAggregator turns the raw Progress values of an installation into
+// a monotonic overall percentage and an estimate of the remaining time.
+//
+// The daemon only updates the percentage when a step finishes, so long
+// running steps such as copying an image show no change for minutes.
+// While the percentage does not change, the aggregator advances Overall at
+// the observed rate, up to a share of the remaining percentage which halves
+// with every nesting level of the current step.
+type ProgressAggregator struct {
+	mutex sync.Mutex
+
+	start   time.Time
+	changed time.Time
+	last    Progress
+	// peak is the highest percentage reported so far.
+	peak    int32
+	overall float64
+	// rate is the smoothed progress in percent per second.
+	rate float64
+}
+
+// ProgressAggregatorNew returns a ProgressAggregator for an installation
+// that starts now.
+func ProgressAggregatorNew() *ProgressAggregator {
+	a := new(ProgressAggregator)
+	a.Reset()
+
+	return a
+}
+
+// Reset starts over for a new installation.
+func (a *ProgressAggregator) Reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.start = time.Now()
+	a.changed = a.start
+	a.last = Progress{}
+	a.peak = 0
+	a.overall = 0
+	a.rate = 0
+}
+
+// Update feeds a new Progress value to the aggregator and returns the
+// resulting estimate.
+func (a *ProgressAggregator) Update(p Progress) ProgressEstimate {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+
+	if p.Percentage > a.peak {
+		if dt := now.Sub(a.changed).Seconds(); dt > 0 {
+			r := float64(p.Percentage-a.peak) / dt
+			if a.rate == 0 {
+				a.rate = r
+			} else {
+				a.rate = rateSmoothing*r + (1-rateSmoothing)*a.rate
+			}
+		}
+		a.peak = p.Percentage
+		a.changed = now
+	}
+
+	a.last = p
+
+	return a.estimate(now)
+}
+
+// Estimate returns the current estimate without a new Progress value.
+func (a *ProgressAggregator) Estimate() ProgressEstimate {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.estimate(time.Now())
+}
+
+// estimate computes the estimate at now. The caller holds the mutex.
+func (a *ProgressAggregator) estimate(now time.Time) ProgressEstimate {
+	reported := math.Min(float64(a.peak), 100)
+
+	overall := reported
+	if reported < 100 {
+		depth := a.last.NestingDepth
+		if depth < 1 {
+			depth = 1
+		}
+
+		share := (100 - reported) / math.Pow(2, float64(depth+1))
+		overall += math.Min(a.rate*now.Sub(a.changed).Seconds(), share)
+	}
+
+	if overall > a.overall {
+		a.overall = overall
+	}
+
+	e := ProgressEstimate{
+		Progress: a.last,
+		Overall:  a.overall,
+		Elapsed:  now.Sub(a.start),
+	}
+
+	rate := a.rate
+	if elapsed := e.Elapsed.Seconds(); elapsed > 0 && a.overall > 0 {
+		// Blend in the average rate so that one quick step does not
+		// dominate the estimate.
+		rate = (rate + a.overall/elapsed) / 2
+	}
+
+	if rate > 0 && a.overall < 100 {
+		e.ETA = time.Duration((100 - a.overall) / rate * float64(time.Second))
+	}
+
+	return e
+}
@@ -0,0 +1,84 @@
+package rauc
+
+import (
+	"sync"
+)
+
+// Event is implemented by all values published on an EventBus.
+type Event interface {
+	// EventType returns a short, stable identifier for the kind of event.
+	EventType() string
+}
+
+// EventBus distributes events to any number of subscribers. Publishing never
+// blocks; events are dropped for subscribers that do not keep up.
+type EventBus struct {
+	mutex       sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// EventBusNew returns a newly allocated EventBus object
+func EventBusNew() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives all events published after the
+// call, buffered to hold up to size events. The returned function cancels
+// the subscription and closes the channel.
+func (b *EventBus) Subscribe(size int) (<-chan Event, func()) {
+	c := make(chan Event, size)
+
+	b.mutex.Lock()
+	b.subscribers[c] = struct{}{}
+	b.mutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, c)
+			b.mutex.Unlock()
+			close(c)
+		})
+	}
+
+	return c, cancel
+}
+
+// Publish sends an event to all current subscribers.
+func (b *EventBus) Publish(e Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for c := range b.subscribers {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// InstallStartedEvent is published when the daemon has accepted an
+// installation request made by InstallBundle.
+type InstallStartedEvent struct {
+	Bundle string
+}
+
+// EventType implements Event.
+func (e InstallStartedEvent) EventType() string {
+	return "install.started"
+}
+
+// InstallCompletedEvent is published when an installation started by
+// InstallBundle has ended. Err is nil on success.
+type InstallCompletedEvent struct {
+	Bundle string
+	Err    error
+}
+
+// EventType implements Event.
+func (e InstallCompletedEvent) EventType() string {
+	return "install.completed"
+}
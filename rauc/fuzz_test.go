@@ -0,0 +1,134 @@
+//go:build go1.18
+// +build go1.18
+
+package rauc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// keyFileSeeds are inputs the key file parser has to handle: byte order
+// marks, lines longer than bufio's default token size, repeated sections,
+// escapes and invalid names.
+var keyFileSeeds = []string{
+	"[system]\ncompatible=board\nbootloader=uboot\n",
+	"\xef\xbb\xbf[system]\ncompatible=board\n",
+	"# comment\n\n[a]\nk = v \n",
+	"[a]\nx=1\n[b]\ny=2\n[a]\nx=3\n",
+	"[a]\nv=\\s\\n\\t\\r\\\\\\q\\\n",
+	"[hooks]\nhooks=" + strings.Repeat("install-check;", 10000) + "\n",
+	"[]\n",
+	"[a]]\n",
+	"[a\n",
+	"[a\x01]\n",
+	"key=outside\n",
+	"[a]\nno-equals\n",
+	"[a]\n=value\n",
+	"[a]\nk\x7f=v\n",
+}
+
+func FuzzParseKeyFile(f *testing.F) {
+	for _, seed := range keyFileSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data string) {
+		sections, err := parseKeyFile(strings.NewReader(data), "fuzz")
+		if err != nil {
+			return
+		}
+
+		for name, keys := range sections {
+			if name == "" || strings.ContainsAny(name, "[]") || hasControl(name) {
+				t.Fatalf("invalid section name %q accepted", name)
+			}
+
+			for key := range keys {
+				if key == "" || hasControl(key) {
+					t.Fatalf("invalid key %q accepted in [%s]", key, name)
+				}
+			}
+		}
+	})
+}
+
+func FuzzParseMountInfo(f *testing.F) {
+	f.Add("22 1 179:2 / / rw,relatime shared:1 - ext4 /dev/mmcblk0p2 rw\n")
+	f.Add("23 22 0:21 / /mnt/with\\040space ro - tmpfs tmp\\011fs ro\n")
+	f.Add("24 22 0:22 / /a rw master:1 shared:2 - overlay overlay rw,lowerdir=" + strings.Repeat("/l:", 30000) + "/l\n")
+	f.Add("25 22 0:23 / /a rw - ext4\n")
+	f.Add("26 22 x:y / /a rw - ext4 /dev/x rw\n")
+	f.Add("27 22 0:24 / /a \\777 - ext4 /dev/x\\0 rw\n")
+	f.Add("-\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		mounts, err := parseMountInfo(strings.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		for _, m := range mounts {
+			if m.FSType == "" {
+				t.Fatalf("mount without filesystem type accepted: %+v", m)
+			}
+		}
+	})
+}
+
+func FuzzParseManifest(f *testing.F) {
+	for _, seed := range keyFileSeeds {
+		f.Add(seed)
+	}
+	f.Add(testManifest)
+	f.Add("[update]\ncompatible=board\n[image.]\nfilename=x\n")
+	f.Add("[image.rootfs.]\n")
+	f.Add("[image.rootfs]\nsize=-1\n")
+	f.Add("[image.rootfs]\nsize=99999999999999999999\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		b, err := ParseManifest(strings.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		if len(b.ManifestHash) != 64 {
+			t.Fatalf("invalid manifest hash %q", b.ManifestHash)
+		}
+
+		for _, image := range b.Images {
+			if image.SlotClass == "" || image.Size < 0 {
+				t.Fatalf("invalid image accepted: %+v", image)
+			}
+		}
+	})
+}
+
+func FuzzParseStatusFile(f *testing.F) {
+	for _, seed := range keyFileSeeds {
+		f.Add(seed)
+	}
+	f.Add(testStatusFile)
+	f.Add("[slot]\nbundle.version=1\ninstalled.count=1\n")
+	f.Add("[slot.]\n")
+	f.Add("[slot.rootfs.0]\ninstalled.count=4294967296\n")
+	f.Add("[slot.rootfs.0]\nsize=-5\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		status, err := ParseStatusFile(bytes.NewReader([]byte(data)))
+		if err != nil {
+			return
+		}
+
+		for _, s := range status {
+			for key, v := range s.Status {
+				if strings.HasSuffix(key, ".count") {
+					if _, ok := v.Value().(uint32); !ok {
+						t.Fatalf("%s of %q is %T", key, s.SlotName, v.Value())
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package rauc
+
+import (
+	"context"
+)
+
+// Gate is a precondition that has to be met before an installation starts.
+type Gate interface {
+	// Check returns nil if the installation may proceed, or an error
+	// describing why it has to be deferred.
+	Check(ctx context.Context) error
+}
+
+// CheckGates runs all gates in order and returns the first error.
+func CheckGates(ctx context.Context, gates []Gate) error {
+	for _, g := range gates {
+		if err := g.Check(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
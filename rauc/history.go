@@ -0,0 +1,90 @@
+package rauc
+
+import (
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// ProgressStep is a progress report of the daemon and the time it was
+// seen.
+type ProgressStep struct {
+	Time time.Time `json:"time" yaml:"time"`
+	Progress
+}
+
+// ProgressHistory records the progress steps of an installation, so that
+// reports of a failed one can name the step it failed in. The zero value
+// is ready to use.
+type ProgressHistory struct {
+	mutex sync.Mutex
+	steps []ProgressStep
+}
+
+// Steps returns the recorded steps, oldest first.
+func (h *ProgressHistory) Steps() []ProgressStep {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return append([]ProgressStep(nil), h.steps...)
+}
+
+// Last returns the most recent step.
+func (h *ProgressHistory) Last() (ProgressStep, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.steps) == 0 {
+		return ProgressStep{}, false
+	}
+
+	return h.steps[len(h.steps)-1], true
+}
+
+// record appends p unless it repeats the last step. It may be called on
+// a nil history.
+func (h *ProgressHistory) record(p Progress) {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if n := len(h.steps); n > 0 && h.steps[n-1].Progress == p {
+		return
+	}
+
+	h.steps = append(h.steps, ProgressStep{Time: time.Now(), Progress: p})
+}
+
+// recordProgressSignal records the progress carried by a PropertiesChanged
+// signal, if any.
+func (h *ProgressHistory) recordProgressSignal(signal *dbus.Signal) {
+	if h == nil {
+		return
+	}
+
+	if p, ok := progressOfSignal(signal); ok {
+		h.record(p)
+	}
+}
+
+// progressOfSignal returns the progress carried by a PropertiesChanged
+// signal, if any.
+func progressOfSignal(signal *dbus.Signal) (Progress, bool) {
+	if signal.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" || len(signal.Body) < 2 {
+		return Progress{}, false
+	}
+
+	changed, _ := signal.Body[1].(map[string]dbus.Variant)
+	v, ok := changed["Progress"]
+	if !ok {
+		return Progress{}, false
+	}
+
+	percentage, message, depth, ok := decodeProgress(v.Value())
+
+	return Progress{Percentage: percentage, Message: message, NestingDepth: depth}, ok
+}
@@ -0,0 +1,122 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const hookDefaultTimeout = time.Minute
+
+// Hook points.
+const (
+	HookBeforeInstall  = "before-install"
+	HookAfterInstall   = "after-install"
+	HookBeforeActivate = "before-activate"
+)
+
+// ErrHookFailed is matched by errors.Is for HookError values.
+var ErrHookFailed = errors.New("RAUC: hook failed")
+
+// HookInfo is passed to hooks.
+type HookInfo struct {
+	Bundle string
+	// Err is the result of the installation, for AfterInstall hooks.
+	Err error
+}
+
+// Hook is a callback run at a hook point, e.g. to stop a database before
+// the installation. An error aborts the flow.
+type Hook struct {
+	Name string
+	Func func(ctx context.Context, info HookInfo) error
+	// Timeout defaults to one minute. The hook's context is cancelled
+	// when it expires, and the hook is considered failed.
+	Timeout time.Duration
+}
+
+// Hooks are the callbacks run around an installation.
+type Hooks struct {
+	// BeforeInstall hooks run before the installation is started. An
+	// error aborts it.
+	BeforeInstall []Hook
+	// AfterInstall hooks run after the installation ended, successfully
+	// or not. An error is returned in place of a successful result.
+	AfterInstall []Hook
+	// BeforeActivate hooks run before the system reboots into an update,
+	// e.g. by the agent. They are not run by InstallBundle.
+	BeforeActivate []Hook
+}
+
+// HookError is returned when a hook fails or times out.
+type HookError struct {
+	Point string
+	Name  string
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("RAUC: %s hook %s: %v", e.Point, e.Name, e.Err)
+}
+
+// Is makes errors.Is(err, ErrHookFailed) true.
+func (e *HookError) Is(target error) bool {
+	return target == ErrHookFailed
+}
+
+// Unwrap returns the error of the hook.
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// RunHooks runs hooks in order and returns a *HookError for the first one
+// that fails.
+func RunHooks(ctx context.Context, point string, hooks []Hook, info HookInfo) error {
+	for _, h := range hooks {
+		if err := runHook(ctx, h, info); err != nil {
+			return &HookError{Point: point, Name: h.Name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// runHook runs h with its timeout. Hooks that ignore their context are
+// abandoned when the timeout expires.
+func runHook(ctx context.Context, h Hook, info HookInfo) error {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = hookDefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.Func(ctx, info)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// afterInstall runs the AfterInstall hooks for the result err of the
+// installation of bundle and returns the result of InstallBundle.
+func afterInstall(hooks Hooks, bundle string, err error) error {
+	if errors.Is(err, ErrDetached) {
+		return err
+	}
+
+	hookErr := RunHooks(context.Background(), HookAfterInstall, hooks.AfterInstall, HookInfo{Bundle: bundle, Err: err})
+	if err != nil {
+		return err
+	}
+
+	return hookErr
+}
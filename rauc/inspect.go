@@ -0,0 +1,257 @@
+package rauc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// BundleImage describes an image in the manifest of a bundle.
+type BundleImage struct {
+	SlotClass string   `json:"slot_class" yaml:"slot_class"`
+	Variant   string   `json:"variant,omitempty" yaml:"variant,omitempty"`
+	Filename  string   `json:"filename" yaml:"filename"`
+	SHA256    string   `json:"sha256" yaml:"sha256"`
+	Size      int64    `json:"size" yaml:"size"`
+	Hooks     []string `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// Adaptive lists the adaptive update methods of the image, see
+	// AdaptiveBlockHashIndex.
+	Adaptive []string `json:"adaptive,omitempty" yaml:"adaptive,omitempty"`
+}
+
+// BundleInfo is the manifest of a bundle, as returned by InspectBundle.
+// Fields the backend does not report are left empty.
+type BundleInfo struct {
+	Compatible  string `json:"compatible" yaml:"compatible"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Build       string `json:"build,omitempty" yaml:"build,omitempty"`
+	// Format is "plain", "verity" or "crypt".
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// ManifestHash is the SHA-256 hash of the manifest, as checked by
+	// InstallBundleOptions.RequireManifestHash.
+	ManifestHash string `json:"manifest_hash,omitempty" yaml:"manifest_hash,omitempty"`
+	// VerityHash is the root hash of verity and crypt bundles.
+	VerityHash string `json:"verity_hash,omitempty" yaml:"verity_hash,omitempty"`
+	// Hooks are the bundle hooks, e.g. "install-check".
+	Hooks []string `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	// Handler is the custom install handler replacing RAUC's own.
+	Handler string        `json:"handler,omitempty" yaml:"handler,omitempty"`
+	Images  []BundleImage `json:"images" yaml:"images"`
+}
+
+// HashAlgorithms returns the hash algorithms the bundle relies on. RAUC
+// uses SHA-256 for image checksums and the verity hash tree.
+func (b *BundleInfo) HashAlgorithms() []string {
+	for _, image := range b.Images {
+		if image.SHA256 != "" {
+			return []string{"sha256"}
+		}
+	}
+
+	if b.VerityHash != "" {
+		return []string{"sha256"}
+	}
+
+	return nil
+}
+
+// Image returns the image for a slot class, preferring the one of the
+// given variant over the default image.
+func (b *BundleInfo) Image(class, variant string) (BundleImage, bool) {
+	var found *BundleImage
+
+	for i := range b.Images {
+		image := &b.Images[i]
+		if image.SlotClass != class {
+			continue
+		}
+
+		if image.Variant == variant && variant != "" {
+			return *image, true
+		}
+		if image.Variant == "" {
+			found = image
+		}
+	}
+
+	if found == nil {
+		return BundleImage{}, false
+	}
+
+	return *found, true
+}
+
+// InspectBundleOptions contains options for the InspectBundle method
+type InspectBundleOptions struct {
+	// TLSCert, TLSKey, TLSCA, TLSNoVerify and HTTPHeaders are used for
+	// bundles on HTTPS servers, as in InstallBundleOptions.
+	TLSCert     string
+	TLSKey      string
+	TLSCA       string
+	TLSNoVerify bool
+	HTTPHeaders []string
+	// Args are passed to the daemon in addition to the options above.
+	Args map[string]interface{}
+}
+
+func (options InspectBundleOptions) daemonArgs() map[string]interface{} {
+	return InstallBundleOptions{
+		TLSCert:     options.TLSCert,
+		TLSKey:      options.TLSKey,
+		TLSCA:       options.TLSCA,
+		TLSNoVerify: options.TLSNoVerify,
+		HTTPHeaders: options.HTTPHeaders,
+		Args:        options.Args,
+	}.daemonArgs()
+}
+
+// InspectBundle returns the manifest of a bundle. The D-Bus backend needs
+// RAUC 1.8 or later.
+func InspectBundle(b Backend, filename string, options InspectBundleOptions) (*BundleInfo, error) {
+	switch b := b.(type) {
+	case *Installer:
+		return b.InspectBundle(filename, options)
+	case *CLI:
+		return b.InspectBundle(filename, options)
+	case *Simulator:
+		return b.InspectBundle(filename, options)
+	}
+
+	return nil, ErrInspectNotSupported
+}
+
+// variantMap returns the dictionary stored for key in m.
+func variantMap(m map[string]dbus.Variant, key string) map[string]dbus.Variant {
+	v, _ := m[key].Value().(map[string]dbus.Variant)
+	return v
+}
+
+func variantString(m map[string]dbus.Variant, key string) string {
+	v, _ := m[key].Value().(string)
+	return v
+}
+
+func variantStrings(m map[string]dbus.Variant, key string) []string {
+	v, _ := m[key].Value().([]string)
+	return v
+}
+
+// bundleInfoOf decodes the reply of the InspectBundle method.
+func bundleInfoOf(info map[string]dbus.Variant) *BundleInfo {
+	manifest := variantMap(info, "manifest")
+	update := variantMap(manifest, "update")
+	bundle := variantMap(manifest, "bundle")
+
+	b := &BundleInfo{
+		Compatible:   variantString(update, "compatible"),
+		Version:      variantString(update, "version"),
+		Description:  variantString(update, "description"),
+		Build:        variantString(update, "build"),
+		Format:       variantString(bundle, "format"),
+		ManifestHash: variantString(info, "manifest-hash"),
+		VerityHash:   variantString(bundle, "verity-hash"),
+		Hooks:        variantStrings(variantMap(manifest, "hooks"), "hooks"),
+		Handler:      variantString(variantMap(manifest, "handler"), "filename"),
+	}
+
+	images, _ := manifest["images"].Value().([]map[string]dbus.Variant)
+	for _, image := range images {
+		size, _ := SlotStatus{Status: image}.GetInt("size")
+
+		b.Images = append(b.Images, BundleImage{
+			SlotClass: variantString(image, "slot-class"),
+			Variant:   variantString(image, "variant"),
+			Filename:  variantString(image, "filename"),
+			SHA256:    variantString(image, "checksum"),
+			Size:      size,
+			Hooks:     variantStrings(image, "hooks"),
+			Adaptive:  variantStrings(image, "adaptive"),
+		})
+	}
+
+	return b
+}
+
+// InspectBundle returns the manifest of a bundle.
+func (p *Installer) InspectBundle(filename string, options InspectBundleOptions) (*BundleInfo, error) {
+	return p.InspectBundleContext(context.Background(), filename, options)
+}
+
+// InspectBundleContext is InspectBundle, giving up when ctx is done.
+func (p *Installer) InspectBundleContext(ctx context.Context, filename string, options InspectBundleOptions) (*BundleInfo, error) {
+	path, err := p.bundlePath(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var info map[string]dbus.Variant
+
+	err = p.call(ctx, p.interfaceForMember("InspectBundle"), path, options.daemonArgs()).Store(&info)
+	if err != nil {
+		return nil, callError("InspectBundle", err)
+	}
+
+	return bundleInfoOf(info), nil
+}
+
+// InspectBundle returns the manifest of a bundle as reported by
+// "rauc info".
+func (c *CLI) InspectBundle(filename string, options InspectBundleOptions) (*BundleInfo, error) {
+	args := []string{"info", "--output-format=json"}
+	args = append(args, cliInstallArgs(options.daemonArgs())...)
+	args = append(args, filename)
+
+	out, err := c.run(args...)
+	if err != nil {
+		return nil, callError("InspectBundle", err)
+	}
+
+	var info struct {
+		Compatible  string   `json:"compatible"`
+		Version     string   `json:"version"`
+		Description string   `json:"description"`
+		Build       string   `json:"build"`
+		Hash        string   `json:"hash"`
+		Hooks       []string `json:"hooks"`
+		Images      []map[string]struct {
+			Variant  string   `json:"variant"`
+			Filename string   `json:"filename"`
+			Checksum string   `json:"checksum"`
+			Size     int64    `json:"size"`
+			Hooks    []string `json:"hooks"`
+			Adaptive []string `json:"adaptive"`
+		} `json:"images"`
+	}
+
+	if err := json.Unmarshal(out, &info); err != nil {
+		return nil, fmt.Errorf("RAUC: InspectBundle(): %v", err)
+	}
+
+	b := &BundleInfo{
+		Compatible:   info.Compatible,
+		Version:      info.Version,
+		Description:  info.Description,
+		Build:        info.Build,
+		ManifestHash: info.Hash,
+		Hooks:        info.Hooks,
+	}
+
+	for _, images := range info.Images {
+		for class, image := range images {
+			b.Images = append(b.Images, BundleImage{
+				SlotClass: class,
+				Variant:   image.Variant,
+				Filename:  image.Filename,
+				SHA256:    image.Checksum,
+				Size:      image.Size,
+				Hooks:     image.Hooks,
+				Adaptive:  image.Adaptive,
+			})
+		}
+	}
+
+	return b, nil
+}
@@ -1,23 +1,54 @@
 package rauc
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/internal/backoff"
 )
 
 // Installer is the central object interface that handles
 // all communication with the RAUC daemon
 type Installer struct {
-	conn   *dbus.Conn
-	object dbus.BusObject
+	busName string
+	path    dbus.ObjectPath
+	iface   string
+	events  *EventBus
+	cache   *propertyCache
+	signals *signalHub
+
+	// conn and object change when the connection is re-established.
+	connMutex sync.RWMutex
+	conn      *dbus.Conn
+	object    dbus.BusObject
+	dial      func() (*dbus.Conn, error)
+
+	checkBundles   bool
+	callTimeout    time.Duration
+	installTimeout time.Duration
+
+	detachMutex sync.Mutex
+	detach      chan struct{}
+	current     *AttachState
 }
 
 const (
 	dbusInterface = "de.pengutronix.rauc"
 )
 
+// busName returns the daemon's bus name configured by options.
+func (options InstallerOptions) busName() string {
+	if options.BusName == "" {
+		return dbusInterface
+	}
+
+	return options.BusName
+}
+
 // SlotStatus is returned by .GetSlotStatus() and contains information
 // on the status of an available boot slots.
 type SlotStatus struct {
@@ -25,55 +56,495 @@ type SlotStatus struct {
 	Status   map[string]dbus.Variant
 }
 
+// InstallerOptions contains options for the InstallerNewWithOptions function
+type InstallerOptions struct {
+	// WaitForDaemon is the maximum time to wait for the RAUC daemon to
+	// appear on the bus. This avoids failing with ServiceUnknown when
+	// started early at boot, before rauc.service is up. Zero disables waiting.
+	WaitForDaemon time.Duration
+	// Events is the bus the Installer publishes its events on. A private
+	// bus is created if nil.
+	Events *EventBus
+	// Conn is the connection to talk to the daemon on, such as the session
+	// bus or a private bus. Defaults to the shared system bus connection.
+	Conn *dbus.Conn
+	// Dial opens the connection to the daemon if Conn is nil, and opens
+	// it again after it was lost. Defaults to dbus.SystemBus. Connections
+	// passed in Conn are not re-established unless Dial is set.
+	Dial func() (*dbus.Conn, error)
+	// SkipReconnect leaves the Installer disconnected after the connection
+	// is lost, instead of re-establishing it.
+	SkipReconnect bool
+	// Cache keeps Compatible, Variant, BootSlot and the slot status until
+	// the daemon signals a change, instead of asking for them on every call.
+	Cache bool
+	// BusName, ObjectPath and InterfacePrefix locate the daemon, for
+	// patched daemons or additional instances. They default to
+	// "de.pengutronix.rauc", "/" and "de.pengutronix.rauc", the installer
+	// interface being InterfacePrefix + ".Installer".
+	BusName         string
+	ObjectPath      dbus.ObjectPath
+	InterfacePrefix string
+	// SkipBundleCheck passes bundle file names to the daemon as they are,
+	// instead of resolving and checking them with CheckBundlePath first.
+	SkipBundleCheck bool
+	// CallTimeout limits every call of the daemon, so that a hung daemon
+	// fails calls with context.DeadlineExceeded instead of blocking them.
+	// Zero disables the limit.
+	CallTimeout time.Duration
+	// InstallTimeout is the Timeout of installations whose
+	// InstallBundleOptions do not set one. Zero waits forever.
+	InstallTimeout time.Duration
+}
+
 // InstallerNew returns a newly allocated Installer object
 func InstallerNew() (*Installer, error) {
+	return InstallerNewWithOptions(InstallerOptions{})
+}
+
+// InstallerNewWithConn returns a newly allocated Installer object that
+// talks to the daemon on conn, e.g. a private bus in integration tests
+func InstallerNewWithConn(conn *dbus.Conn) (*Installer, error) {
+	return InstallerNewWithOptions(InstallerOptions{
+		Conn: conn,
+	})
+}
+
+// InstallerNewSessionBus returns a newly allocated Installer object that
+// talks to a daemon exported on the session bus
+func InstallerNewSessionBus() (*Installer, error) {
+	return InstallerNewWithOptions(InstallerOptions{
+		Dial: dbus.SessionBus,
+	})
+}
+
+// InstallerNewWithOptions returns a newly allocated Installer object,
+// configured by options
+func InstallerNewWithOptions(options InstallerOptions) (*Installer, error) {
 	p := new(Installer)
-	var err error
-	p.conn, err = dbus.SystemBus()
-	if err != nil {
-		return nil, err
+	p.checkBundles = !options.SkipBundleCheck
+	p.callTimeout = options.CallTimeout
+	p.installTimeout = options.InstallTimeout
+	p.busName = options.busName()
+
+	p.iface = options.InterfacePrefix
+	if p.iface == "" {
+		p.iface = dbusInterface
+	}
+	p.iface += ".Installer"
+
+	p.path = options.ObjectPath
+	if p.path == "" {
+		p.path = "/"
 	}
+	p.events = options.Events
+	if p.events == nil {
+		p.events = EventBusNew()
+	}
+
+	p.dial = options.Dial
+	p.conn = options.Conn
+	if p.conn == nil {
+		if p.dial == nil {
+			p.dial = dbus.SystemBus
+		}
 
-	p.object = p.conn.Object(dbusInterface, dbus.ObjectPath("/"))
-	p.conn.AddMatchSignal(
-		dbus.WithMatchInterface(fmt.Sprintf("%s.%s", dbusInterface, "Installer")),
-		dbus.WithMatchMember("Completed"),
-		dbus.WithMatchObjectPath(p.object.Path()))
+		var err error
+		if p.conn, err = p.dial(); err != nil {
+			return nil, &Error{
+				Method:  "InstallerNew",
+				Message: err.Error(),
+				Cause:   ErrDBusUnavailable,
+				Err:     err,
+			}
+		}
+	}
+
+	if options.SkipReconnect {
+		p.dial = nil
+	}
+
+	if options.WaitForDaemon > 0 {
+		if err := waitForName(p.conn, p.busName, options.WaitForDaemon); err != nil {
+			return nil, err
+		}
+	}
+
+	p.object = p.conn.Object(p.busName, p.path)
+	p.startSignals()
+
+	if options.Cache {
+		p.startCache()
+	}
 
 	return p, nil
 }
 
+// nameAvailable reports whether name currently has an owner on the bus or
+// can be activated on demand.
+func nameAvailable(conn *dbus.Conn, name string) (bool, error) {
+	var activatable []string
+	err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&activatable)
+	if err == nil {
+		for _, n := range activatable {
+			if n == name {
+				return true, nil
+			}
+		}
+	}
+
+	var hasOwner bool
+	err = conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, name).Store(&hasOwner)
+	if err != nil {
+		return false, callError("NameHasOwner", err)
+	}
+
+	return hasOwner, nil
+}
+
+// waitForName polls the bus until name has an owner or the timeout expires.
+// Names that the bus can activate on demand are not waited for.
+func waitForName(conn *dbus.Conn, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	b := backoff.Backoff{
+		Initial: 100 * time.Millisecond,
+		Max:     2 * time.Second,
+		Jitter:  0.2,
+	}
+
+	for {
+		available, err := nameAvailable(conn, name)
+		if err != nil {
+			return err
+		}
+
+		if available {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			err := errorf("", ErrDBusUnavailable, "RAUC: %s did not appear on the bus within %v", name, timeout)
+			if state, e := GetServiceState(conn, ServiceUnit); e == nil {
+				err.Message = fmt.Sprintf("%s (%s)", err.Message, state)
+			}
+			return err
+		}
+
+		delay := b.Next()
+		if delay > remaining {
+			delay = remaining
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// Events returns the bus the Installer publishes its events on.
+func (p *Installer) Events() *EventBus {
+	return p.events
+}
+
+// bundlePath returns the name to pass to the daemon for filename. The
+// daemon has a different working directory, and its errors for files it
+// cannot open are less helpful.
+func (p *Installer) bundlePath(filename string) (string, error) {
+	if !p.checkBundles {
+		return filename, nil
+	}
+
+	return CheckBundlePath(filename)
+}
+
+// connection returns the current connection to the bus.
+func (p *Installer) connection() *dbus.Conn {
+	p.connMutex.RLock()
+	defer p.connMutex.RUnlock()
+
+	return p.conn
+}
+
+// daemon returns the daemon's object on the current connection.
+func (p *Installer) daemon() dbus.BusObject {
+	p.connMutex.RLock()
+	defer p.connMutex.RUnlock()
+
+	return p.object
+}
+
 func (p *Installer) interfaceForMember(method string) string {
-	return fmt.Sprintf("%s.%s.%s", dbusInterface, "Installer", method)
+	return p.iface + "." + method
 }
 
 // InstallBundleOptions contains options for the InstallBundle method
 type InstallBundleOptions struct {
 	IgnoreIncompatible bool
+	// TLSCert, TLSKey and TLSCA authenticate streaming installs against
+	// HTTPS servers. They are file names or PKCS#11 URIs.
+	TLSCert string
+	TLSKey  string
+	TLSCA   string
+	// TLSNoVerify skips the verification of the server certificate.
+	TLSNoVerify bool
+	// HTTPHeaders are added to the requests of streaming installs, e.g.
+	// "Authorization: Bearer <token>".
+	HTTPHeaders []string
+	// RequireManifestHash makes the daemon refuse bundles whose manifest
+	// has a different hash.
+	RequireManifestHash string
+	// TransactionID is logged by the daemon for the installation.
+	TransactionID string
+	// Gates are checked before the installation is started. The first
+	// failing gate's error is returned as is.
+	Gates []Gate
+	// Watchdog is fed every WatchdogInterval (default 10 seconds) while
+	// waiting for the installation to complete.
+	Watchdog         Watchdog
+	WatchdogInterval time.Duration
+	// StallTimeout makes InstallBundle return a StallError if the progress
+	// does not change for this long. Zero disables stall detection.
+	StallTimeout time.Duration
+	// Timeout makes InstallBundle return a TimeoutError if the installation
+	// does not complete in time. Zero waits forever.
+	Timeout time.Duration
+	// Lock makes InstallBundle hold the cross-process InstallLock during
+	// the installation, waiting for other processes to release it first.
+	Lock bool
+	// Hooks are run before and after the installation.
+	Hooks Hooks
+	// Args are passed to the daemon in addition to the options above,
+	// for options this package does not know yet. They take precedence
+	// over the fields above. The CLI backend passes them as command line
+	// options.
+	Args map[string]interface{}
+	// VerifyInstalled makes InstallBundle check the slot status after a
+	// successful installation, and return a MismatchError unless a slot
+	// was written and holds the bundle's version.
+	VerifyInstalled bool
+	// ProgressHistory, if not nil, receives the progress steps of the
+	// installation.
+	ProgressHistory *ProgressHistory
+}
+
+// daemonArgs returns the args dictionary of the InstallBundle method.
+// Only options that are set are included, as older daemons reject those
+// they do not know.
+func (options InstallBundleOptions) daemonArgs() map[string]interface{} {
+	args := make(map[string]interface{})
+
+	set := func(key string, value interface{}, ok bool) {
+		if ok {
+			args[key] = value
+		}
+	}
+
+	set("ignore-compatible", true, options.IgnoreIncompatible)
+	set("tls-cert", options.TLSCert, options.TLSCert != "")
+	set("tls-key", options.TLSKey, options.TLSKey != "")
+	set("tls-ca", options.TLSCA, options.TLSCA != "")
+	set("tls-no-verify", true, options.TLSNoVerify)
+	set("http-headers", options.HTTPHeaders, len(options.HTTPHeaders) > 0)
+	set("require-manifest-hash", options.RequireManifestHash, options.RequireManifestHash != "")
+	set("transaction-id", options.TransactionID, options.TransactionID != "")
+
+	for k, v := range options.Args {
+		args[k] = v
+	}
+
+	return args
 }
 
 // InstallBundle triggers the installation of a bundle. This method waits for the "Completed"
 // signal to be sent by the RAUC daemon.
 func (p *Installer) InstallBundle(filename string, options InstallBundleOptions) error {
-	doneChannel := make(chan *dbus.Signal, 10)
-	p.conn.Signal(doneChannel)
+	return p.InstallBundleContext(context.Background(), filename, options)
+}
+
+// InstallBundleContext is InstallBundle, but stops waiting for the
+// installation when ctx is done and returns ctx.Err(). The daemon cannot
+// abort installations, so it runs to completion regardless.
+func (p *Installer) InstallBundleContext(ctx context.Context, filename string, options InstallBundleOptions) (err error) {
+	path, err := p.bundlePath(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := CheckGates(ctx, options.Gates); err != nil {
+		return err
+	}
+
+	unlock, err := lockForInstall(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	args := map[string]interface{}{
-		"ignore-compatible": options.IgnoreIncompatible,
+	if err := RunHooks(ctx, HookBeforeInstall, options.Hooks.BeforeInstall, HookInfo{Bundle: filename}); err != nil {
+		return err
 	}
+	defer func() {
+		err = afterInstall(options.Hooks, filename, err)
+	}()
 
-	err := p.object.Call(p.interfaceForMember("InstallBundle"), 0, filename, args).Err
+	verify, err := prepareVerify(p, filename, options)
 	if err != nil {
-		return fmt.Errorf("RAUC: Install(): %v", err)
+		return err
+	}
+	defer func() {
+		err = verify(err)
+	}()
+
+	// Progress changes arrive on the same channel as the Completed signal.
+	doneChannel, unsubscribe := p.SubscribeSignals(64)
+	defer unsubscribe()
+
+	// The installation stamps of the slots, read before starting, tell
+	// later whether it succeeded while no one was listening.
+	installed, _ := p.installStamps(ctx)
+
+	call := p.call(ctx, p.interfaceForMember("InstallBundle"), path, options.daemonArgs())
+	if call.Err != nil {
+		return callError("Install", call.Err)
+	}
+
+	detach := p.track(&AttachState{
+		Bundle:    filename,
+		Started:   time.Now(),
+		Installed: installed,
+	})
+
+	p.events.Publish(InstallStartedEvent{Bundle: filename})
+	defer func() {
+		if !errors.Is(err, ErrDetached) {
+			p.events.Publish(InstallCompletedEvent{Bundle: filename, Err: err})
+		}
+	}()
+
+	return p.waitForCompletion(ctx, filename, doneChannel, p.completionFilter(call.ResponseSequence), detach, options)
+}
+
+// completion matches the Completed signal of an installation.
+type completion struct {
+	// after is the sequence number of the reply that started the
+	// installation or looked up its state. Older signals are stale.
+	after dbus.Sequence
+	// sender is the daemon's unique bus name, if known.
+	sender string
+}
+
+func (p *Installer) completionFilter(after dbus.Sequence) completion {
+	c := completion{after: after}
+
+	ctx, cancel := p.callContext(context.Background())
+	defer cancel()
+
+	// Signals from previous instances of the daemon are stale as well.
+	p.connection().BusObject().CallWithContext(ctx, "org.freedesktop.DBus.GetNameOwner", 0, p.busName).Store(&c.sender)
+
+	return c
+}
+
+func (p *Installer) matches(c completion, signal *dbus.Signal) bool {
+	if signal.Name != p.interfaceForMember("Completed") || signal.Path != p.path {
+		return false
+	}
+
+	if c.after != dbus.NoSequence && signal.Sequence <= c.after {
+		return false
+	}
+
+	return c.sender == "" || signal.Sender == c.sender
+}
+
+// waitForCompletion waits for the "Completed" signal on doneChannel, or
+// until detach is closed or ctx is done.
+func (p *Installer) waitForCompletion(ctx context.Context, filename string, doneChannel <-chan *dbus.Signal, c completion, detach <-chan struct{}, options InstallBundleOptions) (err error) {
+	defer func() {
+		if !errors.Is(err, ErrDetached) {
+			p.untrack()
+		}
+	}()
+
+	if options.Timeout == 0 {
+		options.Timeout = p.installTimeout
+	}
+
+	var watchdogTick <-chan time.Time
+	if options.Watchdog != nil {
+		interval := options.WatchdogInterval
+		if interval == 0 {
+			interval = 10 * time.Second
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdogTick = ticker.C
+	}
+
+	var stall *stallDetector
+	var stallTick <-chan time.Time
+	if options.StallTimeout > 0 {
+		stall = newStallDetector(filename, options.StallTimeout, p)
+
+		ticker := time.NewTicker(stall.interval())
+		defer ticker.Stop()
+		stallTick = ticker.C
+	}
+
+	var timeout <-chan time.Time
+	if options.Timeout > 0 {
+		timer := time.NewTimer(options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
 	}
 
 	for {
-		signal, ok := <-doneChannel
+		var signal *dbus.Signal
+		var ok bool
+
+		select {
+		case signal, ok = <-doneChannel:
+		case <-watchdogTick:
+			if err := options.Watchdog.Keepalive(); err != nil {
+				return err
+			}
+			continue
+		case <-stallTick:
+			if e := stall.check(); e != nil {
+				p.events.Publish(InstallStalledEvent{Err: e})
+				return e
+			}
+			continue
+		case <-detach:
+			p.events.Publish(InstallDetachedEvent{Bundle: filename})
+			return ErrDetached
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return &TimeoutError{
+				Bundle:  filename,
+				Timeout: options.Timeout,
+				State:   collectDaemonState(p),
+			}
+		}
+
 		if !ok {
-			return errors.New("RAUC: Cannot read from channel")
+			return errorf("", ErrDBusUnavailable, "RAUC: Cannot read from channel")
 		}
 
-		if signal.Name == p.interfaceForMember("Completed") {
+		options.ProgressHistory.recordProgressSignal(signal)
+
+		if signal.Name == SignalReconnected {
+			// Signals of the new connection have new sequence numbers.
+			c = p.completionFilter(dbus.NoSequence)
+
+			if done, err := p.completedWhileDisconnected(ctx); done {
+				return err
+			}
+			continue
+		}
+
+		if p.matches(c, signal) {
 			var code int32
 			err = dbus.Store(signal.Body, &code)
 			if err != nil {
@@ -81,12 +552,12 @@ func (p *Installer) InstallBundle(filename string, options InstallBundleOptions)
 			}
 
 			if code != 0 {
-				errorString, err := p.GetLastError()
+				errorString, err := p.GetLastErrorContext(ctx)
 				if err != nil {
 					return err
 				}
 
-				return errors.New(errorString)
+				return installError(errorString)
 			}
 
 			return nil
@@ -96,9 +567,19 @@ func (p *Installer) InstallBundle(filename string, options InstallBundleOptions)
 
 // Info provides information on a given bundle.
 func (p *Installer) Info(filename string) (compatible string, version string, err error) {
-	err = p.object.Call(p.interfaceForMember("Info"), 0, filename).Store(&compatible, &version)
+	return p.InfoContext(context.Background(), filename)
+}
+
+// InfoContext is Info, giving up when ctx is done.
+func (p *Installer) InfoContext(ctx context.Context, filename string) (compatible string, version string, err error) {
+	path, err := p.bundlePath(filename)
 	if err != nil {
-		return "", "", fmt.Errorf("RAUC: Info(): %v", err)
+		return "", "", err
+	}
+
+	err = p.call(ctx, p.interfaceForMember("Info"), path).Store(&compatible, &version)
+	if err != nil {
+		return "", "", callError("Info", err)
 	}
 
 	return compatible, version, nil
@@ -107,9 +588,22 @@ func (p *Installer) Info(filename string) (compatible string, version string, er
 // Mark keeps a slot bootable (state == “good”), makes it unbootable (state == “bad”)
 // or explicitly activates it for the next boot (state == “active”).
 func (p *Installer) Mark(state string, slotIdentifier string) (slotName string, message string, err error) {
-	err = p.object.Call(p.interfaceForMember("Mark"), 0, state, slotIdentifier).Store(&slotName, &message)
+	return p.MarkContext(context.Background(), state, slotIdentifier)
+}
+
+// MarkContext is Mark, giving up when ctx is done.
+func (p *Installer) MarkContext(ctx context.Context, state string, slotIdentifier string) (slotName string, message string, err error) {
+	if _, err := ParseMarkState(state); err != nil {
+		return "", "", err
+	}
+
+	if p.cache != nil {
+		defer p.cache.invalidateSlotStatus()
+	}
+
+	err = p.call(ctx, p.interfaceForMember("Mark"), state, slotIdentifier).Store(&slotName, &message)
 	if err != nil {
-		return "", "", fmt.Errorf("RAUC: Mark(): %v", err)
+		return "", "", callError("Mark", err)
 	}
 
 	return slotName, message, nil
@@ -117,90 +611,164 @@ func (p *Installer) Mark(state string, slotIdentifier string) (slotName string,
 
 // GetSlotStatus is an access method to get all slots’ status.
 func (p *Installer) GetSlotStatus() (status []SlotStatus, err error) {
-	err = p.object.Call(p.interfaceForMember("GetSlotStatus"), 0).Store(&status)
-	if err != nil {
-		return nil, fmt.Errorf("RAUC: GetSlotStatus(): %v", err)
+	return p.GetSlotStatusContext(context.Background())
+}
+
+// GetSlotStatusContext is GetSlotStatus, giving up when ctx is done.
+func (p *Installer) GetSlotStatusContext(ctx context.Context) (status []SlotStatus, err error) {
+	var generation uint64
+	if p.cache != nil {
+		var cached []SlotStatus
+		if cached, generation = p.cache.lookupSlotStatus(); cached != nil {
+			return cached, nil
+		}
+	}
+
+	call := p.call(ctx, p.interfaceForMember("GetSlotStatus"))
+	if call.Err != nil {
+		return nil, callError("GetSlotStatus", call.Err)
+	}
+
+	if status = p.slotStatusFromBody(call.Body); status == nil {
+		if err = call.Store(&status); err != nil {
+			p.warn("GetSlotStatus", "a(sa{sv})", call.Body)
+			return nil, nil
+		}
+	}
+
+	if p.cache != nil {
+		p.cache.storeSlotStatus(status, generation)
 	}
 
 	return status, nil
 }
 
+// callContext returns ctx limited to the CallTimeout.
+func (p *Installer) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.callTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, p.callTimeout)
+}
+
+// call calls method of the daemon, giving up when ctx is done or after the
+// CallTimeout.
+func (p *Installer) call(ctx context.Context, method string, args ...interface{}) *dbus.Call {
+	ctx, cancel := p.callContext(ctx)
+	defer cancel()
+
+	return p.daemon().CallWithContext(ctx, method, 0, args...)
+}
+
 // Properties
 
+// property reads a property of the daemon, bypassing the cache.
+func (p *Installer) property(ctx context.Context, name string) (dbus.Variant, error) {
+	var v dbus.Variant
+	err := p.call(ctx, "org.freedesktop.DBus.Properties.Get", p.iface, name).Store(&v)
+
+	return v, err
+}
+
 // GetOperation returns the current (global) operation RAUC performs.
 func (p *Installer) GetOperation() (string, error) {
-	v, err := p.object.GetProperty(p.interfaceForMember("Operation"))
+	return p.GetOperationContext(context.Background())
+}
+
+// GetOperationContext is GetOperation, giving up when ctx is done.
+func (p *Installer) GetOperationContext(ctx context.Context) (string, error) {
+	v, err := p.property(ctx, "Operation")
 	if err != nil {
-		return "", fmt.Errorf("RAUC: GetOperation(): %v", err)
+		return "", callError("GetOperation", err)
 	}
 
-	return v.String(), nil
+	return p.stringValue("Operation", v), nil
 }
 
 // GetLastError returns the last message of the last error that occurred.
 func (p *Installer) GetLastError() (string, error) {
-	v, err := p.object.GetProperty(p.interfaceForMember("LastError"))
+	return p.GetLastErrorContext(context.Background())
+}
+
+// GetLastErrorContext is GetLastError, giving up when ctx is done.
+func (p *Installer) GetLastErrorContext(ctx context.Context) (string, error) {
+	v, err := p.property(ctx, "LastError")
 	if err != nil {
-		return "", fmt.Errorf("RAUC: GetLastError(): %v", err)
+		return "", callError("GetLastError", err)
 	}
 
-	return v.String(), nil
+	return p.stringValue("LastError", v), nil
 }
 
 // GetProgress returns installation progress information in the form
 // (percentage, message, nesting depth)
+//
+// Deprecated: Use ProgressOf, which returns a Progress.
 func (p *Installer) GetProgress() (percentage int32, message string, nestingDepth int32, err error) {
-	variant, err := p.object.GetProperty(p.interfaceForMember("Progress"))
-	if err != nil {
-		return -1, "", -1, fmt.Errorf("RAUC: GetProperty(Progress): %v", err)
-	}
+	return p.GetProgressContext(context.Background())
+}
 
-	type progressResponse struct {
-		Percentage   int32
-		Message      string
-		NestingDepth int32
+// GetProgressContext is GetProgress, giving up when ctx is done.
+//
+// Deprecated: Use ProgressOfContext, which returns a Progress.
+func (p *Installer) GetProgressContext(ctx context.Context) (percentage int32, message string, nestingDepth int32, err error) {
+	variant, err := p.property(ctx, "Progress")
+	if err != nil {
+		return -1, "", -1, callError("GetProgress", err)
 	}
 
-	src := make([]interface{}, 1)
-	src[0] = variant.Value()
-
-	var response progressResponse
-	err = dbus.Store(src, &response)
-	if err != nil {
-		return -1, "", -1, fmt.Errorf("RAUC: Cannot store result: %v", err)
+	percentage, message, nestingDepth, ok := decodeProgress(variant.Value())
+	if !ok {
+		p.warn("Progress", "(isi)", variant.Value())
 	}
 
-	return response.Percentage, response.Message, response.NestingDepth, nil
+	return percentage, message, nestingDepth, nil
 }
 
 // GetCompatible returns the system’s compatible string.
 // This can be used to check for usable bundels.
 func (p *Installer) GetCompatible() (string, error) {
-	v, err := p.object.GetProperty(p.interfaceForMember("Compatible"))
+	return p.GetCompatibleContext(context.Background())
+}
+
+// GetCompatibleContext is GetCompatible, giving up when ctx is done.
+func (p *Installer) GetCompatibleContext(ctx context.Context) (string, error) {
+	v, err := p.getProperty(ctx, "Compatible")
 	if err != nil {
-		return "", fmt.Errorf("RAUC: GetProperty(Compatible): %v", err)
+		return "", err
 	}
 
-	return v.String(), nil
+	return p.stringValue("Compatible", v), nil
 }
 
 // GetVariant returns the system’s variant.
 // This can be used to select parts of an bundle.
 func (p *Installer) GetVariant() (string, error) {
-	v, err := p.object.GetProperty(p.interfaceForMember("Variant"))
+	return p.GetVariantContext(context.Background())
+}
+
+// GetVariantContext is GetVariant, giving up when ctx is done.
+func (p *Installer) GetVariantContext(ctx context.Context) (string, error) {
+	v, err := p.getProperty(ctx, "Variant")
 	if err != nil {
-		return "", fmt.Errorf("RAUC: GetProperty(Variant): %v", err)
+		return "", err
 	}
 
-	return v.String(), nil
+	return p.stringValue("Variant", v), nil
 }
 
 // GetBootSlot returns the currently used boot slot.
 func (p *Installer) GetBootSlot() (string, error) {
-	v, err := p.object.GetProperty(p.interfaceForMember("BootSlot"))
+	return p.GetBootSlotContext(context.Background())
+}
+
+// GetBootSlotContext is GetBootSlot, giving up when ctx is done.
+func (p *Installer) GetBootSlotContext(ctx context.Context) (string, error) {
+	v, err := p.getProperty(ctx, "BootSlot")
 	if err != nil {
-		return "", fmt.Errorf("RAUC: GetProperty(BootSlot): %v", err)
+		return "", err
 	}
 
-	return v.String(), nil
+	return p.stringValue("BootSlot", v), nil
 }
@@ -0,0 +1,268 @@
+package rauc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// jobProgressInterval is the time between two progress queries of
+// backends without progress signals.
+const jobProgressInterval = 250 * time.Millisecond
+
+// InstallJob is an installation started by StartInstall.
+type InstallJob struct {
+	// Bundle is the file name the installation was started with.
+	Bundle string
+
+	cancel   context.CancelFunc
+	done     chan error
+	progress chan Progress
+	finished chan struct{}
+	watched  chan struct{}
+
+	mutex     sync.Mutex
+	err       error
+	lastError string
+}
+
+// contextInstaller is implemented by backends that can stop waiting for
+// an installation.
+type contextInstaller interface {
+	InstallBundleContext(ctx context.Context, filename string, options InstallBundleOptions) error
+}
+
+// StartInstall starts the installation of a bundle and returns without
+// waiting for it to complete. Errors that keep the installation from
+// starting, such as failing gates or a busy daemon, are returned right
+// away.
+func StartInstall(b Backend, filename string, options InstallBundleOptions) (*InstallJob, error) {
+	return StartInstallContext(context.Background(), b, filename, options)
+}
+
+// StartInstallContext is StartInstall, but the job stops waiting for the
+// installation when ctx is done, for backends that support it.
+func StartInstallContext(ctx context.Context, b Backend, filename string, options InstallBundleOptions) (*InstallJob, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	j := &InstallJob{
+		Bundle:   filename,
+		cancel:   cancel,
+		done:     make(chan error, 1),
+		progress: make(chan Progress, 16),
+		finished: make(chan struct{}),
+		watched:  make(chan struct{}),
+	}
+
+	// All backends announce the start of an installation on their bus.
+	events, unsubscribe := b.Events().Subscribe(64)
+	defer unsubscribe()
+
+	j.watchProgress(b)
+
+	result := make(chan error, 1)
+	go func() {
+		if c, ok := b.(contextInstaller); ok {
+			result <- c.InstallBundleContext(ctx, filename, options)
+		} else {
+			result <- b.InstallBundle(filename, options)
+		}
+	}()
+
+	if err := waitStarted(events, result, filename); err != nil {
+		j.finish(err, "")
+		return nil, err
+	}
+
+	go func() {
+		err := <-result
+
+		var lastError string
+		if err != nil {
+			// Failures reported by the daemon carry its LastError as is.
+			if l, e := b.GetLastError(); e == nil && l == err.Error() {
+				lastError = l
+			}
+		}
+
+		j.finish(err, lastError)
+	}()
+
+	return j, nil
+}
+
+// waitStarted waits for the InstallStartedEvent of filename. If the
+// installation ends before it is seen, the installation never started
+// and its error is returned.
+func waitStarted(events <-chan Event, result chan error, filename string) error {
+	started := func(e Event) bool {
+		s, ok := e.(InstallStartedEvent)
+		return ok && s.Bundle == filename
+	}
+
+	for {
+		select {
+		case e := <-events:
+			if started(e) {
+				return nil
+			}
+		case err := <-result:
+			// Events are published synchronously, so the start of an
+			// installation that ran is queued by now.
+			for err != nil {
+				select {
+				case e := <-events:
+					if started(e) {
+						result <- err
+						return nil
+					}
+					continue
+				default:
+				}
+
+				return err
+			}
+
+			result <- nil
+			return nil
+		}
+	}
+}
+
+// watchProgress forwards the progress of b to the Progress channel until
+// the job finishes. The Installer's progress signals are subscribed to
+// before it returns, other backends are polled.
+func (j *InstallJob) watchProgress(b Backend) {
+	if p, ok := b.(*Installer); ok {
+		signals, unsubscribe := p.SubscribeSignals(64)
+
+		go func() {
+			defer close(j.watched)
+			defer close(j.progress)
+			defer unsubscribe()
+
+			for {
+				select {
+				case signal, ok := <-signals:
+					if !ok {
+						return
+					}
+
+					if progress, ok := progressOfSignal(signal); ok {
+						j.sendProgress(progress)
+					}
+				case <-j.finished:
+					return
+				}
+			}
+		}()
+
+		return
+	}
+
+	go func() {
+		defer close(j.watched)
+		defer close(j.progress)
+
+		ticker := time.NewTicker(jobProgressInterval)
+		defer ticker.Stop()
+
+		last := Progress{Percentage: -1}
+
+		for {
+			select {
+			case <-ticker.C:
+				if progress, err := ProgressOf(b); err == nil && progress != last {
+					j.sendProgress(progress)
+					last = progress
+				}
+			case <-j.finished:
+				return
+			}
+		}
+	}()
+}
+
+// sendProgress queues progress, dropping the oldest queued value if the
+// receiver does not keep up.
+func (j *InstallJob) sendProgress(progress Progress) {
+	for {
+		select {
+		case j.progress <- progress:
+			return
+		default:
+		}
+
+		select {
+		case <-j.progress:
+		default:
+		}
+	}
+}
+
+func (j *InstallJob) finish(err error, lastError string) {
+	j.mutex.Lock()
+	j.err = err
+	j.lastError = lastError
+	j.mutex.Unlock()
+
+	close(j.finished)
+	<-j.watched
+
+	j.done <- err
+	close(j.done)
+	j.cancel()
+}
+
+// Done returns a channel that receives the result of the installation
+// once it completes, and is closed afterwards.
+func (j *InstallJob) Done() <-chan error {
+	return j.done
+}
+
+// Progress returns a channel that receives the progress of the
+// installation. The oldest values are dropped if they are not received
+// in time. The channel is closed before Done receives the result.
+func (j *InstallJob) Progress() <-chan Progress {
+	return j.progress
+}
+
+// LastError returns the daemon's LastError if the installation failed,
+// and an empty string while it runs, if it succeeded or if it failed on
+// the client side.
+func (j *InstallJob) LastError() string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.lastError
+}
+
+// Wait blocks until the installation completes and returns its result.
+// Unlike Done, it can be used any number of times.
+func (j *InstallJob) Wait() error {
+	<-j.finished
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.err
+}
+
+// Cancel stops waiting for the installation and makes the job complete
+// with context.Canceled. The daemon cannot abort installations, so it
+// runs to completion regardless.
+func (j *InstallJob) Cancel() {
+	j.cancel()
+}
+
+// StartInstall starts the installation of a bundle, see the StartInstall
+// function.
+func (p *Installer) StartInstall(filename string, options InstallBundleOptions) (*InstallJob, error) {
+	return StartInstallContext(context.Background(), p, filename, options)
+}
+
+// StartInstallContext is StartInstall, but the job stops waiting for the
+// installation when ctx is done.
+func (p *Installer) StartInstallContext(ctx context.Context, filename string, options InstallBundleOptions) (*InstallJob, error) {
+	return StartInstallContext(ctx, p, filename, options)
+}
@@ -0,0 +1,179 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitStarted(t *testing.T) {
+	const bundle = "/data/update.raucb"
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name   string
+		events []Event
+		result []error
+		err    error
+		// queued is the result left in the channel for the job.
+		queued []error
+	}{
+		{
+			name:   "started",
+			events: []Event{InstallStartedEvent{Bundle: bundle}},
+		},
+		{
+			name:   "other bundle first",
+			events: []Event{InstallStartedEvent{Bundle: "/data/other.raucb"}, InstallStartedEvent{Bundle: bundle}},
+		},
+		{
+			name:   "refused",
+			result: []error{failed},
+			err:    failed,
+		},
+		{
+			name:   "refused after other events",
+			events: []Event{InstallStartedEvent{Bundle: "/data/other.raucb"}, InstallCompletedEvent{Bundle: "/data/other.raucb"}},
+			result: []error{failed},
+			err:    failed,
+		},
+		{
+			name:   "failed after start",
+			events: []Event{InstallStartedEvent{Bundle: bundle}},
+			result: []error{failed},
+			queued: []error{failed},
+		},
+		{
+			name:   "completed without event",
+			result: []error{nil},
+			queued: []error{nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan Event, len(tt.events))
+			for _, e := range tt.events {
+				events <- e
+			}
+
+			result := make(chan error, 1)
+			for _, err := range tt.result {
+				result <- err
+			}
+
+			if err := waitStarted(events, result, bundle); err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			var queued []error
+			select {
+			case err := <-result:
+				queued = append(queued, err)
+			default:
+			}
+
+			if len(queued) != len(tt.queued) || (len(queued) > 0 && queued[0] != tt.queued[0]) {
+				t.Fatalf("got queued result %v, want %v", queued, tt.queued)
+			}
+		})
+	}
+}
+
+// blockingBackend is a Simulator whose installations run until ctx is
+// done.
+type blockingBackend struct {
+	*Simulator
+}
+
+func (b blockingBackend) InstallBundleContext(ctx context.Context, filename string, options InstallBundleOptions) error {
+	b.events.Publish(InstallStartedEvent{Bundle: filename})
+	<-ctx.Done()
+
+	return ctx.Err()
+}
+
+func TestStartInstall(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   SimulatorOptions
+		bundle    string
+		startErr  error
+		err       error
+		lastError string
+	}{
+		{
+			name:   "success",
+			bundle: "/data/update-2.0.raucb",
+		},
+		{
+			name:      "failure",
+			options:   SimulatorOptions{InstallError: "Installation error: No space left on device"},
+			bundle:    "/data/update-2.0.raucb",
+			err:       ErrNoSpace,
+			lastError: "Installation error: No space left on device",
+		},
+		{
+			name:     "refused",
+			options:  SimulatorOptions{Bundles: map[string]SimulatedBundle{"/data/other.raucb": {Compatible: "other"}}},
+			bundle:   "/data/other.raucb",
+			startErr: ErrIncompatibleBundle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.InstallDuration = 20 * time.Millisecond
+
+			j, err := StartInstall(SimulatorNew(tt.options), tt.bundle, InstallBundleOptions{})
+			if !errors.Is(err, tt.startErr) || (err == nil) != (tt.startErr == nil) {
+				t.Fatalf("got start error %v, want %v", err, tt.startErr)
+			}
+			if err != nil {
+				if j != nil {
+					t.Fatal("got a job for an installation that did not start")
+				}
+				return
+			}
+
+			for range j.Progress() {
+			}
+
+			err = <-j.Done()
+			if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if again := j.Wait(); again != err {
+				t.Errorf("Wait() = %v, Done() = %v", again, err)
+			}
+
+			if _, ok := <-j.Done(); ok {
+				t.Error("Done not closed")
+			}
+
+			if l := j.LastError(); l != tt.lastError {
+				t.Errorf("got LastError %q, want %q", l, tt.lastError)
+			}
+		})
+	}
+}
+
+func TestInstallJobCancel(t *testing.T) {
+	j, err := StartInstall(blockingBackend{SimulatorNew(SimulatorOptions{})}, "/data/update.raucb", InstallBundleOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j.Cancel()
+
+	select {
+	case err := <-j.Done():
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job did not complete after Cancel")
+	}
+}
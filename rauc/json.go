@@ -0,0 +1,160 @@
+package rauc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// plainValue unwraps variants in v, recursively, so it can be encoded
+// without dbus.Variant internals.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case dbus.Variant:
+		return plainValue(v.Value())
+	case map[string]dbus.Variant:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = plainValue(e)
+		}
+		return m
+	case []dbus.Variant:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = plainValue(e)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = plainValue(e)
+		}
+		return s
+	}
+
+	return v
+}
+
+// statusVariant converts a decoded JSON or YAML value back into a
+// variant. Integers are restored as the types RAUC uses for slot status
+// keys: uint32 for counters and uint64 otherwise. Negative numbers become
+// int64, fractional ones float64.
+func statusVariant(key string, value interface{}) (dbus.Variant, bool) {
+	var n uint64
+
+	switch v := value.(type) {
+	case string:
+		return dbus.MakeVariant(v), true
+	case bool:
+		return dbus.MakeVariant(v), true
+	case json.Number:
+		var err error
+		if n, err = strconv.ParseUint(v.String(), 10, 64); err != nil {
+			// As for YAML, negative numbers become int64, fractional float64.
+			if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+				return dbus.MakeVariant(i), true
+			}
+			if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+				return dbus.MakeVariant(f), true
+			}
+			return dbus.MakeVariant(v.String()), true
+		}
+	case int:
+		if v < 0 {
+			return dbus.MakeVariant(int64(v)), true
+		}
+		n = uint64(v)
+	case uint64:
+		n = v
+	case float64:
+		return dbus.MakeVariant(v), true
+	default:
+		return dbus.Variant{}, false
+	}
+
+	if strings.HasSuffix(key, ".count") {
+		return dbus.MakeVariant(uint32(n)), true
+	}
+
+	return dbus.MakeVariant(n), true
+}
+
+// slotStatusDocument is the JSON and YAML representation of a SlotStatus.
+type slotStatusDocument struct {
+	SlotName string                 `json:"slot" yaml:"slot"`
+	Status   map[string]interface{} `json:"status" yaml:"status"`
+}
+
+func (s SlotStatus) document() slotStatusDocument {
+	status, _ := plainValue(s.Status).(map[string]interface{})
+
+	return slotStatusDocument{
+		SlotName: s.SlotName,
+		Status:   status,
+	}
+}
+
+func (s *SlotStatus) fromDocument(d slotStatusDocument) {
+	s.SlotName = d.SlotName
+	s.Status = make(map[string]dbus.Variant, len(d.Status))
+
+	for k, e := range d.Status {
+		if variant, ok := statusVariant(k, e); ok {
+			s.Status[k] = variant
+		}
+	}
+}
+
+// MarshalJSON encodes the slot status with plain values, as in
+// {"slot":"rootfs.0","status":{"state":"booted","size":1024}}.
+func (s SlotStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.document())
+}
+
+// UnmarshalJSON decodes the output of MarshalJSON. Numbers are restored
+// as the integer types RAUC uses for the respective keys.
+func (s *SlotStatus) UnmarshalJSON(data []byte) error {
+	var v slotStatusDocument
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+
+	s.fromDocument(v)
+	return nil
+}
+
+type slotSnapshotDocument struct {
+	slotStatusDocument `yaml:",inline"`
+	Mounts             []Mount `json:"mounts,omitempty" yaml:"mounts,omitempty"`
+}
+
+// MarshalJSON encodes the slot status like SlotStatus, plus its mounts.
+func (s SlotSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(slotSnapshotDocument{
+		slotStatusDocument: s.document(),
+		Mounts:             s.Mounts,
+	})
+}
+
+// UnmarshalJSON decodes the output of MarshalJSON.
+func (s *SlotSnapshot) UnmarshalJSON(data []byte) error {
+	if err := s.SlotStatus.UnmarshalJSON(data); err != nil {
+		return err
+	}
+
+	var v struct {
+		Mounts []Mount `json:"mounts"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	s.Mounts = v.Mounts
+	return nil
+}
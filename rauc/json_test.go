@@ -0,0 +1,48 @@
+package rauc
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+func TestSlotStatusJSON(t *testing.T) {
+	s := SlotStatus{
+		SlotName: "rootfs.0",
+		Status: map[string]dbus.Variant{
+			SlotKeyClass:          dbus.MakeVariant("rootfs"),
+			SlotKeyState:          dbus.MakeVariant(SlotStateBooted),
+			SlotKeySize:           dbus.MakeVariant(uint64(1 << 40)),
+			SlotKeyInstalledCount: dbus.MakeVariant(uint32(3)),
+			"boot-attempts":       dbus.MakeVariant(int64(-1)),
+			"temperature":         dbus.MakeVariant(36.5),
+			"verified":            dbus.MakeVariant(true),
+		},
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got SlotStatus
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.SlotName != s.SlotName {
+		t.Errorf("got slot %q, want %q", got.SlotName, s.SlotName)
+	}
+
+	for k, want := range s.Status {
+		if v := got.Status[k]; !reflect.DeepEqual(v, want) {
+			t.Errorf("%s: got %v (%s), want %v (%s)", k, v, v.Signature(), want, want.Signature())
+		}
+	}
+
+	if len(got.Status) != len(s.Status) {
+		t.Errorf("got %d keys, want %d", len(got.Status), len(s.Status))
+	}
+}
@@ -0,0 +1,196 @@
+package rauc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// KexecKernelPaths lists the kernel image locations tried inside the target
+// slot when KexecOptions.KernelPath is not set.
+var KexecKernelPaths = []string{
+	"/boot/Image",
+	"/boot/zImage",
+	"/boot/bzImage",
+	"/boot/vmlinuz",
+}
+
+// KexecOptions contains options for the KexecActivate method
+type KexecOptions struct {
+	// Class is the slot class to boot into. Defaults to "rootfs".
+	Class string
+	// KernelPath and InitrdPath are paths inside the target slot. If
+	// KernelPath is empty, KexecKernelPaths are tried in order.
+	KernelPath string
+	InitrdPath string
+	// CommandLine for the new kernel. If empty, the running kernel's command
+	// line is reused with root= and rauc.slot= pointing to the target slot.
+	CommandLine string
+	// MountPoint is used to temporarily mount the target slot.
+	// Defaults to /tmp/rauc-kexec.
+	MountPoint string
+	// Fallback is used to reboot normally if any step of the kexec path
+	// fails. Set NoFallback to return the error instead.
+	Fallback   RebootOptions
+	NoFallback bool
+}
+
+// kexecTarget returns the status of the slot to boot into: the slot of the
+// given class that is not booted, is the primary boot slot and that the
+// bootloader considers good.
+func (p *Installer) kexecTarget(ctx context.Context, class string) (*SlotStatus, error) {
+	primary, err := p.GetPrimaryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := p.GetSlotStatusContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for i := range status {
+		s := status[i].Status
+		if statusString(s, SlotKeyClass) != class || statusString(s, SlotKeyBootname) == "" {
+			continue
+		}
+
+		if statusString(s, SlotKeyState) == SlotStateBooted {
+			continue
+		}
+
+		found = true
+		if status[i].SlotName != primary {
+			continue
+		}
+
+		if bs := statusString(s, SlotKeyBootStatus); bs != BootStatusGood {
+			return nil, fmt.Errorf("RAUC: slot %s has boot-status %q", status[i].SlotName, bs)
+		}
+
+		return &status[i], nil
+	}
+
+	if found {
+		return nil, fmt.Errorf("RAUC: no inactive %s slot is the primary boot slot %s", class, primary)
+	}
+
+	return nil, errorf("", ErrSlotNotFound, "RAUC: no inactive %s slot to activate", class)
+}
+
+// kexecCommandLine derives the new kernel's command line from the running
+// one by pointing root= and rauc.slot= to the target slot.
+func kexecCommandLine(device, bootname string) (string, error) {
+	b, err := ioutil.ReadFile("/proc/cmdline")
+	if err != nil {
+		return "", err
+	}
+
+	var args []string
+	for _, arg := range strings.Fields(string(b)) {
+		if strings.HasPrefix(arg, "root=") || strings.HasPrefix(arg, "rauc.slot=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	args = append(args, "root="+device, "rauc.slot="+bootname)
+
+	return strings.Join(args, " "), nil
+}
+
+func (p *Installer) kexecLoad(ctx context.Context, options KexecOptions) error {
+	if _, err := exec.LookPath("kexec"); err != nil {
+		return err
+	}
+
+	slot, err := p.kexecTarget(ctx, options.Class)
+	if err != nil {
+		return err
+	}
+
+	device := statusString(slot.Status, SlotKeyDevice)
+	fsType := statusString(slot.Status, SlotKeyType)
+	bootname := statusString(slot.Status, SlotKeyBootname)
+
+	if err := os.MkdirAll(options.MountPoint, 0755); err != nil {
+		return err
+	}
+
+	if err := mountReadOnly(device, options.MountPoint, fsType); err != nil {
+		return fmt.Errorf("mount %s: %w", device, err)
+	}
+	defer unmount(options.MountPoint)
+
+	kernelPaths := KexecKernelPaths
+	if options.KernelPath != "" {
+		kernelPaths = []string{options.KernelPath}
+	}
+
+	kernel := ""
+	for _, k := range kernelPaths {
+		if _, err := os.Stat(filepath.Join(options.MountPoint, k)); err == nil {
+			kernel = filepath.Join(options.MountPoint, k)
+			break
+		}
+	}
+
+	if kernel == "" {
+		return fmt.Errorf("no kernel image found in slot %s", slot.SlotName)
+	}
+
+	cmdline := options.CommandLine
+	if cmdline == "" {
+		if cmdline, err = kexecCommandLine(device, bootname); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"-l", kernel, "--command-line=" + cmdline}
+	if options.InitrdPath != "" {
+		args = append(args, "--initrd="+filepath.Join(options.MountPoint, options.InitrdPath))
+	}
+
+	if out, err := exec.CommandContext(ctx, "kexec", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("kexec -l: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// KexecActivate boots into the kernel of the freshly installed slot using
+// kexec, skipping the firmware and bootloader. The target slot has to be
+// the primary boot slot and marked good in the bootloader, so that a later
+// regular reboot ends up in the same slot. If any step fails, the system
+// is rebooted normally unless options.NoFallback is set.
+func (p *Installer) KexecActivate(ctx context.Context, options KexecOptions) error {
+	if options.Class == "" {
+		options.Class = "rootfs"
+	}
+
+	if options.MountPoint == "" {
+		options.MountPoint = "/tmp/rauc-kexec"
+	}
+
+	err := p.kexecLoad(ctx, options)
+	if err == nil {
+		// systemctl kexec shuts down services cleanly before jumping into
+		// the loaded kernel.
+		err = exec.CommandContext(ctx, "systemctl", "kexec").Run()
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	if options.NoFallback {
+		return fmt.Errorf("RAUC: KexecActivate(): %w", err)
+	}
+
+	return Reboot(ctx, options.Fallback)
+}
@@ -0,0 +1,125 @@
+package rauc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxKeyFileLine is the longest line parseKeyFile accepts. Long lines
+// occur e.g. in manifests with many hooks or base64 encoded values.
+const maxKeyFileLine = 1024 * 1024
+
+// parseKeyFile parses a file in GKeyFile format, as used by system.conf,
+// manifests and status files, into sections of key/value pairs. Values are
+// unescaped as by g_key_file_get_string(). Sections that appear more than
+// once are merged, later keys override earlier ones. name is used in
+// error messages.
+func parseKeyFile(r io.Reader, name string) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+
+	var section map[string]string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxKeyFileLine)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		raw := scanner.Bytes()
+		if lineNumber == 1 {
+			raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+		}
+		line := strings.TrimSpace(string(raw))
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: malformed section header", name, lineNumber)
+			}
+
+			sectionName := line[1 : len(line)-1]
+			if sectionName == "" || strings.ContainsAny(sectionName, "[]") || hasControl(sectionName) {
+				return nil, fmt.Errorf("%s:%d: invalid section name %q", name, lineNumber, sectionName)
+			}
+
+			if section = sections[sectionName]; section == nil {
+				section = make(map[string]string)
+				sections[sectionName] = section
+			}
+			continue
+		}
+
+		if section == nil {
+			return nil, fmt.Errorf("%s:%d: key outside of section", name, lineNumber)
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected key=value", name, lineNumber)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		if key == "" || hasControl(key) {
+			return nil, fmt.Errorf("%s:%d: invalid key %q", name, lineNumber, key)
+		}
+
+		section[key] = unescapeKeyFileValue(strings.TrimSpace(kv[1]))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%d: %v", name, lineNumber+1, err)
+	}
+
+	return sections, nil
+}
+
+func hasControl(s string) bool {
+	for _, c := range s {
+		if c < 0x20 || c == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unescapeKeyFileValue resolves the escape sequences of GKeyFile values.
+// Unknown sequences are kept as they are.
+func unescapeKeyFileValue(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 's':
+			b.WriteByte(' ')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
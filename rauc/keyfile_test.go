@@ -0,0 +1,87 @@
+package rauc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]map[string]string
+		err   string
+	}{
+		{
+			name:  "sections",
+			input: "[system]\ncompatible=board\n\n[slot.rootfs.0]\ndevice=/dev/sda1\n",
+			want: map[string]map[string]string{
+				"system":        {"compatible": "board"},
+				"slot.rootfs.0": {"device": "/dev/sda1"},
+			},
+		},
+		{
+			name:  "byte order mark",
+			input: "\xef\xbb\xbf[a]\nk=v\n",
+			want:  map[string]map[string]string{"a": {"k": "v"}},
+		},
+		{
+			name:  "comments and spaces",
+			input: "# comment\n  [a]  \n k = v \n# k=other\n",
+			want:  map[string]map[string]string{"a": {"k": "v"}},
+		},
+		{
+			name:  "merged sections",
+			input: "[a]\nx=1\ny=1\n[b]\nz=1\n[a]\nx=2\n",
+			want: map[string]map[string]string{
+				"a": {"x": "2", "y": "1"},
+				"b": {"z": "1"},
+			},
+		},
+		{
+			name:  "escapes",
+			input: "[a]\nv=a\\sb\\nc\\td\\re\\\\f\\qg\\\n",
+			want:  map[string]map[string]string{"a": {"v": "a b\nc\td\re\\f\\qg\\"}},
+		},
+		{
+			name:  "equals in value",
+			input: "[a]\nargs=--x=1\n",
+			want:  map[string]map[string]string{"a": {"args": "--x=1"}},
+		},
+		{
+			name:  "long line",
+			input: "[a]\nv=" + strings.Repeat("x", 100000) + "\n",
+			want:  map[string]map[string]string{"a": {"v": strings.Repeat("x", 100000)}},
+		},
+		{name: "empty section name", input: "[]\n", err: "test:1: invalid section name"},
+		{name: "nested brackets", input: "[a]]\n", err: "test:1: invalid section name"},
+		{name: "unterminated header", input: "\n[a\n", err: "test:2: malformed section header"},
+		{name: "control character", input: "[a\x01]\n", err: "invalid section name"},
+		{name: "key outside section", input: "k=v\n", err: "test:1: key outside of section"},
+		{name: "missing equals", input: "[a]\nk\n", err: "test:2: expected key=value"},
+		{name: "empty key", input: "[a]\n=v\n", err: "test:2: invalid key"},
+		{name: "line too long", input: "[a]\nv=" + strings.Repeat("x", maxKeyFileLine) + "\n", err: "test:2:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections, err := parseKeyFile(strings.NewReader(tt.input), "test")
+
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(sections, tt.want) {
+				t.Fatalf("got %q, want %q", sections, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/holoplot/go-rauc/internal/backoff"
+)
+
+// InstallLockFile is the file locked by InstallLock. All processes using
+// this package on a device share it.
+var InstallLockFile = "/run/go-rauc/install.lock"
+
+// ErrLocked is returned by TryLockInstall if another process holds the
+// install lock.
+var ErrLocked = errors.New("RAUC: install lock is held by another process")
+
+// InstallLock is an advisory lock that processes take before installing,
+// so they wait for each other instead of failing because the daemon is
+// busy.
+type InstallLock struct {
+	f *os.File
+}
+
+func openLockFile() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(InstallLockFile), 0755); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(InstallLockFile, os.O_RDWR|os.O_CREATE, 0644)
+}
+
+// TryLockInstall takes the install lock, or returns ErrLocked if another
+// process holds it.
+func TryLockInstall() (*InstallLock, error) {
+	f, err := openLockFile()
+	if err != nil {
+		return nil, fmt.Errorf("RAUC: TryLockInstall(): %v", err)
+	}
+
+	locked, err := tryLock(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("RAUC: TryLockInstall(): %v", err)
+	}
+
+	if !locked {
+		f.Close()
+		return nil, ErrLocked
+	}
+
+	return &InstallLock{f: f}, nil
+}
+
+// LockInstall waits until it can take the install lock, or the context
+// is done.
+func LockInstall(ctx context.Context) (*InstallLock, error) {
+	b := backoff.Backoff{
+		Initial: 50 * time.Millisecond,
+		Max:     time.Second,
+		Jitter:  0.2,
+	}
+
+	for {
+		l, err := TryLockInstall()
+		if err != ErrLocked {
+			return l, err
+		}
+
+		if err := backoff.Sleep(ctx, b.Next()); err != nil {
+			return nil, fmt.Errorf("RAUC: LockInstall(): %w", err)
+		}
+	}
+}
+
+// Unlock releases the lock.
+func (l *InstallLock) Unlock() error {
+	return l.f.Close()
+}
+
+// lockForInstall takes the install lock if options ask for it, waiting
+// until ctx is done or options.Timeout passed. The returned function
+// releases it.
+func lockForInstall(ctx context.Context, options InstallBundleOptions) (func(), error) {
+	if !options.Lock {
+		return func() {}, nil
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	l, err := LockInstall(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() { l.Unlock() }, nil
+}
@@ -0,0 +1,120 @@
+package rauc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseManifest parses a bundle manifest (manifest.raucm), e.g. one
+// extracted from a plain bundle or written by a build system, without
+// asking the daemon. ManifestHash is the SHA-256 hash of the content read.
+func ParseManifest(r io.Reader) (*BundleInfo, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %v", err)
+	}
+
+	sections, err := parseKeyFile(bytes.NewReader(data), "manifest")
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(data)
+	b := &BundleInfo{
+		ManifestHash: hex.EncodeToString(hash[:]),
+		Images:       []BundleImage{},
+	}
+
+	update := sections["update"]
+	b.Compatible = update["compatible"]
+	b.Version = update["version"]
+	b.Description = update["description"]
+	b.Build = update["build"]
+
+	b.Format = sections["bundle"]["format"]
+	if b.Format == "" {
+		b.Format = "plain"
+	}
+	b.VerityHash = sections["bundle"]["verity-hash"]
+
+	b.Hooks = manifestList(sections["hooks"]["hooks"])
+	b.Handler = sections["handler"]["filename"]
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "image.") {
+			continue
+		}
+
+		image, err := manifestImage(name, sections[name])
+		if err != nil {
+			return nil, err
+		}
+
+		b.Images = append(b.Images, image)
+	}
+
+	return b, nil
+}
+
+// manifestImage decodes an [image.<class>] or [image.<class>.<variant>]
+// section.
+func manifestImage(name string, keys map[string]string) (BundleImage, error) {
+	class := strings.TrimPrefix(name, "image.")
+
+	var variant string
+	if dot := strings.Index(class, "."); dot >= 0 {
+		class, variant = class[:dot], class[dot+1:]
+		if variant == "" {
+			return BundleImage{}, fmt.Errorf("manifest: invalid image section name %q", name)
+		}
+	}
+
+	if class == "" {
+		return BundleImage{}, fmt.Errorf("manifest: invalid image section name %q", name)
+	}
+
+	image := BundleImage{
+		SlotClass: class,
+		Variant:   variant,
+		Filename:  keys["filename"],
+		SHA256:    keys["sha256"],
+		Hooks:     manifestList(keys["hooks"]),
+		Adaptive:  manifestList(keys["adaptive"]),
+	}
+
+	if size, ok := keys["size"]; ok {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			return BundleImage{}, fmt.Errorf("manifest: [%s]: invalid size %q", name, size)
+		}
+		image.Size = n
+	}
+
+	return image, nil
+}
+
+// manifestList splits a GKeyFile list value, separated by semicolons.
+func manifestList(s string) []string {
+	var list []string
+
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,115 @@
+package rauc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testManifest = `[update]
+compatible=board
+version=2024.05.1
+description=Release\sbuild
+build=20240501
+
+[bundle]
+format=verity
+verity-hash=0123abcd
+
+[hooks]
+filename=hook.sh
+hooks=install-check;
+
+[handler]
+filename=custom-handler.sh
+
+[image.rootfs]
+filename=rootfs.ext4
+sha256=a1b2
+size=4096
+hooks=pre-install;post-install
+adaptive=block-hash-index
+
+[image.rootfs.large]
+filename=rootfs-large.ext4
+size=8192
+`
+
+func TestParseManifest(t *testing.T) {
+	b, err := ParseManifest(strings.NewReader(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &BundleInfo{
+		Compatible:   "board",
+		Version:      "2024.05.1",
+		Description:  "Release build",
+		Build:        "20240501",
+		Format:       "verity",
+		ManifestHash: b.ManifestHash,
+		VerityHash:   "0123abcd",
+		Hooks:        []string{"install-check"},
+		Handler:      "custom-handler.sh",
+		Images: []BundleImage{
+			{
+				SlotClass: "rootfs",
+				Filename:  "rootfs.ext4",
+				SHA256:    "a1b2",
+				Size:      4096,
+				Hooks:     []string{"pre-install", "post-install"},
+				Adaptive:  []string{AdaptiveBlockHashIndex},
+			},
+			{
+				SlotClass: "rootfs",
+				Variant:   "large",
+				Filename:  "rootfs-large.ext4",
+				Size:      8192,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(b, want) {
+		t.Fatalf("got %+v\nwant %+v", b, want)
+	}
+
+	if len(b.ManifestHash) != 64 {
+		t.Errorf("manifest hash %q is not a SHA-256 hex digest", b.ManifestHash)
+	}
+
+	if image, ok := b.Image("rootfs", "large"); !ok || image.Filename != "rootfs-large.ext4" {
+		t.Errorf("Image(rootfs, large) = %+v, %v", image, ok)
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"empty class", "[image.]\n"},
+		{"empty variant", "[image.rootfs.]\n"},
+		{"negative size", "[image.rootfs]\nsize=-1\n"},
+		{"invalid size", "[image.rootfs]\nsize=big\n"},
+		{"key outside of section", "compatible=board\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseManifest(strings.NewReader(tt.manifest)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseManifestDefaults(t *testing.T) {
+	b, err := ParseManifest(strings.NewReader("[update]\ncompatible=board\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Format != "plain" || len(b.Images) != 0 || b.Images == nil {
+		t.Fatalf("got %+v", b)
+	}
+}
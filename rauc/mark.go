@@ -0,0 +1,104 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+)
+
+// MarkState is a state Mark puts a slot into: good, bad or active.
+type MarkState string
+
+const (
+	// MarkStateGood keeps a slot bootable.
+	MarkStateGood MarkState = "good"
+	// MarkStateBad makes a slot unbootable.
+	MarkStateBad MarkState = "bad"
+	// MarkStateActive activates a slot for the next boot.
+	MarkStateActive MarkState = "active"
+)
+
+// ErrInvalidMarkState is the cause of Mark calls with a state other than
+// the MarkState constants.
+var ErrInvalidMarkState = errors.New("RAUC: invalid mark state")
+
+// ParseMarkState returns state as MarkState, failing with
+// ErrInvalidMarkState for unknown states.
+func ParseMarkState(state string) (MarkState, error) {
+	switch s := MarkState(state); s {
+	case MarkStateGood, MarkStateBad, MarkStateActive:
+		return s, nil
+	}
+
+	return "", errorf("Mark", ErrInvalidMarkState, "unknown state %q", state)
+}
+
+// MarkResult is the result of marking a slot.
+type MarkResult struct {
+	// Slot is the name of the marked slot.
+	Slot string
+	// State is the state the slot was marked with.
+	State MarkState
+	// Message is the message of RAUC, as in "marked slot rootfs.1 as good".
+	Message string
+}
+
+// markSlot checks state and calls mark.
+func markSlot(state MarkState, slotIdentifier string, mark func(state string, slotIdentifier string) (string, string, error)) (MarkResult, error) {
+	if _, err := ParseMarkState(string(state)); err != nil {
+		return MarkResult{}, err
+	}
+
+	slotName, message, err := mark(string(state), slotIdentifier)
+	if err != nil {
+		return MarkResult{}, err
+	}
+
+	return MarkResult{Slot: slotName, State: state, Message: message}, nil
+}
+
+// MarkSlot marks a slot of b, identified by its name, "booted" or "other".
+func MarkSlot(b Backend, state MarkState, slotIdentifier string) (MarkResult, error) {
+	return markSlot(state, slotIdentifier, b.Mark)
+}
+
+// MarkGood marks a slot of b good, see MarkSlot.
+func MarkGood(b Backend, slotIdentifier string) (MarkResult, error) {
+	return MarkSlot(b, MarkStateGood, slotIdentifier)
+}
+
+// MarkBad marks a slot of b bad, see MarkSlot.
+func MarkBad(b Backend, slotIdentifier string) (MarkResult, error) {
+	return MarkSlot(b, MarkStateBad, slotIdentifier)
+}
+
+// MarkActive activates a slot of b for the next boot, see MarkSlot.
+func MarkActive(b Backend, slotIdentifier string) (MarkResult, error) {
+	return MarkSlot(b, MarkStateActive, slotIdentifier)
+}
+
+// MarkSlot marks a slot, identified by its name, "booted" or "other".
+func (p *Installer) MarkSlot(state MarkState, slotIdentifier string) (MarkResult, error) {
+	return p.MarkSlotContext(context.Background(), state, slotIdentifier)
+}
+
+// MarkSlotContext is MarkSlot, giving up when ctx is done.
+func (p *Installer) MarkSlotContext(ctx context.Context, state MarkState, slotIdentifier string) (MarkResult, error) {
+	return markSlot(state, slotIdentifier, func(state string, slotIdentifier string) (string, string, error) {
+		return p.MarkContext(ctx, state, slotIdentifier)
+	})
+}
+
+// MarkGood marks a slot good, see MarkSlot.
+func (p *Installer) MarkGood(slotIdentifier string) (MarkResult, error) {
+	return p.MarkSlot(MarkStateGood, slotIdentifier)
+}
+
+// MarkBad marks a slot bad, see MarkSlot.
+func (p *Installer) MarkBad(slotIdentifier string) (MarkResult, error) {
+	return p.MarkSlot(MarkStateBad, slotIdentifier)
+}
+
+// MarkActive activates a slot for the next boot, see MarkSlot.
+func (p *Installer) MarkActive(slotIdentifier string) (MarkResult, error) {
+	return p.MarkSlot(MarkStateActive, slotIdentifier)
+}
@@ -0,0 +1,150 @@
+package rauc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	mountInfoPath = "/proc/self/mountinfo"
+)
+
+// Mount describes an entry of /proc/self/mountinfo.
+type Mount struct {
+	MountPoint string `json:"mount_point" yaml:"mount_point"`
+	// Root is the directory within the filesystem that forms the root of
+	// the mount, "/" unless this is a bind mount.
+	Root     string `json:"root" yaml:"root"`
+	FSType   string `json:"fstype" yaml:"fstype"`
+	Source   string `json:"source" yaml:"source"`
+	ReadOnly bool   `json:"read_only" yaml:"read_only"`
+
+	major, minor uint64
+}
+
+// unescapeMountInfo decodes the octal escapes (e.g. "\040" for a space)
+// the kernel uses in mountinfo paths.
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// parseMountInfo parses the content of a mountinfo file.
+func parseMountInfo(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+
+	scanner := bufio.NewScanner(r)
+	// Mount options of overlay and NFS mounts can exceed the default
+	// token size.
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// Optional fields are terminated by a single hyphen.
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+
+		if len(fields) < 6 || sep < 0 || sep+2 >= len(fields) {
+			return nil, fmt.Errorf("RAUC: malformed mountinfo line %q", scanner.Text())
+		}
+
+		m := Mount{
+			Root:       unescapeMountInfo(fields[3]),
+			MountPoint: unescapeMountInfo(fields[4]),
+			FSType:     fields[sep+1],
+			Source:     unescapeMountInfo(fields[sep+2]),
+		}
+
+		for _, o := range strings.Split(fields[5], ",") {
+			if o == "ro" {
+				m.ReadOnly = true
+			}
+		}
+
+		if _, err := fmt.Sscanf(fields[2], "%d:%d", &m.major, &m.minor); err != nil {
+			return nil, fmt.Errorf("RAUC: malformed device number %q in mountinfo", fields[2])
+		}
+
+		mounts = append(mounts, m)
+	}
+
+	return mounts, scanner.Err()
+}
+
+// ReadMounts returns the mounts visible to the current process.
+func ReadMounts() ([]Mount, error) {
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseMountInfo(f)
+}
+
+// MountsForDevice returns all entries in mounts that refer to the given
+// device. Devices are compared by device number where possible, so that
+// /dev/root and symlinks match the slot's configured device path.
+func MountsForDevice(mounts []Mount, device string) []Mount {
+	var result []Mount
+
+	major, minor, haveNumber := deviceNumber(device)
+	canonical := canonicalDevice(device)
+
+	for _, m := range mounts {
+		if haveNumber && m.major == major && m.minor == minor {
+			result = append(result, m)
+		} else if !haveNumber && canonicalDevice(m.Source) == canonical {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
+
+// SlotMounts returns the mounts of each slot in status, keyed by slot name.
+// Slots that are not mounted are not included.
+func SlotMounts(status []SlotStatus) (map[string][]Mount, error) {
+	mounts, err := ReadMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]Mount)
+	for _, s := range status {
+		device := statusString(s.Status, SlotKeyDevice)
+		if device == "" {
+			continue
+		}
+
+		if m := MountsForDevice(mounts, device); len(m) > 0 {
+			result[s.SlotName] = m
+		}
+	}
+
+	return result, nil
+}
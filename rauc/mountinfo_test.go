@@ -0,0 +1,77 @@
+package rauc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Mount
+		err   bool
+	}{
+		{
+			name:  "root",
+			input: "22 1 179:2 / / rw,relatime shared:1 - ext4 /dev/mmcblk0p2 rw\n",
+			want:  []Mount{{MountPoint: "/", Root: "/", FSType: "ext4", Source: "/dev/mmcblk0p2", major: 179, minor: 2}},
+		},
+		{
+			name:  "no optional fields",
+			input: "23 22 0:21 / /tmp rw - tmpfs tmpfs rw\n",
+			want:  []Mount{{MountPoint: "/tmp", Root: "/", FSType: "tmpfs", Source: "tmpfs", minor: 21}},
+		},
+		{
+			name:  "several optional fields",
+			input: "24 22 8:1 / /data ro,noatime master:1 shared:2 - ext4 /dev/sda1 ro\n",
+			want:  []Mount{{MountPoint: "/data", Root: "/", FSType: "ext4", Source: "/dev/sda1", ReadOnly: true, major: 8, minor: 1}},
+		},
+		{
+			name:  "bind mount with escapes",
+			input: "25 22 8:1 /var/lib\\040data /mnt/with\\040space rw - ext4 /dev/sda\\0611 rw\n",
+			want:  []Mount{{MountPoint: "/mnt/with space", Root: "/var/lib data", FSType: "ext4", Source: "/dev/sda11", major: 8, minor: 1}},
+		},
+		{
+			name:  "invalid escape",
+			input: "26 22 8:1 / /a\\9 rw - ext4 /dev/sda1 rw\n",
+			want:  []Mount{{MountPoint: "/a\\9", Root: "/", FSType: "ext4", Source: "/dev/sda1", major: 8, minor: 1}},
+		},
+		{
+			name: "several mounts",
+			input: "22 1 179:2 / / rw - ext4 /dev/root rw\n" +
+				"23 22 179:3 / /data rw - ext4 /dev/mmcblk0p3 rw\n",
+			want: []Mount{
+				{MountPoint: "/", Root: "/", FSType: "ext4", Source: "/dev/root", major: 179, minor: 2},
+				{MountPoint: "/data", Root: "/", FSType: "ext4", Source: "/dev/mmcblk0p3", major: 179, minor: 3},
+			},
+		},
+		{name: "empty", input: ""},
+		{name: "too few fields", input: "22 1 179:2 / /\n", err: true},
+		{name: "no separator", input: "22 1 179:2 / / rw shared:1 ext4 /dev/root rw\n", err: true},
+		{name: "no source", input: "22 1 179:2 / / rw - ext4\n", err: true},
+		{name: "invalid device number", input: "22 1 root / / rw - ext4 /dev/root rw\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mounts, err := parseMountInfo(strings.NewReader(tt.input))
+
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %+v, want error", mounts)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(mounts, tt.want) {
+				t.Fatalf("got %+v, want %+v", mounts, tt.want)
+			}
+		})
+	}
+}
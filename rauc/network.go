@@ -0,0 +1,130 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	networkGateDefaultTimeout    = 5 * time.Second
+	networkGateDefaultRetryAfter = time.Minute
+
+	// NM_STATE_CONNECTED_SITE, anything below has no usable connection.
+	networkManagerStateConnectedSite = 60
+)
+
+// ErrNoNetwork is matched by errors returned from NetworkGate when the
+// device has no usable network connection.
+var ErrNoNetwork = errors.New("RAUC: no network connectivity")
+
+// NoNetworkError is returned by NetworkGate. It matches ErrNoNetwork with
+// errors.Is and carries a hint for when to try again.
+type NoNetworkError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *NoNetworkError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrNoNetwork, e.Reason)
+}
+
+// Is implements error matching for errors.Is.
+func (e *NoNetworkError) Is(target error) bool {
+	return target == ErrNoNetwork
+}
+
+// NetworkGate is a Gate that checks for network connectivity before
+// streaming installs or downloads. It asks NetworkManager or
+// systemd-networkd if available, and then probes the host of URL with
+// a TCP connection.
+type NetworkGate struct {
+	// URL of the bundle. If empty, only the network daemons are asked.
+	URL string
+	// Timeout for the reachability probe. Defaults to 5 seconds.
+	Timeout time.Duration
+	// RetryAfter is reported in NoNetworkError. Defaults to one minute.
+	RetryAfter time.Duration
+}
+
+func (g *NetworkGate) fail(format string, args ...interface{}) error {
+	retryAfter := g.RetryAfter
+	if retryAfter == 0 {
+		retryAfter = networkGateDefaultRetryAfter
+	}
+
+	return &NoNetworkError{
+		Reason:     fmt.Sprintf(format, args...),
+		RetryAfter: retryAfter,
+	}
+}
+
+// daemonState asks NetworkManager and systemd-networkd for the overall
+// connectivity. known is false if neither of them is running.
+func daemonState() (connected bool, known bool) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return false, false
+	}
+
+	nm := conn.Object("org.freedesktop.NetworkManager", dbus.ObjectPath("/org/freedesktop/NetworkManager"))
+	if v, err := nm.GetProperty("org.freedesktop.NetworkManager.State"); err == nil {
+		if state, ok := v.Value().(uint32); ok {
+			return state >= networkManagerStateConnectedSite, true
+		}
+	}
+
+	networkd := conn.Object("org.freedesktop.network1", dbus.ObjectPath("/org/freedesktop/network1"))
+	if v, err := networkd.GetProperty("org.freedesktop.network1.Manager.OperationalState"); err == nil {
+		if state, ok := v.Value().(string); ok {
+			return state == "routable" || state == "degraded", true
+		}
+	}
+
+	return false, false
+}
+
+// Check implements Gate.
+func (g *NetworkGate) Check(ctx context.Context) error {
+	if connected, known := daemonState(); known && !connected {
+		return g.fail("network daemon reports no connection")
+	}
+
+	if g.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(g.URL)
+	if err != nil || u.Host == "" {
+		// Local paths need no network.
+		return nil
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	timeout := g.Timeout
+	if timeout == 0 {
+		timeout = networkGateDefaultTimeout
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	c, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return g.fail("%s unreachable: %v", host, err)
+	}
+	c.Close()
+
+	return nil
+}
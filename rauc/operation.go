@@ -0,0 +1,77 @@
+package rauc
+
+import (
+	"context"
+	"time"
+)
+
+// Operation is a value of the daemon's Operation property.
+type Operation string
+
+// Operations reported by the daemon.
+const (
+	OperationIdle       Operation = "idle"
+	OperationInstalling Operation = "installing"
+)
+
+const operationPollInterval = 500 * time.Millisecond
+
+// WaitForOperation blocks until the daemon reports the target operation
+// or the context is cancelled, and returns the daemon's LastError at that
+// point. The Installer waits for property change signals, other backends
+// are polled.
+func WaitForOperation(ctx context.Context, b Backend, target Operation) (lastError string, err error) {
+	if p, ok := b.(*Installer); ok {
+		return p.WaitForOperation(ctx, target)
+	}
+
+	return pollOperation(ctx, b, target, nil)
+}
+
+// WaitForOperation blocks until the daemon reports the target operation
+// or the context is cancelled, and returns the daemon's LastError at that
+// point. The property is polled as well, in case a signal is missed.
+func (p *Installer) WaitForOperation(ctx context.Context, target Operation) (lastError string, err error) {
+	signals, unsubscribe := p.SubscribeSignals(10)
+	defer unsubscribe()
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		for signal := range signals {
+			if signal.Name == "org.freedesktop.DBus.Properties.PropertiesChanged" {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return pollOperation(ctx, p, target, changed)
+}
+
+// pollOperation checks the operation whenever changed fires, and at
+// least every operationPollInterval.
+func pollOperation(ctx context.Context, b Backend, target Operation, changed <-chan struct{}) (string, error) {
+	ticker := time.NewTicker(operationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		operation, err := b.GetOperation()
+		if err != nil {
+			return "", err
+		}
+
+		if Operation(operation) == target {
+			return b.GetLastError()
+		}
+
+		select {
+		case <-ctx.Done():
+			lastError, _ := b.GetLastError()
+			return lastError, ctx.Err()
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
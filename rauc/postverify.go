@@ -0,0 +1,99 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInstallMismatch is matched by errors.Is for MismatchError values.
+var ErrInstallMismatch = errors.New("RAUC: installed slot does not match bundle")
+
+// MismatchError is returned by InstallBundle with
+// InstallBundleOptions.VerifyInstalled if the daemon reported success but
+// the slot status does not show the bundle as installed.
+type MismatchError struct {
+	Bundle string
+	// Slot is the slot that was written, or empty if none was.
+	Slot     string
+	Expected string
+	Actual   string
+}
+
+func (e *MismatchError) Error() string {
+	if e.Slot == "" {
+		return fmt.Sprintf("RAUC: installation of %s did not update any slot", e.Bundle)
+	}
+
+	return fmt.Sprintf("RAUC: slot %s has version %q after installing %s, expected %q",
+		e.Slot, e.Actual, e.Bundle, e.Expected)
+}
+
+// Is makes errors.Is(err, ErrInstallMismatch) true.
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrInstallMismatch
+}
+
+// installedCounts returns the installation counter of each slot.
+func installedCounts(b Backend) (map[string]int64, error) {
+	slots, err := b.GetSlotStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(slots))
+	for _, s := range slots {
+		counts[s.SlotName], _ = s.GetInt(SlotKeyInstalledCount)
+	}
+
+	return counts, nil
+}
+
+// prepareVerify records the state needed to verify an installation of
+// filename and returns the function that verifies it, given the result
+// of the installation. With options.VerifyInstalled not set, the
+// function returns the result unchanged.
+func prepareVerify(b Backend, filename string, options InstallBundleOptions) (func(error) error, error) {
+	if !options.VerifyInstalled {
+		return func(err error) error { return err }, nil
+	}
+
+	_, version, err := b.Info(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := installedCounts(b)
+	if err != nil {
+		return nil, fmt.Errorf("RAUC: GetSlotStatus(): %v", err)
+	}
+
+	return func(err error) error {
+		if err != nil {
+			return err
+		}
+
+		slots, err := b.GetSlotStatus()
+		if err != nil {
+			return fmt.Errorf("RAUC: GetSlotStatus(): %v", err)
+		}
+
+		e := &MismatchError{Bundle: filename, Expected: version}
+
+		for _, s := range slots {
+			if count, _ := s.GetInt(SlotKeyInstalledCount); count <= before[s.SlotName] {
+				continue
+			}
+
+			actual, _ := s.GetString(SlotKeyBundleVersion)
+			status, _ := s.GetString(SlotKeyStatus)
+			if actual == version && (status == "" || status == InstallStatusOK) {
+				return nil
+			}
+
+			e.Slot = s.SlotName
+			e.Actual = actual
+		}
+
+		return e
+	}, nil
+}
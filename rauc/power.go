@@ -0,0 +1,132 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	powerSupplyDirectory = "/sys/class/power_supply"
+)
+
+// ErrInsufficientPower is matched by errors returned from PowerGate when an
+// installation should be deferred because of the power state.
+var ErrInsufficientPower = errors.New("RAUC: insufficient power")
+
+// PowerError is returned by PowerGate. It matches ErrInsufficientPower
+// with errors.Is.
+type PowerError struct {
+	OnBattery bool
+	// Percentage is the battery charge level, or -1 if unknown.
+	Percentage float64
+}
+
+func (e *PowerError) Error() string {
+	if e.Percentage < 0 {
+		return fmt.Sprintf("%v: on battery", ErrInsufficientPower)
+	}
+
+	return fmt.Sprintf("%v: on battery at %.0f%%", ErrInsufficientPower, e.Percentage)
+}
+
+// Is implements error matching for errors.Is.
+func (e *PowerError) Is(target error) bool {
+	return target == ErrInsufficientPower
+}
+
+// PowerGate is a Gate that defers installations on battery powered devices
+// when their charge is low. The state is read from UPower, or from sysfs if
+// UPower is not running. Devices without a battery always pass.
+type PowerGate struct {
+	// MinBatteryPercent is the charge level required while on battery.
+	MinBatteryPercent float64
+	// RequireAC refuses to install on battery regardless of the charge.
+	RequireAC bool
+}
+
+// powerState returns whether the system runs on battery and the battery
+// charge level (-1 if unknown).
+func powerState() (onBattery bool, percentage float64, err error) {
+	if conn, err := dbus.SystemBus(); err == nil {
+		upower := conn.Object("org.freedesktop.UPower", dbus.ObjectPath("/org/freedesktop/UPower"))
+		if v, err := upower.GetProperty("org.freedesktop.UPower.OnBattery"); err == nil {
+			onBattery, _ = v.Value().(bool)
+			percentage = -1
+
+			display := conn.Object("org.freedesktop.UPower", dbus.ObjectPath("/org/freedesktop/UPower/devices/DisplayDevice"))
+			if v, err := display.GetProperty("org.freedesktop.UPower.Device.Percentage"); err == nil {
+				if p, ok := v.Value().(float64); ok {
+					percentage = p
+				}
+			}
+
+			return onBattery, percentage, nil
+		}
+	}
+
+	return sysfsPowerState()
+}
+
+func readSysfsString(path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+func sysfsPowerState() (onBattery bool, percentage float64, err error) {
+	supplies, err := filepath.Glob(filepath.Join(powerSupplyDirectory, "*"))
+	if err != nil {
+		return false, -1, err
+	}
+
+	haveBattery := false
+	online := false
+	percentage = -1
+
+	for _, s := range supplies {
+		switch readSysfsString(filepath.Join(s, "type")) {
+		case "Battery":
+			haveBattery = true
+			if c, err := strconv.ParseFloat(readSysfsString(filepath.Join(s, "capacity")), 64); err == nil {
+				percentage = c
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if readSysfsString(filepath.Join(s, "online")) == "1" {
+				online = true
+			}
+		}
+	}
+
+	return haveBattery && !online, percentage, nil
+}
+
+// Check implements Gate.
+func (g *PowerGate) Check(ctx context.Context) error {
+	onBattery, percentage, err := powerState()
+	if err != nil {
+		return fmt.Errorf("RAUC: cannot determine power state: %v", err)
+	}
+
+	if !onBattery {
+		return nil
+	}
+
+	if g.RequireAC || (percentage >= 0 && percentage < g.MinBatteryPercent) {
+		return &PowerError{
+			OnBattery:  onBattery,
+			Percentage: percentage,
+		}
+	}
+
+	return nil
+}
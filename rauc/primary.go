@@ -0,0 +1,63 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+)
+
+// GetPrimary returns the name of the slot the bootloader will boot next,
+// as determined by the daemon from the bootloader state.
+func GetPrimary(b Backend) (string, error) {
+	switch b := b.(type) {
+	case *Installer:
+		return b.GetPrimary()
+	case *CLI:
+		return b.GetPrimary()
+	case *Simulator:
+		return b.GetPrimary()
+	}
+
+	return "", errors.New("RAUC: backend cannot get the primary slot")
+}
+
+// GetPrimary returns the name of the primary boot slot.
+func (p *Installer) GetPrimary() (string, error) {
+	return p.GetPrimaryContext(context.Background())
+}
+
+// GetPrimaryContext is GetPrimary, giving up when ctx is done.
+func (p *Installer) GetPrimaryContext(ctx context.Context) (string, error) {
+	var primary string
+
+	err := p.call(ctx, p.interfaceForMember("GetPrimary")).Store(&primary)
+	if err != nil {
+		return "", callError("GetPrimary", err)
+	}
+
+	return primary, nil
+}
+
+// GetPrimary returns the name of the primary boot slot.
+func (c *CLI) GetPrimary() (string, error) {
+	s, err := c.status()
+	if err != nil {
+		return "", callError("GetPrimary", err)
+	}
+
+	return s.Primary, nil
+}
+
+// GetPrimary returns the name of the slot activated last, or of the
+// booted slot.
+func (s *Simulator) GetPrimary() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, slot := range s.slots {
+		if statusString(slot.Status, SlotKeyBootname) == s.primary {
+			return slot.SlotName, nil
+		}
+	}
+
+	return "", errors.New("RAUC: GetPrimary(): no primary slot")
+}
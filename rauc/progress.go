@@ -0,0 +1,51 @@
+package rauc
+
+import (
+	"context"
+	"fmt"
+)
+
+// Progress is the value of the Progress property of the daemon.
+type Progress struct {
+	Percentage int32  `json:"percentage" yaml:"percentage"`
+	Message    string `json:"message" yaml:"message"`
+	// NestingDepth is the depth of the step Message describes, starting
+	// at 1 for the installation as a whole.
+	NestingDepth int32 `json:"nesting_depth" yaml:"nesting_depth"`
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("%d%% %s", p.Percentage, p.Message)
+}
+
+// ProgressOf returns the installation progress of b as Progress.
+func ProgressOf(b Backend) (Progress, error) {
+	var p Progress
+	var err error
+
+	p.Percentage, p.Message, p.NestingDepth, err = b.GetProgress()
+
+	return p, err
+}
+
+// contextProgress is implemented by backends that can give up reading the
+// progress.
+type contextProgress interface {
+	GetProgressContext(ctx context.Context) (percentage int32, message string, nestingDepth int32, err error)
+}
+
+// ProgressOfContext is ProgressOf, giving up when ctx is done if b
+// supports it.
+func ProgressOfContext(ctx context.Context, b Backend) (Progress, error) {
+	c, ok := b.(contextProgress)
+	if !ok {
+		return ProgressOf(b)
+	}
+
+	var p Progress
+	var err error
+
+	p.Percentage, p.Message, p.NestingDepth, err = c.GetProgressContext(ctx)
+
+	return p, err
+}
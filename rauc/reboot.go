@@ -0,0 +1,142 @@
+package rauc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// RebootRequiredFile is the flag file that records that a reboot is needed
+// to activate an installed update. It lives in /run so that it disappears
+// with the reboot.
+var RebootRequiredFile = "/run/rauc-reboot-required"
+
+// RebootRequiredEvent is published when a reboot becomes necessary.
+type RebootRequiredEvent struct {
+	Reason string
+}
+
+// EventType implements Event.
+func (e RebootRequiredEvent) EventType() string {
+	return "reboot-required"
+}
+
+// SetRebootRequired creates the reboot-required flag file, storing reason in
+// it, and publishes a RebootRequiredEvent on bus if it is not nil.
+func SetRebootRequired(reason string, bus *EventBus) error {
+	if err := ioutil.WriteFile(RebootRequiredFile, []byte(reason+"\n"), 0644); err != nil {
+		return fmt.Errorf("RAUC: SetRebootRequired(): %v", err)
+	}
+
+	if bus != nil {
+		bus.Publish(RebootRequiredEvent{Reason: reason})
+	}
+
+	return nil
+}
+
+// RebootRequired reports whether the reboot-required flag is set, and the
+// reason given when it was set.
+func RebootRequired() (bool, string, error) {
+	b, err := ioutil.ReadFile(RebootRequiredFile)
+	if os.IsNotExist(err) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("RAUC: RebootRequired(): %v", err)
+	}
+
+	return true, strings.TrimSpace(string(b)), nil
+}
+
+// ClearRebootRequired removes the reboot-required flag.
+func ClearRebootRequired() error {
+	if err := os.Remove(RebootRequiredFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("RAUC: ClearRebootRequired(): %v", err)
+	}
+
+	return nil
+}
+
+// RebootStrategy selects how Reboot restarts the system.
+type RebootStrategy int
+
+const (
+	// RebootSystemctl runs "systemctl reboot".
+	RebootSystemctl RebootStrategy = iota
+	// RebootLogind asks systemd-logind over D-Bus.
+	RebootLogind
+	// RebootSyscall syncs the filesystems and calls reboot(2) directly,
+	// without shutting down services.
+	RebootSyscall
+	// RebootCommand runs the command given in RebootOptions.Command.
+	RebootCommand
+)
+
+// RebootOptions contains options for the Reboot function
+type RebootOptions struct {
+	Strategy RebootStrategy
+	// Command is the command line to run with RebootCommand.
+	Command []string
+	// Delay is a grace period to wait before rebooting. Cancelling the
+	// context during the delay aborts the reboot.
+	Delay time.Duration
+}
+
+// Reboot restarts the system using the configured strategy.
+func Reboot(ctx context.Context, options RebootOptions) error {
+	if options.Delay > 0 {
+		timer := time.NewTimer(options.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	switch options.Strategy {
+	case RebootSystemctl:
+		if out, err := exec.CommandContext(ctx, "systemctl", "reboot").CombinedOutput(); err != nil {
+			return fmt.Errorf("RAUC: systemctl reboot: %v: %s", err, strings.TrimSpace(string(out)))
+		}
+
+	case RebootLogind:
+		conn, err := dbus.SystemBus()
+		if err != nil {
+			return fmt.Errorf("RAUC: Reboot(): %v", err)
+		}
+
+		obj := conn.Object("org.freedesktop.login1", dbus.ObjectPath("/org/freedesktop/login1"))
+		if err := obj.CallWithContext(ctx, "org.freedesktop.login1.Manager.Reboot", 0, false).Err; err != nil {
+			return fmt.Errorf("RAUC: logind Reboot(): %v", err)
+		}
+
+	case RebootSyscall:
+		if err := rebootSyscall(); err != nil {
+			return fmt.Errorf("RAUC: reboot(2): %v", err)
+		}
+
+	case RebootCommand:
+		if len(options.Command) == 0 {
+			return fmt.Errorf("RAUC: Reboot(): no command given")
+		}
+
+		cmd := exec.CommandContext(ctx, options.Command[0], options.Command[1:]...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("RAUC: %s: %v: %s", options.Command[0], err, strings.TrimSpace(string(out)))
+		}
+
+	default:
+		return fmt.Errorf("RAUC: Reboot(): unknown strategy %d", options.Strategy)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package rauc
+
+import (
+	"context"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/internal/backoff"
+)
+
+// SignalReconnected is the name of the signal SubscribeSignals delivers
+// once a lost connection is re-established. Signals the daemon sent in
+// the meantime are lost. It is never sent on the bus.
+const SignalReconnected = "org.freedesktop.DBus.Local.Reconnected"
+
+// ReconnectedEvent is published when the Installer re-established its
+// lost connection to the bus.
+type ReconnectedEvent struct {
+	// Attempts is the number of connection attempts it took.
+	Attempts int
+	// Downtime is the time since the connection was lost.
+	Downtime time.Duration
+}
+
+// EventType implements Event.
+func (e ReconnectedEvent) EventType() string {
+	return "installer.reconnected"
+}
+
+// reconnect opens a new connection with p.dial, retrying until it
+// succeeds, and returns the channel the daemon's signals arrive on.
+func (p *Installer) reconnect() chan *dbus.Signal {
+	lost := time.Now()
+	b := backoff.Backoff{
+		Initial: 100 * time.Millisecond,
+		Max:     10 * time.Second,
+		Jitter:  0.2,
+	}
+
+	for attempts := 1; ; attempts++ {
+		time.Sleep(b.Next())
+
+		conn, err := p.dial()
+		if err != nil || !conn.Connected() {
+			continue
+		}
+
+		p.connMutex.Lock()
+		p.conn = conn
+		p.object = conn.Object(p.busName, p.path)
+		p.connMutex.Unlock()
+
+		signals := p.addMatches(conn)
+
+		p.events.Publish(ReconnectedEvent{
+			Attempts: attempts,
+			Downtime: time.Since(lost),
+		})
+
+		return signals
+	}
+}
+
+// completedWhileDisconnected checks whether the installation waited for
+// completed while the connection was lost, as its Completed signal is
+// lost then. The result is told from the slot status, as for
+// AttachToCurrentOperation.
+func (p *Installer) completedWhileDisconnected(ctx context.Context) (bool, error) {
+	operation, err := p.GetOperationContext(ctx)
+	if err != nil || Operation(operation) == OperationInstalling {
+		return false, nil
+	}
+
+	state := &AttachState{}
+	p.detachMutex.Lock()
+	if p.current != nil {
+		*state = *p.current
+	}
+	p.detachMutex.Unlock()
+
+	return true, p.installResult(ctx, state)
+}
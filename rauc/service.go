@@ -0,0 +1,74 @@
+package rauc
+
+import (
+	"fmt"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	// ServiceUnit is the systemd unit the RAUC daemon runs in.
+	ServiceUnit = "rauc.service"
+
+	systemdInterface = "org.freedesktop.systemd1"
+)
+
+// ServiceState describes the state of a systemd service unit.
+type ServiceState struct {
+	Unit        string `json:"unit" yaml:"unit"`
+	LoadState   string `json:"load_state" yaml:"load_state"`
+	ActiveState string `json:"active_state" yaml:"active_state"`
+	SubState    string `json:"sub_state" yaml:"sub_state"`
+	// Result is the reason the service last stopped, e.g. "exit-code".
+	Result    string `json:"result" yaml:"result"`
+	NRestarts uint32 `json:"restarts" yaml:"restarts"`
+}
+
+func (s *ServiceState) String() string {
+	return fmt.Sprintf("%s: %s/%s, result %s, %d restarts",
+		s.Unit, s.ActiveState, s.SubState, s.Result, s.NRestarts)
+}
+
+// GetServiceState queries systemd for the state of the given service unit.
+func GetServiceState(conn *dbus.Conn, unit string) (*ServiceState, error) {
+	manager := conn.Object(systemdInterface, dbus.ObjectPath("/org/freedesktop/systemd1"))
+
+	var path dbus.ObjectPath
+	err := manager.Call(systemdInterface+".Manager.LoadUnit", 0, unit).Store(&path)
+	if err != nil {
+		return nil, fmt.Errorf("RAUC: LoadUnit(%s): %v", unit, err)
+	}
+
+	object := conn.Object(systemdInterface, path)
+	s := &ServiceState{Unit: unit}
+
+	props := []struct {
+		name  string
+		value *string
+	}{
+		{"Unit.LoadState", &s.LoadState},
+		{"Unit.ActiveState", &s.ActiveState},
+		{"Unit.SubState", &s.SubState},
+		{"Service.Result", &s.Result},
+	}
+
+	for _, p := range props {
+		v, err := object.GetProperty(systemdInterface + "." + p.name)
+		if err != nil {
+			return nil, fmt.Errorf("RAUC: GetProperty(%s): %v", p.name, err)
+		}
+		*p.value, _ = v.Value().(string)
+	}
+
+	// NRestarts is only available since systemd 235.
+	if v, err := object.GetProperty(systemdInterface + ".Service.NRestarts"); err == nil {
+		s.NRestarts, _ = v.Value().(uint32)
+	}
+
+	return s, nil
+}
+
+// GetServiceState returns the state of rauc.service.
+func (p *Installer) GetServiceState() (*ServiceState, error) {
+	return GetServiceState(p.connection(), ServiceUnit)
+}
@@ -0,0 +1,222 @@
+package rauc
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// signalHub receives the daemon's signals on a single channel and fans
+// them out to any number of subscribers. A subscriber that does not keep
+// up loses its oldest buffered signals, instead of holding up the others.
+type signalHub struct {
+	mutex       sync.Mutex
+	subscribers map[*signalSubscriber]struct{}
+	dropped     uint64
+}
+
+type signalSubscriber struct {
+	c chan *dbus.Signal
+}
+
+// deliver queues signal, making room by dropping the oldest queued one
+// if needed. It reports whether a signal was dropped.
+func (s *signalSubscriber) deliver(signal *dbus.Signal) bool {
+	for dropped := false; ; dropped = true {
+		select {
+		case s.c <- signal:
+			return dropped
+		default:
+		}
+
+		select {
+		case <-s.c:
+		default:
+		}
+	}
+}
+
+// startSignals adds the matches for the daemon's signals and starts
+// fanning them out.
+func (p *Installer) startSignals() {
+	p.signals = &signalHub{
+		subscribers: make(map[*signalSubscriber]struct{}),
+	}
+
+	signals := p.addMatches(p.connection())
+
+	go func() {
+		for {
+			for signal := range signals {
+				if signal.Path != p.path && signal.Name != "org.freedesktop.DBus.NameOwnerChanged" {
+					continue
+				}
+
+				p.signals.deliver(signal)
+			}
+
+			// The connection was closed.
+			if p.dial == nil {
+				p.signals.close()
+				return
+			}
+
+			signals = p.reconnect()
+			p.signals.deliver(&dbus.Signal{Name: SignalReconnected, Path: p.path})
+		}
+	}()
+}
+
+// addMatches adds the matches for the daemon's signals to conn and
+// returns the channel they arrive on.
+func (p *Installer) addMatches(conn *dbus.Conn) chan *dbus.Signal {
+	conn.AddMatchSignal(
+		dbus.WithMatchInterface(p.iface),
+		dbus.WithMatchMember("Completed"),
+		dbus.WithMatchObjectPath(p.path))
+	conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchObjectPath(p.path))
+	conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, p.busName))
+
+	signals := make(chan *dbus.Signal, 64)
+	conn.Signal(signals)
+
+	return signals
+}
+
+func (h *signalHub) deliver(signal *dbus.Signal) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for s := range h.subscribers {
+		if s.deliver(signal) {
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	}
+}
+
+// close ends all subscriptions.
+func (h *signalHub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for s := range h.subscribers {
+		close(s.c)
+		delete(h.subscribers, s)
+	}
+}
+
+// SubscribeSignals returns a channel that receives the daemon's Completed,
+// PropertiesChanged and NameOwnerChanged signals, buffering up to size
+// of them, and a function that ends the subscription. A SignalReconnected
+// signal follows the re-establishment of a lost connection. The channel
+// is closed when the connection is closed for good.
+func (p *Installer) SubscribeSignals(size int) (<-chan *dbus.Signal, func()) {
+	if size < 1 {
+		size = 1
+	}
+
+	s := &signalSubscriber{c: make(chan *dbus.Signal, size)}
+
+	p.signals.mutex.Lock()
+	p.signals.subscribers[s] = struct{}{}
+	p.signals.mutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			p.signals.mutex.Lock()
+			if _, ok := p.signals.subscribers[s]; ok {
+				delete(p.signals.subscribers, s)
+				close(s.c)
+			}
+			p.signals.mutex.Unlock()
+		})
+	}
+
+	return s.c, cancel
+}
+
+// DroppedSignals returns the number of signals subscribers lost because
+// their buffer was full.
+func (p *Installer) DroppedSignals() uint64 {
+	return atomic.LoadUint64(&p.signals.dropped)
+}
+
+// CompletedEvent is the daemon's Completed signal, sent at the end of
+// every installation, whichever client started it.
+type CompletedEvent struct {
+	// Result is 0 if the installation succeeded.
+	Result int32
+	// LastError is the daemon's LastError if the installation failed.
+	LastError string
+	Time      time.Time
+}
+
+// Err returns the failure of the installation, or nil.
+func (e CompletedEvent) Err() error {
+	if e.Result == 0 {
+		return nil
+	}
+
+	if e.LastError == "" {
+		return fmt.Errorf("RAUC: installation failed with result %d", e.Result)
+	}
+
+	return installError(e.LastError)
+}
+
+// SubscribeCompleted returns a channel that receives a CompletedEvent for
+// every installation that completes, including those started by other
+// clients such as the rauc command line tool, and a function that ends
+// the subscription. The channel is closed when the subscription ends or
+// the connection is closed.
+func (p *Installer) SubscribeCompleted() (<-chan CompletedEvent, func()) {
+	signals, unsubscribe := p.SubscribeSignals(16)
+
+	c := make(chan CompletedEvent, 16)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(c)
+
+		for signal := range signals {
+			if signal.Name != p.interfaceForMember("Completed") || signal.Path != p.path {
+				continue
+			}
+
+			e := CompletedEvent{Time: time.Now()}
+			if err := dbus.Store(signal.Body, &e.Result); err != nil {
+				continue
+			}
+
+			if e.Result != 0 {
+				e.LastError, _ = p.GetLastError()
+			}
+
+			select {
+			case c <- e:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+
+	return c, cancel
+}
@@ -0,0 +1,415 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// SimulatedBundle describes a bundle known to the Simulator.
+type SimulatedBundle struct {
+	Compatible string
+	Version    string
+}
+
+// SimulatorOptions contains options for the SimulatorNew function
+type SimulatorOptions struct {
+	// Compatible defaults to "simulator".
+	Compatible string
+	Variant    string
+	// Slots defaults to an A/B pair of rootfs slots, booted from A.
+	Slots []SlotStatus
+	// Bundles maps file names to bundle metadata. Bundles not listed
+	// are compatible and take their version from the file name, as in
+	// "update-1.2.3.raucb".
+	Bundles map[string]SimulatedBundle
+	// InstallDuration is the time a simulated installation takes.
+	// Defaults to 5 seconds.
+	InstallDuration time.Duration
+	// InstallError, if set, makes installations fail half-way with
+	// this message.
+	InstallError string
+	Events       *EventBus
+}
+
+// Simulator is an in-memory Backend for development machines without
+// RAUC. It simulates installations with synthetic progress and keeps
+// track of slot states and marks.
+type Simulator struct {
+	options SimulatorOptions
+	events  *EventBus
+
+	mutex     sync.Mutex
+	slots     []SlotStatus
+	booted    string
+	primary   string
+	operation string
+	lastError string
+	progress  cliProgress
+}
+
+var simulatorSteps = []string{
+	"Installing",
+	"Determining slot states",
+	"Checking bundle",
+	"Verifying signature",
+	"Checking manifest contents",
+	"Determining target install group",
+	"Updating slots",
+	"Copying image to rootfs",
+	"Updating slots done",
+	"Installing done",
+}
+
+// SimulatorNew returns a newly allocated Simulator object
+func SimulatorNew(options SimulatorOptions) *Simulator {
+	if options.Compatible == "" {
+		options.Compatible = "simulator"
+	}
+
+	if options.InstallDuration == 0 {
+		options.InstallDuration = 5 * time.Second
+	}
+
+	s := &Simulator{
+		options:   options,
+		events:    options.Events,
+		slots:     append([]SlotStatus(nil), options.Slots...),
+		operation: string(OperationIdle),
+	}
+
+	if s.events == nil {
+		s.events = EventBusNew()
+	}
+
+	for i := range s.slots {
+		status := make(map[string]dbus.Variant, len(s.slots[i].Status))
+		for k, v := range s.slots[i].Status {
+			status[k] = v
+		}
+		s.slots[i].Status = status
+	}
+
+	if s.slots == nil {
+		s.slots = []SlotStatus{
+			simulatedSlot("rootfs.0", "A", "/dev/sim0", SlotStateBooted),
+			simulatedSlot("rootfs.1", "B", "/dev/sim1", SlotStateInactive),
+		}
+	}
+
+	for _, slot := range s.slots {
+		if statusString(slot.Status, SlotKeyState) == SlotStateBooted {
+			s.booted = statusString(slot.Status, SlotKeyBootname)
+		}
+	}
+	s.primary = s.booted
+
+	return s
+}
+
+func simulatedSlot(name, bootname, device, state string) SlotStatus {
+	return SlotStatus{
+		SlotName: name,
+		Status: map[string]dbus.Variant{
+			SlotKeyClass:      dbus.MakeVariant("rootfs"),
+			SlotKeyType:       dbus.MakeVariant("ext4"),
+			SlotKeyDevice:     dbus.MakeVariant(device),
+			SlotKeyBootname:   dbus.MakeVariant(bootname),
+			SlotKeyState:      dbus.MakeVariant(state),
+			SlotKeyBootStatus: dbus.MakeVariant(BootStatusGood),
+		},
+	}
+}
+
+// Events returns the bus the Simulator publishes its events on.
+func (s *Simulator) Events() *EventBus {
+	return s.events
+}
+
+func (s *Simulator) bundle(filename string) SimulatedBundle {
+	if b, ok := s.options.Bundles[filename]; ok {
+		return b
+	}
+
+	version := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		version = version[i+1:]
+	}
+
+	return SimulatedBundle{
+		Compatible: s.options.Compatible,
+		Version:    version,
+	}
+}
+
+// target returns the index of the first bootable slot that is not booted.
+func (s *Simulator) target() (int, error) {
+	for i, slot := range s.slots {
+		bootname := statusString(slot.Status, SlotKeyBootname)
+		if bootname != "" && bootname != s.booted {
+			return i, nil
+		}
+	}
+
+	return -1, errors.New("no slot to install to")
+}
+
+// InstallBundle simulates the installation of a bundle to the slot that
+// is not booted, and activates it.
+func (s *Simulator) InstallBundle(filename string, options InstallBundleOptions) (err error) {
+	if err := CheckGates(context.Background(), options.Gates); err != nil {
+		return err
+	}
+
+	b := s.bundle(filename)
+	if b.Compatible != s.options.Compatible && !options.IgnoreIncompatible {
+		return errorf("Install", ErrIncompatibleBundle, "compatible mismatch: expected %q, got %q", s.options.Compatible, b.Compatible)
+	}
+
+	if err := RunHooks(context.Background(), HookBeforeInstall, options.Hooks.BeforeInstall, HookInfo{Bundle: filename}); err != nil {
+		return err
+	}
+	defer func() {
+		err = afterInstall(options.Hooks, filename, err)
+	}()
+
+	verify, err := prepareVerify(s, filename, options)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = verify(err)
+	}()
+
+	s.mutex.Lock()
+	if s.operation != string(OperationIdle) {
+		s.mutex.Unlock()
+		return errorf("Install", ErrBusy, "already processing a different method")
+	}
+	s.operation = string(OperationInstalling)
+	s.mutex.Unlock()
+
+	s.events.Publish(InstallStartedEvent{Bundle: filename})
+	defer func() {
+		s.mutex.Lock()
+		s.operation = string(OperationIdle)
+		if err != nil {
+			s.lastError = err.Error()
+		}
+		s.mutex.Unlock()
+
+		s.events.Publish(InstallCompletedEvent{Bundle: filename, Err: err})
+	}()
+
+	step := s.options.InstallDuration / time.Duration(len(simulatorSteps))
+
+	for i, message := range simulatorSteps {
+		percentage := int32(i * 100 / (len(simulatorSteps) - 1))
+
+		s.mutex.Lock()
+		s.progress = cliProgress{percentage, message}
+		s.mutex.Unlock()
+
+		options.ProgressHistory.record(Progress{Percentage: percentage, Message: message, NestingDepth: 1})
+
+		if s.options.InstallError != "" && i == len(simulatorSteps)/2 {
+			return installError(s.options.InstallError)
+		}
+
+		if options.Watchdog != nil {
+			if err := options.Watchdog.Keepalive(); err != nil {
+				return err
+			}
+		}
+
+		time.Sleep(step)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	i, err := s.target()
+	if err != nil {
+		return err
+	}
+
+	status := s.slots[i].Status
+	count, _ := status[SlotKeyInstalledCount].Value().(uint32)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	status[SlotKeyBundleCompatible] = dbus.MakeVariant(b.Compatible)
+	status[SlotKeyBundleVersion] = dbus.MakeVariant(b.Version)
+	status[SlotKeyInstalledTimestamp] = dbus.MakeVariant(now)
+	status[SlotKeyInstalledCount] = dbus.MakeVariant(count + 1)
+	status[SlotKeyStatus] = dbus.MakeVariant(InstallStatusOK)
+	status[SlotKeyBootStatus] = dbus.MakeVariant(BootStatusGood)
+
+	s.activate(i)
+
+	return nil
+}
+
+// activate makes slot i the primary boot slot. The caller holds the mutex.
+func (s *Simulator) activate(i int) {
+	status := s.slots[i].Status
+	count, _ := status[SlotKeyActivatedCount].Value().(uint32)
+
+	status[SlotKeyActivatedTimestamp] = dbus.MakeVariant(time.Now().UTC().Format(time.RFC3339))
+	status[SlotKeyActivatedCount] = dbus.MakeVariant(count + 1)
+
+	s.primary = statusString(status, SlotKeyBootname)
+}
+
+// Reboot simulates a reboot into the primary slot.
+func (s *Simulator) Reboot() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.booted = s.primary
+	for _, slot := range s.slots {
+		bootname := statusString(slot.Status, SlotKeyBootname)
+		if bootname == "" {
+			continue
+		}
+
+		state := SlotStateInactive
+		if bootname == s.booted {
+			state = SlotStateBooted
+		}
+		slot.Status[SlotKeyState] = dbus.MakeVariant(state)
+	}
+}
+
+// Info provides information on a given bundle.
+func (s *Simulator) Info(filename string) (compatible string, version string, err error) {
+	b := s.bundle(filename)
+	return b.Compatible, b.Version, nil
+}
+
+// InspectBundle returns a manifest with a single image for the rootfs
+// slot class.
+func (s *Simulator) InspectBundle(filename string, options InspectBundleOptions) (*BundleInfo, error) {
+	b := s.bundle(filename)
+
+	return &BundleInfo{
+		Compatible: b.Compatible,
+		Version:    b.Version,
+		Format:     "plain",
+		Images: []BundleImage{{
+			SlotClass: "rootfs",
+			Filename:  "rootfs.img",
+		}},
+	}, nil
+}
+
+// Mark keeps a slot bootable (state == “good”), makes it unbootable (state == “bad”)
+// or explicitly activates it for the next boot (state == “active”).
+func (s *Simulator) Mark(state string, slotIdentifier string) (slotName string, message string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	i := -1
+	for n, slot := range s.slots {
+		bootname := statusString(slot.Status, SlotKeyBootname)
+		if bootname == "" {
+			continue
+		}
+		booted := bootname == s.booted
+
+		if slotIdentifier == slot.SlotName ||
+			(slotIdentifier == "booted" && booted) ||
+			(slotIdentifier == "other" && !booted) {
+			i = n
+			break
+		}
+	}
+
+	if i < 0 {
+		return "", "", errorf("Mark", ErrSlotNotFound, "no slot with identifier %q", slotIdentifier)
+	}
+
+	slotName = s.slots[i].SlotName
+
+	switch state {
+	case "good", "bad":
+		s.slots[i].Status[SlotKeyBootStatus] = dbus.MakeVariant(state)
+		if state == "bad" && s.primary == statusString(s.slots[i].Status, SlotKeyBootname) {
+			s.primary = s.booted
+		}
+	case "active":
+		s.activate(i)
+	default:
+		return "", "", errorf("Mark", ErrInvalidMarkState, "unknown state %q", state)
+	}
+
+	return slotName, fmt.Sprintf("marked slot %s as %s", slotName, state), nil
+}
+
+// GetSlotStatus is an access method to get all slots’ status.
+func (s *Simulator) GetSlotStatus() ([]SlotStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := make([]SlotStatus, len(s.slots))
+	for i, slot := range s.slots {
+		status[i] = SlotStatus{
+			SlotName: slot.SlotName,
+			Status:   make(map[string]dbus.Variant, len(slot.Status)),
+		}
+
+		for k, v := range slot.Status {
+			status[i].Status[k] = v
+		}
+	}
+
+	return status, nil
+}
+
+// GetOperation returns the current (global) operation.
+func (s *Simulator) GetOperation() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.operation, nil
+}
+
+// GetLastError returns the message of the last failed installation.
+func (s *Simulator) GetLastError() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lastError, nil
+}
+
+// GetProgress returns installation progress information in the form
+// (percentage, message, nesting depth)
+func (s *Simulator) GetProgress() (percentage int32, message string, nestingDepth int32, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.progress.percentage, s.progress.message, 1, nil
+}
+
+// GetCompatible returns the simulated system’s compatible string.
+func (s *Simulator) GetCompatible() (string, error) {
+	return s.options.Compatible, nil
+}
+
+// GetVariant returns the simulated system’s variant.
+func (s *Simulator) GetVariant() (string, error) {
+	return s.options.Variant, nil
+}
+
+// GetBootSlot returns the currently used boot slot.
+func (s *Simulator) GetBootSlot() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.booted, nil
+}
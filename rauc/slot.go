@@ -0,0 +1,227 @@
+package rauc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Slot is the typed form of a SlotStatus, for the keys listed in
+// slotkeys.go. Missing keys leave the zero value.
+type Slot struct {
+	Name        string `json:"name" yaml:"name"`
+	Class       string `json:"class" yaml:"class"`
+	Device      string `json:"device" yaml:"device"`
+	Type        string `json:"type" yaml:"type"`
+	Bootname    string `json:"bootname,omitempty" yaml:"bootname,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Parent      string `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Mountpoint  string `json:"mountpoint,omitempty" yaml:"mountpoint,omitempty"`
+	// State is SlotStateBooted, SlotStateActive or SlotStateInactive.
+	State string `json:"state" yaml:"state"`
+	// BootStatus is BootStatusGood or BootStatusBad for slots with a
+	// bootname.
+	BootStatus string `json:"boot_status,omitempty" yaml:"boot_status,omitempty"`
+
+	BundleCompatible  string `json:"bundle_compatible,omitempty" yaml:"bundle_compatible,omitempty"`
+	BundleVersion     string `json:"bundle_version,omitempty" yaml:"bundle_version,omitempty"`
+	BundleDescription string `json:"bundle_description,omitempty" yaml:"bundle_description,omitempty"`
+	BundleBuild       string `json:"bundle_build,omitempty" yaml:"bundle_build,omitempty"`
+	BundleHash        string `json:"bundle_hash,omitempty" yaml:"bundle_hash,omitempty"`
+	SHA256            string `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	Size              int64  `json:"size,omitempty" yaml:"size,omitempty"`
+
+	InstalledTimestamp time.Time `json:"installed_timestamp" yaml:"installed_timestamp,omitempty"`
+	InstalledCount     int64     `json:"installed_count,omitempty" yaml:"installed_count,omitempty"`
+	ActivatedTimestamp time.Time `json:"activated_timestamp" yaml:"activated_timestamp,omitempty"`
+	ActivatedCount     int64     `json:"activated_count,omitempty" yaml:"activated_count,omitempty"`
+	// InstallStatus is InstallStatusOK or InstallStatusFailed.
+	InstallStatus string `json:"install_status,omitempty" yaml:"install_status,omitempty"`
+}
+
+// Slot decodes the status map into a Slot.
+func (s SlotStatus) Slot() Slot {
+	str := func(key string) string {
+		v, _ := s.GetString(key)
+		return v
+	}
+
+	num := func(key string) int64 {
+		v, _ := s.GetInt(key)
+		return v
+	}
+
+	installed, _ := s.InstalledTimestamp()
+	activated, _ := s.ActivatedTimestamp()
+
+	return Slot{
+		Name:               s.SlotName,
+		Class:              str(SlotKeyClass),
+		Device:             str(SlotKeyDevice),
+		Type:               str(SlotKeyType),
+		Bootname:           str(SlotKeyBootname),
+		Description:        str(SlotKeyDescription),
+		Parent:             str(SlotKeyParent),
+		Mountpoint:         str(SlotKeyMountpoint),
+		State:              str(SlotKeyState),
+		BootStatus:         str(SlotKeyBootStatus),
+		BundleCompatible:   str(SlotKeyBundleCompatible),
+		BundleVersion:      str(SlotKeyBundleVersion),
+		BundleDescription:  str(SlotKeyBundleDescription),
+		BundleBuild:        str(SlotKeyBundleBuild),
+		BundleHash:         str(SlotKeyBundleHash),
+		SHA256:             str(SlotKeySHA256),
+		Size:               num(SlotKeySize),
+		InstalledTimestamp: installed,
+		InstalledCount:     num(SlotKeyInstalledCount),
+		ActivatedTimestamp: activated,
+		ActivatedCount:     num(SlotKeyActivatedCount),
+		InstallStatus:      str(SlotKeyStatus),
+	}
+}
+
+// Booted reports whether the system runs from the slot.
+func (s Slot) Booted() bool {
+	return s.State == SlotStateBooted
+}
+
+// SlotsOf decodes the result of GetSlotStatus into Slots.
+func SlotsOf(status []SlotStatus) []Slot {
+	slots := make([]Slot, 0, len(status))
+	for _, s := range status {
+		slots = append(slots, s.Slot())
+	}
+
+	return slots
+}
+
+// matchesClass reports whether s is of class, or has a bootname if class
+// is empty.
+func (s Slot) matchesClass(class string) bool {
+	if class == "" {
+		return s.Bootname != ""
+	}
+
+	return s.Class == class
+}
+
+// FindBootedSlot returns the slot of class in use by the running system:
+// the booted slot, or the active child of the booted slot. An empty class
+// matches all slots with a bootname.
+func FindBootedSlot(slots []Slot, class string) (Slot, bool) {
+	for _, s := range slots {
+		if s.matchesClass(class) && (s.State == SlotStateBooted || s.State == SlotStateActive) {
+			return s, true
+		}
+	}
+
+	return Slot{}, false
+}
+
+// FindOtherSlot returns the first inactive slot of class, the target of
+// installations on A/B systems. An empty class matches all slots with a
+// bootname.
+func FindOtherSlot(slots []Slot, class string) (Slot, bool) {
+	for _, s := range slots {
+		if s.matchesClass(class) && s.State == SlotStateInactive {
+			return s, true
+		}
+	}
+
+	return Slot{}, false
+}
+
+// bootedSlot is FindBootedSlot, failing with ErrSlotNotFound.
+func bootedSlot(slots []Slot, class string) (Slot, error) {
+	s, ok := FindBootedSlot(slots, class)
+	if !ok {
+		return Slot{}, fmt.Errorf("%w: no booted slot of class %q", ErrSlotNotFound, class)
+	}
+
+	return s, nil
+}
+
+// otherSlot is FindOtherSlot, failing with ErrSlotNotFound.
+func otherSlot(slots []Slot, class string) (Slot, error) {
+	s, ok := FindOtherSlot(slots, class)
+	if !ok {
+		return Slot{}, fmt.Errorf("%w: no inactive slot of class %q", ErrSlotNotFound, class)
+	}
+
+	return s, nil
+}
+
+// GetBootedSlot returns the slot of class in use on b, see FindBootedSlot.
+func GetBootedSlot(b Backend, class string) (Slot, error) {
+	slots, err := GetSlots(b)
+	if err != nil {
+		return Slot{}, err
+	}
+
+	return bootedSlot(slots, class)
+}
+
+// GetOtherSlot returns the inactive slot of class on b, see FindOtherSlot.
+func GetOtherSlot(b Backend, class string) (Slot, error) {
+	slots, err := GetSlots(b)
+	if err != nil {
+		return Slot{}, err
+	}
+
+	return otherSlot(slots, class)
+}
+
+// GetSlots returns the status of all slots of b, decoded into Slots.
+func GetSlots(b Backend) ([]Slot, error) {
+	status, err := b.GetSlotStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return SlotsOf(status), nil
+}
+
+// GetSlots is GetSlotStatus, decoded into Slots.
+func (p *Installer) GetSlots() ([]Slot, error) {
+	return p.GetSlotsContext(context.Background())
+}
+
+// GetSlotsContext is GetSlots, giving up when ctx is done.
+func (p *Installer) GetSlotsContext(ctx context.Context) ([]Slot, error) {
+	status, err := p.GetSlotStatusContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return SlotsOf(status), nil
+}
+
+// GetBootedSlot returns the slot of class in use, see FindBootedSlot.
+func (p *Installer) GetBootedSlot(class string) (Slot, error) {
+	return p.GetBootedSlotContext(context.Background(), class)
+}
+
+// GetBootedSlotContext is GetBootedSlot, giving up when ctx is done.
+func (p *Installer) GetBootedSlotContext(ctx context.Context, class string) (Slot, error) {
+	slots, err := p.GetSlotsContext(ctx)
+	if err != nil {
+		return Slot{}, err
+	}
+
+	return bootedSlot(slots, class)
+}
+
+// GetOtherSlot returns the inactive slot of class, see FindOtherSlot.
+func (p *Installer) GetOtherSlot(class string) (Slot, error) {
+	return p.GetOtherSlotContext(context.Background(), class)
+}
+
+// GetOtherSlotContext is GetOtherSlot, giving up when ctx is done.
+func (p *Installer) GetOtherSlotContext(ctx context.Context, class string) (Slot, error) {
+	slots, err := p.GetSlotsContext(ctx)
+	if err != nil {
+		return Slot{}, err
+	}
+
+	return otherSlot(slots, class)
+}
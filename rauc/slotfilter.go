@@ -0,0 +1,76 @@
+package rauc
+
+import (
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// SlotFilter restricts a slot status to some slots and keys. Empty
+// fields do not restrict anything.
+type SlotFilter struct {
+	// Classes keeps the slots of these classes, e.g. "rootfs".
+	Classes []string
+	// Slots keeps the slots with these names, e.g. "rootfs.0".
+	Slots []string
+	// Keys keeps these status keys, e.g. SlotKeyBundleVersion.
+	Keys []string
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches reports whether the filter keeps slot s.
+func (f SlotFilter) matches(s SlotStatus) bool {
+	if len(f.Slots) > 0 && !contains(f.Slots, s.SlotName) {
+		return false
+	}
+
+	if len(f.Classes) > 0 {
+		class, _ := s.GetString(SlotKeyClass)
+		return contains(f.Classes, class)
+	}
+
+	return true
+}
+
+// Apply returns the slots of status kept by the filter, with only the
+// kept keys. The status maps are shared with status unless Keys is set.
+func (f SlotFilter) Apply(status []SlotStatus) []SlotStatus {
+	filtered := make([]SlotStatus, 0, len(status))
+
+	for _, s := range status {
+		if !f.matches(s) {
+			continue
+		}
+
+		if len(f.Keys) > 0 {
+			fields := make(map[string]dbus.Variant, len(f.Keys))
+			for _, k := range f.Keys {
+				if v, ok := s.Status[k]; ok {
+					fields[k] = v
+				}
+			}
+			s.Status = fields
+		}
+
+		filtered = append(filtered, s)
+	}
+
+	return filtered
+}
+
+// GetSlotStatusFiltered returns the status of the slots kept by filter.
+func GetSlotStatusFiltered(b Backend, filter SlotFilter) ([]SlotStatus, error) {
+	status, err := b.GetSlotStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.Apply(status), nil
+}
@@ -0,0 +1,58 @@
+package rauc
+
+// Keys of the slot status map returned by GetSlotStatus. Which keys are
+// present depends on the RAUC version, the slot configuration and whether
+// the slot has been written to by RAUC before.
+const (
+	SlotKeyClass       = "class"
+	SlotKeyDevice      = "device"
+	SlotKeyType        = "type"
+	SlotKeyBootname    = "bootname"
+	SlotKeyDescription = "description"
+	SlotKeyParent      = "parent"
+	SlotKeyMountpoint  = "mountpoint"
+	// SlotKeyState holds one of SlotStateBooted, SlotStateActive or
+	// SlotStateInactive.
+	SlotKeyState = "state"
+	// SlotKeyBootStatus holds BootStatusGood or BootStatusBad. It is only
+	// present for slots with a bootname.
+	SlotKeyBootStatus = "boot-status"
+
+	SlotKeyBundleCompatible  = "bundle.compatible"
+	SlotKeyBundleVersion     = "bundle.version"
+	SlotKeyBundleDescription = "bundle.description"
+	SlotKeyBundleBuild       = "bundle.build"
+	SlotKeyBundleHash        = "bundle.hash"
+	// SlotKeySHA256 and SlotKeySize describe the image last written.
+	SlotKeySHA256 = "sha256"
+	SlotKeySize   = "size"
+
+	SlotKeyInstalledTimestamp = "installed.timestamp"
+	SlotKeyInstalledCount     = "installed.count"
+	SlotKeyActivatedTimestamp = "activated.timestamp"
+	SlotKeyActivatedCount     = "activated.count"
+	// SlotKeyStatus holds InstallStatusOK or InstallStatusFailed.
+	SlotKeyStatus = "status"
+)
+
+// Values of SlotKeyState.
+const (
+	// SlotStateBooted is the slot the system is running from.
+	SlotStateBooted = "booted"
+	// SlotStateActive is a slot in use, e.g. the child of the booted slot.
+	SlotStateActive = "active"
+	// SlotStateInactive is a slot not in use, a possible install target.
+	SlotStateInactive = "inactive"
+)
+
+// Values of SlotKeyBootStatus.
+const (
+	BootStatusGood = "good"
+	BootStatusBad  = "bad"
+)
+
+// Values of SlotKeyStatus.
+const (
+	InstallStatusOK     = "ok"
+	InstallStatusFailed = "failed"
+)
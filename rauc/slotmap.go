@@ -0,0 +1,176 @@
+package rauc
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+const (
+	partUUIDDirectory = "/dev/disk/by-partuuid"
+)
+
+// SlotInfo holds the different names under which a slot is known
+// to RAUC, the bootloader, and the kernel.
+type SlotInfo struct {
+	SlotName string
+	Bootname string
+	Device   string
+	PartUUID string
+}
+
+// SlotMap resolves between slot names (e.g. "rootfs.0"), bootnames (e.g. "A"),
+// device paths and partition UUIDs.
+type SlotMap struct {
+	slots []SlotInfo
+}
+
+// statusString returns the string value stored for key in a slot status map,
+// or an empty string if the key is missing or not a string.
+func statusString(status map[string]dbus.Variant, key string) string {
+	s, _ := SlotStatus{Status: status}.GetString(key)
+	return s
+}
+
+// canonicalDevice resolves symlinks such as /dev/disk/by-label/* to the
+// underlying device node.
+func canonicalDevice(device string) string {
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		return resolved
+	}
+
+	return device
+}
+
+// partUUIDs returns a map from canonical device path to partition UUID
+// as published by udev.
+func partUUIDs() map[string]string {
+	m := make(map[string]string)
+
+	entries, err := ioutil.ReadDir(partUUIDDirectory)
+	if err != nil {
+		return m
+	}
+
+	for _, e := range entries {
+		device := canonicalDevice(filepath.Join(partUUIDDirectory, e.Name()))
+		m[device] = e.Name()
+	}
+
+	return m
+}
+
+// SlotMapNew builds a SlotMap from the result of .GetSlotStatus().
+func SlotMapNew(status []SlotStatus) *SlotMap {
+	uuids := partUUIDs()
+	m := new(SlotMap)
+
+	for _, s := range status {
+		device := statusString(s.Status, SlotKeyDevice)
+		m.slots = append(m.slots, SlotInfo{
+			SlotName: s.SlotName,
+			Bootname: statusString(s.Status, SlotKeyBootname),
+			Device:   device,
+			PartUUID: uuids[canonicalDevice(device)],
+		})
+	}
+
+	return m
+}
+
+// GetSlotMap queries the slot status and returns a SlotMap for it.
+func (p *Installer) GetSlotMap() (*SlotMap, error) {
+	status, err := p.GetSlotStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return SlotMapNew(status), nil
+}
+
+// Slots returns all slots in the map.
+func (m *SlotMap) Slots() []SlotInfo {
+	return append([]SlotInfo(nil), m.slots...)
+}
+
+func (m *SlotMap) find(match func(SlotInfo) bool) (SlotInfo, bool) {
+	for _, s := range m.slots {
+		if match(s) {
+			return s, true
+		}
+	}
+
+	return SlotInfo{}, false
+}
+
+// ByName looks up a slot by its RAUC slot name.
+func (m *SlotMap) ByName(slotName string) (SlotInfo, bool) {
+	return m.find(func(s SlotInfo) bool {
+		return s.SlotName == slotName
+	})
+}
+
+// ByBootname looks up a slot by its bootname. Only slots that are directly
+// handled by the bootloader have a bootname.
+func (m *SlotMap) ByBootname(bootname string) (SlotInfo, bool) {
+	return m.find(func(s SlotInfo) bool {
+		return s.Bootname != "" && s.Bootname == bootname
+	})
+}
+
+// ByDevice looks up a slot by its device path. Symlinks are resolved on
+// both sides, so /dev/disk/by-* paths match the device node they point to.
+func (m *SlotMap) ByDevice(device string) (SlotInfo, bool) {
+	device = canonicalDevice(device)
+
+	return m.find(func(s SlotInfo) bool {
+		return s.Device != "" && canonicalDevice(s.Device) == device
+	})
+}
+
+// ByPartUUID looks up a slot by the partition UUID of its device.
+func (m *SlotMap) ByPartUUID(partUUID string) (SlotInfo, bool) {
+	return m.find(func(s SlotInfo) bool {
+		return s.PartUUID != "" && strings.EqualFold(s.PartUUID, partUUID)
+	})
+}
+
+// slotStatusFromBody converts a GetSlotStatus reply without reflection,
+// reusing the decoded maps. Malformed entries are skipped with a warning.
+// It returns nil if the reply is not an array of structs at all, so the
+// caller can fall back to dbus.Store.
+func (p *Installer) slotStatusFromBody(body []interface{}) []SlotStatus {
+	if len(body) != 1 {
+		return nil
+	}
+
+	entries, ok := body[0].([][]interface{})
+	if !ok {
+		return nil
+	}
+
+	status := make([]SlotStatus, 0, len(entries))
+	for _, e := range entries {
+		var name string
+		var fields map[string]dbus.Variant
+
+		ok := len(e) == 2
+		if ok {
+			var ok1, ok2 bool
+			name, ok1 = e[0].(string)
+			fields, ok2 = e[1].(map[string]dbus.Variant)
+			ok = ok1 && ok2
+		}
+
+		if !ok {
+			p.warn("GetSlotStatus", "(sa{sv})", e)
+			continue
+		}
+
+		status = append(status, SlotStatus{SlotName: name, Status: fields})
+	}
+
+	return status
+}
@@ -0,0 +1,134 @@
+package rauc
+
+import (
+	"math"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// value returns the value stored for key, unwrapping nested variants.
+func (s SlotStatus) value(key string) (interface{}, bool) {
+	v, ok := s.Status[key]
+	if !ok {
+		return nil, false
+	}
+
+	value := v.Value()
+	for {
+		inner, isVariant := value.(dbus.Variant)
+		if !isVariant {
+			return value, true
+		}
+		value = inner.Value()
+	}
+}
+
+// GetString returns the string stored for key. ok is false if the key is
+// missing or holds a different type.
+func (s SlotStatus) GetString(key string) (string, bool) {
+	v, _ := s.value(key)
+	str, ok := v.(string)
+	return str, ok
+}
+
+// GetInt returns the integer stored for key, which may be of any D-Bus
+// integer type. ok is false if the key is missing, holds a different type
+// or does not fit into an int64.
+func (s SlotStatus) GetInt(key string) (int64, bool) {
+	v, _ := s.value(key)
+
+	switch n := v.(type) {
+	case byte:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	}
+
+	return 0, false
+}
+
+// GetBool returns the boolean stored for key. ok is false if the key is
+// missing or holds a different type.
+func (s SlotStatus) GetBool(key string) (bool, bool) {
+	v, _ := s.value(key)
+	b, ok := v.(bool)
+	return b, ok
+}
+
+// GetTime returns the timestamp stored as string for key. ok is false if
+// the key is missing or cannot be parsed.
+func (s SlotStatus) GetTime(key string) (time.Time, bool) {
+	str, ok := s.GetString(key)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return parseTimestamp(str)
+}
+
+// timestampLayouts are the formats timestamps appear in. RAUC writes
+// "%Y-%m-%dT%H:%M:%SZ" in UTC; the others are accepted for status files
+// edited by hand or written by other tools.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseTimestamp parses a timestamp as written by RAUC. Timestamps without
+// a zone are taken as UTC.
+func parseTimestamp(s string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// InstalledTimestamp returns the time the slot was last written to.
+func (s SlotStatus) InstalledTimestamp() (time.Time, bool) {
+	return s.GetTime(SlotKeyInstalledTimestamp)
+}
+
+// ActivatedTimestamp returns the time the slot was last marked active.
+func (s SlotStatus) ActivatedTimestamp() (time.Time, bool) {
+	return s.GetTime(SlotKeyActivatedTimestamp)
+}
+
+// TimeSinceInstall returns the time passed since the slot was last
+// written to. ok is false if the slot status carries no timestamp.
+func (s SlotStatus) TimeSinceInstall() (time.Duration, bool) {
+	t, ok := s.InstalledTimestamp()
+	if !ok {
+		return 0, false
+	}
+
+	return time.Since(t), true
+}
+
+// TimeSinceActivation returns the time passed since the slot was last
+// marked active.
+func (s SlotStatus) TimeSinceActivation() (time.Duration, bool) {
+	t, ok := s.ActivatedTimestamp()
+	if !ok {
+		return 0, false
+	}
+
+	return time.Since(t), true
+}
@@ -0,0 +1,105 @@
+package rauc
+
+import (
+	"fmt"
+	"time"
+)
+
+// SlotSnapshot is the status of a single slot as part of a Snapshot,
+// enriched with the places the slot is currently mounted at.
+type SlotSnapshot struct {
+	SlotStatus
+	Mounts []Mount
+}
+
+// Snapshot is a point-in-time view of the daemon's properties and all
+// slots, as returned by .Snapshot().
+type Snapshot struct {
+	Time       time.Time      `json:"time" yaml:"time"`
+	Operation  string         `json:"operation" yaml:"operation"`
+	Compatible string         `json:"compatible" yaml:"compatible"`
+	Variant    string         `json:"variant" yaml:"variant"`
+	BootSlot   string         `json:"boot_slot" yaml:"boot_slot"`
+	Slots      []SlotSnapshot `json:"slots" yaml:"slots"`
+	// Service is the state of rauc.service, or nil if systemd could not
+	// be queried.
+	Service *ServiceState `json:"service,omitempty" yaml:"service,omitempty"`
+}
+
+// Snapshot collects the daemon's properties and the status of all slots.
+func (p *Installer) Snapshot() (*Snapshot, error) {
+	return SnapshotOf(p)
+}
+
+// SnapshotOptions contains options for the SnapshotWithOptions function
+type SnapshotOptions struct {
+	// Filter restricts the slots and keys of the snapshot.
+	Filter SlotFilter
+	// SkipMounts and SkipService leave out the mounts of the slots and
+	// the service state, which are expensive to collect.
+	SkipMounts  bool
+	SkipService bool
+}
+
+// SnapshotOf collects the properties and the status of all slots through
+// any Backend. The service state is only available through the Installer.
+func SnapshotOf(p Backend) (*Snapshot, error) {
+	return SnapshotWithOptions(p, SnapshotOptions{})
+}
+
+// SnapshotWithOptions is like SnapshotOf, configured by options.
+func SnapshotWithOptions(p Backend, options SnapshotOptions) (*Snapshot, error) {
+	var err error
+	s := &Snapshot{
+		Time: time.Now(),
+	}
+
+	// Not all systems run RAUC under systemd.
+	if installer, ok := p.(*Installer); ok && !options.SkipService {
+		s.Service, _ = installer.GetServiceState()
+	}
+
+	if s.Operation, err = p.GetOperation(); err != nil {
+		if s.Service != nil {
+			err = fmt.Errorf("%v (%s)", err, s.Service)
+		}
+		return nil, err
+	}
+
+	if s.Compatible, err = p.GetCompatible(); err != nil {
+		return nil, err
+	}
+
+	if s.Variant, err = p.GetVariant(); err != nil {
+		return nil, err
+	}
+
+	if s.BootSlot, err = p.GetBootSlot(); err != nil {
+		return nil, err
+	}
+
+	status, err := p.GetSlotStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	slots := SlotFilter{Classes: options.Filter.Classes, Slots: options.Filter.Slots}.Apply(status)
+
+	// Mount information is best-effort, /proc may not be accessible. It
+	// needs the devices, so it is collected before filtering keys.
+	var mounts map[string][]Mount
+	if !options.SkipMounts {
+		mounts, _ = SlotMounts(slots)
+	}
+
+	status = options.Filter.Apply(slots)
+
+	for _, st := range status {
+		s.Slots = append(s.Slots, SlotSnapshot{
+			SlotStatus: st,
+			Mounts:     mounts[st.SlotName],
+		})
+	}
+
+	return s, nil
+}
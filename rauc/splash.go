@@ -0,0 +1,75 @@
+package rauc
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Splash displays installation progress, e.g. on a boot or update splash
+// screen.
+type Splash interface {
+	ShowProgress(percentage int32, message string) error
+}
+
+// Plymouth is a Splash that forwards progress to a running plymouthd.
+type Plymouth struct {
+	// Command is the plymouth client to use. Defaults to "plymouth".
+	Command string
+}
+
+func (s *Plymouth) run(args ...string) error {
+	command := s.Command
+	if command == "" {
+		command = "plymouth"
+	}
+
+	if out, err := exec.Command(command, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %v: %s", command, args[0], err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// ShowProgress implements Splash.
+func (s *Plymouth) ShowProgress(percentage int32, message string) error {
+	if err := s.run("system-update", "--progress="+strconv.Itoa(int(percentage))); err != nil {
+		return err
+	}
+
+	return s.run("display-message", "--text="+message)
+}
+
+// ForwardProgress polls the daemon's progress every interval and passes
+// every change on to splash, until the context is cancelled.
+func (p *Installer) ForwardProgress(ctx context.Context, splash Splash, interval time.Duration) error {
+	return ForwardProgress(ctx, p, splash, interval)
+}
+
+// ForwardProgress is the ForwardProgress method for any Backend.
+func ForwardProgress(ctx context.Context, p Backend, splash Splash, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := Progress{Percentage: -1}
+
+	for {
+		progress, err := ProgressOf(p)
+		if err == nil && (progress.Percentage != last.Percentage || progress.Message != last.Message) {
+			if err := splash.ShowProgress(progress.Percentage, progress.Message); err != nil {
+				return err
+			}
+
+			last = progress
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
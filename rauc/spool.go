@@ -0,0 +1,104 @@
+package rauc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// DefaultSpoolDirectory is used by SpoolBundle if no directory is
+// configured. /run is a tmpfs on most systems, so the bundle has to fit
+// into RAM; use a directory on disk for larger bundles.
+var DefaultSpoolDirectory = "/run/go-rauc"
+
+var (
+	// ErrSpoolTooLarge is returned by SpoolBundle if the bundle exceeds
+	// SpoolOptions.MaxBytes.
+	ErrSpoolTooLarge = errors.New("RAUC: bundle exceeds the spool size limit")
+	// ErrChecksumMismatch is returned by SpoolBundle if the bundle does
+	// not match SpoolOptions.SHA256.
+	ErrChecksumMismatch = errors.New("RAUC: bundle checksum mismatch")
+)
+
+// SpoolOptions contains options for the SpoolBundle function
+type SpoolOptions struct {
+	// Directory receives the bundle. Defaults to DefaultSpoolDirectory.
+	Directory string
+	// MaxBytes limits the size of the bundle. Zero means no limit.
+	MaxBytes int64
+	// SHA256, if not empty, is the expected hex digest of the bundle.
+	SHA256 string
+}
+
+// SpoolBundle copies a bundle streamed from r, e.g. os.Stdin, to a file
+// that can be installed. The size limit and checksum are checked before
+// it returns, and the file is removed if they are not met. remove deletes
+// the file once it is no longer needed.
+func SpoolBundle(r io.Reader, options SpoolOptions) (path string, remove func(), err error) {
+	dir := options.Directory
+	if dir == "" {
+		dir = DefaultSpoolDirectory
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, fmt.Errorf("RAUC: SpoolBundle(): %v", err)
+	}
+
+	// Older RAUC versions insist on the .raucb extension.
+	f, err := ioutil.TempFile(dir, "spool-*.raucb")
+	if err != nil {
+		return "", nil, fmt.Errorf("RAUC: SpoolBundle(): %v", err)
+	}
+
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	if options.MaxBytes > 0 {
+		// Read one byte more than allowed to tell an exact fit from
+		// an overflow.
+		r = io.LimitReader(r, options.MaxBytes+1)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return "", nil, fmt.Errorf("RAUC: SpoolBundle(): %v", err)
+	}
+
+	if options.MaxBytes > 0 && n > options.MaxBytes {
+		return "", nil, ErrSpoolTooLarge
+	}
+
+	if options.SHA256 != "" {
+		if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, options.SHA256) {
+			return "", nil, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, options.SHA256, sum)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return "", nil, fmt.Errorf("RAUC: SpoolBundle(): %v", err)
+	}
+
+	return f.Name(), func() { os.Remove(f.Name()) }, nil
+}
+
+// InstallBundleFromReader spools the bundle streamed from r with
+// SpoolBundle and installs it. The spooled file is removed afterwards.
+func InstallBundleFromReader(b Backend, r io.Reader, spool SpoolOptions, options InstallBundleOptions) error {
+	path, remove, err := SpoolBundle(r, spool)
+	if err != nil {
+		return err
+	}
+	defer remove()
+
+	return b.InstallBundle(path, options)
+}
@@ -0,0 +1,91 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInstallStalled is matched by errors.Is for StallError values.
+var ErrInstallStalled = errors.New("RAUC: installation stalled")
+
+// StallError is returned by InstallBundle if the progress of the
+// installation did not change within InstallBundleOptions.StallTimeout.
+// The daemon may still be working on the installation.
+type StallError struct {
+	Bundle     string
+	Percentage int32
+	Message    string
+	Since      time.Time
+}
+
+func (e *StallError) Error() string {
+	return fmt.Sprintf("RAUC: installation of %s stalled at %d%% (%s) since %s",
+		e.Bundle, e.Percentage, e.Message, e.Since.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrInstallStalled) true.
+func (e *StallError) Is(target error) bool {
+	return target == ErrInstallStalled
+}
+
+// InstallStalledEvent is published when a StallError is returned.
+type InstallStalledEvent struct {
+	Err *StallError
+}
+
+// EventType implements Event.
+func (InstallStalledEvent) EventType() string { return "install.stalled" }
+
+// stallDetector tracks the progress of an installation.
+type stallDetector struct {
+	bundle  string
+	timeout time.Duration
+	backend Backend
+
+	progress Progress
+	since    time.Time
+}
+
+func newStallDetector(bundle string, timeout time.Duration, backend Backend) *stallDetector {
+	return &stallDetector{
+		bundle:   bundle,
+		timeout:  timeout,
+		backend:  backend,
+		progress: Progress{Percentage: -1},
+		since:    time.Now(),
+	}
+}
+
+// interval returns how often check should be called.
+func (d *stallDetector) interval() time.Duration {
+	interval := d.timeout / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+
+	return interval
+}
+
+// check polls the progress and returns a StallError if it did not change
+// for longer than the timeout. Errors reading the progress count as no
+// change.
+func (d *stallDetector) check() *StallError {
+	progress, err := ProgressOf(d.backend)
+	if err == nil && (progress.Percentage != d.progress.Percentage || progress.Message != d.progress.Message) {
+		d.progress = progress
+		d.since = time.Now()
+		return nil
+	}
+
+	if time.Since(d.since) < d.timeout {
+		return nil
+	}
+
+	return &StallError{
+		Bundle:     d.bundle,
+		Percentage: d.progress.Percentage,
+		Message:    d.progress.Message,
+		Since:      d.since,
+	}
+}
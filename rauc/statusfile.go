@@ -0,0 +1,78 @@
+package rauc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// ParseStatusFile parses a RAUC status file: the central status file
+// (statusfile in system.conf) with a [slot.<name>] section per slot, or
+// a per-slot status.raucs with a single [slot] section, whose SlotStatus
+// has an empty SlotName. The keys match those of GetSlotStatus, such as
+// SlotKeyBundleVersion and SlotKeyInstalledTimestamp.
+func ParseStatusFile(r io.Reader) ([]SlotStatus, error) {
+	sections, err := parseKeyFile(r, "status file")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		if name == "slot" || strings.HasPrefix(name, "slot.") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	status := make([]SlotStatus, 0, len(names))
+	for _, name := range names {
+		s := SlotStatus{
+			SlotName: strings.TrimPrefix(name, "slot."),
+			Status:   make(map[string]dbus.Variant),
+		}
+		if name == "slot" {
+			s.SlotName = ""
+		} else if s.SlotName == "" {
+			return nil, fmt.Errorf("status file: invalid slot section name %q", name)
+		}
+
+		for key, value := range sections[name] {
+			v, err := statusFileVariant(key, value)
+			if err != nil {
+				return nil, fmt.Errorf("status file: [%s]: %v", name, err)
+			}
+			s.Status[key] = v
+		}
+
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// statusFileVariant converts a value of a status file to the type the
+// daemon uses for key.
+func statusFileVariant(key, value string) (dbus.Variant, error) {
+	if key != SlotKeySize && !strings.HasSuffix(key, ".count") {
+		return dbus.MakeVariant(value), nil
+	}
+
+	bits := 64
+	if key != SlotKeySize {
+		bits = 32
+	}
+
+	n, err := strconv.ParseUint(value, 10, bits)
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("invalid %s %q", key, value)
+	}
+
+	v, _ := statusVariant(key, n)
+
+	return v, nil
+}
@@ -0,0 +1,88 @@
+package rauc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStatusFile = `[slot.rootfs.0]
+bundle.compatible=board
+bundle.version=1.0
+sha256=a1b2
+size=4096
+installed.timestamp=2024-05-01T10:00:00Z
+installed.count=3
+activated.timestamp=2024-05-01T10:01:00Z
+activated.count=2
+status=ok
+
+[slot.rootfs.1]
+bundle.version=0.9
+status=failed
+`
+
+func TestParseStatusFile(t *testing.T) {
+	status, err := ParseStatusFile(strings.NewReader(testStatusFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 2 || status[0].SlotName != "rootfs.0" || status[1].SlotName != "rootfs.1" {
+		t.Fatalf("got %+v", status)
+	}
+
+	slot := status[0].Slot()
+	want := Slot{
+		Name:               "rootfs.0",
+		BundleCompatible:   "board",
+		BundleVersion:      "1.0",
+		SHA256:             "a1b2",
+		Size:               4096,
+		InstalledTimestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		InstalledCount:     3,
+		ActivatedTimestamp: time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC),
+		ActivatedCount:     2,
+		InstallStatus:      InstallStatusOK,
+	}
+
+	if !reflect.DeepEqual(slot, want) {
+		t.Fatalf("got %+v\nwant %+v", slot, want)
+	}
+
+	if v := status[0].Status[SlotKeyInstalledCount].Value(); v != uint32(3) {
+		t.Errorf("installed.count is %T %v, want uint32", v, v)
+	}
+}
+
+func TestParseStatusFileSingleSlot(t *testing.T) {
+	status, err := ParseStatusFile(strings.NewReader("[slot]\nbundle.version=1.0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 1 || status[0].SlotName != "" || status[0].Slot().BundleVersion != "1.0" {
+		t.Fatalf("got %+v", status)
+	}
+}
+
+func TestParseStatusFileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+	}{
+		{"empty slot name", "[slot.]\n"},
+		{"invalid count", "[slot.rootfs.0]\ninstalled.count=many\n"},
+		{"count overflow", "[slot.rootfs.0]\ninstalled.count=4294967296\n"},
+		{"negative size", "[slot.rootfs.0]\nsize=-1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseStatusFile(strings.NewReader(tt.status)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
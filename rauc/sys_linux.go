@@ -0,0 +1,48 @@
+package rauc
+
+import (
+	"os"
+	"syscall"
+)
+
+func mountReadOnly(device, target, fsType string) error {
+	return syscall.Mount(device, target, fsType, syscall.MS_RDONLY, "")
+}
+
+func unmount(target string) error {
+	return syscall.Unmount(target, 0)
+}
+
+// deviceNumber returns the major and minor number of a block device node.
+func deviceNumber(device string) (uint64, uint64, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(device, &st); err != nil {
+		return 0, 0, false
+	}
+
+	if st.Mode&syscall.S_IFMT != syscall.S_IFBLK {
+		return 0, 0, false
+	}
+
+	rdev := uint64(st.Rdev)
+	major := ((rdev >> 8) & 0xfff) | ((rdev >> 32) &^ 0xfff)
+	minor := (rdev & 0xff) | ((rdev >> 12) &^ 0xff)
+
+	return major, minor, true
+}
+
+func rebootSyscall() error {
+	syscall.Sync()
+	return syscall.Reboot(syscall.LINUX_REBOOT_CMD_RESTART)
+}
+
+// tryLock takes an exclusive flock on f without blocking. It returns
+// false if the lock is held by someone else.
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+
+	return err == nil, err
+}
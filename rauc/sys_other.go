@@ -0,0 +1,31 @@
+//go:build !linux
+// +build !linux
+
+package rauc
+
+import (
+	"errors"
+	"os"
+)
+
+var errNotSupported = errors.New("not supported on this platform")
+
+func mountReadOnly(device, target, fsType string) error {
+	return errNotSupported
+}
+
+func unmount(target string) error {
+	return errNotSupported
+}
+
+func deviceNumber(device string) (uint64, uint64, bool) {
+	return 0, 0, false
+}
+
+func rebootSyscall() error {
+	return errNotSupported
+}
+
+func tryLock(f *os.File) (bool, error) {
+	return false, errNotSupported
+}
@@ -0,0 +1,125 @@
+package rauc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SystemConfigPaths lists the locations RAUC searches for its system.conf,
+// in order of precedence.
+var SystemConfigPaths = []string{
+	"/run/rauc/system.conf",
+	"/etc/rauc/system.conf",
+	"/usr/lib/rauc/system.conf",
+}
+
+// SlotConfig describes a [slot.<class>.<index>] section of system.conf.
+type SlotConfig struct {
+	// Name is the slot name, e.g. "rootfs.0".
+	Name     string
+	Class    string
+	Index    string
+	Device   string
+	Type     string
+	Bootname string
+	Parent   string
+	Keys     map[string]string
+}
+
+// SystemConfig is the parsed content of RAUC's system.conf.
+type SystemConfig struct {
+	Compatible string
+	Bootloader string
+	Slots      []SlotConfig
+	// Sections holds the raw key/value pairs of all sections, keyed by
+	// section name.
+	Sections map[string]map[string]string
+}
+
+// ParseSystemConfig parses a system.conf file in GKeyFile format.
+func ParseSystemConfig(r io.Reader) (*SystemConfig, error) {
+	sections, err := parseKeyFile(r, "system.conf")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &SystemConfig{
+		Sections: sections,
+	}
+
+	if system, ok := c.Sections["system"]; ok {
+		c.Compatible = system["compatible"]
+		c.Bootloader = system["bootloader"]
+	}
+
+	names := make([]string, 0, len(c.Sections))
+	for name := range c.Sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, "slot.") {
+			continue
+		}
+
+		slotName := strings.TrimPrefix(name, "slot.")
+		dot := strings.LastIndex(slotName, ".")
+		if dot <= 0 || dot == len(slotName)-1 {
+			return nil, fmt.Errorf("system.conf: invalid slot section name %q", name)
+		}
+
+		keys := c.Sections[name]
+		c.Slots = append(c.Slots, SlotConfig{
+			Name:     slotName,
+			Class:    slotName[:dot],
+			Index:    slotName[dot+1:],
+			Device:   keys["device"],
+			Type:     keys["type"],
+			Bootname: keys["bootname"],
+			Parent:   keys["parent"],
+			Keys:     keys,
+		})
+	}
+
+	return c, nil
+}
+
+// ReadSystemConfig reads and parses the system.conf at the given path. If
+// path is empty, the first existing file in SystemConfigPaths is used.
+func ReadSystemConfig(path string) (*SystemConfig, error) {
+	if path == "" {
+		for _, p := range SystemConfigPaths {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+
+		if path == "" {
+			return nil, fmt.Errorf("RAUC: no system.conf found")
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseSystemConfig(f)
+}
+
+// Slot returns the configuration of the slot with the given name.
+func (c *SystemConfig) Slot(name string) (*SlotConfig, bool) {
+	for i := range c.Slots {
+		if c.Slots[i].Name == name {
+			return &c.Slots[i], true
+		}
+	}
+
+	return nil, false
+}
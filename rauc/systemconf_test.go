@@ -0,0 +1,80 @@
+package rauc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSystemConfig = `[system]
+compatible=board
+bootloader=uboot
+
+[keyring]
+path=/etc/rauc/keyring.pem
+
+[slot.rootfs.1]
+device=/dev/mmcblk0p3
+type=ext4
+bootname=B
+
+[slot.rootfs.0]
+device=/dev/mmcblk0p2
+type=ext4
+bootname=A
+
+[slot.appfs.0]
+device=/dev/mmcblk0p4
+parent=rootfs.0
+`
+
+func TestParseSystemConfig(t *testing.T) {
+	c, err := ParseSystemConfig(strings.NewReader(testSystemConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Compatible != "board" || c.Bootloader != "uboot" {
+		t.Errorf("got compatible %q, bootloader %q, want board, uboot", c.Compatible, c.Bootloader)
+	}
+
+	if path := c.Sections["keyring"]["path"]; path != "/etc/rauc/keyring.pem" {
+		t.Errorf("got keyring path %q", path)
+	}
+
+	want := []SlotConfig{
+		{Name: "appfs.0", Class: "appfs", Index: "0", Device: "/dev/mmcblk0p4", Parent: "rootfs.0"},
+		{Name: "rootfs.0", Class: "rootfs", Index: "0", Device: "/dev/mmcblk0p2", Type: "ext4", Bootname: "A"},
+		{Name: "rootfs.1", Class: "rootfs", Index: "1", Device: "/dev/mmcblk0p3", Type: "ext4", Bootname: "B"},
+	}
+
+	for i := range c.Slots {
+		c.Slots[i].Keys = nil
+	}
+
+	if !reflect.DeepEqual(c.Slots, want) {
+		t.Fatalf("got slots %+v, want %+v", c.Slots, want)
+	}
+}
+
+func TestParseSystemConfigErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "no index", input: "[slot.rootfs]\n", err: `invalid slot section name "slot.rootfs"`},
+		{name: "empty index", input: "[slot.rootfs.]\n", err: "invalid slot section name"},
+		{name: "empty class", input: "[slot..0]\n", err: "invalid slot section name"},
+		{name: "malformed", input: "[system\n", err: "system.conf:1: malformed section header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSystemConfig(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package rauc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	thermalZoneDirectory = "/sys/class/thermal"
+)
+
+// ErrTooHot is matched by errors returned from ThermalGate when the system
+// is above the configured temperature.
+var ErrTooHot = errors.New("RAUC: temperature too high")
+
+// ThermalError is returned by ThermalGate. It matches ErrTooHot with
+// errors.Is.
+type ThermalError struct {
+	Zone    string
+	Celsius float64
+}
+
+func (e *ThermalError) Error() string {
+	return fmt.Sprintf("%v: %s at %.1f°C", ErrTooHot, e.Zone, e.Celsius)
+}
+
+// Is implements error matching for errors.Is.
+func (e *ThermalError) Is(target error) bool {
+	return target == ErrTooHot
+}
+
+// ThermalEvent is published by ThermalGate.Watch when the temperature
+// crosses the threshold in either direction.
+type ThermalEvent struct {
+	Zone     string
+	Celsius  float64
+	Overheat bool
+}
+
+// EventType implements Event.
+func (e ThermalEvent) EventType() string {
+	return "thermal"
+}
+
+// ThermalGate is a Gate that refuses installations while any thermal zone
+// is above MaxCelsius. Flash writes and bundle decompression add
+// considerable heat, which passively cooled devices may not cope with.
+type ThermalGate struct {
+	MaxCelsius float64
+	// Zones restricts the check to thermal zones of the given types
+	// (e.g. "cpu-thermal"). All zones are checked if empty.
+	Zones []string
+}
+
+// hottest returns the hottest matching zone and its temperature.
+func (g *ThermalGate) hottest() (string, float64, error) {
+	zones, err := filepath.Glob(filepath.Join(thermalZoneDirectory, "thermal_zone*"))
+	if err != nil {
+		return "", 0, err
+	}
+
+	zone := ""
+	max := -273.15
+
+	for _, z := range zones {
+		zoneType := readSysfsString(filepath.Join(z, "type"))
+
+		if len(g.Zones) > 0 {
+			found := false
+			for _, t := range g.Zones {
+				if t == zoneType {
+					found = true
+				}
+			}
+
+			if !found {
+				continue
+			}
+		}
+
+		milli, err := strconv.ParseInt(readSysfsString(filepath.Join(z, "temp")), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if c := float64(milli) / 1000; zone == "" || c > max {
+			zone = zoneType
+			max = c
+		}
+	}
+
+	if zone == "" {
+		return "", 0, fmt.Errorf("RAUC: no readable thermal zone found")
+	}
+
+	return zone, max, nil
+}
+
+// Check implements Gate.
+func (g *ThermalGate) Check(ctx context.Context) error {
+	zone, celsius, err := g.hottest()
+	if err != nil {
+		return err
+	}
+
+	if celsius > g.MaxCelsius {
+		return &ThermalError{
+			Zone:    zone,
+			Celsius: celsius,
+		}
+	}
+
+	return nil
+}
+
+// Watch samples the temperature every interval until the context is
+// cancelled and publishes a ThermalEvent on bus, if not nil, whenever the
+// threshold is crossed. RAUC cannot pause a running installation, so this
+// is meant to let applications reduce their own load while an install is
+// in progress.
+func (g *ThermalGate) Watch(ctx context.Context, interval time.Duration, bus *EventBus) error {
+	if interval <= 0 {
+		return fmt.Errorf("RAUC: ThermalGate.Watch(): invalid interval %v", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	overheat := false
+
+	for {
+		if zone, celsius, err := g.hottest(); err == nil {
+			if hot := celsius > g.MaxCelsius; hot != overheat {
+				overheat = hot
+				if bus != nil {
+					bus.Publish(ThermalEvent{
+						Zone:     zone,
+						Celsius:  celsius,
+						Overheat: hot,
+					})
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
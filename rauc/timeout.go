@@ -0,0 +1,50 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInstallTimeout is matched by errors.Is for TimeoutError values.
+var ErrInstallTimeout = errors.New("RAUC: installation timed out")
+
+// DaemonState is the state of the daemon at a certain point in time, for
+// diagnostics. Fields that could not be read are left empty.
+type DaemonState struct {
+	Time      time.Time
+	Operation string
+	Progress
+	LastError string
+}
+
+// collectDaemonState collects the DaemonState of b on a best-effort basis.
+func collectDaemonState(b Backend) DaemonState {
+	s := DaemonState{Time: time.Now()}
+
+	s.Operation, _ = b.GetOperation()
+	s.Progress, _ = ProgressOf(b)
+	s.LastError, _ = b.GetLastError()
+
+	return s
+}
+
+// TimeoutError is returned by InstallBundle if the installation did not
+// complete within InstallBundleOptions.Timeout. The daemon may still be
+// working on the installation.
+type TimeoutError struct {
+	Bundle  string
+	Timeout time.Duration
+	// State is the daemon's state when the timeout expired.
+	State DaemonState
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("RAUC: installation of %s did not complete within %v (operation %s, %d%% %s)",
+		e.Bundle, e.Timeout, e.State.Operation, e.State.Percentage, e.State.Message)
+}
+
+// Is makes errors.Is(err, ErrInstallTimeout) true.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrInstallTimeout
+}
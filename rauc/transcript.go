@@ -0,0 +1,398 @@
+package rauc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// TranscriptEntry is a single call recorded by a Recorder. Transcripts are
+// stored as one JSON encoded entry per line.
+type TranscriptEntry struct {
+	// Offset is the time since the start of the recording at which the
+	// call was made, Duration the time it took.
+	Offset   time.Duration   `json:"offset"`
+	Duration time.Duration   `json:"duration"`
+	Method   string          `json:"method"`
+	Args     []string        `json:"args,omitempty"`
+	Results  json.RawMessage `json:"results,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type transcriptVariant struct {
+	Signature string `json:"signature"`
+	Value     string `json:"value"`
+}
+
+type transcriptSlot struct {
+	SlotName string                       `json:"slot"`
+	Status   map[string]transcriptVariant `json:"status"`
+}
+
+func encodeSlotStatus(status []SlotStatus) []transcriptSlot {
+	slots := make([]transcriptSlot, len(status))
+	for i, s := range status {
+		slots[i] = transcriptSlot{
+			SlotName: s.SlotName,
+			Status:   make(map[string]transcriptVariant, len(s.Status)),
+		}
+
+		for k, v := range s.Status {
+			slots[i].Status[k] = transcriptVariant{v.Signature().String(), v.String()}
+		}
+	}
+
+	return slots
+}
+
+func decodeSlotStatus(slots []transcriptSlot) ([]SlotStatus, error) {
+	status := make([]SlotStatus, len(slots))
+	for i, s := range slots {
+		status[i] = SlotStatus{
+			SlotName: s.SlotName,
+			Status:   make(map[string]dbus.Variant, len(s.Status)),
+		}
+
+		for k, v := range s.Status {
+			sig, err := dbus.ParseSignature(v.Signature)
+			if err != nil {
+				return nil, err
+			}
+
+			variant, err := dbus.ParseVariant(v.Value, sig)
+			if err != nil {
+				return nil, err
+			}
+
+			status[i].Status[k] = variant
+		}
+	}
+
+	return status, nil
+}
+
+// Recorder is a Backend that passes all calls on to another Backend and
+// records them, with their results and timing, to a transcript.
+type Recorder struct {
+	backend Backend
+	start   time.Time
+
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// RecorderNew returns a newly allocated Recorder object that writes the
+// transcript of all calls to backend to w
+func RecorderNew(backend Backend, w io.Writer) *Recorder {
+	return &Recorder{
+		backend: backend,
+		start:   time.Now(),
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Err returns the first error that occurred while writing the transcript.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.err
+}
+
+func (r *Recorder) record(start time.Time, method string, args []string, err error, results ...interface{}) {
+	e := TranscriptEntry{
+		Offset:   start.Sub(r.start),
+		Duration: time.Since(start),
+		Method:   method,
+		Args:     args,
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	} else if len(results) > 0 {
+		e.Results, _ = json.Marshal(results)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err == nil {
+		r.err = r.encoder.Encode(e)
+	}
+}
+
+// Events returns the event bus of the recorded Backend.
+func (r *Recorder) Events() *EventBus {
+	return r.backend.Events()
+}
+
+// InstallBundle calls and records InstallBundle.
+func (r *Recorder) InstallBundle(filename string, options InstallBundleOptions) error {
+	start := time.Now()
+	err := r.backend.InstallBundle(filename, options)
+	r.record(start, "InstallBundle", []string{filename}, err)
+	return err
+}
+
+// Info calls and records Info.
+func (r *Recorder) Info(filename string) (compatible string, version string, err error) {
+	start := time.Now()
+	compatible, version, err = r.backend.Info(filename)
+	r.record(start, "Info", []string{filename}, err, compatible, version)
+	return
+}
+
+// Mark calls and records Mark.
+func (r *Recorder) Mark(state string, slotIdentifier string) (slotName string, message string, err error) {
+	start := time.Now()
+	slotName, message, err = r.backend.Mark(state, slotIdentifier)
+	r.record(start, "Mark", []string{state, slotIdentifier}, err, slotName, message)
+	return
+}
+
+// GetSlotStatus calls and records GetSlotStatus.
+func (r *Recorder) GetSlotStatus() ([]SlotStatus, error) {
+	start := time.Now()
+	status, err := r.backend.GetSlotStatus()
+	r.record(start, "GetSlotStatus", nil, err, encodeSlotStatus(status))
+	return status, err
+}
+
+func (r *Recorder) getString(method string, get func() (string, error)) (string, error) {
+	start := time.Now()
+	v, err := get()
+	r.record(start, method, nil, err, v)
+	return v, err
+}
+
+// GetOperation calls and records GetOperation.
+func (r *Recorder) GetOperation() (string, error) {
+	return r.getString("GetOperation", r.backend.GetOperation)
+}
+
+// GetLastError calls and records GetLastError.
+func (r *Recorder) GetLastError() (string, error) {
+	return r.getString("GetLastError", r.backend.GetLastError)
+}
+
+// GetProgress calls and records GetProgress.
+func (r *Recorder) GetProgress() (percentage int32, message string, nestingDepth int32, err error) {
+	start := time.Now()
+	percentage, message, nestingDepth, err = r.backend.GetProgress()
+	r.record(start, "GetProgress", nil, err, percentage, message, nestingDepth)
+	return
+}
+
+// GetCompatible calls and records GetCompatible.
+func (r *Recorder) GetCompatible() (string, error) {
+	return r.getString("GetCompatible", r.backend.GetCompatible)
+}
+
+// GetVariant calls and records GetVariant.
+func (r *Recorder) GetVariant() (string, error) {
+	return r.getString("GetVariant", r.backend.GetVariant)
+}
+
+// GetBootSlot calls and records GetBootSlot.
+func (r *Recorder) GetBootSlot() (string, error) {
+	return r.getString("GetBootSlot", r.backend.GetBootSlot)
+}
+
+// ErrTranscriptMismatch is returned by a Replayer for calls that the
+// transcript does not contain.
+var ErrTranscriptMismatch = errors.New("RAUC: call does not match transcript")
+
+// ReplayerOptions contains options for the ReplayerNew function
+type ReplayerOptions struct {
+	// Speed scales the recorded call durations. Zero replays without
+	// any delay, 1 in real time.
+	Speed  float64
+	Events *EventBus
+}
+
+// Replayer is a Backend that answers calls from a transcript written by
+// a Recorder. Calls of each method are answered in the order they were
+// recorded, regardless of how calls of different methods interleave.
+// Once all recorded calls of a getter are used up, its last result is
+// repeated.
+type Replayer struct {
+	options ReplayerOptions
+	events  *EventBus
+
+	mutex   sync.Mutex
+	entries map[string][]TranscriptEntry
+	last    map[string]TranscriptEntry
+}
+
+// ReplayerNew returns a newly allocated Replayer object that reads its
+// transcript from r
+func ReplayerNew(r io.Reader, options ReplayerOptions) (*Replayer, error) {
+	p := &Replayer{
+		options: options,
+		events:  options.Events,
+		entries: make(map[string][]TranscriptEntry),
+		last:    make(map[string]TranscriptEntry),
+	}
+
+	if p.events == nil {
+		p.events = EventBusNew()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var e TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("RAUC: transcript line %d: %v", n, err)
+		}
+
+		p.entries[e.Method] = append(p.entries[e.Method], e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("RAUC: reading transcript: %v", err)
+	}
+
+	return p, nil
+}
+
+// next returns the next recorded call of method and decodes its results.
+func (p *Replayer) next(method string, args []string, results ...interface{}) error {
+	p.mutex.Lock()
+	queue := p.entries[method]
+
+	var e TranscriptEntry
+	switch {
+	case len(queue) > 0:
+		e = queue[0]
+		p.entries[method] = queue[1:]
+		p.last[method] = e
+	case strings.HasPrefix(method, "Get"):
+		var ok bool
+		if e, ok = p.last[method]; !ok {
+			p.mutex.Unlock()
+			return fmt.Errorf("%w: %s()", ErrTranscriptMismatch, method)
+		}
+	default:
+		p.mutex.Unlock()
+		return fmt.Errorf("%w: unexpected %s()", ErrTranscriptMismatch, method)
+	}
+	p.mutex.Unlock()
+
+	if strings.Join(e.Args, "\x00") != strings.Join(args, "\x00") {
+		return fmt.Errorf("%w: %s(%s), recorded %s(%s)", ErrTranscriptMismatch,
+			method, strings.Join(args, ", "), method, strings.Join(e.Args, ", "))
+	}
+
+	if p.options.Speed > 0 {
+		time.Sleep(time.Duration(float64(e.Duration) / p.options.Speed))
+	}
+
+	if e.Error != "" {
+		return errors.New(e.Error)
+	}
+
+	if len(results) > 0 && len(e.Results) > 0 {
+		raw := make([]json.RawMessage, 0, len(results))
+		if err := json.Unmarshal(e.Results, &raw); err != nil {
+			return fmt.Errorf("RAUC: transcript %s(): %v", method, err)
+		}
+
+		for i := range results {
+			if i >= len(raw) {
+				break
+			}
+			if err := json.Unmarshal(raw[i], results[i]); err != nil {
+				return fmt.Errorf("RAUC: transcript %s(): %v", method, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Events returns the bus the Replayer publishes its events on.
+func (p *Replayer) Events() *EventBus {
+	return p.events
+}
+
+// InstallBundle replays a recorded installation.
+func (p *Replayer) InstallBundle(filename string, options InstallBundleOptions) (err error) {
+	p.events.Publish(InstallStartedEvent{Bundle: filename})
+	defer func() {
+		p.events.Publish(InstallCompletedEvent{Bundle: filename, Err: err})
+	}()
+
+	return p.next("InstallBundle", []string{filename})
+}
+
+// Info replays a recorded Info call.
+func (p *Replayer) Info(filename string) (compatible string, version string, err error) {
+	err = p.next("Info", []string{filename}, &compatible, &version)
+	return
+}
+
+// Mark replays a recorded Mark call.
+func (p *Replayer) Mark(state string, slotIdentifier string) (slotName string, message string, err error) {
+	err = p.next("Mark", []string{state, slotIdentifier}, &slotName, &message)
+	return
+}
+
+// GetSlotStatus replays a recorded GetSlotStatus call.
+func (p *Replayer) GetSlotStatus() ([]SlotStatus, error) {
+	var slots []transcriptSlot
+	if err := p.next("GetSlotStatus", nil, &slots); err != nil {
+		return nil, err
+	}
+
+	return decodeSlotStatus(slots)
+}
+
+func (p *Replayer) getString(method string) (v string, err error) {
+	err = p.next(method, nil, &v)
+	return
+}
+
+// GetOperation replays a recorded GetOperation call.
+func (p *Replayer) GetOperation() (string, error) {
+	return p.getString("GetOperation")
+}
+
+// GetLastError replays a recorded GetLastError call.
+func (p *Replayer) GetLastError() (string, error) {
+	return p.getString("GetLastError")
+}
+
+// GetProgress replays a recorded GetProgress call.
+func (p *Replayer) GetProgress() (percentage int32, message string, nestingDepth int32, err error) {
+	err = p.next("GetProgress", nil, &percentage, &message, &nestingDepth)
+	return
+}
+
+// GetCompatible replays a recorded GetCompatible call.
+func (p *Replayer) GetCompatible() (string, error) {
+	return p.getString("GetCompatible")
+}
+
+// GetVariant replays a recorded GetVariant call.
+func (p *Replayer) GetVariant() (string, error) {
+	return p.getString("GetVariant")
+}
+
+// GetBootSlot replays a recorded GetBootSlot call.
+func (p *Replayer) GetBootSlot() (string, error) {
+	return p.getString("GetBootSlot")
+}
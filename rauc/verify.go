@@ -0,0 +1,71 @@
+package rauc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VerifyReport is the result of VerifyBundle.
+type VerifyReport struct {
+	Bundle string `json:"bundle"`
+	// Verified is set if the daemon accepted the bundle's signature.
+	// VerifyError holds its reason otherwise, and the bundle's
+	// compatible and version are unknown. They are unknown as well if
+	// the daemon refused the bundle as incompatible.
+	Verified    bool   `json:"verified"`
+	VerifyError string `json:"verify_error,omitempty"`
+	Compatible  string `json:"compatible,omitempty"`
+	Version     string `json:"version,omitempty"`
+	// SystemCompatible is the compatible string of the system the bundle
+	// was checked against.
+	SystemCompatible string `json:"system_compatible"`
+	CompatibleMatch  bool   `json:"compatible_match"`
+}
+
+// Err returns nil if the bundle can be installed on the system, and an
+// error wrapping ErrSignatureInvalid or ErrIncompatibleBundle otherwise.
+func (r *VerifyReport) Err() error {
+	switch {
+	case !r.Verified:
+		return fmt.Errorf("%w: %s: %s", ErrSignatureInvalid, r.Bundle, r.VerifyError)
+	case !r.CompatibleMatch:
+		return fmt.Errorf("%w: %s: expected %q, got %q", ErrIncompatibleBundle, r.Bundle, r.SystemCompatible, r.Compatible)
+	}
+
+	return nil
+}
+
+// VerifyBundle checks the signature of a bundle, given by its path or
+// URL, and whether it is compatible with the system, without installing
+// it. Problems with the bundle, failures caused by ErrSignatureInvalid,
+// ErrNotABundle or ErrIncompatibleBundle, are reported in the returned
+// VerifyReport. Other failures, e.g. of the bus, are returned as errors.
+func VerifyBundle(b Backend, pathOrURL string) (*VerifyReport, error) {
+	system, err := b.GetCompatible()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &VerifyReport{
+		Bundle:           pathOrURL,
+		SystemCompatible: system,
+	}
+
+	r.Compatible, r.Version, err = b.Info(pathOrURL)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrIncompatibleBundle):
+		r.Verified = true
+		return r, nil
+	case errors.Is(err, ErrSignatureInvalid), errors.Is(err, ErrNotABundle):
+		r.VerifyError = err.Error()
+		return r, nil
+	default:
+		return nil, err
+	}
+
+	r.Verified = true
+	r.CompatibleMatch = r.Compatible == system
+
+	return r, nil
+}
@@ -0,0 +1,68 @@
+package rauc
+
+import (
+	"errors"
+	"testing"
+)
+
+// infoBackend is a Simulator whose Info fails with err.
+type infoBackend struct {
+	*Simulator
+	err error
+}
+
+func (b *infoBackend) Info(filename string) (string, string, error) {
+	if b.err != nil {
+		return "", "", b.err
+	}
+
+	return b.Simulator.Info(filename)
+}
+
+func TestVerifyBundle(t *testing.T) {
+	bundles := map[string]SimulatedBundle{
+		"/data/other.raucb": {Compatible: "other", Version: "2.0"},
+	}
+
+	tests := []struct {
+		name     string
+		bundle   string
+		infoErr  error
+		err      bool
+		verified bool
+		match    bool
+		cause    error
+	}{
+		{name: "installable", bundle: "/data/update.raucb", verified: true, match: true},
+		{name: "incompatible", bundle: "/data/other.raucb", verified: true, cause: ErrIncompatibleBundle},
+		{name: "refused as incompatible", bundle: "/data/update.raucb", infoErr: installError("compatible mismatch"), verified: true, cause: ErrIncompatibleBundle},
+		{name: "invalid signature", bundle: "/data/update.raucb", infoErr: installError("signature verification failed"), cause: ErrSignatureInvalid},
+		{name: "bus failure", bundle: "/data/update.raucb", infoErr: errorf("Info", ErrDBusUnavailable, "no reply"), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &infoBackend{Simulator: SimulatorNew(SimulatorOptions{Bundles: bundles}), err: tt.infoErr}
+
+			r, err := VerifyBundle(b, tt.bundle)
+			if tt.err {
+				if !errors.Is(err, tt.infoErr) {
+					t.Fatalf("got report %+v and error %v, want %v", r, err, tt.infoErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if r.Verified != tt.verified || r.CompatibleMatch != tt.match {
+				t.Fatalf("got %+v", r)
+			}
+
+			if err := r.Err(); (tt.cause == nil) != (err == nil) || (tt.cause != nil && !errors.Is(err, tt.cause)) {
+				t.Fatalf("got error %v, want %v", err, tt.cause)
+			}
+		})
+	}
+}
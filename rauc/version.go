@@ -0,0 +1,38 @@
+package rauc
+
+import (
+	"runtime/debug"
+)
+
+const modulePath = "github.com/holoplot/go-rauc"
+
+// Version returns the version of this module as recorded in the build
+// information of the binary, e.g. "v1.2.0", or "(devel)" if it is built
+// from a working copy.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+
+	for _, m := range info.Deps {
+		if m.Path == modulePath {
+			if m.Replace != nil && m.Replace.Version != "" {
+				return m.Replace.Version
+			}
+			return m.Version
+		}
+	}
+
+	return "(devel)"
+}
+
+// UserAgent returns the User-Agent used for HTTP requests,
+// e.g. "go-rauc/v1.2.0".
+func UserAgent() string {
+	return "go-rauc/" + Version()
+}
@@ -0,0 +1,152 @@
+package rauc
+
+import (
+	"context"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// watchedProperties are the properties reported by WatchProperties.
+var watchedProperties = []string{"Operation", "Progress", "LastError"}
+
+// PropertyChange is a new value of the Operation, Progress or LastError
+// property of the daemon.
+type PropertyChange struct {
+	Name string
+	// Value is a Progress for the Progress property, and a string for
+	// the others.
+	Value interface{}
+	Time  time.Time
+}
+
+// Operation returns the new operation, if the change is one of the
+// Operation property.
+func (c PropertyChange) Operation() (Operation, bool) {
+	s, ok := c.Value.(string)
+	return Operation(s), ok && c.Name == "Operation"
+}
+
+// Progress returns the new progress, if the change is one of the Progress
+// property.
+func (c PropertyChange) Progress() (Progress, bool) {
+	p, ok := c.Value.(Progress)
+	return p, ok && c.Name == "Progress"
+}
+
+// LastError returns the new error message, if the change is one of the
+// LastError property.
+func (c PropertyChange) LastError() (string, bool) {
+	s, ok := c.Value.(string)
+	return s, ok && c.Name == "LastError"
+}
+
+func (p *Installer) decodeProperty(name string, v dbus.Variant) interface{} {
+	if name != "Progress" {
+		return p.stringValue(name, v)
+	}
+
+	percentage, message, depth, ok := decodeProgress(v.Value())
+	if !ok {
+		p.warn("Progress", "(isi)", v.Value())
+	}
+
+	return Progress{Percentage: percentage, Message: message, NestingDepth: depth}
+}
+
+// propertyChanges returns the changes of watched properties in signal.
+// Invalidated properties are read from the daemon.
+func (p *Installer) propertyChanges(ctx context.Context, signal *dbus.Signal) []PropertyChange {
+	if signal.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" || signal.Path != p.path || len(signal.Body) < 3 {
+		return nil
+	}
+
+	if iface, _ := signal.Body[0].(string); iface != p.iface {
+		return nil
+	}
+
+	changed, _ := signal.Body[1].(map[string]dbus.Variant)
+	invalidated, _ := signal.Body[2].([]string)
+
+	var changes []PropertyChange
+
+	for _, name := range watchedProperties {
+		v, ok := changed[name]
+		if !ok && contains(invalidated, name) {
+			var err error
+			if v, err = p.property(ctx, name); err == nil {
+				ok = true
+			}
+		}
+
+		if ok {
+			changes = append(changes, PropertyChange{
+				Name:  name,
+				Value: p.decodeProperty(name, v),
+				Time:  time.Now(),
+			})
+		}
+	}
+
+	return changes
+}
+
+// WatchProperties returns a channel that receives the current values of
+// the Operation, Progress and LastError properties, followed by every
+// change the daemon signals, so that they need not be polled. The channel
+// is closed when ctx is done or the connection is closed.
+func (p *Installer) WatchProperties(ctx context.Context) (<-chan PropertyChange, error) {
+	// Subscribe first, to not miss changes while reading the values.
+	signals, unsubscribe := p.SubscribeSignals(64)
+
+	initial := make([]PropertyChange, 0, len(watchedProperties))
+	for _, name := range watchedProperties {
+		v, err := p.property(ctx, name)
+		if err != nil {
+			unsubscribe()
+			return nil, callError("WatchProperties", err)
+		}
+
+		initial = append(initial, PropertyChange{
+			Name:  name,
+			Value: p.decodeProperty(name, v),
+			Time:  time.Now(),
+		})
+	}
+
+	c := make(chan PropertyChange, 16)
+
+	go func() {
+		defer close(c)
+		defer unsubscribe()
+
+		send := func(changes []PropertyChange) bool {
+			for _, change := range changes {
+				select {
+				case c <- change:
+				case <-ctx.Done():
+					return false
+				}
+			}
+
+			return true
+		}
+
+		if !send(initial) {
+			return
+		}
+
+		for {
+			select {
+			case signal, ok := <-signals:
+				if !ok || !send(p.propertyChanges(ctx, signal)) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
@@ -0,0 +1,48 @@
+package rauc
+
+import (
+	"fmt"
+	"os"
+)
+
+// Watchdog is kept alive by InstallBundle while it waits for the daemon, for
+// systems that reset when the application's main loop stops feeding the
+// hardware watchdog during a long installation. Applications that already
+// own the watchdog can implement this interface to coordinate with their
+// existing feeder.
+type Watchdog interface {
+	Keepalive() error
+}
+
+// DeviceWatchdog is a Watchdog backed by a kernel watchdog device.
+type DeviceWatchdog struct {
+	f *os.File
+}
+
+// WatchdogOpen opens a watchdog device such as /dev/watchdog. Note that
+// opening the device starts the watchdog timer.
+func WatchdogOpen(path string) (*DeviceWatchdog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("RAUC: WatchdogOpen(): %v", err)
+	}
+
+	return &DeviceWatchdog{f: f}, nil
+}
+
+// Keepalive implements Watchdog.
+func (w *DeviceWatchdog) Keepalive() error {
+	// Any character other than the magic 'V' resets the timer.
+	if _, err := w.f.Write([]byte{0}); err != nil {
+		return fmt.Errorf("RAUC: watchdog keepalive: %v", err)
+	}
+
+	return nil
+}
+
+// Close disarms the watchdog using the magic close character and closes the
+// device. Drivers built with CONFIG_WATCHDOG_NOWAYOUT keep running anyway.
+func (w *DeviceWatchdog) Close() error {
+	w.f.Write([]byte("V"))
+	return w.f.Close()
+}
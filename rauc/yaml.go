@@ -0,0 +1,41 @@
+package rauc
+
+import (
+	yaml "gopkg.in/yaml.v3"
+)
+
+// MarshalYAML encodes the slot status with plain values, like MarshalJSON.
+func (s SlotStatus) MarshalYAML() (interface{}, error) {
+	return s.document(), nil
+}
+
+// UnmarshalYAML decodes the output of MarshalYAML.
+func (s *SlotStatus) UnmarshalYAML(value *yaml.Node) error {
+	var d slotStatusDocument
+	if err := value.Decode(&d); err != nil {
+		return err
+	}
+
+	s.fromDocument(d)
+	return nil
+}
+
+// MarshalYAML encodes the slot status like SlotStatus, plus its mounts.
+func (s SlotSnapshot) MarshalYAML() (interface{}, error) {
+	return slotSnapshotDocument{
+		slotStatusDocument: s.document(),
+		Mounts:             s.Mounts,
+	}, nil
+}
+
+// UnmarshalYAML decodes the output of MarshalYAML.
+func (s *SlotSnapshot) UnmarshalYAML(value *yaml.Node) error {
+	var d slotSnapshotDocument
+	if err := value.Decode(&d); err != nil {
+		return err
+	}
+
+	s.fromDocument(d.slotStatusDocument)
+	s.Mounts = d.Mounts
+	return nil
+}
@@ -0,0 +1,6 @@
+// Package raucmock provides a scripted implementation of
+// rauc.InstallerAPI for unit tests of code using the rauc package. Unlike
+// rauctest.Daemon it needs no D-Bus daemon: slot statuses, install
+// outcomes and progress sequences are set up front, and every call is
+// recorded for later assertions.
+package raucmock
@@ -0,0 +1,881 @@
+package raucmock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/holoplot/go-rauc/rauctest"
+)
+
+const (
+	installerInterface = "de.pengutronix.rauc.Installer"
+	daemonPath         = dbus.ObjectPath("/")
+)
+
+// Install is the scripted outcome of one InstallBundle call.
+type Install struct {
+	// Err is returned right away, as if the daemon refused the call.
+	Err error
+	// Progress is the sequence of Progress values reported while the
+	// installation runs, one per Options.ProgressInterval.
+	Progress []rauctest.Progress
+	// LastError makes the installation fail with this message.
+	LastError string
+	// Slots replaces the slot status once the installation succeeded.
+	Slots []rauc.SlotStatus
+}
+
+// Options contains options for the MockNew function
+type Options struct {
+	// Compatible and Variant default to "board" and "".
+	Compatible string
+	Variant    string
+	// Slots defaults to rauctest.SlotStatusAB.
+	Slots []rauc.SlotStatus
+	// BootSlot defaults to the bootname of the booted slot.
+	BootSlot string
+	// Primary is the name of the primary slot, defaulting to the booted
+	// slot.
+	Primary string
+	// Artifacts is returned by GetArtifactStatus.
+	Artifacts []rauc.Artifact
+	// Service is returned by GetServiceState and part of Snapshot. If nil,
+	// GetServiceState fails as on systems without systemd.
+	Service *rauc.ServiceState
+	// Bundles maps file names to what Info and InspectBundle return.
+	// Other bundles are reported as not found.
+	Bundles map[string]rauc.BundleInfo
+	// Installs are the outcomes of consecutive InstallBundle calls. Calls
+	// beyond the script succeed without progress.
+	Installs []Install
+	// ProgressInterval is the time between two Progress values.
+	ProgressInterval time.Duration
+	// Errors makes the methods named by the keys, such as "GetSlotStatus",
+	// fail with the error instead.
+	Errors map[string]error
+	Events *rauc.EventBus
+}
+
+// Call is a recorded method call.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// installation is an InstallBundle call in progress.
+type installation struct {
+	bundle string
+	done   chan struct{}
+	err    error
+}
+
+// Mock is a scripted rauc.InstallerAPI. Client side InstallBundleOptions
+// such as Gates, Hooks and Verify are not applied.
+type Mock struct {
+	options Options
+	events  *rauc.EventBus
+
+	mutex     sync.Mutex
+	calls     []Call
+	slots     []rauc.SlotStatus
+	installs  []Install
+	operation string
+	lastError string
+	primary   string
+	progress  rauctest.Progress
+	current   *installation
+	detached  *installation
+	detach    chan struct{}
+	completed map[chan rauc.CompletedEvent]struct{}
+	watchers  map[chan rauc.PropertyChange]struct{}
+	signals   map[chan *dbus.Signal]struct{}
+	dropped   uint64
+}
+
+var _ rauc.InstallerAPI = (*Mock)(nil)
+
+// MockNew returns a newly allocated Mock object
+func MockNew(options Options) *Mock {
+	if options.Compatible == "" {
+		options.Compatible = "board"
+	}
+
+	if options.Slots == nil {
+		options.Slots = rauctest.SlotStatusAB()
+	}
+
+	m := &Mock{
+		options:   options,
+		events:    options.Events,
+		slots:     copySlots(options.Slots),
+		installs:  append([]Install(nil), options.Installs...),
+		operation: string(rauc.OperationIdle),
+		completed: make(map[chan rauc.CompletedEvent]struct{}),
+		watchers:  make(map[chan rauc.PropertyChange]struct{}),
+		signals:   make(map[chan *dbus.Signal]struct{}),
+	}
+
+	if m.events == nil {
+		m.events = rauc.EventBusNew()
+	}
+
+	m.primary = options.Primary
+	if m.primary == "" {
+		for _, slot := range rauc.SlotsOf(m.slots) {
+			if slot.Booted() {
+				m.primary = slot.Name
+			}
+		}
+	}
+
+	return m
+}
+
+func copySlots(slots []rauc.SlotStatus) []rauc.SlotStatus {
+	c := make([]rauc.SlotStatus, len(slots))
+	for i, slot := range slots {
+		c[i] = rauc.SlotStatus{
+			SlotName: slot.SlotName,
+			Status:   make(map[string]dbus.Variant, len(slot.Status)),
+		}
+
+		for k, v := range slot.Status {
+			c[i].Status[k] = v
+		}
+	}
+
+	return c
+}
+
+// record notes a call and returns the error scripted for method.
+func (m *Mock) record(method string, args ...interface{}) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+
+	return m.options.Errors[method]
+}
+
+// Calls returns the calls made so far, in order. Context variants are
+// recorded under the name of the plain method.
+func (m *Mock) Calls() []Call {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsTo returns the calls made to method so far.
+func (m *Mock) CallsTo(method string) []Call {
+	var calls []Call
+	for _, c := range m.Calls() {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// SetSlots replaces the slot status.
+func (m *Mock) SetSlots(slots []rauc.SlotStatus) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.slots = copySlots(slots)
+}
+
+// Events returns the bus the Mock publishes its events on.
+func (m *Mock) Events() *rauc.EventBus {
+	return m.events
+}
+
+// InstallBundle runs the next scripted installation.
+func (m *Mock) InstallBundle(filename string, options rauc.InstallBundleOptions) error {
+	return m.InstallBundleContext(context.Background(), filename, options)
+}
+
+// InstallBundleContext is InstallBundle, but stops waiting when ctx is
+// done. The installation keeps running, as with the real daemon.
+func (m *Mock) InstallBundleContext(ctx context.Context, filename string, options rauc.InstallBundleOptions) error {
+	if err := m.record("InstallBundle", filename, options); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+
+	var install Install
+	if len(m.installs) > 0 {
+		install, m.installs = m.installs[0], m.installs[1:]
+	}
+
+	if install.Err != nil {
+		m.mutex.Unlock()
+		return install.Err
+	}
+
+	if m.operation != string(rauc.OperationIdle) {
+		m.mutex.Unlock()
+		return &rauc.Error{
+			Method:  "Install",
+			Message: "already processing a different method",
+			Cause:   rauc.ErrBusy,
+		}
+	}
+
+	i := &installation{
+		bundle: filename,
+		done:   make(chan struct{}),
+	}
+
+	m.operation = string(rauc.OperationInstalling)
+	m.changed("Operation", m.operation)
+	m.current = i
+	if m.detach == nil {
+		m.detach = make(chan struct{})
+	}
+	detach := m.detach
+	m.mutex.Unlock()
+
+	m.events.Publish(rauc.InstallStartedEvent{Bundle: filename})
+
+	go m.run(i, install)
+
+	select {
+	case <-i.done:
+	case <-detach:
+		m.events.Publish(rauc.InstallDetachedEvent{Bundle: filename})
+		return rauc.ErrDetached
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	m.events.Publish(rauc.InstallCompletedEvent{Bundle: filename, Err: i.err})
+
+	return i.err
+}
+
+// StartInstall starts the next scripted installation, see
+// rauc.StartInstall. The job polls GetProgress, which is recorded.
+func (m *Mock) StartInstall(filename string, options rauc.InstallBundleOptions) (*rauc.InstallJob, error) {
+	return rauc.StartInstallContext(context.Background(), m, filename, options)
+}
+
+// StartInstallContext is StartInstall, but the job stops waiting when ctx
+// is done.
+func (m *Mock) StartInstallContext(ctx context.Context, filename string, options rauc.InstallBundleOptions) (*rauc.InstallJob, error) {
+	return rauc.StartInstallContext(ctx, m, filename, options)
+}
+
+// run steps through the progress of install and completes i.
+func (m *Mock) run(i *installation, install Install) {
+	for _, progress := range install.Progress {
+		m.mutex.Lock()
+		m.progress = progress
+		m.changed("Progress", rauc.Progress(progress))
+		m.mutex.Unlock()
+
+		time.Sleep(m.options.ProgressInterval)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	e := rauc.CompletedEvent{Time: time.Now()}
+
+	if install.LastError != "" {
+		m.lastError = install.LastError
+		m.changed("LastError", m.lastError)
+		e.Result, e.LastError = 1, install.LastError
+		i.err = e.Err()
+	} else if install.Slots != nil {
+		m.slots = copySlots(install.Slots)
+	}
+
+	for c := range m.completed {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+
+	m.signal(installerInterface+".Completed", e.Result)
+
+	m.operation = string(rauc.OperationIdle)
+	m.changed("Operation", m.operation)
+	if m.current == i {
+		m.current = nil
+	}
+
+	close(i.done)
+}
+
+// changed reports a property change to the watchers and signal
+// subscribers. The caller holds the mutex.
+func (m *Mock) changed(name string, value interface{}) {
+	change := rauc.PropertyChange{Name: name, Value: value, Time: time.Now()}
+
+	for c := range m.watchers {
+		select {
+		case c <- change:
+		default:
+		}
+	}
+
+	if p, ok := value.(rauc.Progress); ok {
+		value = []interface{}{p.Percentage, p.Message, p.NestingDepth}
+	}
+
+	m.signal("org.freedesktop.DBus.Properties.PropertiesChanged",
+		installerInterface, map[string]dbus.Variant{name: dbus.MakeVariant(value)}, []string{})
+}
+
+// signal delivers a signal as the daemon would send it to the signal
+// subscribers. The caller holds the mutex.
+func (m *Mock) signal(name string, body ...interface{}) {
+	signal := &dbus.Signal{
+		Path: daemonPath,
+		Name: name,
+		Body: body,
+	}
+
+	for c := range m.signals {
+		select {
+		case c <- signal:
+		default:
+			m.dropped++
+		}
+	}
+}
+
+// SubscribeSignals returns a channel that receives the Completed and
+// PropertiesChanged signals the daemon would send during scripted
+// installations, buffering up to size of them, and a function that ends
+// the subscription.
+func (m *Mock) SubscribeSignals(size int) (<-chan *dbus.Signal, func()) {
+	if size < 1 {
+		size = 1
+	}
+
+	c := make(chan *dbus.Signal, size)
+
+	m.mutex.Lock()
+	m.signals[c] = struct{}{}
+	m.mutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			m.mutex.Lock()
+			delete(m.signals, c)
+			close(c)
+			m.mutex.Unlock()
+		})
+	}
+
+	return c, cancel
+}
+
+// DroppedSignals returns the number of signals subscribers lost because
+// their buffer was full.
+func (m *Mock) DroppedSignals() uint64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.dropped
+}
+
+// WatchProperties returns a channel that receives the current values of
+// the Operation, Progress and LastError properties, followed by their
+// changes during scripted installations. Changes are dropped while the
+// channel is full. The channel is closed when ctx is done.
+func (m *Mock) WatchProperties(ctx context.Context) (<-chan rauc.PropertyChange, error) {
+	if err := m.record("WatchProperties"); err != nil {
+		return nil, err
+	}
+
+	c := make(chan rauc.PropertyChange, 64)
+
+	m.mutex.Lock()
+	now := time.Now()
+	c <- rauc.PropertyChange{Name: "Operation", Value: m.operation, Time: now}
+	c <- rauc.PropertyChange{Name: "Progress", Value: rauc.Progress(m.progress), Time: now}
+	c <- rauc.PropertyChange{Name: "LastError", Value: m.lastError, Time: now}
+	m.watchers[c] = struct{}{}
+	m.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		m.mutex.Lock()
+		delete(m.watchers, c)
+		close(c)
+		m.mutex.Unlock()
+	}()
+
+	return c, nil
+}
+
+// SubscribeCompleted returns a channel that receives a CompletedEvent for
+// every scripted installation that completes, and a function that ends
+// the subscription. Events are dropped while the channel is full.
+func (m *Mock) SubscribeCompleted() (<-chan rauc.CompletedEvent, func()) {
+	c := make(chan rauc.CompletedEvent, 16)
+
+	m.mutex.Lock()
+	m.completed[c] = struct{}{}
+	m.mutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			m.mutex.Lock()
+			delete(m.completed, c)
+			close(c)
+			m.mutex.Unlock()
+		})
+	}
+
+	return c, cancel
+}
+
+// Detach makes InstallBundle calls that are waiting return
+// rauc.ErrDetached. AttachToCurrentOperation picks the installation up.
+func (m *Mock) Detach() error {
+	if err := m.record("Detach"); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.current == nil {
+		return nil
+	}
+
+	m.detached, m.current = m.current, nil
+	if m.detach != nil {
+		close(m.detach)
+		m.detach = nil
+	}
+
+	return nil
+}
+
+// AttachToCurrentOperation waits for the installation detached from with
+// Detach to complete.
+func (m *Mock) AttachToCurrentOperation(options rauc.InstallBundleOptions) (string, error) {
+	return m.AttachToCurrentOperationContext(context.Background(), options)
+}
+
+// AttachToCurrentOperationContext is AttachToCurrentOperation, but stops
+// waiting when ctx is done.
+func (m *Mock) AttachToCurrentOperationContext(ctx context.Context, options rauc.InstallBundleOptions) (string, error) {
+	if err := m.record("AttachToCurrentOperation", options); err != nil {
+		return "", err
+	}
+
+	m.mutex.Lock()
+	i := m.detached
+	m.mutex.Unlock()
+
+	if i == nil {
+		return "", rauc.ErrNothingToAttach
+	}
+
+	select {
+	case <-i.done:
+	case <-ctx.Done():
+		return i.bundle, ctx.Err()
+	}
+
+	m.mutex.Lock()
+	if m.detached == i {
+		m.detached = nil
+	}
+	m.mutex.Unlock()
+
+	return i.bundle, i.err
+}
+
+// WaitForOperation polls the operation until it is target.
+func (m *Mock) WaitForOperation(ctx context.Context, target rauc.Operation) (string, error) {
+	if err := m.record("WaitForOperation", target); err != nil {
+		return "", err
+	}
+
+	return rauc.WaitForOperation(ctx, backend{m}, target)
+}
+
+// backend hides the Mock's WaitForOperation from rauc.WaitForOperation.
+type backend struct {
+	rauc.Backend
+}
+
+func (m *Mock) bundle(method, filename string) (*rauc.BundleInfo, error) {
+	b, ok := m.options.Bundles[filename]
+	if !ok {
+		return nil, fmt.Errorf("RAUC: %s(): %s: no such file or directory", method, filename)
+	}
+
+	return &b, nil
+}
+
+// Info returns the compatible and version of a bundle in Options.Bundles.
+func (m *Mock) Info(filename string) (string, string, error) {
+	return m.InfoContext(context.Background(), filename)
+}
+
+// InfoContext is Info.
+func (m *Mock) InfoContext(ctx context.Context, filename string) (string, string, error) {
+	if err := m.record("Info", filename); err != nil {
+		return "", "", err
+	}
+
+	b, err := m.bundle("Info", filename)
+	if err != nil {
+		return "", "", err
+	}
+
+	return b.Compatible, b.Version, nil
+}
+
+// InspectBundle returns a bundle in Options.Bundles.
+func (m *Mock) InspectBundle(filename string, options rauc.InspectBundleOptions) (*rauc.BundleInfo, error) {
+	return m.InspectBundleContext(context.Background(), filename, options)
+}
+
+// InspectBundleContext is InspectBundle.
+func (m *Mock) InspectBundleContext(ctx context.Context, filename string, options rauc.InspectBundleOptions) (*rauc.BundleInfo, error) {
+	if err := m.record("InspectBundle", filename, options); err != nil {
+		return nil, err
+	}
+
+	return m.bundle("InspectBundle", filename)
+}
+
+// Mark sets the boot status of a slot for "good" and "bad". Marking a
+// slot "active" makes it the primary slot.
+func (m *Mock) Mark(state string, slotIdentifier string) (string, string, error) {
+	return m.MarkContext(context.Background(), state, slotIdentifier)
+}
+
+// MarkContext is Mark.
+func (m *Mock) MarkContext(ctx context.Context, state string, slotIdentifier string) (string, string, error) {
+	if err := m.record("Mark", state, slotIdentifier); err != nil {
+		return "", "", err
+	}
+
+	if _, err := rauc.ParseMarkState(state); err != nil {
+		return "", "", err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, slot := range m.slots {
+		s := slot.Slot()
+		if s.Bootname == "" {
+			continue
+		}
+
+		if slotIdentifier == s.Name ||
+			(slotIdentifier == "booted" && s.Booted()) ||
+			(slotIdentifier == "other" && !s.Booted()) {
+			if rauc.MarkState(state) == rauc.MarkStateActive {
+				m.primary = s.Name
+			} else {
+				slot.Status[rauc.SlotKeyBootStatus] = dbus.MakeVariant(state)
+			}
+
+			return s.Name, fmt.Sprintf("marked slot %s as %s", s.Name, state), nil
+		}
+	}
+
+	return "", "", &rauc.Error{
+		Method:  "Mark",
+		Message: fmt.Sprintf("no slot with identifier %q", slotIdentifier),
+		Cause:   rauc.ErrSlotNotFound,
+	}
+}
+
+// MarkSlot is Mark with a typed state and result.
+func (m *Mock) MarkSlot(state rauc.MarkState, slotIdentifier string) (rauc.MarkResult, error) {
+	return m.MarkSlotContext(context.Background(), state, slotIdentifier)
+}
+
+// MarkSlotContext is MarkSlot.
+func (m *Mock) MarkSlotContext(ctx context.Context, state rauc.MarkState, slotIdentifier string) (rauc.MarkResult, error) {
+	slotName, message, err := m.MarkContext(ctx, string(state), slotIdentifier)
+	if err != nil {
+		return rauc.MarkResult{}, err
+	}
+
+	return rauc.MarkResult{Slot: slotName, State: state, Message: message}, nil
+}
+
+// MarkGood is MarkSlot with rauc.MarkStateGood.
+func (m *Mock) MarkGood(slotIdentifier string) (rauc.MarkResult, error) {
+	return m.MarkSlot(rauc.MarkStateGood, slotIdentifier)
+}
+
+// MarkBad is MarkSlot with rauc.MarkStateBad.
+func (m *Mock) MarkBad(slotIdentifier string) (rauc.MarkResult, error) {
+	return m.MarkSlot(rauc.MarkStateBad, slotIdentifier)
+}
+
+// MarkActive is MarkSlot with rauc.MarkStateActive.
+func (m *Mock) MarkActive(slotIdentifier string) (rauc.MarkResult, error) {
+	return m.MarkSlot(rauc.MarkStateActive, slotIdentifier)
+}
+
+// GetSlotStatus returns a copy of the slot status.
+func (m *Mock) GetSlotStatus() ([]rauc.SlotStatus, error) {
+	return m.GetSlotStatusContext(context.Background())
+}
+
+// GetSlotStatusContext is GetSlotStatus.
+func (m *Mock) GetSlotStatusContext(ctx context.Context) ([]rauc.SlotStatus, error) {
+	if err := m.record("GetSlotStatus"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return copySlots(m.slots), nil
+}
+
+// GetSlots returns the slot status decoded into Slots.
+func (m *Mock) GetSlots() ([]rauc.Slot, error) {
+	return m.GetSlotsContext(context.Background())
+}
+
+// GetSlotsContext is GetSlots.
+func (m *Mock) GetSlotsContext(ctx context.Context) ([]rauc.Slot, error) {
+	status, err := m.GetSlotStatusContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return rauc.SlotsOf(status), nil
+}
+
+// GetBootedSlot returns the slot of class in use, see
+// rauc.FindBootedSlot.
+func (m *Mock) GetBootedSlot(class string) (rauc.Slot, error) {
+	return m.GetBootedSlotContext(context.Background(), class)
+}
+
+// GetBootedSlotContext is GetBootedSlot.
+func (m *Mock) GetBootedSlotContext(ctx context.Context, class string) (rauc.Slot, error) {
+	return rauc.GetBootedSlot(m, class)
+}
+
+// GetOtherSlot returns the inactive slot of class, see
+// rauc.FindOtherSlot.
+func (m *Mock) GetOtherSlot(class string) (rauc.Slot, error) {
+	return m.GetOtherSlotContext(context.Background(), class)
+}
+
+// GetOtherSlotContext is GetOtherSlot.
+func (m *Mock) GetOtherSlotContext(ctx context.Context, class string) (rauc.Slot, error) {
+	return rauc.GetOtherSlot(m, class)
+}
+
+// GetOperation returns "installing" while a scripted installation runs.
+func (m *Mock) GetOperation() (string, error) {
+	return m.GetOperationContext(context.Background())
+}
+
+// GetOperationContext is GetOperation.
+func (m *Mock) GetOperationContext(ctx context.Context) (string, error) {
+	if err := m.record("GetOperation"); err != nil {
+		return "", err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.operation, nil
+}
+
+// GetLastError returns the message of the last failed installation.
+func (m *Mock) GetLastError() (string, error) {
+	return m.GetLastErrorContext(context.Background())
+}
+
+// GetLastErrorContext is GetLastError.
+func (m *Mock) GetLastErrorContext(ctx context.Context) (string, error) {
+	if err := m.record("GetLastError"); err != nil {
+		return "", err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.lastError, nil
+}
+
+// GetProgress returns the current value of the scripted progress.
+func (m *Mock) GetProgress() (int32, string, int32, error) {
+	return m.GetProgressContext(context.Background())
+}
+
+// GetProgressContext is GetProgress.
+func (m *Mock) GetProgressContext(ctx context.Context) (int32, string, int32, error) {
+	if err := m.record("GetProgress"); err != nil {
+		return 0, "", 0, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.progress.Percentage, m.progress.Message, m.progress.NestingDepth, nil
+}
+
+// GetCompatible returns Options.Compatible.
+func (m *Mock) GetCompatible() (string, error) {
+	return m.GetCompatibleContext(context.Background())
+}
+
+// GetCompatibleContext is GetCompatible.
+func (m *Mock) GetCompatibleContext(ctx context.Context) (string, error) {
+	if err := m.record("GetCompatible"); err != nil {
+		return "", err
+	}
+
+	return m.options.Compatible, nil
+}
+
+// GetVariant returns Options.Variant.
+func (m *Mock) GetVariant() (string, error) {
+	return m.GetVariantContext(context.Background())
+}
+
+// GetVariantContext is GetVariant.
+func (m *Mock) GetVariantContext(ctx context.Context) (string, error) {
+	if err := m.record("GetVariant"); err != nil {
+		return "", err
+	}
+
+	return m.options.Variant, nil
+}
+
+// GetBootSlot returns Options.BootSlot, or the bootname of the booted
+// slot.
+func (m *Mock) GetBootSlot() (string, error) {
+	return m.GetBootSlotContext(context.Background())
+}
+
+// GetBootSlotContext is GetBootSlot.
+func (m *Mock) GetBootSlotContext(ctx context.Context) (string, error) {
+	if err := m.record("GetBootSlot"); err != nil {
+		return "", err
+	}
+
+	if m.options.BootSlot != "" {
+		return m.options.BootSlot, nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, slot := range rauc.SlotsOf(m.slots) {
+		if slot.Booted() && slot.Bootname != "" {
+			return slot.Bootname, nil
+		}
+	}
+
+	return "", nil
+}
+
+// GetPrimary returns Options.Primary, or the slot marked active last.
+func (m *Mock) GetPrimary() (string, error) {
+	return m.GetPrimaryContext(context.Background())
+}
+
+// GetPrimaryContext is GetPrimary.
+func (m *Mock) GetPrimaryContext(ctx context.Context) (string, error) {
+	if err := m.record("GetPrimary"); err != nil {
+		return "", err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.primary, nil
+}
+
+// GetArtifactStatus returns Options.Artifacts.
+func (m *Mock) GetArtifactStatus() ([]rauc.Artifact, error) {
+	return m.GetArtifactStatusContext(context.Background())
+}
+
+// GetArtifactStatusContext is GetArtifactStatus.
+func (m *Mock) GetArtifactStatusContext(ctx context.Context) ([]rauc.Artifact, error) {
+	if err := m.record("GetArtifactStatus"); err != nil {
+		return nil, err
+	}
+
+	return append([]rauc.Artifact(nil), m.options.Artifacts...), nil
+}
+
+// Snapshot collects the scripted properties and slot status, without
+// mounts, and Options.Service.
+func (m *Mock) Snapshot() (*rauc.Snapshot, error) {
+	if err := m.record("Snapshot"); err != nil {
+		return nil, err
+	}
+
+	s, err := rauc.SnapshotWithOptions(m, rauc.SnapshotOptions{SkipMounts: true, SkipService: true})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Service, _ = m.GetServiceState()
+
+	return s, nil
+}
+
+// GetServiceState returns a copy of Options.Service.
+func (m *Mock) GetServiceState() (*rauc.ServiceState, error) {
+	if err := m.record("GetServiceState"); err != nil {
+		return nil, err
+	}
+
+	if m.options.Service == nil {
+		return nil, fmt.Errorf("RAUC: LoadUnit(%s): systemd not available", rauc.ServiceUnit)
+	}
+
+	s := *m.options.Service
+	return &s, nil
+}
+
+// GetSlotMap returns a SlotMap for the scripted slot status.
+func (m *Mock) GetSlotMap() (*rauc.SlotMap, error) {
+	if err := m.record("GetSlotMap"); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	status := copySlots(m.slots)
+	m.mutex.Unlock()
+
+	return rauc.SlotMapNew(status), nil
+}
+
+// KexecActivate only records the call, nothing is booted.
+func (m *Mock) KexecActivate(ctx context.Context, options rauc.KexecOptions) error {
+	return m.record("KexecActivate", options)
+}
+
+// ForwardProgress passes the scripted progress on to splash.
+func (m *Mock) ForwardProgress(ctx context.Context, splash rauc.Splash, interval time.Duration) error {
+	return rauc.ForwardProgress(ctx, m, splash, interval)
+}
@@ -0,0 +1,174 @@
+package raucmock
+
+import (
+	"errors"
+	"testing"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/rauc"
+	"github.com/holoplot/go-rauc/rauctest"
+)
+
+func TestMockInstall(t *testing.T) {
+	updated := rauctest.SlotStatusAB()
+	updated[1].Status[rauc.SlotKeyBundleVersion] = rauctest.SlotStatusAB()[0].Status[rauc.SlotKeyBundleVersion]
+
+	tests := []struct {
+		name      string
+		installs  []Install
+		err       error
+		lastError string
+		version   string
+	}{
+		{name: "unscripted", version: "0.9.0"},
+		{name: "refused", installs: []Install{{Err: rauc.ErrBusy}}, err: rauc.ErrBusy, version: "0.9.0"},
+		{name: "failed", installs: []Install{{LastError: "No space left on device"}}, err: rauc.ErrNoSpace, lastError: "No space left on device", version: "0.9.0"},
+		{name: "slots", installs: []Install{{Slots: updated}}, version: "1.0.0"},
+		{name: "progress", installs: []Install{{Progress: []rauctest.Progress{{Percentage: 0}, {Percentage: 100}}}}, version: "0.9.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := MockNew(Options{Installs: tt.installs})
+
+			err := m.InstallBundle("/data/update.raucb", rauc.InstallBundleOptions{})
+			if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if lastError, _ := m.GetLastError(); lastError != tt.lastError {
+				t.Errorf("got LastError %q, want %q", lastError, tt.lastError)
+			}
+
+			rauctest.AssertInstalledVersion(t, m, "rootfs.1", tt.version)
+			rauctest.WaitForOperation(t, m, string(rauc.OperationIdle), 0)
+
+			if calls := m.CallsTo("InstallBundle"); len(calls) != 1 || calls[0].Args[0] != "/data/update.raucb" {
+				t.Errorf("got calls %+v, want one InstallBundle call", calls)
+			}
+		})
+	}
+}
+
+func TestMockMark(t *testing.T) {
+	tests := []struct {
+		state      string
+		identifier string
+		slot       string
+		bootStatus string
+		err        error
+	}{
+		{state: "bad", identifier: "other", slot: "rootfs.1", bootStatus: "bad"},
+		{state: "bad", identifier: "booted", slot: "rootfs.0", bootStatus: "bad"},
+		{state: "good", identifier: "rootfs.1", slot: "rootfs.1", bootStatus: "good"},
+		{state: "active", identifier: "other", slot: "rootfs.1", bootStatus: "good"},
+		{state: "bad", identifier: "appfs.0", err: rauc.ErrSlotNotFound},
+		{state: "broken", identifier: "other", err: rauc.ErrInvalidMarkState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state+" "+tt.identifier, func(t *testing.T) {
+			m := MockNew(Options{})
+
+			slot, _, err := m.Mark(tt.state, tt.identifier)
+			if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if slot != tt.slot {
+				t.Errorf("marked %q, want %q", slot, tt.slot)
+			}
+
+			rauctest.AssertBootStatus(t, m, tt.slot, tt.bootStatus)
+
+			if tt.state == "active" {
+				if primary, _ := m.GetPrimary(); primary != tt.slot {
+					t.Errorf("got primary %q, want %q", primary, tt.slot)
+				}
+			}
+		})
+	}
+}
+
+func TestMockErrors(t *testing.T) {
+	failure := errors.New("failure")
+	m := MockNew(Options{Errors: map[string]error{"GetSlotStatus": failure}})
+
+	if _, err := m.GetSlotStatus(); err != failure {
+		t.Errorf("GetSlotStatus() = %v, want %v", err, failure)
+	}
+
+	if _, err := m.GetCompatible(); err != nil {
+		t.Errorf("GetCompatible() = %v, want no error", err)
+	}
+
+	if calls := m.Calls(); len(calls) != 2 || calls[0].Method != "GetSlotStatus" {
+		t.Errorf("got calls %+v", calls)
+	}
+}
+
+func TestMockSignals(t *testing.T) {
+	m := MockNew(Options{Installs: []Install{{
+		Progress:  []rauctest.Progress{{Percentage: 0, Message: "Installing"}, {Percentage: 50, Message: "Copying"}},
+		LastError: "Failed to copy image",
+	}}})
+
+	signals, cancel := m.SubscribeSignals(16)
+	defer cancel()
+
+	if err := m.InstallBundle("/data/update.raucb", rauc.InstallBundleOptions{}); err == nil {
+		t.Fatal("installation did not fail")
+	}
+
+	var progress []int32
+	var result int32 = -1
+
+	for len(signals) > 0 {
+		s := <-signals
+		switch s.Name {
+		case "org.freedesktop.DBus.Properties.PropertiesChanged":
+			changed := s.Body[1].(map[string]dbus.Variant)
+			if v, ok := changed["Progress"]; ok {
+				progress = append(progress, v.Value().([]interface{})[0].(int32))
+			}
+		case "de.pengutronix.rauc.Installer.Completed":
+			result = s.Body[0].(int32)
+		}
+	}
+
+	if len(progress) != 2 || progress[1] != 50 {
+		t.Errorf("got progress %v, want [0 50]", progress)
+	}
+
+	if result != 1 {
+		t.Errorf("got Completed result %d, want 1", result)
+	}
+
+	if dropped := m.DroppedSignals(); dropped != 0 {
+		t.Errorf("dropped %d signals", dropped)
+	}
+}
+
+func TestMockSnapshot(t *testing.T) {
+	service := &rauc.ServiceState{Unit: rauc.ServiceUnit, ActiveState: "active"}
+
+	s, err := MockNew(Options{Service: service}).Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Slots) != len(rauctest.SlotStatusAB()) || s.Compatible != "board" {
+		t.Errorf("got %+v", s)
+	}
+
+	if s.Service == nil || *s.Service != *service {
+		t.Errorf("got service %+v, want %+v", s.Service, service)
+	}
+
+	if _, err := MockNew(Options{}).GetServiceState(); err == nil {
+		t.Error("got service state without Options.Service")
+	}
+}
@@ -0,0 +1,113 @@
+package rauctest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+func slotValue(t testing.TB, b rauc.Backend, slotName, key string) (string, bool) {
+	t.Helper()
+
+	status, err := b.GetSlotStatus()
+	if err != nil {
+		t.Fatalf("GetSlotStatus(): %v", err)
+	}
+
+	for _, s := range status {
+		if s.SlotName != slotName {
+			continue
+		}
+
+		v, ok := s.Status[key]
+		if !ok {
+			return "", false
+		}
+
+		if str, ok := v.Value().(string); ok {
+			return str, true
+		}
+
+		return v.String(), true
+	}
+
+	t.Fatalf("no slot %s", slotName)
+	return "", false
+}
+
+// AssertSlotStatus checks that the status key of a slot has the value want.
+func AssertSlotStatus(t testing.TB, b rauc.Backend, slotName, key, want string) {
+	t.Helper()
+
+	got, ok := slotValue(t, b, slotName, key)
+	if !ok {
+		t.Errorf("slot %s: no %s", slotName, key)
+	} else if got != want {
+		t.Errorf("slot %s: %s is %q, want %q", slotName, key, got, want)
+	}
+}
+
+// AssertInstalledVersion checks the version of the bundle last installed
+// to a slot.
+func AssertInstalledVersion(t testing.TB, b rauc.Backend, slotName, version string) {
+	t.Helper()
+	AssertSlotStatus(t, b, slotName, rauc.SlotKeyBundleVersion, version)
+}
+
+// AssertBootStatus checks whether a slot is marked good or bad.
+func AssertBootStatus(t testing.TB, b rauc.Backend, slotName, bootStatus string) {
+	t.Helper()
+	AssertSlotStatus(t, b, slotName, rauc.SlotKeyBootStatus, bootStatus)
+}
+
+// AssertBootSlot checks the bootname of the booted slot.
+func AssertBootSlot(t testing.TB, b rauc.Backend, want string) {
+	t.Helper()
+
+	got, err := b.GetBootSlot()
+	if err != nil {
+		t.Fatalf("GetBootSlot(): %v", err)
+	}
+
+	if got != want {
+		t.Errorf("booted from %q, want %q", got, want)
+	}
+}
+
+// AssertInstallFails checks that installing a bundle fails with the
+// message want.
+func AssertInstallFails(t testing.TB, b rauc.Backend, bundle, want string) {
+	t.Helper()
+
+	err := b.InstallBundle(bundle, rauc.InstallBundleOptions{})
+	if err == nil {
+		t.Errorf("installing %s succeeded, want %q", bundle, want)
+	} else if err.Error() != want {
+		t.Errorf("installing %s failed with %q, want %q", bundle, err, want)
+	}
+}
+
+// WaitForOperation waits until the operation reported by b is want, and
+// fails the test after timeout.
+func WaitForOperation(t testing.TB, b rauc.Backend, want string, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		got, err := b.GetOperation()
+		if err != nil {
+			t.Fatalf("GetOperation(): %v", err)
+		}
+
+		if got == want {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("operation is %q after %v, want %q", got, timeout, want)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
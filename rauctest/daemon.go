@@ -0,0 +1,450 @@
+package rauctest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const (
+	busName   = "de.pengutronix.rauc"
+	objPath   = dbus.ObjectPath("/")
+	installer = busName + ".Installer"
+)
+
+const daemonIntrospection = `
+<interface name="de.pengutronix.rauc.Installer">
+	<method name="Install">
+		<arg name="source" type="s" direction="in"/>
+	</method>
+	<method name="InstallBundle">
+		<arg name="source" type="s" direction="in"/>
+		<arg name="args" type="a{sv}" direction="in"/>
+	</method>
+	<method name="Info">
+		<arg name="bundle" type="s" direction="in"/>
+		<arg name="compatible" type="s" direction="out"/>
+		<arg name="version" type="s" direction="out"/>
+	</method>
+	<method name="InspectBundle">
+		<arg name="source" type="s" direction="in"/>
+		<arg name="args" type="a{sv}" direction="in"/>
+		<arg name="info" type="a{sv}" direction="out"/>
+	</method>
+	<method name="Mark">
+		<arg name="state" type="s" direction="in"/>
+		<arg name="slot_identifier" type="s" direction="in"/>
+		<arg name="slot_name" type="s" direction="out"/>
+		<arg name="message" type="s" direction="out"/>
+	</method>
+	<method name="GetSlotStatus">
+		<arg name="slot_status_array" type="a(sa{sv})" direction="out"/>
+	</method>
+	<method name="GetArtifactStatus">
+		<arg name="repositories" type="aa{sv}" direction="out"/>
+	</method>
+	<method name="GetPrimary">
+		<arg name="primary" type="s" direction="out"/>
+	</method>
+	<signal name="Completed">
+		<arg name="result" type="i"/>
+	</signal>
+	<property name="Operation" type="s" access="read"/>
+	<property name="LastError" type="s" access="read"/>
+	<property name="Progress" type="(isi)" access="read"/>
+	<property name="Compatible" type="s" access="read"/>
+	<property name="Variant" type="s" access="read"/>
+	<property name="BootSlot" type="s" access="read"/>
+</interface>`
+
+// DaemonOptions contains options for the DaemonNew function
+type DaemonOptions struct {
+	// Simulator configures the simulated system behind the daemon.
+	Simulator rauc.SimulatorOptions
+	// Conn is the connection to export the daemon on. If nil, a private
+	// dbus-daemon is started and stopped again by Close.
+	Conn *dbus.Conn
+	// Artifacts are reported by GetArtifactStatus.
+	Artifacts []rauc.Artifact
+	// ProgressInterval is the time between two updates of the Progress
+	// property during an installation. Defaults to 50 milliseconds.
+	ProgressInterval time.Duration
+}
+
+// Daemon emulates the D-Bus API of the RAUC daemon on top of a
+// rauc.Simulator.
+type Daemon struct {
+	// Simulator holds the simulated system state. It may be used to
+	// inspect or change it directly.
+	Simulator *rauc.Simulator
+
+	options DaemonOptions
+	cmd     *exec.Cmd
+	address string
+	conn    *dbus.Conn
+	props   *prop.Properties
+
+	mutex      sync.Mutex
+	installing bool
+	done       chan struct{}
+}
+
+// DaemonNew starts a fake RAUC daemon
+func DaemonNew(options DaemonOptions) (*Daemon, error) {
+	if options.ProgressInterval == 0 {
+		options.ProgressInterval = 50 * time.Millisecond
+	}
+
+	d := &Daemon{
+		Simulator: rauc.SimulatorNew(options.Simulator),
+		options:   options,
+		conn:      options.Conn,
+		done:      make(chan struct{}),
+	}
+
+	if d.conn == nil {
+		if err := d.startBus(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.export(); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *Daemon) startBus() error {
+	d.cmd = exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+
+	stdout, err := d.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := d.cmd.Start(); err != nil {
+		return fmt.Errorf("rauctest: starting dbus-daemon: %v", err)
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		d.cmd.Process.Kill()
+		d.cmd.Wait()
+		return fmt.Errorf("rauctest: reading bus address: %v", err)
+	}
+
+	d.address = strings.TrimSpace(line)
+
+	if d.conn, err = dbus.Connect(d.address); err != nil {
+		d.cmd.Process.Kill()
+		d.cmd.Wait()
+		return fmt.Errorf("rauctest: %v", err)
+	}
+
+	return nil
+}
+
+func (d *Daemon) export() error {
+	compatible, _ := d.Simulator.GetCompatible()
+	variant, _ := d.Simulator.GetVariant()
+	bootSlot, _ := d.Simulator.GetBootSlot()
+
+	readOnly := func(v interface{}) *prop.Prop {
+		return &prop.Prop{Value: v, Emit: prop.EmitTrue}
+	}
+
+	var err error
+	d.props, err = prop.Export(d.conn, objPath, prop.Map{
+		installer: {
+			"Operation":  readOnly("idle"),
+			"LastError":  readOnly(""),
+			"Progress":   readOnly(rauc.Progress{}),
+			"Compatible": readOnly(compatible),
+			"Variant":    readOnly(variant),
+			"BootSlot":   readOnly(bootSlot),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("rauctest: %v", err)
+	}
+
+	if err := d.conn.Export(&daemonObject{d}, objPath, installer); err != nil {
+		return fmt.Errorf("rauctest: %v", err)
+	}
+
+	node := "<node>" + daemonIntrospection + prop.IntrospectDataString + introspect.IntrospectDataString + "</node>"
+	if err := d.conn.Export(introspect.Introspectable(node), objPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("rauctest: %v", err)
+	}
+
+	reply, err := d.conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("rauctest: %v", err)
+	}
+
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("rauctest: %s is already owned", busName)
+	}
+
+	return nil
+}
+
+// Address returns the address of the private bus, or an empty string if
+// the daemon was exported on a connection passed in DaemonOptions.
+func (d *Daemon) Address() string {
+	return d.address
+}
+
+// Connect opens a new client connection to the private bus.
+func (d *Daemon) Connect() (*dbus.Conn, error) {
+	if d.address == "" {
+		return nil, errors.New("rauctest: daemon does not run on a private bus")
+	}
+
+	return dbus.Connect(d.address)
+}
+
+// InstallerNew returns an Installer that talks to the fake daemon. On
+// the private bus, it reconnects with Connect after losing its
+// connection.
+func (d *Daemon) InstallerNew() (*rauc.Installer, error) {
+	// Simulated bundles are known by name only.
+	options := rauc.InstallerOptions{
+		SkipBundleCheck: true,
+	}
+
+	if d.address != "" {
+		options.Dial = d.Connect
+	} else {
+		options.Conn = d.conn
+	}
+
+	return rauc.InstallerNewWithOptions(options)
+}
+
+// Reboot simulates a reboot into the primary slot.
+func (d *Daemon) Reboot() {
+	d.Simulator.Reboot()
+
+	bootSlot, _ := d.Simulator.GetBootSlot()
+	d.props.SetMust(installer, "BootSlot", bootSlot)
+}
+
+// Close stops the daemon and the private bus, if any.
+func (d *Daemon) Close() error {
+	close(d.done)
+
+	if d.cmd == nil {
+		d.conn.ReleaseName(busName)
+		return nil
+	}
+
+	d.conn.Close()
+	d.cmd.Process.Kill()
+	d.cmd.Wait()
+
+	return nil
+}
+
+func (d *Daemon) install(source string, ignoreIncompatible bool) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.installing {
+		return errors.New("Already processing a different method")
+	}
+	d.installing = true
+
+	d.props.SetMust(installer, "Operation", "installing")
+
+	result := make(chan error, 1)
+	go func() {
+		result <- d.Simulator.InstallBundle(source, rauc.InstallBundleOptions{
+			IgnoreIncompatible: ignoreIncompatible,
+		})
+	}()
+
+	go func() {
+		ticker := time.NewTicker(d.options.ProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case err := <-result:
+				d.finish(err)
+				return
+			case <-ticker.C:
+				d.updateProgress()
+			case <-d.done:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (d *Daemon) updateProgress() {
+	p, _ := rauc.ProgressOf(d.Simulator)
+
+	if d.props.GetMust(installer, "Progress") != p {
+		d.props.SetMust(installer, "Progress", p)
+	}
+}
+
+func (d *Daemon) finish(err error) {
+	d.updateProgress()
+
+	var code int32
+	if err != nil {
+		code = 1
+		d.props.SetMust(installer, "LastError", err.Error())
+	}
+
+	d.props.SetMust(installer, "Operation", "idle")
+
+	d.mutex.Lock()
+	d.installing = false
+	d.mutex.Unlock()
+
+	d.conn.Emit(objPath, installer+".Completed", code)
+}
+
+// daemonObject holds the methods exported on the bus.
+type daemonObject struct {
+	d *Daemon
+}
+
+func (o *daemonObject) Install(source string) *dbus.Error {
+	if err := o.d.install(source, false); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	return nil
+}
+
+func (o *daemonObject) InstallBundle(source string, args map[string]dbus.Variant) *dbus.Error {
+	ignoreIncompatible, _ := args["ignore-compatible"].Value().(bool)
+
+	if err := o.d.install(source, ignoreIncompatible); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	return nil
+}
+
+func (o *daemonObject) Info(bundle string) (string, string, *dbus.Error) {
+	compatible, version, err := o.d.Simulator.Info(bundle)
+	if err != nil {
+		return "", "", dbus.MakeFailedError(err)
+	}
+
+	return compatible, version, nil
+}
+
+func (o *daemonObject) InspectBundle(source string, args map[string]dbus.Variant) (map[string]dbus.Variant, *dbus.Error) {
+	b, err := o.d.Simulator.InspectBundle(source, rauc.InspectBundleOptions{})
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	images := make([]map[string]dbus.Variant, 0, len(b.Images))
+	for _, image := range b.Images {
+		images = append(images, map[string]dbus.Variant{
+			"slot-class": dbus.MakeVariant(image.SlotClass),
+			"filename":   dbus.MakeVariant(image.Filename),
+			"size":       dbus.MakeVariant(uint64(image.Size)),
+		})
+	}
+
+	manifest := map[string]dbus.Variant{
+		"update": dbus.MakeVariant(map[string]dbus.Variant{
+			"compatible": dbus.MakeVariant(b.Compatible),
+			"version":    dbus.MakeVariant(b.Version),
+		}),
+		"bundle": dbus.MakeVariant(map[string]dbus.Variant{
+			"format": dbus.MakeVariant(b.Format),
+		}),
+		"images": dbus.MakeVariant(images),
+	}
+
+	return map[string]dbus.Variant{
+		"manifest": dbus.MakeVariant(manifest),
+	}, nil
+}
+
+func (o *daemonObject) Mark(state string, slotIdentifier string) (string, string, *dbus.Error) {
+	slotName, message, err := o.d.Simulator.Mark(state, slotIdentifier)
+	if err != nil {
+		return "", "", dbus.MakeFailedError(err)
+	}
+
+	return slotName, message, nil
+}
+
+func (o *daemonObject) GetArtifactStatus() ([]map[string]dbus.Variant, *dbus.Error) {
+	var repos []map[string]dbus.Variant
+	index := make(map[string]int)
+
+	for _, a := range o.d.options.Artifacts {
+		i, ok := index[a.Repo]
+		if !ok {
+			i = len(repos)
+			index[a.Repo] = i
+			repos = append(repos, map[string]dbus.Variant{
+				"name":      dbus.MakeVariant(a.Repo),
+				"artifacts": dbus.MakeVariant([]map[string]dbus.Variant{}),
+			})
+		}
+
+		references := a.References
+		if references == nil {
+			references = []string{}
+		}
+
+		artifacts := repos[i]["artifacts"].Value().([]map[string]dbus.Variant)
+		artifacts = append(artifacts, map[string]dbus.Variant{
+			"name": dbus.MakeVariant(a.Name),
+			"instances": dbus.MakeVariant([]map[string]dbus.Variant{{
+				"checksum":   dbus.MakeVariant(a.Checksum),
+				"references": dbus.MakeVariant(references),
+			}}),
+		})
+		repos[i]["artifacts"] = dbus.MakeVariant(artifacts)
+	}
+
+	if repos == nil {
+		repos = []map[string]dbus.Variant{}
+	}
+
+	return repos, nil
+}
+
+func (o *daemonObject) GetPrimary() (string, *dbus.Error) {
+	primary, err := o.d.Simulator.GetPrimary()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+
+	return primary, nil
+}
+
+func (o *daemonObject) GetSlotStatus() ([]rauc.SlotStatus, *dbus.Error) {
+	status, err := o.d.Simulator.GetSlotStatus()
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	return status, nil
+}
@@ -0,0 +1,181 @@
+package rauctest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+func TestDaemon(t *testing.T) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	tests := []struct {
+		name    string
+		options rauc.SimulatorOptions
+		bundle  string
+		err     string
+		version string
+	}{
+		{
+			name:    "install",
+			bundle:  "/data/update-2.0.raucb",
+			version: "2.0",
+		},
+		{
+			name:    "failure",
+			options: rauc.SimulatorOptions{InstallError: "Failed to copy image"},
+			bundle:  "/data/update-2.0.raucb",
+			err:     "Failed to copy image",
+		},
+		{
+			name:    "incompatible",
+			options: rauc.SimulatorOptions{Bundles: map[string]rauc.SimulatedBundle{"/data/other.raucb": {Compatible: "other", Version: "9"}}},
+			bundle:  "/data/other.raucb",
+			err:     `RAUC: Install(): compatible mismatch: expected "simulator", got "other"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.InstallDuration = 50 * time.Millisecond
+			tt.options.Slots = SlotStatusAB()
+
+			d, err := DaemonNew(DaemonOptions{Simulator: tt.options})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			installer, err := d.InstallerNew()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.err != "" {
+				AssertInstallFails(t, installer, tt.bundle, tt.err)
+				return
+			}
+
+			if err := installer.InstallBundle(tt.bundle, rauc.InstallBundleOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			AssertInstalledVersion(t, installer, "rootfs.1", tt.version)
+			WaitForOperation(t, installer, string(rauc.OperationIdle), time.Second)
+		})
+	}
+}
+
+// TestUnseenCompletion covers installations that complete while no one
+// listens for their Completed signal, after Detach or while the connection
+// is lost. The failure repeats the LastError of an earlier installation.
+func TestUnseenCompletion(t *testing.T) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	const bundle = "/data/update-2.0.raucb"
+
+	dir, err := ioutil.TempDir("", "rauctest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rauc.AttachStateFile = filepath.Join(dir, "install.json")
+
+	tests := []struct {
+		name      string
+		reconnect bool
+		err       string
+	}{
+		{name: "detached success"},
+		{name: "detached failure", err: "Failed to copy image"},
+		{name: "disconnected success", reconnect: true},
+		{name: "disconnected failure", reconnect: true, err: "Failed to copy image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := DaemonNew(DaemonOptions{Simulator: rauc.SimulatorOptions{
+				InstallDuration: 50 * time.Millisecond,
+				InstallError:    tt.err,
+				Slots:           SlotStatusAB(),
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			var mutex sync.Mutex
+			var conn *dbus.Conn
+			installer, err := rauc.InstallerNewWithOptions(rauc.InstallerOptions{
+				SkipBundleCheck: true,
+				Dial: func() (*dbus.Conn, error) {
+					c, err := d.Connect()
+
+					mutex.Lock()
+					conn = c
+					mutex.Unlock()
+
+					return c, err
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.err != "" {
+				AssertInstallFails(t, installer, bundle, tt.err)
+			}
+
+			result := make(chan error, 1)
+			go func() {
+				result <- installer.InstallBundle(bundle, rauc.InstallBundleOptions{})
+			}()
+
+			WaitForOperation(t, installer, string(rauc.OperationInstalling), time.Second)
+
+			if tt.reconnect {
+				// Reconnecting takes longer than the installation.
+				mutex.Lock()
+				conn.Close()
+				mutex.Unlock()
+			} else {
+				if err := installer.Detach(); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := <-result; !errors.Is(err, rauc.ErrDetached) {
+					t.Fatalf("got %v, want ErrDetached", err)
+				}
+
+				WaitForOperation(t, installer, string(rauc.OperationIdle), time.Second)
+
+				go func() {
+					_, err := installer.AttachToCurrentOperation(rauc.InstallBundleOptions{})
+					result <- err
+				}()
+			}
+
+			err = <-result
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				AssertInstalledVersion(t, installer, "rootfs.1", "2.0")
+			} else if err == nil || err.Error() != tt.err {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
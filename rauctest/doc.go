@@ -0,0 +1,4 @@
+// Package rauctest provides helpers for testing code that uses the rauc
+// package, without RAUC or hardware: a fake RAUC daemon on a private
+// D-Bus daemon, and canned data for update flows.
+package rauctest
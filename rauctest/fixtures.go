@@ -0,0 +1,146 @@
+package rauctest
+
+import (
+	dbus "github.com/godbus/dbus/v5"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+// Error messages as reported in LastError by the RAUC daemon.
+const (
+	ErrorCompatibleMismatch = "Compatible mismatch: Expected 'board' but bundle manifest has 'other-board'"
+	ErrorSignature          = "signature verification failed: Verify error:certificate has expired"
+	ErrorNoSpace            = "Installation error: Failed updating slot rootfs.1: failed to run write: No space left on device"
+	ErrorBusy               = "Already processing a different method"
+	ErrorNotBundle          = "Failed to check bundle: File size 0 is too small to contain a valid signature"
+)
+
+// Progress is a single value of the Progress property.
+type Progress struct {
+	Percentage   int32
+	Message      string
+	NestingDepth int32
+}
+
+// ProgressSuccess is the sequence of Progress values of a successful
+// installation of a bundle with a single image.
+var ProgressSuccess = []Progress{
+	{0, "Installing", 1},
+	{0, "Determining slot states", 2},
+	{20, "Determining slot states done.", 2},
+	{20, "Checking bundle", 2},
+	{20, "Verifying signature", 3},
+	{40, "Verifying signature done.", 3},
+	{40, "Checking bundle done.", 2},
+	{40, "Checking manifest contents", 2},
+	{60, "Checking manifest contents done.", 2},
+	{60, "Determining target install group", 2},
+	{80, "Determining target install group done.", 2},
+	{80, "Updating slots", 2},
+	{80, "Checking slot rootfs.1", 3},
+	{85, "Checking slot rootfs.1 done.", 3},
+	{85, "Copying image to rootfs.1", 3},
+	{99, "Copying image to rootfs.1 done.", 3},
+	{99, "Updating slots done.", 2},
+	{100, "Installing done.", 1},
+}
+
+// ProgressSignatureFailure is the sequence of Progress values of an
+// installation that fails to verify the bundle signature.
+var ProgressSignatureFailure = []Progress{
+	{0, "Installing", 1},
+	{0, "Determining slot states", 2},
+	{20, "Determining slot states done.", 2},
+	{20, "Checking bundle", 2},
+	{20, "Verifying signature", 3},
+	{40, "Verifying signature failed.", 3},
+	{40, "Checking bundle failed.", 2},
+	{100, "Installing failed.", 1},
+}
+
+func slot(name string, status map[string]interface{}) rauc.SlotStatus {
+	s := rauc.SlotStatus{
+		SlotName: name,
+		Status:   make(map[string]dbus.Variant, len(status)),
+	}
+
+	for k, v := range status {
+		s.Status[k] = dbus.MakeVariant(v)
+	}
+
+	return s
+}
+
+// SlotStatusAB returns the status of a typical A/B system with one rootfs
+// slot per boot slot, booted from A. Each call returns a new copy.
+func SlotStatusAB() []rauc.SlotStatus {
+	return []rauc.SlotStatus{
+		slot("rootfs.0", map[string]interface{}{
+			"class":               "rootfs",
+			"device":              "/dev/mmcblk0p2",
+			"type":                "ext4",
+			"bootname":            "A",
+			"state":               "booted",
+			"mountpoint":          "/",
+			"boot-status":         "good",
+			"sha256":              "b0c1b8a5e32e4a1b3db70d2ec6b42b2b7ba6ed5e9f0a8b4bc4f4a7b45e1d2f3a",
+			"size":                uint64(268435456),
+			"bundle.compatible":   "board",
+			"bundle.version":      "1.0.0",
+			"installed.timestamp": "2024-03-01T10:00:00Z",
+			"installed.count":     uint32(1),
+			"activated.timestamp": "2024-03-01T10:00:05Z",
+			"activated.count":     uint32(1),
+			"status":              "ok",
+		}),
+		slot("rootfs.1", map[string]interface{}{
+			"class":               "rootfs",
+			"device":              "/dev/mmcblk0p3",
+			"type":                "ext4",
+			"bootname":            "B",
+			"state":               "inactive",
+			"boot-status":         "good",
+			"sha256":              "5d41402abc4b2a76b9719d911017c592ae1f5e8c3a2f4c09e1b7d6a0fc1e2b3d",
+			"size":                uint64(268435456),
+			"bundle.compatible":   "board",
+			"bundle.version":      "0.9.0",
+			"installed.timestamp": "2024-01-15T08:30:00Z",
+			"installed.count":     uint32(1),
+			"activated.timestamp": "2024-01-15T08:30:04Z",
+			"activated.count":     uint32(1),
+			"status":              "ok",
+		}),
+	}
+}
+
+// SlotStatusWithChildren returns SlotStatusAB with an appfs slot below
+// each rootfs slot.
+func SlotStatusWithChildren() []rauc.SlotStatus {
+	return append(SlotStatusAB(),
+		slot("appfs.0", map[string]interface{}{
+			"class":  "appfs",
+			"device": "/dev/mmcblk0p5",
+			"type":   "ext4",
+			"parent": "rootfs.0",
+			"state":  "active",
+			"status": "ok",
+		}),
+		slot("appfs.1", map[string]interface{}{
+			"class":  "appfs",
+			"device": "/dev/mmcblk0p6",
+			"type":   "ext4",
+			"parent": "rootfs.1",
+			"state":  "inactive",
+			"status": "ok",
+		}),
+	)
+}
+
+// SlotStatusBadOther returns SlotStatusAB with the other slot marked bad,
+// as after a failed installation or a fallback.
+func SlotStatusBadOther() []rauc.SlotStatus {
+	status := SlotStatusAB()
+	status[1].Status[rauc.SlotKeyBootStatus] = dbus.MakeVariant(rauc.BootStatusBad)
+	status[1].Status[rauc.SlotKeyStatus] = dbus.MakeVariant(rauc.InstallStatusFailed)
+
+	return status
+}
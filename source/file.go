@@ -0,0 +1,84 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	Register("file", func(u *url.URL) (BundleSource, error) {
+		return &FileSource{Path: u.Path}, nil
+	})
+}
+
+// FileSource offers a local bundle file, or the most recently modified
+// *.raucb file in a local directory.
+type FileSource struct {
+	Path string
+}
+
+// Latest implements BundleSource.
+func (s *FileSource) Latest(ctx context.Context) (*Bundle, error) {
+	st, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+
+	if !st.IsDir() {
+		return &Bundle{Location: s.Path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+
+	var latest os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".raucb") {
+			continue
+		}
+
+		if latest == nil || e.ModTime().After(latest.ModTime()) {
+			latest = e
+		}
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("source: no bundle in %s", s.Path)
+	}
+
+	return &Bundle{Location: filepath.Join(s.Path, latest.Name())}, nil
+}
+
+// Resolve implements BundleSource.
+func (s *FileSource) Resolve(ctx context.Context, b *Bundle) (string, error) {
+	return b.Location, nil
+}
+
+// Fetch implements BundleSource.
+func (s *FileSource) Fetch(ctx context.Context, b *Bundle, dest string) error {
+	from, err := os.Open(b.Location)
+	if err != nil {
+		return fmt.Errorf("source: %v", err)
+	}
+	defer from.Close()
+
+	to, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("source: %v", err)
+	}
+
+	if _, err := io.Copy(to, from); err != nil {
+		to.Close()
+		return fmt.Errorf("source: %v", err)
+	}
+
+	return to.Close()
+}
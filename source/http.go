@@ -0,0 +1,53 @@
+package source
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/holoplot/go-rauc/download"
+)
+
+func init() {
+	factory := func(u *url.URL) (BundleSource, error) {
+		return HTTPSourceNew(u.String(), download.Options{})
+	}
+
+	Register("http", factory)
+	Register("https", factory)
+}
+
+// HTTPSource offers a single bundle at an HTTP(S) URL. The daemon streams
+// it directly unless Fetch is used.
+type HTTPSource struct {
+	URL     string
+	manager *download.Manager
+}
+
+// HTTPSourceNew returns a newly allocated HTTPSource object that fetches
+// with a download manager configured by options
+func HTTPSourceNew(url string, options download.Options) (*HTTPSource, error) {
+	manager, err := download.ManagerNew(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPSource{
+		URL:     url,
+		manager: manager,
+	}, nil
+}
+
+// Latest implements BundleSource.
+func (s *HTTPSource) Latest(ctx context.Context) (*Bundle, error) {
+	return &Bundle{Location: s.URL}, nil
+}
+
+// Resolve implements BundleSource.
+func (s *HTTPSource) Resolve(ctx context.Context, b *Bundle) (string, error) {
+	return b.Location, nil
+}
+
+// Fetch implements BundleSource.
+func (s *HTTPSource) Fetch(ctx context.Context, b *Bundle, dest string) error {
+	return s.manager.Download(ctx, b.Location, dest)
+}
@@ -0,0 +1,280 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/holoplot/go-rauc/download"
+)
+
+// init registers the index+http and index+https schemes. The query
+// parameters "compatible" and "variant" are taken as IndexOptions, e.g.
+// "index+https://updates.example.com/index.json?compatible=board".
+func init() {
+	factory := func(u *url.URL) (BundleSource, error) {
+		index := *u
+		index.Scheme = strings.TrimPrefix(u.Scheme, "index+")
+
+		query := index.Query()
+		options := IndexOptions{
+			Compatible: query.Get("compatible"),
+			Variant:    query.Get("variant"),
+		}
+		query.Del("compatible")
+		query.Del("variant")
+		index.RawQuery = query.Encode()
+
+		return IndexSourceNew(index.String(), options)
+	}
+
+	Register("index+http", factory)
+	Register("index+https", factory)
+}
+
+// Index is a repository index, a JSON document listing the bundles on a
+// server:
+//
+//	{
+//	  "bundles": [
+//	    {
+//	      "version": "1.2.0",
+//	      "compatible": "board",
+//	      "url": "board-1.2.0.raucb",
+//	      "size": 268435456,
+//	      "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+//	      "rollout": {"percentage": 10, "not_before": "2024-05-01T00:00:00Z"}
+//	    }
+//	  ]
+//	}
+//
+// URLs are relative to the index. All fields but version and url are
+// optional.
+type Index struct {
+	Bundles []IndexEntry `json:"bundles"`
+}
+
+// IndexEntry is a bundle listed in an Index.
+type IndexEntry struct {
+	Version    string `json:"version"`
+	Compatible string `json:"compatible,omitempty"`
+	// Variants restricts the bundle to devices of the given variants.
+	Variants []string `json:"variants,omitempty"`
+	URL      string   `json:"url"`
+	Size     int64    `json:"size,omitempty"`
+	SHA256   string   `json:"sha256,omitempty"`
+	Rollout  *struct {
+		Percentage float64   `json:"percentage"`
+		NotBefore  time.Time `json:"not_before,omitempty"`
+	} `json:"rollout,omitempty"`
+}
+
+// IndexOptions contains options for the IndexSourceNew function
+type IndexOptions struct {
+	// Compatible and Variant select the bundles for this device, usually
+	// the values reported by the RAUC daemon. Bundles are not filtered by
+	// empty values.
+	Compatible string
+	Variant    string
+	// Download configures how the index and bundles are fetched.
+	Download download.Options
+}
+
+// IndexSource offers the newest suitable bundle listed in a repository
+// index, so that updates can be served from static hosting.
+type IndexSource struct {
+	URL     string
+	options IndexOptions
+	manager *download.Manager
+
+	mutex sync.Mutex
+	// entries maps bundle URLs of the last index read to their entries.
+	entries map[string]IndexEntry
+}
+
+// IndexSourceNew returns a newly allocated IndexSource object for the
+// index at url
+func IndexSourceNew(url string, options IndexOptions) (*IndexSource, error) {
+	manager, err := download.ManagerNew(options.Download)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexSource{
+		URL:     url,
+		options: options,
+		manager: manager,
+	}, nil
+}
+
+// Index downloads and parses the index.
+func (s *IndexSource) Index(ctx context.Context) (*Index, error) {
+	f, err := ioutil.TempFile("", "rauc-index-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := s.manager.Download(ctx, s.URL, f.Name()); err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+
+	index := new(Index)
+	if err := json.Unmarshal(b, index); err != nil {
+		return nil, fmt.Errorf("source: %s: %v", s.URL, err)
+	}
+
+	return index, nil
+}
+
+// suitable reports whether e is meant for this device.
+func (s *IndexSource) suitable(e IndexEntry) bool {
+	if e.Version == "" || e.URL == "" {
+		return false
+	}
+
+	if s.options.Compatible != "" && e.Compatible != "" && e.Compatible != s.options.Compatible {
+		return false
+	}
+
+	if s.options.Variant == "" || len(e.Variants) == 0 {
+		return true
+	}
+
+	for _, v := range e.Variants {
+		if v == s.options.Variant {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Latest implements BundleSource. It returns the suitable bundle with the
+// highest version.
+func (s *IndexSource) Latest(ctx context.Context) (*Bundle, error) {
+	index, err := s.Index(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+
+	entries := make(map[string]IndexEntry)
+	var best *IndexEntry
+	var bestURL string
+
+	for i := range index.Bundles {
+		e := index.Bundles[i]
+		if !s.suitable(e) {
+			continue
+		}
+
+		ref, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+
+		location := base.ResolveReference(ref).String()
+		entries[location] = e
+
+		if best == nil || compareVersions(e.Version, best.Version) > 0 {
+			best, bestURL = &index.Bundles[i], location
+		}
+	}
+
+	s.mutex.Lock()
+	s.entries = entries
+	s.mutex.Unlock()
+
+	if best == nil {
+		return nil, fmt.Errorf("source: no suitable bundle in %s", s.URL)
+	}
+
+	b := &Bundle{
+		Location:   bestURL,
+		Version:    best.Version,
+		Compatible: best.Compatible,
+	}
+
+	if best.Rollout != nil {
+		b.Rollout = &Rollout{
+			Percentage: best.Rollout.Percentage,
+			NotBefore:  best.Rollout.NotBefore,
+		}
+	}
+
+	return b, nil
+}
+
+// Resolve implements BundleSource. Bundles are streamed by the daemon,
+// which verifies their signature.
+func (s *IndexSource) Resolve(ctx context.Context, b *Bundle) (string, error) {
+	return b.Location, nil
+}
+
+// Fetch implements BundleSource. The size and hash of the bundle are
+// checked against the index.
+func (s *IndexSource) Fetch(ctx context.Context, b *Bundle, dest string) error {
+	if err := s.manager.Download(ctx, b.Location, dest); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	e, ok := s.entries[b.Location]
+	s.mutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if err := verifyFile(dest, e.Size, e.SHA256); err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("source: %s: %v", b.Location, err)
+	}
+
+	return nil
+}
+
+// verifyFile checks the size and SHA-256 hash of a file, if given.
+func verifyFile(path string, size int64, sum string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+
+	if size > 0 && n != size {
+		return fmt.Errorf("size %d does not match index (%d)", n, size)
+	}
+
+	if sum != "" && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), sum) {
+		return fmt.Errorf("SHA-256 does not match index")
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package source
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// UpdateService is the DNS-SD service type update servers advertise.
+	UpdateService = "_rauc-update._tcp"
+
+	mdnsAddress        = "224.0.0.251:5353"
+	mdnsDefaultTimeout = 2 * time.Second
+
+	dnsTypeA    = 1
+	dnsTypePTR  = 12
+	dnsTypeTXT  = 16
+	dnsTypeAAAA = 28
+	dnsTypeSRV  = 33
+	dnsClassIN  = 1
+)
+
+var errDNSMalformed = errors.New("source: malformed mDNS message")
+
+func init() {
+	// mdns:///path/to/bundle.raucb discovers an update server and fetches
+	// the given path from it over HTTP.
+	Register("mdns", func(u *url.URL) (BundleSource, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), mdnsDefaultTimeout)
+		defer cancel()
+
+		servers, err := Discover(ctx, UpdateService)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("source: no %s server found", UpdateService)
+		}
+
+		return Open(servers[0].URL(u.Path))
+	})
+}
+
+// Server is an update server found through DNS-SD.
+type Server struct {
+	Instance string
+	Host     string
+	Port     uint16
+	Addrs    []net.IP
+	// TXT holds the key/value pairs of the service's TXT record.
+	// The optional keys "scheme" and "path" are used by URL.
+	TXT map[string]string
+}
+
+// URL returns the URL of a file on the server. If p is empty, the server's
+// "path" TXT key is used. TXT records are not authenticated, so the
+// "scheme" key can only choose between http and https.
+func (s *Server) URL(p string) string {
+	scheme := strings.ToLower(s.TXT["scheme"])
+	if scheme != "https" {
+		scheme = "http"
+	}
+
+	if p == "" {
+		p = s.TXT["path"]
+	}
+
+	host := s.Host
+	if len(s.Addrs) > 0 {
+		host = s.Addrs[0].String()
+	}
+
+	u := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(int(s.Port))),
+		Path:   "/" + strings.TrimPrefix(p, "/"),
+	}
+
+	return u.String()
+}
+
+func appendDNSName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+
+	return append(b, 0)
+}
+
+// readDNSName decodes a possibly compressed name at offset and returns it
+// along with the offset following it.
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	end := -1
+
+	for jumps := 0; jumps < 32; {
+		if offset >= len(msg) {
+			return "", 0, errDNSMalformed
+		}
+
+		l := int(msg[offset])
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.Join(labels, "."), end, nil
+
+		case l&0xc0 == 0xc0:
+			if offset+1 >= len(msg) {
+				return "", 0, errDNSMalformed
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3fff)
+			jumps++
+
+		default:
+			if offset+1+l > len(msg) {
+				return "", 0, errDNSMalformed
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+l]))
+			offset += 1 + l
+		}
+	}
+
+	return "", 0, errDNSMalformed
+}
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	data  []byte
+	// msg and offset locate data in its message, for names in rdata.
+	msg    []byte
+	offset int
+}
+
+// parseDNSRecords returns all resource records of a response.
+func parseDNSRecords(msg []byte) ([]dnsRecord, error) {
+	if len(msg) < 12 {
+		return nil, errDNSMalformed
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) +
+		int(binary.BigEndian.Uint16(msg[8:])) +
+		int(binary.BigEndian.Uint16(msg[10:]))
+
+	offset := 12
+	for i := 0; i < questions; i++ {
+		_, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4
+	}
+
+	var result []dnsRecord
+	for i := 0; i < records; i++ {
+		name, next, err := readDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		if next+10 > len(msg) {
+			return nil, errDNSMalformed
+		}
+
+		rtype := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		offset = next + 10
+
+		if offset+length > len(msg) {
+			return nil, errDNSMalformed
+		}
+
+		result = append(result, dnsRecord{
+			name:   strings.ToLower(name),
+			rtype:  rtype,
+			data:   msg[offset : offset+length],
+			msg:    msg,
+			offset: offset,
+		})
+		offset += length
+	}
+
+	return result, nil
+}
+
+// Discover sends a DNS-SD query for service (e.g. UpdateService) on the
+// local network and collects the answers until the context is done.
+func Discover(ctx context.Context, service string) ([]Server, error) {
+	query := make([]byte, 12)
+	binary.BigEndian.PutUint16(query[4:], 1)
+	query = appendDNSName(query, service+".local")
+	query = append(query, 0, dnsTypePTR, 0, dnsClassIN)
+
+	addr, err := net.ResolveUDPAddr("udp4", mdnsAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// Querying from an ephemeral port requests unicast replies.
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("source: mdns: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteTo(query, addr); err != nil {
+		return nil, fmt.Errorf("source: mdns: %v", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(mdnsDefaultTimeout)
+	}
+	conn.SetReadDeadline(deadline)
+
+	servicePTR := strings.ToLower(service + ".local")
+	instances := make(map[string]*Server)
+	var order []string
+	var records []dnsRecord
+	buf := make([]byte, 9000)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+
+		msg := append([]byte(nil), buf[:n]...)
+		r, err := parseDNSRecords(msg)
+		if err != nil {
+			continue
+		}
+
+		for _, rec := range r {
+			if rec.rtype == dnsTypePTR && rec.name == servicePTR {
+				instance, _, err := readDNSName(rec.msg, rec.offset)
+				if err != nil {
+					continue
+				}
+
+				key := strings.ToLower(instance)
+				if _, ok := instances[key]; !ok {
+					instances[key] = &Server{
+						Instance: instance,
+						TXT:      make(map[string]string),
+					}
+					order = append(order, key)
+				}
+			}
+
+			records = append(records, rec)
+		}
+	}
+
+	hosts := make(map[string][]net.IP)
+	for _, rec := range records {
+		switch rec.rtype {
+		case dnsTypeA, dnsTypeAAAA:
+			hosts[rec.name] = append(hosts[rec.name], net.IP(rec.data))
+		}
+	}
+
+	var servers []Server
+	for _, key := range order {
+		s := instances[key]
+
+		for _, rec := range records {
+			if rec.name != key {
+				continue
+			}
+
+			switch rec.rtype {
+			case dnsTypeSRV:
+				if len(rec.data) < 7 {
+					continue
+				}
+				target, _, err := readDNSName(rec.msg, rec.offset+6)
+				if err != nil {
+					continue
+				}
+				s.Port = binary.BigEndian.Uint16(rec.data[4:])
+				s.Host = target
+
+			case dnsTypeTXT:
+				for d := rec.data; len(d) > 0; {
+					l := int(d[0])
+					if 1+l > len(d) {
+						break
+					}
+					kv := strings.SplitN(string(d[1:1+l]), "=", 2)
+					if len(kv) == 2 {
+						s.TXT[strings.ToLower(kv[0])] = kv[1]
+					} else if kv[0] != "" {
+						s.TXT[strings.ToLower(kv[0])] = ""
+					}
+					d = d[1+l:]
+				}
+			}
+		}
+
+		if s.Host == "" {
+			continue
+		}
+
+		s.Addrs = hosts[strings.ToLower(s.Host)]
+		servers = append(servers, *s)
+	}
+
+	return servers, nil
+}
@@ -0,0 +1,28 @@
+package source
+
+import (
+	"net"
+	"testing"
+)
+
+func TestServerURL(t *testing.T) {
+	tests := []struct {
+		txt  map[string]string
+		p    string
+		want string
+	}{
+		{want: "http://192.0.2.1:8080/"},
+		{txt: map[string]string{"scheme": "https", "path": "/bundles/update.raucb"}, want: "https://192.0.2.1:8080/bundles/update.raucb"},
+		{txt: map[string]string{"scheme": "HTTPS"}, p: "update.raucb", want: "https://192.0.2.1:8080/update.raucb"},
+		{txt: map[string]string{"scheme": "file"}, p: "/etc/shadow", want: "http://192.0.2.1:8080/etc/shadow"},
+		{txt: map[string]string{"scheme": "sftp"}, want: "http://192.0.2.1:8080/"},
+	}
+
+	for _, tt := range tests {
+		s := &Server{Host: "updates.local", Port: 8080, Addrs: []net.IP{net.ParseIP("192.0.2.1")}, TXT: tt.txt}
+
+		if got := s.URL(tt.p); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.txt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package source
+
+import (
+	"context"
+	"sync"
+
+	"github.com/holoplot/go-rauc/download"
+)
+
+// MirrorSource offers a single bundle served by several mirrors. Each
+// request fails over to the next mirror, see download.MirrorOrder.
+type MirrorSource struct {
+	Mirrors []download.Mirror
+	manager *download.Manager
+
+	mutex  sync.Mutex
+	served string
+}
+
+// MirrorSourceNew returns a newly allocated MirrorSource object that
+// fetches with a download manager configured by options
+func MirrorSourceNew(mirrors []download.Mirror, options download.Options) (*MirrorSource, error) {
+	manager, err := download.ManagerNew(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MirrorSource{
+		Mirrors: mirrors,
+		manager: manager,
+	}, nil
+}
+
+// Latest implements BundleSource. The location is the first mirror.
+func (s *MirrorSource) Latest(ctx context.Context) (*Bundle, error) {
+	return &Bundle{Location: s.Mirrors[0].URL}, nil
+}
+
+// Resolve implements BundleSource. It returns the first reachable
+// mirror, for the daemon to stream from.
+func (s *MirrorSource) Resolve(ctx context.Context, b *Bundle) (string, error) {
+	url, err := s.manager.Reachable(ctx, s.Mirrors)
+	if err == nil {
+		s.setServed(url)
+	}
+
+	return url, err
+}
+
+// Fetch implements BundleSource.
+func (s *MirrorSource) Fetch(ctx context.Context, b *Bundle, dest string) error {
+	url, err := s.manager.DownloadMirrors(ctx, s.Mirrors, dest)
+	if err == nil {
+		s.setServed(url)
+	}
+
+	return err
+}
+
+func (s *MirrorSource) setServed(url string) {
+	s.mutex.Lock()
+	s.served = url
+	s.mutex.Unlock()
+}
+
+// Served returns the mirror of the last successful Resolve or Fetch.
+func (s *MirrorSource) Served() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.served
+}
@@ -0,0 +1,445 @@
+package source
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/holoplot/go-rauc/download"
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const (
+	s3DefaultRegion    = "us-east-1"
+	s3FetchExpiry      = time.Hour
+	s3UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3TimeFormat       = "20060102T150405Z"
+)
+
+func init() {
+	// s3://bucket/prefix?endpoint=https://minio.example.com&region=eu-west-1
+	Register("s3", func(u *url.URL) (BundleSource, error) {
+		q := u.Query()
+
+		s := &S3Source{
+			Endpoint:     q.Get("endpoint"),
+			Region:       q.Get("region"),
+			Bucket:       u.Host,
+			Prefix:       strings.TrimPrefix(u.Path, "/"),
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+
+		if _, err := s.endpointURL(); err != nil {
+			return nil, err
+		}
+
+		return s, nil
+	})
+}
+
+// S3Source offers the bundles stored below a prefix of an S3 compatible
+// bucket (AWS S3, MinIO, ...). Requests are signed with AWS Signature
+// Version 4.
+type S3Source struct {
+	// Endpoint is the base URL of the service. If empty, the AWS endpoint of
+	// Region is used with virtual-hosted style addressing; custom endpoints
+	// use path style addressing.
+	Endpoint string
+	// Region defaults to us-east-1.
+	Region string
+	Bucket string
+	Prefix string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// VersionPattern extracts the version from an object key through its
+	// first capture group. If set, the bundle with the highest version wins.
+	VersionPattern *regexp.Regexp
+	// VersionMetadata names the user metadata (x-amz-meta-*) that holds the
+	// bundle version. If set, the bundle with the highest version wins.
+	VersionMetadata string
+	// Presign makes Resolve return a presigned URL valid for the given
+	// duration, so the daemon can stream the bundle. Bundles are fetched
+	// otherwise.
+	Presign time.Duration
+	// Download configures the download manager used by Fetch.
+	Download download.Options
+
+	client   *http.Client
+	endpoint *url.URL
+}
+
+func (s *S3Source) region() string {
+	if s.Region == "" {
+		return s3DefaultRegion
+	}
+
+	return s.Region
+}
+
+// endpointURL parses and validates Endpoint on first use. It returns nil
+// for the AWS endpoint.
+func (s *S3Source) endpointURL() (*url.URL, error) {
+	if s.Endpoint == "" || s.endpoint != nil {
+		return s.endpoint, nil
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("source: s3: invalid endpoint: %v", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("source: s3: invalid endpoint %q, want http(s)://host", s.Endpoint)
+	}
+
+	s.endpoint = u
+
+	return u, nil
+}
+
+// objectURL returns the unsigned URL of a key, or of the bucket if key is
+// empty.
+func (s *S3Source) objectURL(key string) (*url.URL, error) {
+	endpoint, err := s.endpointURL()
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint == nil {
+		return &url.URL{
+			Scheme: "https",
+			Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", s.Bucket, s.region()),
+			Path:   "/" + key,
+		}, nil
+	}
+
+	u := *endpoint
+	u.Path += "/" + s.Bucket + "/" + key
+
+	return &u, nil
+}
+
+// s3Escape encodes a string as required for canonical requests: everything
+// but unreserved characters is percent-encoded.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func s3CanonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+
+		for _, v := range values {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+// signature computes the SigV4 signature of a canonical request.
+func (s *S3Source) signature(t time.Time, canonicalRequest string) string {
+	date := t.Format("20060102")
+	scope := date + "/" + s.region() + "/s3/aws4_request"
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.Format(s3TimeFormat),
+		scope,
+		sha256Hex(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func (s *S3Source) credential(t time.Time) string {
+	return s.AccessKey + "/" + t.Format("20060102") + "/" + s.region() + "/s3/aws4_request"
+}
+
+// presign returns a URL for a GET request of key that is valid for expiry.
+func (s *S3Source) presign(key string, t time.Time, expiry time.Duration) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	t = t.UTC()
+
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.credential(t))
+	q.Set("X-Amz-Date", t.Format(s3TimeFormat))
+	q.Set("X-Amz-Expires", fmt.Sprint(int(expiry.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if s.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	query := s3CanonicalQuery(q)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		s3Escape(u.Path, true),
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	u.RawQuery = query + "&X-Amz-Signature=" + s.signature(t, canonicalRequest)
+
+	return u.String(), nil
+}
+
+// do sends a request without body, signed in the Authorization header.
+func (s *S3Source) do(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	t := time.Now().UTC()
+
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": s3EmptyPayloadHash,
+		"x-amz-date":           t.Format(s3TimeFormat),
+	}
+	if s.SessionToken != "" {
+		headers["x-amz-security-token"] = s.SessionToken
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		s3Escape(u.Path, true),
+		s3CanonicalQuery(u.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3EmptyPayloadHash,
+	}, "\n")
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range headers {
+		if k != "host" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	req.Header.Set("User-Agent", rauc.UserAgent())
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s, SignedHeaders=%s, Signature=%s",
+		s.credential(t), signedHeaders, s.signature(t, canonicalRequest)))
+
+	if s.client == nil {
+		s.client = &http.Client{}
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("source: s3: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("source: s3: %s %s: %s", method, u.Path, resp.Status)
+	}
+
+	return resp, nil
+}
+
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+type s3ListResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// list returns all bundle objects below the prefix.
+func (s *S3Source) list(ctx context.Context) ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+
+	for {
+		u, err := s.objectURL("")
+		if err != nil {
+			return nil, err
+		}
+
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", s.Prefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+
+		resp, err := s.do(ctx, http.MethodGet, u)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("source: s3: cannot decode listing: %v", err)
+		}
+
+		for _, o := range result.Contents {
+			if strings.HasSuffix(o.Key, ".raucb") {
+				objects = append(objects, o)
+			}
+		}
+
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Latest implements BundleSource.
+func (s *S3Source) Latest(ctx context.Context) (*Bundle, error) {
+	objects, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *Bundle
+	var bestModified time.Time
+
+	for _, o := range objects {
+		b := &Bundle{Location: o.Key}
+
+		if s.VersionPattern != nil {
+			m := s.VersionPattern.FindStringSubmatch(o.Key)
+			if len(m) < 2 {
+				continue
+			}
+			b.Version = m[1]
+		} else if s.VersionMetadata != "" {
+			u, err := s.objectURL(o.Key)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := s.do(ctx, http.MethodHead, u)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body.Close()
+
+			if b.Version = resp.Header.Get("X-Amz-Meta-" + s.VersionMetadata); b.Version == "" {
+				continue
+			}
+		}
+
+		switch {
+		case best == nil:
+		case b.Version != "" && compareVersions(b.Version, best.Version) > 0:
+		case b.Version == "" && o.LastModified.After(bestModified):
+		default:
+			continue
+		}
+
+		best = b
+		bestModified = o.LastModified
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("source: s3: no bundle below s3://%s/%s", s.Bucket, s.Prefix)
+	}
+
+	return best, nil
+}
+
+// Resolve implements BundleSource.
+func (s *S3Source) Resolve(ctx context.Context, b *Bundle) (string, error) {
+	if s.Presign == 0 {
+		return "", ErrNotResolvable
+	}
+
+	return s.presign(b.Location, time.Now(), s.Presign)
+}
+
+// Fetch implements BundleSource.
+func (s *S3Source) Fetch(ctx context.Context, b *Bundle, dest string) error {
+	manager, err := download.ManagerNew(s.Download)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.presign(b.Location, time.Now(), s3FetchExpiry)
+	if err != nil {
+		return err
+	}
+
+	return manager.Download(ctx, u, dest)
+}
@@ -0,0 +1,49 @@
+package source
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestOpenS3Endpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		url      string
+		err      bool
+	}{
+		{url: "https://updates.s3.us-east-1.amazonaws.com/bundles/a.raucb"},
+		{endpoint: "https://minio.example.com/", url: "https://minio.example.com/updates/bundles/a.raucb"},
+		{endpoint: "http://10.0.0.1:9000", url: "http://10.0.0.1:9000/updates/bundles/a.raucb"},
+		{endpoint: "://x", err: true},
+		{endpoint: "ftp://minio.example.com", err: true},
+		{endpoint: "minio.example.com", err: true},
+	}
+
+	for _, tt := range tests {
+		raw := "s3://updates/bundles"
+		if tt.endpoint != "" {
+			raw += "?endpoint=" + url.QueryEscape(tt.endpoint)
+		}
+
+		s, err := Open(raw)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: got no error", tt.endpoint)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%q: %v", tt.endpoint, err)
+		}
+
+		u, err := s.(*S3Source).objectURL("bundles/a.raucb")
+		if err != nil {
+			t.Fatalf("%q: %v", tt.endpoint, err)
+		}
+
+		if u.String() != tt.url {
+			t.Errorf("%q: got %s, want %s", tt.endpoint, u, tt.url)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package source abstracts where bundles come from. Sources are selected by
+// the scheme of their URL, and users can register their own transports.
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNotResolvable is returned by BundleSource.Resolve if the daemon cannot
+// install the bundle from its location directly, so it has to be fetched.
+var ErrNotResolvable = errors.New("source: bundle has to be fetched")
+
+// Bundle describes a bundle offered by a source.
+type Bundle struct {
+	// Location identifies the bundle within its source.
+	Location string
+	// Version and Compatible are empty if the source does not know them
+	// without inspecting the bundle.
+	Version    string
+	Compatible string
+	// Rollout restricts the bundle to a part of the fleet. It is nil if the
+	// source has no rollout information, which means all devices.
+	Rollout *Rollout
+}
+
+// Rollout describes a staged rollout of a bundle, as announced by the
+// manifest of a server.
+type Rollout struct {
+	// Percentage of devices that should install the bundle. Zero pauses
+	// the rollout.
+	Percentage float64
+	// NotBefore is the earliest time devices may install the bundle.
+	// The zero value means immediately.
+	NotBefore time.Time
+}
+
+// BundleSource is implemented by all bundle transports.
+type BundleSource interface {
+	// Latest returns the newest bundle the source offers.
+	Latest(ctx context.Context) (*Bundle, error)
+	// Resolve returns a path or URL the RAUC daemon can install the bundle
+	// from directly, or ErrNotResolvable.
+	Resolve(ctx context.Context, b *Bundle) (string, error)
+	// Fetch stores the bundle in the local file dest.
+	Fetch(ctx context.Context, b *Bundle, dest string) error
+}
+
+// Factory creates a BundleSource for a URL.
+type Factory func(u *url.URL) (BundleSource, error)
+
+var (
+	factoriesMutex sync.Mutex
+	factories      = make(map[string]Factory)
+)
+
+// Register makes a factory available for the given URL scheme, replacing
+// any factory previously registered for it.
+func Register(scheme string, f Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	factories[scheme] = f
+}
+
+// Open returns a BundleSource for the given URL. URLs without a scheme are
+// treated as local paths.
+func Open(rawURL string) (BundleSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("source: %v", err)
+	}
+
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = "file"
+	}
+
+	factoriesMutex.Lock()
+	f, ok := factories[scheme]
+	factoriesMutex.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("source: no source registered for scheme %q", scheme)
+	}
+
+	return f(u)
+}
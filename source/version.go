@@ -0,0 +1,78 @@
+package source
+
+import (
+	"strconv"
+	"strings"
+)
+
+// compareVersions compares two version strings component-wise, numerically
+// where both components are numbers and lexically otherwise. As in semantic
+// versioning, a pre-release suffix after "-", as in 1.2.0-rc1, ranks below
+// the release itself. It returns -1, 0 or 1.
+func compareVersions(a, b string) int {
+	ra, pa := splitPrerelease(a)
+	rb, pb := splitPrerelease(b)
+
+	if c := compareComponents(ra, rb); c != 0 {
+		return c
+	}
+
+	switch {
+	case pa == pb:
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	}
+
+	return compareComponents(pa, pb)
+}
+
+// splitPrerelease splits a version at the first "-" that precedes any
+// build metadata ("+...").
+func splitPrerelease(s string) (string, string) {
+	i := strings.IndexAny(s, "-+")
+	if i < 0 || s[i] == '+' {
+		return s, ""
+	}
+
+	return s[:i], s[i+1:]
+}
+
+func compareComponents(a, b string) int {
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool {
+			return r == '.' || r == '-' || r == '+' || r == '_'
+		})
+	}
+
+	pa, pb := split(a), split(b)
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		if i >= len(pa) {
+			return -1
+		}
+		if i >= len(pb) {
+			return 1
+		}
+
+		na, errA := strconv.ParseUint(pa[i], 10, 64)
+		nb, errB := strconv.ParseUint(pb[i], 10, 64)
+
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && pa[i] != pb[i]:
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,32 @@
+package source
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2.0", b: "1.2.0", want: 0},
+		{a: "1.2.0", b: "1.10.0", want: -1},
+		{a: "2.0", b: "1.9.9", want: 1},
+		{a: "1.2", b: "1.2.1", want: -1},
+		{a: "1.2.0-rc1", b: "1.2.0", want: -1},
+		{a: "1.2.0", b: "1.2.0-rc1", want: 1},
+		{a: "1.2.0-rc1", b: "1.2.0-rc2", want: -1},
+		{a: "1.2.0-rc2", b: "1.2.0-rc10", want: 1},
+		{a: "1.2.0-rc.2", b: "1.2.0-rc.10", want: -1},
+		{a: "1.2.0-rc1", b: "1.1.9", want: 1},
+		{a: "1.2.0-alpha", b: "1.2.0-beta", want: -1},
+		{a: "1.2.0+2", b: "1.2.0+1", want: 1},
+		{a: "1.2.0+build-1", b: "1.2.0", want: 1},
+		{a: "1.2.0-rc1+build", b: "1.2.0", want: -1},
+		{a: "v1.2", b: "v1.3", want: -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
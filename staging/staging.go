@@ -0,0 +1,427 @@
+// Package staging manages a directory of downloaded bundles waiting to be
+// installed. Bundles are named by the hash of their manifest, kept until
+// they are installed or go stale, and the directory is kept below a size
+// limit, so old bundles cannot fill up the data partition.
+package staging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/holoplot/go-rauc/rauc"
+)
+
+const (
+	defaultMaxAge = 7 * 24 * time.Hour
+
+	bundleSuffix   = ".raucb"
+	metadataSuffix = ".json"
+	incomingPrefix = "incoming-"
+)
+
+// DefaultDirectory is used by AreaNew if no directory is configured. It
+// has to be on persistent storage, as staged bundles are meant to survive
+// a reboot, and may exceed the RAM a tmpfs can use.
+var DefaultDirectory = "/var/lib/go-rauc/staging"
+
+// ErrTooLarge is returned by Store if a bundle alone exceeds MaxBytes.
+var ErrTooLarge = errors.New("staging: bundle exceeds size limit")
+
+// Options contains options for the AreaNew function
+type Options struct {
+	// Directory holds the staged bundles. It is created if needed.
+	// Defaults to DefaultDirectory.
+	Directory string
+	// Backend inspects stored bundles for the hash of their manifest,
+	// which names them. Bundles are named by the SHA-256 hash of their
+	// content if Backend is nil or cannot inspect bundles.
+	Backend rauc.Backend
+	// MaxBytes caps the size of all staged bundles. The oldest bundles are
+	// removed to stay below it. Zero means no limit.
+	MaxBytes int64
+	// MaxAge is the time after which a bundle that was not installed is
+	// removed. Defaults to seven days.
+	MaxAge time.Duration
+}
+
+// Entry describes a staged bundle.
+type Entry struct {
+	// Path of the bundle file.
+	Path string `json:"-"`
+	// Location is the location of the bundle in its source.
+	Location string `json:"location"`
+	// Version is empty if it was not known when the bundle was stored.
+	Version string `json:"version,omitempty"`
+	// ManifestHash is empty if the bundle could not be inspected.
+	ManifestHash string    `json:"manifest_hash,omitempty"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	Stored       time.Time `json:"stored"`
+}
+
+// Area is a managed staging directory.
+type Area struct {
+	options Options
+	mutex   sync.Mutex
+}
+
+// AreaNew returns a newly allocated Area object and removes stale files
+// from its directory.
+func AreaNew(options Options) (*Area, error) {
+	if options.Directory == "" {
+		options.Directory = DefaultDirectory
+	}
+
+	if options.MaxAge == 0 {
+		options.MaxAge = defaultMaxAge
+	}
+
+	if err := os.MkdirAll(options.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("staging: %v", err)
+	}
+
+	a := &Area{options: options}
+	if err := a.Cleanup(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Directory returns the directory of the area.
+func (a *Area) Directory() string {
+	return a.options.Directory
+}
+
+// entries returns all staged bundles, oldest first. The caller holds the
+// mutex.
+func (a *Area) entries() ([]Entry, error) {
+	matches, err := filepath.Glob(filepath.Join(a.options.Directory, "*"+metadataSuffix))
+	if err != nil {
+		return nil, fmt.Errorf("staging: %v", err)
+	}
+
+	var entries []Entry
+
+	for _, m := range matches {
+		b, err := ioutil.ReadFile(m)
+		if err != nil {
+			continue
+		}
+
+		var e Entry
+		if json.Unmarshal(b, &e) != nil {
+			continue
+		}
+
+		e.Path = strings.TrimSuffix(m, metadataSuffix) + bundleSuffix
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Stored.Before(entries[j].Stored)
+	})
+
+	return entries, nil
+}
+
+// Entries returns all staged bundles, oldest first.
+func (a *Area) Entries() ([]Entry, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.entries()
+}
+
+// Usage returns the size of all staged bundles.
+func (a *Area) Usage() (int64, error) {
+	entries, err := a.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+
+	return size, nil
+}
+
+// Lookup returns the staged bundle for a source location and version.
+// Bundles of unknown version are never found, as the source may offer
+// different content under the same location over time.
+func (a *Area) Lookup(location, version string) (Entry, bool) {
+	if version == "" {
+		return Entry{}, false
+	}
+
+	entries, err := a.Entries()
+	if err != nil {
+		return Entry{}, false
+	}
+
+	// Prefer the newest copy.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Location == location && entries[i].Version == version {
+			if _, err := os.Stat(entries[i].Path); err == nil {
+				return entries[i], true
+			}
+		}
+	}
+
+	return Entry{}, false
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// manifestHash returns the hash of the bundle's manifest, or an empty
+// string if the backend cannot inspect bundles.
+func (a *Area) manifestHash(path string) (string, error) {
+	if a.options.Backend == nil {
+		return "", nil
+	}
+
+	info, err := rauc.InspectBundle(a.options.Backend, path, rauc.InspectBundleOptions{})
+	if errors.Is(err, rauc.ErrInspectNotSupported) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return info.ManifestHash, nil
+}
+
+// Store stages the bundle of a source location. fetch has to write the
+// bundle to dest. Older bundles are removed if the area exceeds MaxBytes
+// afterwards.
+func (a *Area) Store(location, version string, fetch func(dest string) error) (Entry, error) {
+	if err := a.Cleanup(); err != nil {
+		return Entry{}, err
+	}
+
+	f, err := ioutil.TempFile(a.options.Directory, incomingPrefix+"*"+bundleSuffix)
+	if err != nil {
+		return Entry{}, fmt.Errorf("staging: %v", err)
+	}
+	incoming := f.Name()
+	f.Close()
+
+	fail := func(err error) (Entry, error) {
+		os.Remove(incoming)
+		return Entry{}, err
+	}
+
+	if err := fetch(incoming); err != nil {
+		return fail(err)
+	}
+
+	sum, size, err := hashFile(incoming)
+	if err != nil {
+		return fail(fmt.Errorf("staging: %v", err))
+	}
+
+	if a.options.MaxBytes > 0 && size > a.options.MaxBytes {
+		return fail(fmt.Errorf("%w: %d bytes", ErrTooLarge, size))
+	}
+
+	manifestHash, err := a.manifestHash(incoming)
+	if err != nil {
+		return fail(err)
+	}
+
+	name := manifestHash
+	if name == "" {
+		name = sum
+	}
+
+	e := Entry{
+		Path:         filepath.Join(a.options.Directory, name+bundleSuffix),
+		Location:     location,
+		Version:      version,
+		ManifestHash: manifestHash,
+		SHA256:       sum,
+		Size:         size,
+		Stored:       time.Now(),
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := os.Rename(incoming, e.Path); err != nil {
+		return fail(fmt.Errorf("staging: %v", err))
+	}
+
+	if err := writeMetadata(e); err != nil {
+		os.Remove(e.Path)
+		return Entry{}, err
+	}
+
+	return e, a.enforceLimit(e.Path)
+}
+
+func writeMetadata(e Entry) error {
+	metadata, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("staging: %v", err)
+	}
+
+	if err := ioutil.WriteFile(strings.TrimSuffix(e.Path, bundleSuffix)+metadataSuffix, metadata, 0644); err != nil {
+		return fmt.Errorf("staging: %v", err)
+	}
+
+	return nil
+}
+
+// SetVersion records the version of a staged bundle, once it is known.
+func (a *Area) SetVersion(path, version string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	entries, err := a.entries()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Path == path {
+			e.Version = version
+			return writeMetadata(e)
+		}
+	}
+
+	return fmt.Errorf("staging: %s is not staged", path)
+}
+
+// remove deletes a bundle and its metadata. The caller holds the mutex.
+func remove(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("staging: %v", err)
+	}
+
+	err = os.Remove(strings.TrimSuffix(path, bundleSuffix) + metadataSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("staging: %v", err)
+	}
+
+	return nil
+}
+
+// Remove deletes a staged bundle, e.g. after it has been installed.
+func (a *Area) Remove(path string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return remove(path)
+}
+
+// enforceLimit removes the oldest bundles, except keep, until the area is
+// within MaxBytes. The caller holds the mutex.
+func (a *Area) enforceLimit(keep string) error {
+	if a.options.MaxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := a.entries()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+
+	for _, e := range entries {
+		if size <= a.options.MaxBytes {
+			break
+		}
+
+		if e.Path == keep {
+			continue
+		}
+
+		if err := remove(e.Path); err != nil {
+			return err
+		}
+		size -= e.Size
+	}
+
+	return nil
+}
+
+// Cleanup removes bundles older than MaxAge, leftovers of interrupted
+// downloads and files without metadata, and enforces MaxBytes.
+func (a *Area) Cleanup() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	entries, err := a.entries()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, e := range entries {
+		if time.Since(e.Stored) > a.options.MaxAge {
+			if err := remove(e.Path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		known[filepath.Base(e.Path)] = true
+		known[strings.TrimSuffix(filepath.Base(e.Path), bundleSuffix)+metadataSuffix] = true
+	}
+
+	files, err := ioutil.ReadDir(a.options.Directory)
+	if err != nil {
+		return fmt.Errorf("staging: %v", err)
+	}
+
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || known[name] {
+			continue
+		}
+
+		// Downloads of other processes may still be in progress.
+		if strings.HasPrefix(name, incomingPrefix) && time.Since(fi.ModTime()) < time.Hour {
+			continue
+		}
+
+		if strings.HasSuffix(name, bundleSuffix) || strings.HasSuffix(name, metadataSuffix) || strings.HasPrefix(name, incomingPrefix) {
+			if err := os.Remove(filepath.Join(a.options.Directory, name)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("staging: %v", err)
+			}
+		}
+	}
+
+	return a.enforceLimit("")
+}